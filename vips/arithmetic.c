@@ -49,3 +49,145 @@ int find_trim(VipsImage *in, int *left, int *top, int *width, int *height,
 int getpoint(VipsImage *in, double **vector, int n, int x, int y) {
   return vips_getpoint(in, vector, &n, x, y, NULL);
 }
+
+// casts a float image back to the format of the input, rounding to the
+// nearest integer for integer formats
+static int round_cast(VipsImage *base, VipsImage *in, VipsImage **out,
+                      VipsBandFormat format) {
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 1);
+
+  if (vips_band_format_isint(format)) {
+    if (vips_rint(in, &t[0], NULL)) {
+      return 1;
+    }
+    in = t[0];
+  }
+  return vips_cast(in, out, format, NULL);
+}
+
+int blend(VipsImage *in, VipsImage *other, VipsImage **out, double weight) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 3);
+
+  if (vips_linear1(in, &t[0], 1.0 - weight, 0, NULL) ||
+      vips_linear1(other, &t[1], weight, 0, NULL) ||
+      vips_add(t[0], t[1], &t[2], NULL) ||
+      round_cast(base, t[2], out, in->BandFmt)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+// out = in + (other - in) * mask / max, max being the white of the mask
+int blend_mask(VipsImage *in, VipsImage *other, VipsImage *mask,
+               VipsImage **out, double max) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 4);
+
+  if (vips_linear1(mask, &t[0], 1.0 / max, 0, NULL) ||
+      vips_subtract(other, in, &t[1], NULL) ||
+      vips_multiply(t[1], t[0], &t[2], NULL) ||
+      vips_add(in, t[2], &t[3], NULL) ||
+      round_cast(base, t[3], out, in->BandFmt)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+// out = (in - dark) * mean(flat - dark) / (flat - dark)
+int calibrate_flat_field(VipsImage *in, VipsImage *dark, VipsImage *flat,
+                         VipsImage **out) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 8);
+  double *gain, *offset;
+  int bands, i, err;
+
+  if (vips_cast(in, &t[0], VIPS_FORMAT_FLOAT, NULL) ||
+      vips_cast(dark, &t[1], VIPS_FORMAT_FLOAT, NULL) ||
+      vips_cast(flat, &t[2], VIPS_FORMAT_FLOAT, NULL) ||
+      vips_subtract(t[0], t[1], &t[3], NULL) ||
+      vips_subtract(t[2], t[1], &t[4], NULL) ||
+      vips_stats(t[4], &t[5], NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  // the gain of each band is the mean of that band of the flat field, which
+  // vips_stats puts in column 4 of rows 1 to bands
+  bands = t[4]->Bands;
+  gain = g_new(double, bands);
+  offset = g_new0(double, bands);
+  for (i = 0; i < bands; i++) {
+    gain[i] = *VIPS_MATRIX(t[5], 4, i + 1);
+  }
+
+  err = vips_divide(t[3], t[4], &t[6], NULL) ||
+        vips_linear(t[6], &t[7], gain, offset, bands, NULL) ||
+        round_cast(base, t[7], out, in->BandFmt);
+
+  g_free(gain);
+  g_free(offset);
+  g_object_unref(base);
+  return err;
+}
+
+// the pixel-wise mean of images of the same size and bands
+int stack_mean(VipsImage **in, int n, VipsImage **out) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 2);
+
+  if (vips_sum(in, &t[0], n, NULL) ||
+      vips_linear1(t[0], &t[1], 1.0 / n, 0, NULL) ||
+      round_cast(base, t[1], out, in[0]->BandFmt)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+// selects the index-th smallest value of each band of every pixel across
+// the images
+int stack_rank(VipsImage **in, int n, VipsImage **out, int index) {
+  return vips_bandrank(in, out, n, "index", index, NULL);
+}
+
+// 1 where the first band of the mask is nonzero, 0 elsewhere
+int mask_weights(VipsObject *base, VipsImage *mask, VipsImage **out) {
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 2);
+
+  return vips_extract_band(mask, &t[0], 0, NULL) ||
+         vips_notequal_const1(t[0], &t[1], 0, NULL) ||
+         vips_linear1(t[1], out, 1.0 / 255.0, 0, NULL);
+}
+
+// the average of the bands of the pixels where the mask is nonzero
+int average_masked(VipsImage *in, VipsImage *mask, double *out) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 2);
+  double masked, coverage;
+
+  if (mask_weights(VIPS_OBJECT(base), mask, &t[0]) ||
+      vips_multiply(in, t[0], &t[1], NULL) ||
+      vips_avg(t[1], &masked, NULL) ||
+      vips_avg(t[0], &coverage, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+  g_object_unref(base);
+
+  if (coverage == 0) {
+    vips_error("average_masked", "the mask selects no pixels");
+    return 1;
+  }
+
+  *out = masked / coverage;
+  return 0;
+}
@@ -88,6 +88,17 @@ func vipsAverage(in *C.VipsImage) (float64, error) {
 	return float64(out), nil
 }
 
+func vipsAverageMasked(in, mask *C.VipsImage) (float64, error) {
+	incOpCounter("averageMasked")
+	var out C.double
+
+	if err := C.average_masked(in, mask, &out); err != 0 {
+		return 0, handleVipsError()
+	}
+
+	return float64(out), nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-arithmetic.html#vips-find-trim
 func vipsFindTrim(in *C.VipsImage, threshold float64, backgroundColor *Color) (int, int, int, int, error) {
 	incOpCounter("findTrim")
@@ -114,3 +125,68 @@ func vipsGetPoint(in *C.VipsImage, n int, x int, y int) ([]float64, error) {
 	// maximum n is 4
 	return (*[4]float64)(unsafe.Pointer(out))[:n:n], nil
 }
+
+func vipsBlend(in, other *C.VipsImage, weight float64) (*C.VipsImage, error) {
+	incOpCounter("blend")
+	var out *C.VipsImage
+
+	if err := C.blend(in, other, &out, C.double(weight)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsBlendMask(in, other, mask *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("blendMask")
+	var out *C.VipsImage
+
+	// the mask is scaled to 0-1 by the white of its format
+	max := 255.0
+	switch BandFormat(mask.BandFmt) {
+	case BandFormatUshort:
+		max = 65535
+	case BandFormatFloat, BandFormatDouble:
+		max = 1
+	}
+
+	if err := C.blend_mask(in, other, mask, &out, C.double(max)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsCalibrateFlatField(in, dark, flat *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("calibrateFlatField")
+	var out *C.VipsImage
+
+	if err := C.calibrate_flat_field(in, dark, flat, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsStackMean(ins []*C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("stackMean")
+	var out *C.VipsImage
+
+	if err := C.stack_mean(&ins[0], C.int(len(ins)), &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-bandrank
+func vipsStackRank(ins []*C.VipsImage, index int) (*C.VipsImage, error) {
+	incOpCounter("stackRank")
+	var out *C.VipsImage
+
+	if err := C.stack_rank(&ins[0], C.int(len(ins)), &out, C.int(index)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
@@ -13,3 +13,12 @@ int average(VipsImage *in, double *out);
 int find_trim(VipsImage *in, int *left, int *top, int *width, int *height,
               double threshold, double r, double g, double b);
 int getpoint(VipsImage *in, double **vector, int n, int x, int y);
+int blend(VipsImage *in, VipsImage *other, VipsImage **out, double weight);
+int blend_mask(VipsImage *in, VipsImage *other, VipsImage *mask,
+               VipsImage **out, double max);
+int calibrate_flat_field(VipsImage *in, VipsImage *dark, VipsImage *flat,
+                         VipsImage **out);
+int stack_mean(VipsImage **in, int n, VipsImage **out);
+int stack_rank(VipsImage **in, int n, VipsImage **out, int index);
+int mask_weights(VipsObject *base, VipsImage *mask, VipsImage **out);
+int average_masked(VipsImage *in, VipsImage *mask, double *out);
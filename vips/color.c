@@ -11,12 +11,315 @@ int to_colorspace(VipsImage *in, VipsImage **out, VipsInterpretation space) {
 
 // https://libvips.github.io/libvips/API/8.6/libvips-colour.html#vips-icc-transform
 int icc_transform(VipsImage *in, VipsImage **out, const char *output_profile, const char *input_profile, VipsIntent intent,
-	int depth, gboolean embedded) {
+	int depth, gboolean embedded, gboolean black_point_compensation) {
+#if (VIPS_MAJOR_VERSION > 8) || (VIPS_MINOR_VERSION >= 12)
 	return vips_icc_transform(
     	in, out, output_profile,
     	"input_profile", input_profile ? input_profile : "none",
     	"intent", intent,
     	"depth", depth ? depth : 8,
     	"embedded", embedded,
+    	"black_point_compensation", black_point_compensation,
     	NULL);
+#else
+	if (black_point_compensation) {
+		vips_error("icc_transform", "%s", "black point compensation requires libvips 8.12+");
+		return -1;
+	}
+
+	return vips_icc_transform(
+    	in, out, output_profile,
+    	"input_profile", input_profile ? input_profile : "none",
+    	"intent", intent,
+    	"depth", depth ? depth : 8,
+    	"embedded", embedded,
+    	NULL);
+#endif
+}
+
+// map_to_palette maps every pixel of in through lut, a 3-band sRGB table
+// indexed by the 6 most significant bits of each channel. pattern, if not
+// NULL, is a uchar dither pattern biased by 128 which is tiled over the image
+// and added to every channel before the lookup. Alpha is kept as is.
+int map_to_palette(VipsImage *in, VipsImage **out, VipsImage *lut,
+                   VipsImage *pattern) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 15);
+  VipsImage *rgb;
+  VipsImage *alpha = NULL;
+
+  if (vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL) ||
+      vips_cast(t[0], &t[1], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_extract_band(t[1], &t[2], 0, "n", 3, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+  rgb = t[2];
+
+  if (t[1]->Bands > 3) {
+    if (vips_extract_band(t[1], &t[3], 3, "n", 1, NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    alpha = t[3];
+  }
+
+  if (pattern) {
+    int across = VIPS_ROUND_UP(rgb->Xsize, pattern->Xsize) / pattern->Xsize;
+    int down = VIPS_ROUND_UP(rgb->Ysize, pattern->Ysize) / pattern->Ysize;
+
+    if (vips_replicate(pattern, &t[4], across, down, NULL) ||
+        vips_extract_area(t[4], &t[5], 0, 0, rgb->Xsize, rgb->Ysize, NULL) ||
+        vips_linear1(t[5], &t[6], 1.0, -128.0, NULL) ||
+        vips_add(rgb, t[6], &t[7], NULL) ||
+        vips_cast(t[7], &t[8], VIPS_FORMAT_UCHAR, NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    rgb = t[8];
+  }
+
+  t[9] = vips_image_new_matrixv(3, 1, 4096.0, 64.0, 1.0);
+
+  if (vips_rshift_const1(rgb, &t[10], 2, NULL) ||
+      vips_recomb(t[10], &t[11], t[9], NULL) ||
+      vips_cast(t[11], &t[12], VIPS_FORMAT_UINT, NULL) ||
+      vips_maplut(t[12], &t[13], lut, NULL) ||
+      vips_copy(t[13], &t[14], "interpretation", VIPS_INTERPRETATION_sRGB,
+                NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  if (alpha) {
+    if (vips_bandjoin2(t[14], alpha, out, NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+  } else if (vips_copy(t[14], out, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+// reduce_bitdepth turns in into a one band greyscale image using only the
+// 2^bitdepth levels evenly spread over 0-255, so that savers writing fewer
+// bits per pixel keep them unchanged. Without a pattern 1-bit output is
+// thresholded, otherwise pattern holds an ordered dither matrix of values
+// 0-63 which is tiled over the image. Alpha is flattened against white.
+int reduce_bitdepth(VipsImage *in, VipsImage **out, int bitdepth,
+                    double threshold, VipsImage *pattern) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 14);
+  VipsImage *grey;
+  double levels = (1 << bitdepth) - 1;
+
+  if (vips_colourspace(in, &t[0], VIPS_INTERPRETATION_B_W, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+  grey = t[0];
+
+  if (vips_image_hasalpha(grey)) {
+    VipsArrayDouble *white = vips_array_double_newv(1, 255.0);
+    int code = vips_flatten(grey, &t[1], "background", white, NULL);
+
+    vips_area_unref(VIPS_AREA(white));
+    if (code) {
+      g_object_unref(base);
+      return 1;
+    }
+    grey = t[1];
+  }
+
+  if (vips_cast(grey, &t[2], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_extract_band(t[2], &t[3], 0, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  if (!pattern && bitdepth == 1) {
+    if (vips_moreeq_const1(t[3], &t[4], threshold, NULL) ||
+        vips_copy(t[4], out, "interpretation", VIPS_INTERPRETATION_B_W,
+                  NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+
+    g_object_unref(base);
+    return 0;
+  }
+
+  // level = floor(v * levels / 255 + offset), with offset 0.5 to round to the
+  // nearest level or the dither matrix value scaled to 0-1
+  if (vips_linear1(t[3], &t[5], levels / 255.0, 0.0, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  if (pattern) {
+    int across = VIPS_ROUND_UP(t[5]->Xsize, pattern->Xsize) / pattern->Xsize;
+    int down = VIPS_ROUND_UP(t[5]->Ysize, pattern->Ysize) / pattern->Ysize;
+
+    if (vips_replicate(pattern, &t[6], across, down, NULL) ||
+        vips_extract_area(t[6], &t[7], 0, 0, t[5]->Xsize, t[5]->Ysize,
+                          NULL) ||
+        vips_linear1(t[7], &t[8], 1.0 / 64.0, 0.5 / 64.0, NULL) ||
+        vips_add(t[5], t[8], &t[9], NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+  } else if (vips_linear1(t[5], &t[9], 1.0, 0.5, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  if (vips_floor(t[9], &t[10], NULL) ||
+      vips_linear1(t[10], &t[11], 255.0 / levels, 0.0, NULL) ||
+      vips_rint(t[11], &t[12], NULL) ||
+      vips_cast(t[12], &t[13], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_copy(t[13], out, "interpretation", VIPS_INTERPRETATION_B_W, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+// Converts the image to 8-bit BT.601 limited range YCbCr with the chroma
+// planes averaged over 2x2 blocks, the layout of I420 video frames. Alpha is
+// flattened on black.
+int to_yuv420(VipsImage *in, VipsImage **y, VipsImage **u, VipsImage **v) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 14);
+  VipsImage *rgb;
+  double offsets[3] = {16.0, 128.0, 128.0};
+  double ones[3] = {1.0, 1.0, 1.0};
+
+  t[0] = vips_image_new_matrixv(3, 3,
+     0.257,  0.504,  0.098,
+    -0.148, -0.291,  0.439,
+     0.439, -0.368, -0.071);
+
+  if (vips_colourspace(in, &t[1], VIPS_INTERPRETATION_sRGB, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+  rgb = t[1];
+
+  if (vips_image_hasalpha(rgb)) {
+    if (vips_flatten(rgb, &t[2], NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    rgb = t[2];
+  }
+
+  if (vips_extract_band(rgb, &t[3], 0, "n", 3, NULL) ||
+      vips_recomb(t[3], &t[4], t[0], NULL) ||
+      vips_linear(t[4], &t[5], ones, offsets, 3, NULL) ||
+      // luma at full resolution, rounded
+      vips_extract_band(t[5], &t[6], 0, NULL) ||
+      vips_linear1(t[6], &t[7], 1.0, 0.5, NULL) ||
+      vips_cast(t[7], y, VIPS_FORMAT_UCHAR, NULL) ||
+      // chroma averaged over 2x2 blocks, repeating the last row and column
+      // of odd sizes
+      vips_extract_band(t[5], &t[8], 1, "n", 2, NULL) ||
+      vips_embed(t[8], &t[9], 0, 0, VIPS_ROUND_UP(t[8]->Xsize, 2),
+                 VIPS_ROUND_UP(t[8]->Ysize, 2), "extend", VIPS_EXTEND_COPY,
+                 NULL) ||
+      vips_shrink(t[9], &t[10], 2.0, 2.0, NULL) ||
+      vips_linear1(t[10], &t[11], 1.0, 0.5, NULL) ||
+      vips_cast(t[11], &t[12], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_extract_band(t[12], u, 0, NULL) ||
+      vips_extract_band(t[12], v, 1, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+// the color difference in CIE76 delta E above which a color is considered
+// out of the gamut of the printer, larger than the error of the round trip
+// through the profile of in-gamut colors
+#define SOFT_PROOF_GAMUT_DE 5.0
+
+// Simulates on screen how the image would print: the colors go through the
+// printer profile and back to the display profile. With gamut_warning, the
+// colors that the printer can't reproduce with relative colorimetric intent
+// are painted grey. Alpha is kept as is.
+int soft_proof(VipsImage *in, VipsImage **out, const char *input_profile,
+               const char *printer_profile, const char *display_profile,
+               VipsIntent intent, gboolean gamut_warning) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 14);
+  VipsImage *color = in;
+  VipsImage *proof;
+  int bands = in->Bands;
+
+  if (vips_image_hasalpha(in)) {
+    bands -= 1;
+    if (vips_extract_band(in, &t[0], 0, "n", bands, NULL) ||
+        vips_extract_band(in, &t[1], bands, NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    color = t[0];
+  }
+
+  if (vips_icc_import(color, &t[2], "input_profile", input_profile,
+                      "embedded", TRUE, "intent", intent, NULL) ||
+      vips_icc_export(t[2], &t[3], "output_profile", printer_profile,
+                      "intent", intent, "depth", 16, NULL) ||
+      vips_icc_import(t[3], &t[4], "input_profile", printer_profile,
+                      "intent", intent, NULL) ||
+      vips_icc_export(t[4], &t[5], "output_profile", display_profile,
+                      "intent", intent, "depth", 8, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+  proof = t[5];
+
+  if (gamut_warning) {
+    double grey[3] = {128.0, 128.0, 128.0};
+    double zero[3] = {0.0, 0.0, 0.0};
+
+    if (vips_icc_export(t[2], &t[6], "output_profile", printer_profile,
+                        "intent", VIPS_INTENT_RELATIVE, "depth", 16, NULL) ||
+        vips_icc_import(t[6], &t[7], "input_profile", printer_profile,
+                        "intent", VIPS_INTENT_RELATIVE, NULL) ||
+        vips_dE76(t[2], t[7], &t[8], NULL) ||
+        vips_moreeq_const1(t[8], &t[9], SOFT_PROOF_GAMUT_DE, NULL) ||
+        vips_linear(proof, &t[10], zero, grey, 3, "uchar", TRUE, NULL) ||
+        vips_ifthenelse(t[9], t[10], proof, &t[11], NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    proof = t[11];
+  }
+
+  if (color != in) {
+    if (vips_cast(t[1], &t[12], VIPS_FORMAT_UCHAR, "shift",
+                  in->BandFmt == VIPS_FORMAT_USHORT, NULL) ||
+        vips_bandjoin2(proof, t[12], &t[13], NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    proof = t[13];
+  }
+
+  if (vips_copy(proof, out, "interpretation", VIPS_INTERPRETATION_sRGB,
+                NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
 }
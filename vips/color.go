@@ -2,6 +2,15 @@ package vips
 
 // #include "color.h"
 import "C"
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
 
 // Color represents an RGB
 type Color struct {
@@ -13,6 +22,94 @@ type ColorRGBA struct {
 	R, G, B, A uint8
 }
 
+// RGBA implements color.Color
+func (c Color) RGBA() (r, g, b, a uint32) {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 255}.RGBA()
+}
+
+// RGBA implements color.Color, the channels of ColorRGBA not being premultiplied by alpha
+func (c ColorRGBA) RGBA() (r, g, b, a uint32) {
+	return color.NRGBA{R: c.R, G: c.G, B: c.B, A: c.A}.RGBA()
+}
+
+// Hex returns the color as #rrggbb
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Hex returns the color as #rrggbbaa
+func (c ColorRGBA) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// ColorFromColor converts any color.Color to a Color, dropping its alpha channel
+func ColorFromColor(c color.Color) Color {
+	rgba := ColorRGBAFromColor(c)
+	return Color{R: rgba.R, G: rgba.G, B: rgba.B}
+}
+
+// ColorRGBAFromColor converts any color.Color to a ColorRGBA
+func ColorRGBAFromColor(c color.Color) ColorRGBA {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return ColorRGBA{R: n.R, G: n.G, B: n.B, A: n.A}
+}
+
+// ParseHexColor parses a hex color as #rgb, #rgba, #rrggbb or #rrggbbaa, the leading # being optional.
+// Colors without alpha are opaque.
+func ParseHexColor(s string) (ColorRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+
+	switch len(hex) {
+	case 3, 4:
+		// expand the shorthand, e.g. #f80 to #ff8800
+		var b strings.Builder
+		for _, r := range hex {
+			b.WriteRune(r)
+			b.WriteRune(r)
+		}
+		hex = b.String()
+	case 6, 8:
+	default:
+		return ColorRGBA{}, fmt.Errorf("invalid hex color %q", s)
+	}
+
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return ColorRGBA{}, fmt.Errorf("invalid hex color %q", s)
+	}
+
+	return ColorRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// NamedColor looks up a CSS/SVG color name such as "steelblue", case insensitively.
+// "transparent" is transparent black.
+func NamedColor(name string) (ColorRGBA, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "transparent" {
+		return ColorRGBA{}, true
+	}
+
+	c, ok := colornames.Map[name]
+	if !ok {
+		return ColorRGBA{}, false
+	}
+
+	return ColorRGBAFromColor(c), true
+}
+
+// ParseColor parses a hex color (see ParseHexColor) or a color name (see NamedColor)
+func ParseColor(s string) (ColorRGBA, error) {
+	if c, ok := NamedColor(s); ok {
+		return c, nil
+	}
+
+	return ParseHexColor(s)
+}
+
 // Interpretation represents VIPS_INTERPRETATION type
 type Interpretation int
 
@@ -71,7 +168,7 @@ func vipsToColorSpace(in *C.VipsImage, interpretation Interpretation) (*C.VipsIm
 }
 
 func vipsICCTransform(in *C.VipsImage, outputProfile string, inputProfile string, intent Intent, depth int,
-	embedded bool) (*C.VipsImage, error) {
+	embedded bool, blackPointCompensation bool) (*C.VipsImage, error) {
 	var out *C.VipsImage
 	var cInputProfile *C.char
 	var cEmbedded C.gboolean
@@ -88,7 +185,172 @@ func vipsICCTransform(in *C.VipsImage, outputProfile string, inputProfile string
 		cEmbedded = C.TRUE
 	}
 
-	if res := C.icc_transform(in, &out, cOutputProfile, cInputProfile, C.VipsIntent(intent), C.int(depth), cEmbedded); res != 0 {
+	if res := C.icc_transform(in, &out, cOutputProfile, cInputProfile, C.VipsIntent(intent), C.int(depth), cEmbedded,
+		toGboolean(blackPointCompensation)); res != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// bayerMatrix is the 8x8 ordered dither threshold map used by vipsMapToPalette
+var bayerMatrix = [64]int{
+	0, 32, 8, 40, 2, 34, 10, 42,
+	48, 16, 56, 24, 50, 18, 58, 26,
+	12, 44, 4, 36, 14, 46, 6, 38,
+	60, 28, 52, 20, 62, 30, 54, 22,
+	3, 35, 11, 43, 1, 33, 9, 41,
+	51, 19, 59, 27, 49, 17, 57, 25,
+	15, 47, 7, 39, 13, 45, 5, 37,
+	63, 31, 55, 23, 61, 29, 53, 21,
+}
+
+// paletteLUTSize is the number of entries of the palette lookup table, 6 bits per channel
+const paletteLUTSize = 1 << 18
+
+func vipsMapToPalette(in *C.VipsImage, palette []Color, dither float64) (*C.VipsImage, error) {
+	incOpCounter("mapToPalette")
+	var out *C.VipsImage
+
+	if len(palette) == 0 {
+		return nil, fmt.Errorf("palette must contain at least one color")
+	}
+
+	lut, err := vipsImageFromMemory(paletteLUT(palette), paletteLUTSize, 1, 3, InterpretationSRGB)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(lut)
+
+	var pattern *C.VipsImage
+	if spread := dither * paletteSpread(palette); spread > 0 {
+		buf := make([]byte, len(bayerMatrix))
+		for i, b := range bayerMatrix {
+			offset := (float64(b)+0.5)/float64(len(bayerMatrix)) - 0.5
+			buf[i] = uint8(math.Max(0, math.Min(255, math.Round(128+offset*spread))))
+		}
+
+		if pattern, err = vipsImageFromMemory(buf, 8, 8, 1, InterpretationBW); err != nil {
+			return nil, err
+		}
+		defer clearImage(pattern)
+	}
+
+	if err := C.map_to_palette(in, &out, lut, pattern); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// paletteLUT maps every 6 bit per channel RGB value to its nearest palette color
+func paletteLUT(palette []Color) []byte {
+	lut := make([]byte, paletteLUTSize*3)
+	for i := 0; i < paletteLUTSize; i++ {
+		r, g, b := (i>>12)*4+2, (i>>6&63)*4+2, (i&63)*4+2
+
+		nearest, best := palette[0], math.MaxInt32
+		for _, c := range palette {
+			dr, dg, db := r-int(c.R), g-int(c.G), b-int(c.B)
+			if d := dr*dr + dg*dg + db*db; d < best {
+				nearest, best = c, d
+			}
+		}
+
+		lut[i*3], lut[i*3+1], lut[i*3+2] = nearest.R, nearest.G, nearest.B
+	}
+
+	return lut
+}
+
+// paletteSpread is the per channel dither amplitude that lets ordered dithering reach
+// the neighbouring palette colors: the mean distance of each color to its nearest other one
+func paletteSpread(palette []Color) float64 {
+	if len(palette) < 2 {
+		return 0
+	}
+
+	total := 0.0
+	for i, a := range palette {
+		nearest := math.MaxFloat64
+		for j, b := range palette {
+			if i == j {
+				continue
+			}
+			dr, dg, db := float64(a.R)-float64(b.R), float64(a.G)-float64(b.G), float64(a.B)-float64(b.B)
+			if d := math.Sqrt(dr*dr + dg*dg + db*db); d > 0 && d < nearest {
+				nearest = d
+			}
+		}
+		if nearest < math.MaxFloat64 {
+			total += nearest
+		}
+	}
+
+	return total / float64(len(palette)) / math.Sqrt(3)
+}
+
+func vipsReduceBitdepth(in *C.VipsImage, bitdepth int, reduction BitdepthReduction, threshold float64) (*C.VipsImage, error) {
+	incOpCounter("reduceBitdepth")
+	var out *C.VipsImage
+
+	if bitdepth != 1 && bitdepth != 2 && bitdepth != 4 {
+		return nil, fmt.Errorf("bitdepth must be 1, 2 or 4, got %d", bitdepth)
+	}
+
+	if threshold == 0 {
+		threshold = 128
+	}
+
+	var pattern *C.VipsImage
+	if reduction == BitdepthReductionDither {
+		buf := make([]byte, len(bayerMatrix))
+		for i, b := range bayerMatrix {
+			buf[i] = uint8(b)
+		}
+
+		var err error
+		if pattern, err = vipsImageFromMemory(buf, 8, 8, 1, InterpretationBW); err != nil {
+			return nil, err
+		}
+		defer clearImage(pattern)
+	}
+
+	if err := C.reduce_bitdepth(in, &out, C.int(bitdepth), C.double(threshold), pattern); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsToYUV420(in *C.VipsImage) (*C.VipsImage, *C.VipsImage, *C.VipsImage, error) {
+	incOpCounter("toYUV420")
+	var y, u, v *C.VipsImage
+
+	if err := C.to_yuv420(in, &y, &u, &v); err != 0 {
+		clearImage(y)
+		clearImage(u)
+		clearImage(v)
+		return nil, nil, nil, handleVipsError()
+	}
+
+	return y, u, v, nil
+}
+
+func vipsSoftProof(in *C.VipsImage, inputProfile, printerProfile, displayProfile string, intent Intent,
+	gamutWarning bool) (*C.VipsImage, error) {
+	incOpCounter("softProof")
+	var out *C.VipsImage
+
+	cInputProfile := C.CString(inputProfile)
+	defer freeCString(cInputProfile)
+	cPrinterProfile := C.CString(printerProfile)
+	defer freeCString(cPrinterProfile)
+	cDisplayProfile := C.CString(displayProfile)
+	defer freeCString(cDisplayProfile)
+
+	if err := C.soft_proof(in, &out, cInputProfile, cPrinterProfile, cDisplayProfile, C.VipsIntent(intent),
+		toGboolean(gamutWarning)); err != 0 {
 		return nil, handleImageError(out)
 	}
 
@@ -7,4 +7,16 @@ int is_colorspace_supported(VipsImage *in);
 int to_colorspace(VipsImage *in, VipsImage **out, VipsInterpretation space);
 
 int icc_transform(VipsImage *in, VipsImage **out, const char *output_profile, const char *input_profile, VipsIntent intent,
-	int depth, gboolean embedded);
+	int depth, gboolean embedded, gboolean black_point_compensation);
+
+int map_to_palette(VipsImage *in, VipsImage **out, VipsImage *lut,
+                   VipsImage *pattern);
+
+int reduce_bitdepth(VipsImage *in, VipsImage **out, int bitdepth,
+                    double threshold, VipsImage *pattern);
+
+int to_yuv420(VipsImage *in, VipsImage **y, VipsImage **u, VipsImage **v);
+
+int soft_proof(VipsImage *in, VipsImage **out, const char *input_profile,
+               const char *printer_profile, const char *display_profile,
+               VipsIntent intent, gboolean gamut_warning);
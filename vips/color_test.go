@@ -0,0 +1,57 @@
+package vips
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHexColor(t *testing.T) {
+	tests := map[string]ColorRGBA{
+		"#ff8000":   {R: 255, G: 128, B: 0, A: 255},
+		"FF800080":  {R: 255, G: 128, B: 0, A: 128},
+		"#f80":      {R: 255, G: 136, B: 0, A: 255},
+		"#f808":     {R: 255, G: 136, B: 0, A: 136},
+		"#00000000": {},
+	}
+	for s, want := range tests {
+		c, err := ParseHexColor(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, c, s)
+	}
+
+	for _, s := range []string{"", "#", "#12345", "#gggggg", "#ff8000ff00"} {
+		_, err := ParseHexColor(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("SteelBlue")
+	require.NoError(t, err)
+	assert.Equal(t, ColorRGBA{R: 70, G: 130, B: 180, A: 255}, c)
+
+	c, err = ParseColor("transparent")
+	require.NoError(t, err)
+	assert.Equal(t, ColorRGBA{}, c)
+
+	c, err = ParseColor("#4682b4")
+	require.NoError(t, err)
+	assert.Equal(t, "#4682b4ff", c.Hex())
+
+	_, err = ParseColor("no such color")
+	assert.Error(t, err)
+}
+
+func TestColor_Conversions(t *testing.T) {
+	var _ color.Color = Color{}
+	var _ color.Color = ColorRGBA{}
+
+	half := ColorRGBA{R: 255, G: 0, B: 0, A: 128}
+	assert.Equal(t, color.NRGBA{R: 255, A: 128}, color.NRGBAModel.Convert(half))
+	assert.Equal(t, half, ColorRGBAFromColor(color.NRGBA{R: 255, A: 128}))
+	assert.Equal(t, Color{R: 1, G: 2, B: 3}, ColorFromColor(color.RGBA{R: 1, G: 2, B: 3, A: 255}))
+	assert.Equal(t, "#010203", Color{R: 1, G: 2, B: 3}.Hex())
+}
@@ -2,26 +2,85 @@ package vips
 
 // #include <vips/vips.h>
 import "C"
+import (
+	"fmt"
+	"image"
+)
 
 // ImageComposite image to composite param
 type ImageComposite struct {
 	Image     *ImageRef
 	BlendMode BlendMode
 	X, Y      int
+	// Opacity scales the alpha of the image from 0 (transparent) to 1 (unchanged), the default
+	Opacity Float64Parameter
+	// Source is the area of the image composited with its top left corner at X, Y, the whole image when empty
+	Source image.Rectangle
 }
 
-func toVipsCompositeStructs(r *ImageRef, datas []*ImageComposite) ([]*C.VipsImage, []C.int, []C.int, []C.int) {
+// overlay returns the image cropped to its source area with its opacity applied, the image itself when neither is
+// set. The caller has to clear the returned image unless it is the image itself.
+func (c *ImageComposite) overlay() (*C.VipsImage, error) {
+	in := c.Image.image
+
+	opacity := 1.0
+	if c.Opacity.IsSet() {
+		opacity = c.Opacity.Get()
+		if opacity < 0 || opacity > 1 {
+			return nil, fmt.Errorf("composite opacity must be between 0 and 1, got %g", opacity)
+		}
+	}
+
+	out := in
+	if !c.Source.Empty() {
+		area, err := cropArea(c.Source, image.Rect(0, 0, c.Image.Width(), c.Image.Height()), CropModeError)
+		if err != nil {
+			return nil, err
+		}
+		if out, err = vipsExtractArea(in, area.Min.X, area.Min.Y, area.Dx(), area.Dy()); err != nil {
+			return nil, err
+		}
+	}
+
+	if opacity < 1 {
+		transparent, err := vipsCompositeOpacity(out, opacity)
+		if out != in {
+			clearImage(out)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = transparent
+	}
+
+	return out, nil
+}
+
+// toVipsCompositeStructs prepares the overlays of a composite, returning the intermediate images to clear afterwards
+func toVipsCompositeStructs(r *ImageRef, datas []*ImageComposite) ([]*C.VipsImage, []C.int, []C.int, []C.int, []*C.VipsImage, error) {
 	ins := []*C.VipsImage{r.image}
 	modes := []C.int{}
 	xs := []C.int{}
 	ys := []C.int{}
+	var intermediates []*C.VipsImage
 
 	for _, image := range datas {
-		ins = append(ins, image.Image.image)
+		overlay, err := image.overlay()
+		if err != nil {
+			for _, in := range intermediates {
+				clearImage(in)
+			}
+			return nil, nil, nil, nil, nil, err
+		}
+		if overlay != image.Image.image {
+			intermediates = append(intermediates, overlay)
+		}
+
+		ins = append(ins, overlay)
 		modes = append(modes, C.int(image.BlendMode))
 		xs = append(xs, C.int(image.X))
 		ys = append(ys, C.int(image.Y))
 	}
 
-	return ins, modes, xs, ys
+	return ins, modes, xs, ys, intermediates, nil
 }
@@ -64,18 +64,9 @@ int embed_multi_page_image(VipsImage *in, VipsImage **out, int left, int top, in
   return 0;
 }
 
-int embed_multi_page_image_background(VipsImage *in, VipsImage **out, int left, int top, int width,
-                                   int height, double r, double g, double b, double a) {
-  double background[3] = {r, g, b};
-  double backgroundRGBA[4] = {r, g, b, a};
-
-  VipsArrayDouble *vipsBackground;
-
-  if (in->Bands <= 3) {
-    vipsBackground = vips_array_double_new(background, 3);
-  } else {
-    vipsBackground = vips_array_double_new(backgroundRGBA, 4);
-  }
+static int embed_multi_page_image_array(VipsImage *in, VipsImage **out, int left,
+                                       int top, int width, int height,
+                                       VipsArrayDouble *vipsBackground) {
   VipsObject *base = VIPS_OBJECT(vips_image_new());
   int page_height = vips_image_get_page_height(in);
   int in_width = in->Xsize;
@@ -91,7 +82,6 @@ int embed_multi_page_image_background(VipsImage *in, VipsImage **out, int left,
       vips_embed(page[i], &page[i], left, top, width, height,
           "extend", VIPS_EXTEND_BACKGROUND, "background", vipsBackground, NULL)
     ) {
-      vips_area_unref(VIPS_AREA(vipsBackground));
       g_object_unref(base);
       return -1;
     }
@@ -102,16 +92,57 @@ int embed_multi_page_image_background(VipsImage *in, VipsImage **out, int left,
     vips_arrayjoin(page, &copy[0], n_pages, "across", 1, NULL) ||
     vips_copy(copy[0], out, NULL)
   ) {
-    vips_area_unref(VIPS_AREA(vipsBackground));
     g_object_unref(base);
     return -1;
   }
   vips_image_set_int(*out, VIPS_META_PAGE_HEIGHT, height);
-  vips_area_unref(VIPS_AREA(vipsBackground));
   g_object_unref(base);
   return 0;
 }
 
+int embed_multi_page_image_background(VipsImage *in, VipsImage **out, int left, int top, int width,
+                                   int height, double r, double g, double b, double a) {
+  double background[3] = {r, g, b};
+  double backgroundRGBA[4] = {r, g, b, a};
+
+  VipsArrayDouble *vipsBackground;
+
+  if (in->Bands <= 3) {
+    vipsBackground = vips_array_double_new(background, 3);
+  } else {
+    vipsBackground = vips_array_double_new(backgroundRGBA, 4);
+  }
+
+  int code = embed_multi_page_image_array(in, out, left, top, width, height,
+                                          vipsBackground);
+
+  vips_area_unref(VIPS_AREA(vipsBackground));
+  return code;
+}
+
+int embed_image_constant(VipsImage *in, VipsImage **out, int left, int top,
+                         int width, int height, double *background, int n) {
+  VipsArrayDouble *vipsBackground = vips_array_double_new(background, n);
+
+  int code = vips_embed(in, out, left, top, width, height,
+    "extend", VIPS_EXTEND_BACKGROUND, "background", vipsBackground, NULL);
+
+  vips_area_unref(VIPS_AREA(vipsBackground));
+  return code;
+}
+
+int embed_multi_page_image_constant(VipsImage *in, VipsImage **out, int left,
+                                    int top, int width, int height,
+                                    double *background, int n) {
+  VipsArrayDouble *vipsBackground = vips_array_double_new(background, n);
+
+  int code = embed_multi_page_image_array(in, out, left, top, width, height,
+                                          vipsBackground);
+
+  vips_area_unref(VIPS_AREA(vipsBackground));
+  return code;
+}
+
 int flip_image(VipsImage *in, VipsImage **out, int direction) {
   return vips_flip(in, out, direction, NULL);
 }
@@ -207,22 +238,193 @@ int similarity(VipsImage *in, VipsImage **out, double scale, double angle,
   return code;
 }
 
+// converts an 8-bit RGBA color to a background matching the bands, color
+// space and depth of the image, optionally premultiplied like the pixels when
+// there is an alpha, and without the alpha for flatten. CMYK colors are
+// converted without a profile and bands past the color and alpha ones are
+// zero.
+static VipsArrayDouble *image_background(VipsImage *in, double r, double g,
+                                         double b, double a,
+                                         gboolean premultiplied,
+                                         gboolean with_alpha) {
+  double scale = is_16bit(in->Type) ? 65535.0 / 255.0 : 1.0;
+  double alpha = premultiplied && vips_image_hasalpha(in) ? a / 255.0 : 1.0;
+  int colors = in->Bands - (vips_image_hasalpha(in) ? 1 : 0);
+  double *background = g_new0(double, in->Bands);
+  VipsArrayDouble *array;
+  int i;
+
+  if (in->Type == VIPS_INTERPRETATION_CMYK && colors >= 4) {
+    double k = 255.0 - VIPS_MAX(r, VIPS_MAX(g, b));
+
+    if (k < 255.0) {
+      background[0] = (255.0 - r - k) * 255.0 / (255.0 - k);
+      background[1] = (255.0 - g - k) * 255.0 / (255.0 - k);
+      background[2] = (255.0 - b - k) * 255.0 / (255.0 - k);
+    }
+    background[3] = k;
+  } else if (colors < 3) {
+    background[0] = 0.2126 * r + 0.7152 * g + 0.0722 * b;
+  } else {
+    background[0] = r;
+    background[1] = g;
+    background[2] = b;
+  }
+  for (i = 0; i < colors; i++) {
+    background[i] *= alpha * scale;
+  }
+  if (vips_image_hasalpha(in)) {
+    background[colors] = a * scale;
+  }
+
+  array = vips_array_double_new(background,
+                                with_alpha ? in->Bands : colors);
+  g_free(background);
+  return array;
+}
+
+static int rotate_page(VipsImage *in, VipsImage **out, double angle,
+                       VipsArrayDouble *background) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 3);
+
+  if (!vips_image_hasalpha(in)) {
+    g_object_unref(base);
+    return vips_rotate(in, out, angle, "background", background, NULL);
+  }
+
+  // rotate premultiplied so that transparent pixels don't bleed their color
+  // into the interpolated edges
+  if (
+    vips_premultiply(in, &t[0], NULL) ||
+    vips_rotate(t[0], &t[1], angle, "background", background, NULL) ||
+    vips_unpremultiply(t[1], &t[2], NULL) ||
+    vips_cast(t[2], out, in->BandFmt, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+int rotate_angle(VipsImage *in, VipsImage **out, double angle, double r,
+                 double g, double b, double a) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  int page_height = vips_image_get_page_height(in);
+  int n_pages = in->Ysize / page_height;
+  VipsArrayDouble *vipsBackground =
+      image_background(in, r, g, b, a, TRUE, TRUE);
+  VipsImage **page = (VipsImage **) vips_object_local_array(base, n_pages);
+  VipsImage **rotated = (VipsImage **) vips_object_local_array(base, n_pages);
+  VipsImage **copy = (VipsImage **) vips_object_local_array(base, 1);
+
+  // rotate every frame on its own, all of them end up with the same size
+  for (int i = 0; i < n_pages; i++) {
+    if (
+      vips_extract_area(in, &page[i], 0, page_height * i, in->Xsize, page_height, NULL) ||
+      rotate_page(page[i], &rotated[i], angle, vipsBackground)
+    ) {
+      vips_area_unref(VIPS_AREA(vipsBackground));
+      g_object_unref(base);
+      return -1;
+    }
+  }
+  vips_area_unref(VIPS_AREA(vipsBackground));
+
+  // reassemble frames and set page height
+  // copy before modifying metadata
+  if(
+    vips_arrayjoin(rotated, &copy[0], n_pages, "across", 1, NULL) ||
+    vips_copy(copy[0], out, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+  if (n_pages > 1) {
+    vips_image_set_int(*out, VIPS_META_PAGE_HEIGHT, rotated[0]->Ysize);
+  }
+  g_object_unref(base);
+  return 0;
+}
+
+int shear_image(VipsImage *in, VipsImage **out, double shear_x,
+                double shear_y, double r, double g, double b, double a) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 3);
+  VipsArrayDouble *vipsBackground =
+      image_background(in, r, g, b, a, TRUE, TRUE);
+  int err;
+
+  // the output area is the bounding box of the sheared image, sheared
+  // premultiplied so that transparent pixels don't bleed their color into
+  // the interpolated edges
+  if (vips_image_hasalpha(in)) {
+    err =
+      vips_premultiply(in, &t[0], NULL) ||
+      vips_affine(t[0], &t[1], 1.0, shear_x, shear_y, 1.0,
+                  "background", vipsBackground,
+                  "extend", VIPS_EXTEND_BACKGROUND, NULL) ||
+      vips_unpremultiply(t[1], &t[2], NULL) ||
+      vips_cast(t[2], out, in->BandFmt, NULL);
+  } else {
+    err = vips_affine(in, out, 1.0, shear_x, shear_y, 1.0,
+                      "background", vipsBackground,
+                      "extend", VIPS_EXTEND_BACKGROUND, NULL);
+  }
+
+  vips_area_unref(VIPS_AREA(vipsBackground));
+  g_object_unref(base);
+  return err ? -1 : 0;
+}
+
 int smartcrop(VipsImage *in, VipsImage **out, int width, int height,
               int interesting) {
   return vips_smartcrop(in, out, width, height, "interesting", interesting,
                         NULL);
 }
 
-int flatten_image(VipsImage *in, VipsImage **out, double r, double g,
-                  double b) {
-  if (is_16bit(in->Type)) {
-    r = 65535 * r / 255;
-    g = 65535 * g / 255;
-    b = 65535 * b / 255;
+// reports the crop rectangle from the offsets vips_extract_area sets on the
+// output, and the focal point libvips picked with the attention strategy, the
+// centre of the crop with the others, which don't set attention_x/y
+int smartcrop_with_details(VipsImage *in, VipsImage **out, int width,
+                           int height, int interesting, int premultiplied,
+                           int *left, int *top, int *attention_x,
+                           int *attention_y) {
+  int err;
+  gboolean attention = interesting == VIPS_INTERESTING_ATTENTION;
+
+#if (VIPS_MAJOR_VERSION > 8) || (VIPS_MINOR_VERSION >= 13)
+  err = vips_smartcrop(in, out, width, height, "interesting", interesting,
+                       "premultiplied", premultiplied, "attention_x",
+                       attention_x, "attention_y", attention_y, NULL);
+#elif (VIPS_MINOR_VERSION >= 12)
+  err = vips_smartcrop(in, out, width, height, "interesting", interesting,
+                       "attention_x", attention_x, "attention_y", attention_y,
+                       NULL);
+#else
+  attention = FALSE;
+  err = vips_smartcrop(in, out, width, height, "interesting", interesting,
+                       NULL);
+#endif
+  if (err) {
+    return err;
   }
 
-  double background[3] = {r, g, b};
-  VipsArrayDouble *vipsBackground = vips_array_double_new(background, 3);
+  *left = -(*out)->Xoffset;
+  *top = -(*out)->Yoffset;
+  if (!attention) {
+    *attention_x = *left + (*out)->Xsize / 2;
+    *attention_y = *top + (*out)->Ysize / 2;
+  }
+  return 0;
+}
+
+int flatten_image(VipsImage *in, VipsImage **out, double r, double g,
+                  double b) {
+  VipsArrayDouble *vipsBackground =
+      image_background(in, r, g, b, 255.0, FALSE, FALSE);
 
   int code = vips_flatten(in, out, "background", vipsBackground, "max_alpha",
                           is_16bit(in->Type) ? 65535.0 : 255.0, NULL);
@@ -240,6 +442,43 @@ int add_alpha(VipsImage *in, VipsImage **out) {
   return vips_addalpha(in, out, NULL);
 }
 
+int mask_alpha(VipsImage *in, VipsImage *mask, VipsImage **out) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 9);
+  VipsImage *with_alpha = in;
+  int bands;
+
+  if (!vips_image_hasalpha(in)) {
+    if (vips_addalpha(in, &t[0], NULL)) {
+      g_object_unref(base);
+      return -1;
+    }
+    with_alpha = t[0];
+  }
+  bands = with_alpha->Bands - 1;
+
+  // alpha * mask / mask max, rounded back to the format of the image
+  if (
+    vips_extract_band(with_alpha, &t[1], 0, "n", bands, NULL) ||
+    vips_extract_band(with_alpha, &t[2], bands, NULL) ||
+    vips_extract_band(mask, &t[3], 0, NULL) ||
+    vips_linear1(t[3], &t[4], 1.0 / max_alpha(mask), 0.0, NULL) ||
+    vips_multiply(t[2], t[4], &t[5], NULL) ||
+    (vips_band_format_isint(with_alpha->BandFmt)
+       ? vips_rint(t[5], &t[6], NULL)
+       : vips_copy(t[5], &t[6], NULL)) ||
+    vips_cast(t[6], &t[7], with_alpha->BandFmt, NULL) ||
+    vips_bandjoin2(t[1], t[7], &t[8], NULL) ||
+    vips_copy(t[8], out, "interpretation", with_alpha->Type, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
 int premultiply_alpha(VipsImage *in, VipsImage **out) {
   return vips_premultiply(in, out, "max_alpha", max_alpha(in), NULL);
 }
@@ -281,6 +520,33 @@ int composite2_image(VipsImage *base, VipsImage *overlay, VipsImage **out,
   return vips_composite2(base, overlay, out, mode, "x", x, "y", y, NULL);
 }
 
+// scales the alpha band by opacity, adding an opaque one to images without
+int composite_opacity(VipsImage *in, VipsImage **out, double opacity) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 5);
+  VipsImage *rgba = in;
+
+  if (!vips_image_hasalpha(in)) {
+    if (vips_addalpha(in, &t[0], NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    rgba = t[0];
+  }
+
+  if (vips_extract_band(rgba, &t[1], 0, "n", rgba->Bands - 1, NULL) ||
+      vips_extract_band(rgba, &t[2], rgba->Bands - 1, "n", 1, NULL) ||
+      vips_linear1(t[2], &t[3], opacity, 0, NULL) ||
+      vips_cast(t[3], &t[4], rgba->BandFmt, NULL) ||
+      vips_bandjoin2(t[1], t[4], out, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
 int insert_image(VipsImage *main, VipsImage *sub, VipsImage **out, int x, int y, int expand, double r, double g, double b, double a) {
   if (is_16bit(main->Type)) {
     r = 65535 * r / 255;
@@ -307,6 +573,18 @@ int insert_image(VipsImage *main, VipsImage *sub, VipsImage **out, int x, int y,
   return code;
 }
 
+int insert_image_constant(VipsImage *main, VipsImage *sub, VipsImage **out,
+                          int x, int y, int expand, double *background,
+                          int n) {
+  VipsArrayDouble *vipsBackground = vips_array_double_new(background, n);
+
+  int code = vips_insert(main, sub, out, x, y, "expand", expand, "background",
+                         vipsBackground, NULL);
+
+  vips_area_unref(VIPS_AREA(vipsBackground));
+  return code;
+}
+
 int join(VipsImage *in1, VipsImage *in2, VipsImage **out, int direction) {
   return vips_join(in1, in2, out, direction, NULL);
 }
@@ -315,10 +593,64 @@ int arrayjoin(VipsImage **in, VipsImage **out, int n, int across) {
   return vips_arrayjoin(in, out, n, "across", across, NULL);
 }
 
+// join_tiles assembles a grid of tile_width x tile_height tiles, those of the
+// last column and row possibly smaller, into a width x height image
+int join_tiles(VipsImage **in, VipsImage **out, int n, int across,
+               int tile_width, int tile_height, int width, int height) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 1);
+
+  if (vips_arrayjoin(in, &t[0], n, "across", across, "hspacing", tile_width,
+                     "vspacing", tile_height, NULL) ||
+      vips_extract_area(t[0], out, 0, 0, width, height, NULL)) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
 int replicate(VipsImage *in, VipsImage **out, int across, int down) {
   return vips_replicate(in, out, across, down, NULL);
 }
 
+int mirror_tile(VipsImage *in, VipsImage **out, int across, int down) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 5);
+
+  // a 2x2 block of the image and its mirrors tiles seamlessly, repeat it and
+  // trim the odd tiles
+  if (
+    vips_flip(in, &t[0], VIPS_DIRECTION_HORIZONTAL, NULL) ||
+    vips_join(in, t[0], &t[1], VIPS_DIRECTION_HORIZONTAL, NULL) ||
+    vips_flip(t[1], &t[2], VIPS_DIRECTION_VERTICAL, NULL) ||
+    vips_join(t[1], t[2], &t[3], VIPS_DIRECTION_VERTICAL, NULL) ||
+    vips_replicate(t[3], &t[4], (across + 1) / 2, (down + 1) / 2, NULL) ||
+    vips_extract_area(t[4], out, 0, 0, across * in->Xsize, down * in->Ysize,
+                      NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+int gravity_image(VipsImage *in, VipsImage **out,
+                  VipsCompassDirection direction, int width, int height,
+                  int extend, double r, double g, double b, double a) {
+  VipsArrayDouble *vipsBackground =
+      image_background(in, r, g, b, a, FALSE, TRUE);
+
+  int code = vips_gravity(in, out, direction, width, height, "extend", extend,
+                          "background", vipsBackground, NULL);
+
+  vips_area_unref(VIPS_AREA(vipsBackground));
+  return code;
+}
+
 int grid(VipsImage *in, VipsImage **out, int tileHeight, int across, int down){
   return vips_grid(in, out, tileHeight, across, down, NULL);
 }
@@ -2,6 +2,7 @@ package vips
 
 // #include "conversion.h"
 import "C"
+import "image"
 
 // BandFormat represents VIPS_FORMAT type
 type BandFormat int
@@ -103,6 +104,22 @@ const (
 	ExtendBackground ExtendStrategy = C.VIPS_EXTEND_BACKGROUND
 )
 
+// Gravity represents VIPS_COMPASS_DIRECTION type, the placement of an image on a canvas
+type Gravity int
+
+// Gravity enum
+const (
+	GravityCentre    Gravity = C.VIPS_COMPASS_DIRECTION_CENTRE
+	GravityNorth     Gravity = C.VIPS_COMPASS_DIRECTION_NORTH
+	GravityEast      Gravity = C.VIPS_COMPASS_DIRECTION_EAST
+	GravitySouth     Gravity = C.VIPS_COMPASS_DIRECTION_SOUTH
+	GravityWest      Gravity = C.VIPS_COMPASS_DIRECTION_WEST
+	GravityNorthEast Gravity = C.VIPS_COMPASS_DIRECTION_NORTH_EAST
+	GravitySouthEast Gravity = C.VIPS_COMPASS_DIRECTION_SOUTH_EAST
+	GravitySouthWest Gravity = C.VIPS_COMPASS_DIRECTION_SOUTH_WEST
+	GravityNorthWest Gravity = C.VIPS_COMPASS_DIRECTION_NORTH_WEST
+)
+
 // Interesting represents VIPS_INTERESTING type
 // https://libvips.github.io/libvips/API/current/libvips-conversion.html#VipsInteresting
 type Interesting int
@@ -180,6 +197,31 @@ func vipsEmbedMultiPageBackground(in *C.VipsImage, left, top, width, height int,
 	return out, nil
 }
 
+// https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-embed
+func vipsEmbedConstant(in *C.VipsImage, left, top, width, height int, background []float64) (*C.VipsImage, error) {
+	incOpCounter("embed")
+	var out *C.VipsImage
+
+	if err := C.embed_image_constant(in, &out, C.int(left), C.int(top), C.int(width), C.int(height),
+		(*C.double)(&background[0]), C.int(len(background))); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsEmbedMultiPageConstant(in *C.VipsImage, left, top, width, height int, background []float64) (*C.VipsImage, error) {
+	incOpCounter("embedMultiPageConstant")
+	var out *C.VipsImage
+
+	if err := C.embed_multi_page_image_constant(in, &out, C.int(left), C.int(top), C.int(width), C.int(height),
+		(*C.double)(&background[0]), C.int(len(background))); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-flip
 func vipsFlip(in *C.VipsImage, direction Direction) (*C.VipsImage, error) {
 	incOpCounter("flip")
@@ -242,6 +284,32 @@ func vipsSimilarity(in *C.VipsImage, scale float64, angle float64, color *ColorR
 	return out, nil
 }
 
+// https://libvips.github.io/libvips/API/current/libvips-resample.html#vips-rotate
+func vipsRotateAngle(in *C.VipsImage, angle float64, color *ColorRGBA) (*C.VipsImage, error) {
+	incOpCounter("rotate")
+	var out *C.VipsImage
+
+	if err := C.rotate_angle(in, &out, C.double(angle),
+		C.double(color.R), C.double(color.G), C.double(color.B), C.double(color.A)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-resample.html#vips-affine
+func vipsShear(in *C.VipsImage, shearX, shearY float64, color *ColorRGBA) (*C.VipsImage, error) {
+	incOpCounter("shear")
+	var out *C.VipsImage
+
+	if err := C.shear_image(in, &out, C.double(shearX), C.double(shearY),
+		C.double(color.R), C.double(color.G), C.double(color.B), C.double(color.A)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // http://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-smartcrop
 func vipsSmartCrop(in *C.VipsImage, width int, height int, interesting Interesting) (*C.VipsImage, error) {
 	incOpCounter("smartcrop")
@@ -254,6 +322,21 @@ func vipsSmartCrop(in *C.VipsImage, width int, height int, interesting Interesti
 	return out, nil
 }
 
+// http://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-smartcrop
+func vipsSmartCropWithDetails(in *C.VipsImage, width int, height int, interesting Interesting, premultiplied bool) (*C.VipsImage, image.Rectangle, image.Point, error) {
+	incOpCounter("smartcrop")
+	var out *C.VipsImage
+	var left, top, attentionX, attentionY C.int
+
+	if err := C.smartcrop_with_details(in, &out, C.int(width), C.int(height), C.int(interesting),
+		C.int(boolToInt(premultiplied)), &left, &top, &attentionX, &attentionY); err != 0 {
+		return nil, image.Rectangle{}, image.Point{}, handleImageError(out)
+	}
+
+	crop := image.Rect(int(left), int(top), int(left)+int(out.Xsize), int(top)+int(out.Ysize))
+	return out, crop, image.Pt(int(attentionX), int(attentionY)), nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-rot
 func vipsRotate(in *C.VipsImage, angle Angle) (*C.VipsImage, error) {
 	incOpCounter("rot")
@@ -338,6 +421,17 @@ func vipsAddAlpha(in *C.VipsImage) (*C.VipsImage, error) {
 	return out, nil
 }
 
+func vipsMaskAlpha(in, mask *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("maskAlpha")
+	var out *C.VipsImage
+
+	if err := C.mask_alpha(in, mask, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-premultiply
 func vipsPremultiplyAlpha(in *C.VipsImage) (*C.VipsImage, error) {
 	incOpCounter("premultiplyAlpha")
@@ -397,6 +491,17 @@ func vipsComposite2(base *C.VipsImage, overlay *C.VipsImage, mode BlendMode, x,
 	return out, nil
 }
 
+func vipsCompositeOpacity(in *C.VipsImage, opacity float64) (*C.VipsImage, error) {
+	incOpCounter("compositeOpacity")
+	var out *C.VipsImage
+
+	if err := C.composite_opacity(in, &out, C.double(opacity)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 func vipsInsert(main *C.VipsImage, sub *C.VipsImage, x, y int, expand bool, background *ColorRGBA) (*C.VipsImage, error) {
 	incOpCounter("insert")
 	var out *C.VipsImage
@@ -417,6 +522,19 @@ func vipsInsert(main *C.VipsImage, sub *C.VipsImage, x, y int, expand bool, back
 	return out, nil
 }
 
+// https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-insert
+func vipsInsertConstant(main *C.VipsImage, sub *C.VipsImage, x, y int, expand bool, background []float64) (*C.VipsImage, error) {
+	incOpCounter("insert")
+	var out *C.VipsImage
+
+	if err := C.insert_image_constant(main, sub, &out, C.int(x), C.int(y), C.int(boolToInt(expand)),
+		(*C.double)(&background[0]), C.int(len(background))); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-join
 func vipsJoin(input1 *C.VipsImage, input2 *C.VipsImage, dir Direction) (*C.VipsImage, error) {
 	incOpCounter("join")
@@ -441,6 +559,18 @@ func vipsArrayJoin(inputs []*C.VipsImage, across int) (*C.VipsImage, error) {
 	return out, nil
 }
 
+// https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-arrayjoin
+func vipsJoinTiles(tiles []*C.VipsImage, across, tileWidth, tileHeight, width, height int) (*C.VipsImage, error) {
+	incOpCounter("arrayjoin")
+	var out *C.VipsImage
+
+	if err := C.join_tiles(&tiles[0], &out, C.int(len(tiles)), C.int(across), C.int(tileWidth), C.int(tileHeight),
+		C.int(width), C.int(height)); err != 0 {
+		return nil, handleImageError(out)
+	}
+	return out, nil
+}
+
 // https://www.libvips.org/API/current/libvips-conversion.html#vips-replicate
 func vipsReplicate(in *C.VipsImage, across int, down int) (*C.VipsImage, error) {
 	incOpCounter("replicate")
@@ -452,6 +582,29 @@ func vipsReplicate(in *C.VipsImage, across int, down int) (*C.VipsImage, error)
 	return out, nil
 }
 
+// https://www.libvips.org/API/current/libvips-conversion.html#vips-gravity
+func vipsGravity(in *C.VipsImage, gravity Gravity, width, height int, extend ExtendStrategy, backgroundColor *ColorRGBA) (*C.VipsImage, error) {
+	incOpCounter("gravity")
+	var out *C.VipsImage
+
+	if err := C.gravity_image(in, &out, C.VipsCompassDirection(gravity), C.int(width), C.int(height), C.int(extend),
+		C.double(backgroundColor.R), C.double(backgroundColor.G), C.double(backgroundColor.B),
+		C.double(backgroundColor.A)); err != 0 {
+		return nil, handleImageError(out)
+	}
+	return out, nil
+}
+
+func vipsMirrorTile(in *C.VipsImage, across int, down int) (*C.VipsImage, error) {
+	incOpCounter("mirrorTile")
+	var out *C.VipsImage
+
+	if err := C.mirror_tile(in, &out, C.int(across), C.int(down)); err != 0 {
+		return nil, handleImageError(out)
+	}
+	return out, nil
+}
+
 // https://www.libvips.org/API/current/libvips-conversion.html#vips-grid
 func vipsGrid(in *C.VipsImage, tileHeight, across, down int) (*C.VipsImage, error) {
 	incOpCounter("grid")
@@ -13,6 +13,10 @@ int embed_multi_page_image(VipsImage *in, VipsImage **out, int left, int top, in
                 int height, int extend);
 int embed_multi_page_image_background(VipsImage *in, VipsImage **out, int left, int top,
                 int width, int height, double r, double g, double b, double a);
+int embed_image_constant(VipsImage *in, VipsImage **out, int left, int top,
+                int width, int height, double *background, int n);
+int embed_multi_page_image_constant(VipsImage *in, VipsImage **out, int left, int top,
+                int width, int height, double *background, int n);
 
 int flip_image(VipsImage *in, VipsImage **out, int direction);
 
@@ -29,14 +33,23 @@ int autorot_image(VipsImage *in, VipsImage **out);
 int zoom_image(VipsImage *in, VipsImage **out, int xfac, int yfac);
 int smartcrop(VipsImage *in, VipsImage **out, int width, int height,
               int interesting);
+int smartcrop_with_details(VipsImage *in, VipsImage **out, int width,
+                           int height, int interesting, int premultiplied,
+                           int *left, int *top, int *attention_x,
+                           int *attention_y);
 
 int bandjoin(VipsImage **in, VipsImage **out, int n);
 int bandjoin_const(VipsImage *in, VipsImage **out, double constants[], int n);
 int similarity(VipsImage *in, VipsImage **out, double scale, double angle,
                double r, double g, double b, double a, double idx, double idy,
                double odx, double ody);
+int rotate_angle(VipsImage *in, VipsImage **out, double angle, double r,
+                 double g, double b, double a);
+int shear_image(VipsImage *in, VipsImage **out, double shear_x,
+                double shear_y, double r, double g, double b, double a);
 int flatten_image(VipsImage *in, VipsImage **out, double r, double g, double b);
 int add_alpha(VipsImage *in, VipsImage **out);
+int mask_alpha(VipsImage *in, VipsImage *mask, VipsImage **out);
 int premultiply_alpha(VipsImage *in, VipsImage **out);
 int unpremultiply_alpha(VipsImage *in, VipsImage **out);
 int cast(VipsImage *in, VipsImage **out, int bandFormat);
@@ -46,15 +59,24 @@ int composite_image(VipsImage **in, VipsImage **out, int n, int *mode, int *x,
                     int *y);
 int composite2_image(VipsImage *base, VipsImage *overlay, VipsImage **out,
                      int mode, gint x, gint y);
+int composite_opacity(VipsImage *in, VipsImage **out, double opacity);
 
 int insert_image(VipsImage *main, VipsImage *sub, VipsImage **out, int x, int y,
                  int expand, double r, double g, double b, double a);
+int insert_image_constant(VipsImage *main, VipsImage *sub, VipsImage **out,
+                          int x, int y, int expand, double *background, int n);
 
 int join(VipsImage *in1, VipsImage *in2, VipsImage **out, int direction);
 int arrayjoin(VipsImage **in, VipsImage **out, int n, int across);
+int join_tiles(VipsImage **in, VipsImage **out, int n, int across,
+               int tile_width, int tile_height, int width, int height);
 
 int is_16bit(VipsInterpretation interpretation);
 
 int replicate(VipsImage *in, VipsImage **out, int across, int down);
+int mirror_tile(VipsImage *in, VipsImage **out, int across, int down);
+int gravity_image(VipsImage *in, VipsImage **out,
+                  VipsCompassDirection direction, int width, int height,
+                  int extend, double r, double g, double b, double a);
 
 int grid(VipsImage *in, VipsImage **out, int tileHeight, int across, int down);
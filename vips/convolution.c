@@ -1,5 +1,7 @@
 #include "convolution.h"
 
+#include <math.h>
+
 int gaussian_blur_image(VipsImage *in, VipsImage **out, double sigma) {
   return vips_gaussblur(in, out, sigma, NULL);
 }
@@ -8,3 +10,189 @@ int sharpen_image(VipsImage *in, VipsImage **out, double sigma, double x1,
                   double m2) {
   return vips_sharpen(in, out, "sigma", sigma, "x1", x1, "m2", m2, NULL);
 }
+
+int sharpen_image_params(VipsImage *in, VipsImage **out, double sigma,
+                         double x1, double y2, double y3, double m1,
+                         double m2) {
+  return vips_sharpen(in, out, "sigma", sigma, "x1", x1, "y2", y2, "y3", y3,
+                      "m1", m1, "m2", m2, NULL);
+}
+
+int conv_kernel(VipsImage *in, VipsImage **out, double *kernel, int width,
+                int height, double scale, double offset,
+                VipsPrecision precision, int layers, int cluster,
+                gboolean separable) {
+  VipsImage *mask = vips_image_new_matrix_from_array(width, height, kernel,
+                                                     width * height);
+  int err;
+
+  if (!mask) {
+    return -1;
+  }
+
+  vips_image_set_double(mask, "scale", scale);
+  vips_image_set_double(mask, "offset", offset);
+
+  if (separable) {
+    err = vips_convsep(in, out, mask, "precision", precision, "layers", layers,
+                       "cluster", cluster, NULL);
+  } else {
+    err = vips_conv(in, out, mask, "precision", precision, "layers", layers,
+                    "cluster", cluster, NULL);
+  }
+
+  g_object_unref(mask);
+  return err;
+}
+
+int sobel_image(VipsImage *in, VipsImage **out) {
+  return vips_sobel(in, out, NULL);
+}
+
+int scharr_image(VipsImage *in, VipsImage **out) {
+#if (VIPS_MAJOR_VERSION > 8) || (VIPS_MINOR_VERSION >= 13)
+  return vips_scharr(in, out, NULL);
+#else
+  vips_error("scharr", "%s", "requires libvips 8.13+");
+  return -1;
+#endif
+}
+
+int canny_image(VipsImage *in, VipsImage **out, double sigma,
+                VipsPrecision precision) {
+  return vips_canny(in, out, "sigma", sigma, "precision", precision, NULL);
+}
+
+int feather_alpha(VipsImage *in, VipsImage **out, double sigma) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+  int bands = in->Bands - 1;
+
+  if (
+    vips_extract_band(in, &t[0], 0, "n", bands, NULL) ||
+    vips_extract_band(in, &t[1], bands, NULL) ||
+    vips_gaussblur(t[1], &t[2], sigma, NULL) ||
+    vips_bandjoin2(t[0], t[2], out, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+int energy_map(VipsImage *in, VipsImage **out) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 10);
+  double max;
+
+  // gradient magnitude approximated as |gx| + |gy| of the Sobel operator
+  // over the luminance of the image, ignoring any alpha band
+  t[0] = vips_image_new_matrixv(3, 3,
+    -1.0, 0.0, 1.0,
+    -2.0, 0.0, 2.0,
+    -1.0, 0.0, 1.0);
+  t[1] = vips_image_new_matrixv(3, 3,
+    -1.0, -2.0, -1.0,
+     0.0,  0.0,  0.0,
+     1.0,  2.0,  1.0);
+
+  if (
+    vips_colourspace(in, &t[2], VIPS_INTERPRETATION_B_W, NULL) ||
+    vips_extract_band(t[2], &t[3], 0, NULL) ||
+    vips_conv(t[3], &t[4], t[0], "precision", VIPS_PRECISION_FLOAT, NULL) ||
+    vips_conv(t[3], &t[5], t[1], "precision", VIPS_PRECISION_FLOAT, NULL) ||
+    vips_abs(t[4], &t[6], NULL) ||
+    vips_abs(t[5], &t[7], NULL) ||
+    vips_add(t[6], t[7], &t[8], NULL) ||
+    vips_max(t[8], &max, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  if (max <= 0) {
+    max = 1;
+  }
+
+  if (vips_linear1(t[8], &t[9], 255.0 / max, 0.0, "uchar", TRUE, NULL)) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  if (vips_copy(t[9], out, "interpretation", VIPS_INTERPRETATION_B_W, NULL)) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+// convolve the luminance of the image, ignoring any alpha band
+static int convolve_luminance(VipsObject *base, VipsImage *in, VipsImage **out,
+                              VipsImage *kernel) {
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 2);
+
+  if (
+    vips_colourspace(in, &t[0], VIPS_INTERPRETATION_B_W, NULL) ||
+    vips_extract_band(t[0], &t[1], 0, NULL) ||
+    vips_conv(t[1], out, kernel, "precision", VIPS_PRECISION_FLOAT, NULL)
+  ) {
+    return -1;
+  }
+
+  return 0;
+}
+
+int blur_score(VipsImage *in, double *out) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+  double deviation;
+
+  t[0] = vips_image_new_matrixv(3, 3,
+    0.0,  1.0, 0.0,
+    1.0, -4.0, 1.0,
+    0.0,  1.0, 0.0);
+
+  if (
+    convolve_luminance(VIPS_OBJECT(base), in, &t[1], t[0]) ||
+    vips_deviate(t[1], &deviation, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  *out = deviation * deviation;
+
+  g_object_unref(base);
+  return 0;
+}
+
+int noise_score(VipsImage *in, double *out) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+  double avg;
+
+  // Immerkær, "Fast Noise Variance Estimation": the difference of two
+  // Laplacians cancels out image structure and leaves mostly noise
+  t[0] = vips_image_new_matrixv(3, 3,
+     1.0, -2.0,  1.0,
+    -2.0,  4.0, -2.0,
+     1.0, -2.0,  1.0);
+
+  if (
+    convolve_luminance(VIPS_OBJECT(base), in, &t[1], t[0]) ||
+    vips_abs(t[1], &t[2], NULL) ||
+    vips_avg(t[2], &avg, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  *out = avg * sqrt(0.5 * M_PI) / 6.0;
+
+  g_object_unref(base);
+  return 0;
+}
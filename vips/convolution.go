@@ -2,6 +2,68 @@ package vips
 
 // #include "convolution.h"
 import "C"
+import (
+	"errors"
+	"fmt"
+)
+
+// Precision represents VIPS_PRECISION type, its zero value is PrecisionFloat
+type Precision int
+
+// Precision enum
+const (
+	PrecisionFloat Precision = iota
+	PrecisionInteger
+	PrecisionApproximate
+)
+
+var vipsPrecisions = map[Precision]C.VipsPrecision{
+	PrecisionFloat:       C.VIPS_PRECISION_FLOAT,
+	PrecisionInteger:     C.VIPS_PRECISION_INTEGER,
+	PrecisionApproximate: C.VIPS_PRECISION_APPROXIMATE,
+}
+
+func (p Precision) vips() C.VipsPrecision {
+	if precision, ok := vipsPrecisions[p]; ok {
+		return precision
+	}
+	return C.VIPS_PRECISION_FLOAT
+}
+
+// ConvOptions are the options of a convolution with Conv
+type ConvOptions struct {
+	// Scale divides the result, e.g. the sum of the kernel to keep the brightness, 0 for 1
+	Scale float64
+	// Offset is added to the result after scaling, e.g. 128 to show negative responses
+	Offset float64
+	// Precision of the computation: float, the default, is exact, integer rounds the kernel to integers and is fastest,
+	// approximate builds the kernel from a few boxes and is fast for large kernels
+	Precision Precision
+	// Layers of the approximation of the kernel with PrecisionApproximate, 0 for the default of 5
+	Layers int
+	// Cluster lines closer than this in the approximation with PrecisionApproximate, 0 for the default of 1
+	Cluster int
+	// Separable applies a single row or column kernel horizontally then vertically, which is much faster than the
+	// equivalent square kernel
+	Separable bool
+}
+
+// flattenKernel returns the values of a kernel row by row, checking that it is a non-empty rectangle
+func flattenKernel(kernel [][]float64) ([]float64, int, int, error) {
+	if len(kernel) == 0 || len(kernel[0]) == 0 {
+		return nil, 0, 0, errors.New("kernel is empty")
+	}
+
+	width, height := len(kernel[0]), len(kernel)
+	values := make([]float64, 0, width*height)
+	for y, row := range kernel {
+		if len(row) != width {
+			return nil, 0, 0, fmt.Errorf("kernel row %d has %d values, expected %d", y, len(row), width)
+		}
+		values = append(values, row...)
+	}
+	return values, width, height, nil
+}
 
 // https://libvips.github.io/libvips/API/current/libvips-convolution.html#vips-gaussblur
 func vipsGaussianBlur(in *C.VipsImage, sigma float64) (*C.VipsImage, error) {
@@ -26,3 +88,131 @@ func vipsSharpen(in *C.VipsImage, sigma float64, x1 float64, m2 float64) (*C.Vip
 
 	return out, nil
 }
+
+// https://libvips.github.io/libvips/API/current/libvips-convolution.html#vips-sharpen
+func vipsSharpenParams(in *C.VipsImage, params *SharpenParams) (*C.VipsImage, error) {
+	incOpCounter("sharpen")
+	var out *C.VipsImage
+
+	if err := C.sharpen_image_params(in, &out, C.double(params.Sigma), C.double(params.X1), C.double(params.Y2),
+		C.double(params.Y3), C.double(params.M1), C.double(params.M2)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-convolution.html#vips-conv
+func vipsConv(in *C.VipsImage, kernel [][]float64, opts ConvOptions) (*C.VipsImage, error) {
+	incOpCounter("conv")
+	var out *C.VipsImage
+
+	values, width, height, err := flattenKernel(kernel)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Separable && width != 1 && height != 1 {
+		return nil, fmt.Errorf("separable kernel must be a single row or column, got %dx%d", width, height)
+	}
+
+	scale := opts.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	layers := opts.Layers
+	if layers <= 0 {
+		layers = 5
+	}
+	cluster := opts.Cluster
+	if cluster <= 0 {
+		cluster = 1
+	}
+
+	if err := C.conv_kernel(in, &out, (*C.double)(&values[0]), C.int(width), C.int(height), C.double(scale),
+		C.double(opts.Offset), opts.Precision.vips(), C.int(layers), C.int(cluster),
+		toGboolean(opts.Separable)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-convolution.html#vips-sobel
+func vipsSobel(in *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("sobel")
+	var out *C.VipsImage
+
+	if err := C.sobel_image(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-convolution.html#vips-scharr
+func vipsScharr(in *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("scharr")
+	var out *C.VipsImage
+
+	if err := C.scharr_image(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-convolution.html#vips-canny
+func vipsCanny(in *C.VipsImage, sigma float64, precision Precision) (*C.VipsImage, error) {
+	incOpCounter("canny")
+	var out *C.VipsImage
+
+	if err := C.canny_image(in, &out, C.double(sigma), precision.vips()); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsFeatherAlpha(in *C.VipsImage, sigma float64) (*C.VipsImage, error) {
+	incOpCounter("featherAlpha")
+	var out *C.VipsImage
+
+	if err := C.feather_alpha(in, &out, C.double(sigma)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsEnergyMap(in *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("energyMap")
+	var out *C.VipsImage
+
+	if err := C.energy_map(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsBlurScore(in *C.VipsImage) (float64, error) {
+	incOpCounter("blurScore")
+	var out C.double
+
+	if err := C.blur_score(in, &out); err != 0 {
+		return 0, handleVipsError()
+	}
+
+	return float64(out), nil
+}
+
+func vipsNoiseScore(in *C.VipsImage) (float64, error) {
+	incOpCounter("noiseScore")
+	var out C.double
+
+	if err := C.noise_score(in, &out); err != 0 {
+		return 0, handleVipsError()
+	}
+
+	return float64(out), nil
+}
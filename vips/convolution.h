@@ -6,3 +6,18 @@
 int gaussian_blur_image(VipsImage *in, VipsImage **out, double sigma);
 int sharpen_image(VipsImage *in, VipsImage **out, double sigma, double x1,
                   double m2);
+int sharpen_image_params(VipsImage *in, VipsImage **out, double sigma,
+                         double x1, double y2, double y3, double m1,
+                         double m2);
+int energy_map(VipsImage *in, VipsImage **out);
+int blur_score(VipsImage *in, double *out);
+int noise_score(VipsImage *in, double *out);
+int conv_kernel(VipsImage *in, VipsImage **out, double *kernel, int width,
+                int height, double scale, double offset,
+                VipsPrecision precision, int layers, int cluster,
+                gboolean separable);
+int sobel_image(VipsImage *in, VipsImage **out);
+int scharr_image(VipsImage *in, VipsImage **out);
+int canny_image(VipsImage *in, VipsImage **out, double sigma,
+                VipsPrecision precision);
+int feather_alpha(VipsImage *in, VipsImage **out, double sigma);
@@ -22,3 +22,65 @@ int identity(VipsImage **out, int ushort) {
     return vips_identity(out, NULL);
   }
 }
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-gaussnoise
+int gaussnoise(VipsImage **out, int width, int height, double mean,
+               double sigma, int seed) {
+#if (VIPS_MAJOR_VERSION > 8) || (VIPS_MINOR_VERSION >= 13)
+  return vips_gaussnoise(out, width, height, "mean", mean, "sigma", sigma,
+                         "seed", seed, NULL);
+#else
+  return vips_gaussnoise(out, width, height, "mean", mean, "sigma", sigma,
+                         NULL);
+#endif
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-perlin
+int perlin(VipsImage **out, int width, int height, int cell_size,
+           gboolean uchar, int seed) {
+#if (VIPS_MAJOR_VERSION > 8) || (VIPS_MINOR_VERSION >= 13)
+  return vips_perlin(out, width, height, "cell_size", cell_size, "uchar",
+                     uchar, "seed", seed, NULL);
+#else
+  return vips_perlin(out, width, height, "cell_size", cell_size, "uchar",
+                     uchar, NULL);
+#endif
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-worley
+int worley(VipsImage **out, int width, int height, int cell_size, int seed) {
+#if (VIPS_MAJOR_VERSION > 8) || (VIPS_MINOR_VERSION >= 13)
+  return vips_worley(out, width, height, "cell_size", cell_size, "seed", seed,
+                     NULL);
+#else
+  return vips_worley(out, width, height, "cell_size", cell_size, NULL);
+#endif
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-sines
+int sines(VipsImage **out, int width, int height, double hfreq, double vfreq,
+          gboolean uchar) {
+  return vips_sines(out, width, height, "hfreq", hfreq, "vfreq", vfreq,
+                    "uchar", uchar, NULL);
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-zone
+int zone(VipsImage **out, int width, int height, gboolean uchar) {
+  return vips_zone(out, width, height, "uchar", uchar, NULL);
+}
+
+// https://libvips.github.io/libvips/API/current/VipsImage.html#vips-image-new-from-memory-copy
+int image_from_memory(const void *data, size_t size, VipsImage **out,
+                      int width, int height, int bands, VipsBandFormat format,
+                      VipsInterpretation interpretation) {
+  VipsImage *base = vips_image_new_from_memory_copy(data, size, width, height,
+                                                    bands, format);
+  if (!base) {
+    return 1;
+  }
+
+  int ret = vips_copy(base, out, "interpretation", interpretation, NULL);
+  g_object_unref(base);
+
+  return ret;
+}
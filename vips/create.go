@@ -2,6 +2,10 @@ package vips
 
 // #include "create.h"
 import "C"
+import (
+	"fmt"
+	"unsafe"
+)
 
 // https://libvips.github.io/libvips/API/current/libvips-create.html#vips-xyz
 func vipsXYZ(width int, height int) (*C.VipsImage, error) {
@@ -35,3 +39,85 @@ func vipsIdentity(ushort bool) (*C.VipsImage, error) {
 
 	return out, nil
 }
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-gaussnoise
+func vipsGaussNoise(width, height int, mean, sigma float64, seed int) (*C.VipsImage, error) {
+	incOpCounter("gaussnoise")
+	var out *C.VipsImage
+
+	if err := C.gaussnoise(&out, C.int(width), C.int(height), C.double(mean), C.double(sigma), C.int(seed)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-perlin
+func vipsPerlin(width, height, cellSize int, uchar bool, seed int) (*C.VipsImage, error) {
+	incOpCounter("perlin")
+	var out *C.VipsImage
+
+	if err := C.perlin(&out, C.int(width), C.int(height), C.int(cellSize), toGboolean(uchar), C.int(seed)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-worley
+func vipsWorley(width, height, cellSize int, seed int) (*C.VipsImage, error) {
+	incOpCounter("worley")
+	var out *C.VipsImage
+
+	if err := C.worley(&out, C.int(width), C.int(height), C.int(cellSize), C.int(seed)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-sines
+func vipsSines(width, height int, hfreq, vfreq float64, uchar bool) (*C.VipsImage, error) {
+	incOpCounter("sines")
+	var out *C.VipsImage
+
+	if err := C.sines(&out, C.int(width), C.int(height), C.double(hfreq), C.double(vfreq), toGboolean(uchar)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-create.html#vips-zone
+func vipsZone(width, height int, uchar bool) (*C.VipsImage, error) {
+	incOpCounter("zone")
+	var out *C.VipsImage
+
+	if err := C.zone(&out, C.int(width), C.int(height), toGboolean(uchar)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/VipsImage.html#vips-image-new-from-memory-copy
+func vipsImageFromMemory(buf []byte, width, height, bands int, interpretation Interpretation) (*C.VipsImage, error) {
+	return vipsImageFromMemoryFormat(buf, width, height, bands, BandFormatUchar, interpretation)
+}
+
+// vipsImageFromMemoryFormat copies interleaved pixels of the given band format, in native byte order
+func vipsImageFromMemoryFormat(buf []byte, width, height, bands int, format BandFormat, interpretation Interpretation) (*C.VipsImage, error) {
+	incOpCounter("imageFromMemory")
+	var out *C.VipsImage
+
+	size := width * height * bands * int(C.vips_format_sizeof(C.VipsBandFormat(format)))
+	if len(buf) == 0 || len(buf) != size {
+		return nil, fmt.Errorf("expected %d bytes for a %dx%d image with %d bands, got %d", size, width, height, bands, len(buf))
+	}
+
+	if err := C.image_from_memory(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &out, C.int(width), C.int(height), C.int(bands), C.VipsBandFormat(format), C.VipsInterpretation(interpretation)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
@@ -10,3 +10,14 @@
 int xyz(VipsImage **out, int width, int height);
 int black(VipsImage **out, int width, int height);
 int identity(VipsImage **out, int ushort);
+int gaussnoise(VipsImage **out, int width, int height, double mean,
+               double sigma, int seed);
+int perlin(VipsImage **out, int width, int height, int cell_size,
+           gboolean uchar, int seed);
+int worley(VipsImage **out, int width, int height, int cell_size, int seed);
+int sines(VipsImage **out, int width, int height, double hfreq, double vfreq,
+          gboolean uchar);
+int zone(VipsImage **out, int width, int height, gboolean uchar);
+int image_from_memory(const void *data, size_t size, VipsImage **out,
+                      int width, int height, int bands, VipsBandFormat format,
+                      VipsInterpretation interpretation);
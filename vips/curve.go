@@ -0,0 +1,152 @@
+package vips
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"unsafe"
+)
+
+// CurvePoint is a control point of a tone curve, mapping input level X to output level Y, both between 0 and 1
+type CurvePoint struct {
+	X, Y float64
+}
+
+// ToneCurve maps the levels of the image through a smooth curve passing through the given control points, like the
+// curves adjustment of image editors, e.g. {0, 0}, {0.25, 0.2}, {0.75, 0.8}, {1, 1} for more contrast. Levels before
+// the first and after the last point keep the value of that point. The curve is a monotone cubic spline, so it does
+// not overshoot between points. Alpha is not changed. The image must be 8 or 16-bit unsigned.
+func (r *ImageRef) ToneCurve(points []CurvePoint) error {
+	curve, err := newToneCurve(points)
+	if err != nil {
+		return err
+	}
+
+	var size int
+	switch r.BandFormat() {
+	case BandFormatUchar:
+		size = 256
+	case BandFormatUshort:
+		size = 65536
+	default:
+		return fmt.Errorf("tone curves need an 8 or 16-bit unsigned image, got band format %d", r.BandFormat())
+	}
+
+	bands := r.Bands()
+	alpha := -1
+	if r.HasAlpha() {
+		alpha = bands - 1
+	}
+
+	// one LUT band per image band, the alpha band mapped to itself
+	lut := make([]uint16, size*bands)
+	for i := 0; i < size; i++ {
+		value := uint16(math.Round(curve.at(float64(i)/float64(size-1)) * float64(size-1)))
+		for b := 0; b < bands; b++ {
+			if b == alpha {
+				lut[i*bands+b] = uint16(i)
+			} else {
+				lut[i*bands+b] = value
+			}
+		}
+	}
+
+	var lutImage *ImageRef
+	if size == 256 {
+		buf := make([]byte, len(lut))
+		for i, v := range lut {
+			buf[i] = uint8(v)
+		}
+		lutImage, err = newLUTImage(buf, size, bands, BandFormatUchar)
+	} else {
+		lutImage, err = newLUTImage((*[1 << 30]byte)(unsafe.Pointer(&lut[0]))[:2*len(lut):2*len(lut)], size, bands, BandFormatUshort)
+	}
+	if err != nil {
+		return err
+	}
+	defer lutImage.Close()
+
+	return r.Maplut(lutImage)
+}
+
+func newLUTImage(buf []byte, size, bands int, format BandFormat) (*ImageRef, error) {
+	img, err := vipsImageFromMemoryFormat(buf, size, 1, bands, format, InterpretationHistogram)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(img, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// toneCurve is a monotone cubic Hermite spline through control points, see Fritsch and Carlson, "Monotone Piecewise
+// Cubic Interpolation"
+type toneCurve struct {
+	points  []CurvePoint
+	tangent []float64
+}
+
+func newToneCurve(points []CurvePoint) (*toneCurve, error) {
+	if len(points) < 2 {
+		return nil, errors.New("a tone curve needs at least two points")
+	}
+
+	sorted := make([]CurvePoint, len(points))
+	copy(sorted, points)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].X < sorted[j].X })
+
+	for i, p := range sorted {
+		if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+			return nil, fmt.Errorf("tone curve point %v is outside of 0-1", p)
+		}
+		if i > 0 && p.X == sorted[i-1].X {
+			return nil, fmt.Errorf("tone curve has two points at %g", p.X)
+		}
+	}
+
+	n := len(sorted)
+	slope := make([]float64, n-1)
+	for i := range slope {
+		slope[i] = (sorted[i+1].Y - sorted[i].Y) / (sorted[i+1].X - sorted[i].X)
+	}
+
+	tangent := make([]float64, n)
+	tangent[0], tangent[n-1] = slope[0], slope[n-2]
+	for i := 1; i < n-1; i++ {
+		if slope[i-1]*slope[i] > 0 {
+			tangent[i] = (slope[i-1] + slope[i]) / 2
+		}
+	}
+
+	// limits the tangents so that the curve stays monotone between points
+	for i, s := range slope {
+		if s == 0 {
+			tangent[i], tangent[i+1] = 0, 0
+			continue
+		}
+		a, b := tangent[i]/s, tangent[i+1]/s
+		if h := math.Hypot(a, b); h > 3 {
+			tangent[i], tangent[i+1] = 3*a/h*s, 3*b/h*s
+		}
+	}
+
+	return &toneCurve{points: sorted, tangent: tangent}, nil
+}
+
+// at returns the output level of input level x, clamped to 0-1
+func (c *toneCurve) at(x float64) float64 {
+	p := c.points
+	if x <= p[0].X {
+		return p[0].Y
+	}
+	if x >= p[len(p)-1].X {
+		return p[len(p)-1].Y
+	}
+
+	i := sort.Search(len(p), func(i int) bool { return p[i].X > x }) - 1
+	h := p[i+1].X - p[i].X
+	t := (x - p[i].X) / h
+	t2, t3 := t*t, t*t*t
+
+	y := (2*t3-3*t2+1)*p[i].Y + (t3-2*t2+t)*h*c.tangent[i] + (-2*t3+3*t2)*p[i+1].Y + (t3-t2)*h*c.tangent[i+1]
+	return math.Max(0, math.Min(1, y))
+}
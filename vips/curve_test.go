@@ -0,0 +1,60 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRef_ToneCurve(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+	require.NoError(t, img.AddAlpha())
+	inverted, err := img.Copy()
+	require.NoError(t, err)
+	require.NoError(t, inverted.Invert())
+
+	require.NoError(t, img.ToneCurve([]CurvePoint{{X: 0, Y: 1}, {X: 1, Y: 0}}))
+	assert.Equal(t, BandFormatUchar, img.BandFormat())
+
+	point, err := img.GetPoint(30, 40)
+	require.NoError(t, err)
+	expected, err := inverted.GetPoint(30, 40)
+	require.NoError(t, err)
+	// alpha is kept
+	assert.Equal(t, append(expected[:3], 255), point)
+
+	require.NoError(t, img.Cast(BandFormatFloat))
+	assert.Error(t, img.ToneCurve([]CurvePoint{{X: 0, Y: 1}, {X: 1, Y: 0}}))
+}
+
+func TestToneCurve(t *testing.T) {
+	identity, err := newToneCurve([]CurvePoint{{X: 0, Y: 0}, {X: 1, Y: 1}})
+	require.NoError(t, err)
+	for _, x := range []float64{0, 0.3, 0.5, 1} {
+		assert.InDelta(t, x, identity.at(x), 1e-9)
+	}
+
+	// control points in any order, the curve flat outside of them and monotone between them
+	curve, err := newToneCurve([]CurvePoint{{X: 0.9, Y: 1}, {X: 0.2, Y: 0.1}, {X: 0.6, Y: 0.9}, {X: 0.5, Y: 0.9}})
+	require.NoError(t, err)
+	assert.Equal(t, 0.1, curve.at(0))
+	assert.Equal(t, 1.0, curve.at(0.95))
+	assert.Equal(t, 0.9, curve.at(0.55))
+	previous := 0.0
+	for i := 0; i <= 100; i++ {
+		y := curve.at(float64(i) / 100)
+		assert.GreaterOrEqual(t, y, previous)
+		previous = y
+	}
+
+	_, err = newToneCurve([]CurvePoint{{X: 0, Y: 0}})
+	assert.Error(t, err)
+	_, err = newToneCurve([]CurvePoint{{X: 0, Y: 0}, {X: 0, Y: 1}})
+	assert.Error(t, err)
+	_, err = newToneCurve([]CurvePoint{{X: 0, Y: 0}, {X: 1, Y: 2}})
+	assert.Error(t, err)
+}
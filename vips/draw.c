@@ -2,8 +2,10 @@
 
 #include "conversion.h"
 
-int draw_rect(VipsImage *in, double r, double g, double b, double a, int left,
-              int top, int width, int height, int fill) {
+// fills ink with the color scaled to the image format, returns the number of
+// bands of the ink
+static int draw_ink(VipsImage *in, double r, double g, double b, double a,
+                    double *ink) {
   if (is_16bit(in->Type)) {
     r = 65535 * r / 255;
     g = 65535 * g / 255;
@@ -11,14 +13,53 @@ int draw_rect(VipsImage *in, double r, double g, double b, double a, int left,
     a = 65535 * a / 255;
   }
 
-  double background[3] = {r, g, b};
-  double backgroundRGBA[4] = {r, g, b, a};
+  ink[0] = r;
+  ink[1] = g;
+  ink[2] = b;
+  ink[3] = a;
 
   if (in->Bands <= 3) {
-    return vips_draw_rect(in, background, 3, left, top, width, height, "fill",
-                          fill, NULL);
-  } else {
-    return vips_draw_rect(in, backgroundRGBA, 4, left, top, width, height,
-                          "fill", fill, NULL);
+    return 3;
   }
+  return 4;
+}
+
+int draw_rect(VipsImage *in, double r, double g, double b, double a, int left,
+              int top, int width, int height, int fill) {
+  double ink[4];
+  int n = draw_ink(in, r, g, b, a, ink);
+
+  return vips_draw_rect(in, ink, n, left, top, width, height, "fill", fill,
+                        NULL);
+}
+
+int draw_circle(VipsImage *in, double r, double g, double b, double a, int cx,
+                int cy, int radius, int fill) {
+  double ink[4];
+  int n = draw_ink(in, r, g, b, a, ink);
+
+  return vips_draw_circle(in, ink, n, cx, cy, radius, "fill", fill, NULL);
+}
+
+int draw_line(VipsImage *in, double r, double g, double b, double a, int x1,
+              int y1, int x2, int y2) {
+  double ink[4];
+  int n = draw_ink(in, r, g, b, a, ink);
+
+  return vips_draw_line(in, ink, n, x1, y1, x2, y2, NULL);
+}
+
+int draw_flood(VipsImage *in, double r, double g, double b, double a, int x,
+               int y, int equal, int *left, int *top, int *width,
+               int *height) {
+  double ink[4];
+  int n = draw_ink(in, r, g, b, a, ink);
+
+  return vips_draw_flood(in, ink, n, x, y, "equal", equal, "left", left,
+                         "top", top, "width", width, "height", height, NULL);
+}
+
+int draw_image(VipsImage *in, VipsImage *sub, int x, int y,
+               VipsCombineMode mode) {
+  return vips_draw_image(in, sub, x, y, "mode", mode, NULL);
 }
@@ -2,6 +2,16 @@ package vips
 
 // #include "draw.h"
 import "C"
+import "image"
+
+// CombineMode represents VIPS_COMBINE_MODE type
+type CombineMode int
+
+// CombineMode enum
+const (
+	CombineModeSet CombineMode = C.VIPS_COMBINE_MODE_SET
+	CombineModeAdd CombineMode = C.VIPS_COMBINE_MODE_ADD
+)
 
 // https://libvips.github.io/libvips/API/current/libvips-draw.html#vips-draw-rect
 func vipsDrawRect(in *C.VipsImage, color ColorRGBA, left int, top int, width int, height int, fill bool) error {
@@ -19,3 +29,51 @@ func vipsDrawRect(in *C.VipsImage, color ColorRGBA, left int, top int, width int
 
 	return nil
 }
+
+// https://libvips.github.io/libvips/API/current/libvips-draw.html#vips-draw-circle
+func vipsDrawCircle(in *C.VipsImage, color ColorRGBA, cx int, cy int, radius int, fill bool) error {
+	incOpCounter("draw_circle")
+
+	if err := C.draw_circle(in, C.double(color.R), C.double(color.G), C.double(color.B), C.double(color.A),
+		C.int(cx), C.int(cy), C.int(radius), C.int(boolToInt(fill))); err != 0 {
+		return handleImageError(in)
+	}
+
+	return nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-draw.html#vips-draw-line
+func vipsDrawLine(in *C.VipsImage, color ColorRGBA, x1 int, y1 int, x2 int, y2 int) error {
+	incOpCounter("draw_line")
+
+	if err := C.draw_line(in, C.double(color.R), C.double(color.G), C.double(color.B), C.double(color.A),
+		C.int(x1), C.int(y1), C.int(x2), C.int(y2)); err != 0 {
+		return handleImageError(in)
+	}
+
+	return nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-draw.html#vips-draw-flood
+func vipsDrawFlood(in *C.VipsImage, color ColorRGBA, x int, y int, equal bool) (image.Rectangle, error) {
+	incOpCounter("draw_flood")
+	var left, top, width, height C.int
+
+	if err := C.draw_flood(in, C.double(color.R), C.double(color.G), C.double(color.B), C.double(color.A),
+		C.int(x), C.int(y), C.int(boolToInt(equal)), &left, &top, &width, &height); err != 0 {
+		return image.Rectangle{}, handleImageError(in)
+	}
+
+	return image.Rect(int(left), int(top), int(left+width), int(top+height)), nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-draw.html#vips-draw-image
+func vipsDrawImage(in *C.VipsImage, sub *C.VipsImage, x int, y int, mode CombineMode) error {
+	incOpCounter("draw_image")
+
+	if err := C.draw_image(in, sub, C.int(x), C.int(y), C.VipsCombineMode(mode)); err != 0 {
+		return handleImageError(in)
+	}
+
+	return nil
+}
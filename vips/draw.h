@@ -5,3 +5,12 @@
 
 int draw_rect(VipsImage *in, double r, double g, double b, double a, int left,
               int top, int width, int height, int fill);
+int draw_circle(VipsImage *in, double r, double g, double b, double a, int cx,
+                int cy, int radius, int fill);
+int draw_line(VipsImage *in, double r, double g, double b, double a, int x1,
+              int y1, int x2, int y2);
+int draw_flood(VipsImage *in, double r, double g, double b, double a, int x,
+               int y, int equal, int *left, int *top, int *width,
+               int *height);
+int draw_image(VipsImage *in, VipsImage *sub, int x, int y,
+               VipsCombineMode mode);
@@ -7,14 +7,38 @@ import (
 	"errors"
 	"fmt"
 	dbg "runtime/debug"
+	"strings"
+	"time"
 	"unsafe"
 )
 
 var (
 	// ErrUnsupportedImageFormat when image type is unsupported
 	ErrUnsupportedImageFormat = errors.New("unsupported image format")
+
+	// ErrInvalidICCProfile when ICC profile data lacks a valid header
+	ErrInvalidICCProfile = errors.New("invalid ICC profile")
+
+	// ErrExportTimeout when an export was aborted for exceeding its MaxDuration, see ExportTimeoutError
+	ErrExportTimeout = errors.New("export timed out")
 )
 
+// ExportTimeoutError is returned when an export was aborted for exceeding its MaxDuration.
+// It matches ErrExportTimeout with errors.Is.
+type ExportTimeoutError struct {
+	Format      ImageType
+	MaxDuration time.Duration
+}
+
+func (e *ExportTimeoutError) Error() string {
+	return fmt.Sprintf("%s export exceeded %v", strings.TrimPrefix(e.Format.FileExt(), "."), e.MaxDuration)
+}
+
+// Unwrap returns ErrExportTimeout
+func (e *ExportTimeoutError) Unwrap() error {
+	return ErrExportTimeout
+}
+
 func handleImageError(out *C.VipsImage) error {
 	if out != nil {
 		clearImage(out)
@@ -0,0 +1,347 @@
+package vips
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExifRational is an EXIF rational value, e.g. an exposure time of 1/200
+type ExifRational struct {
+	Numerator   int64
+	Denominator int64
+}
+
+// Float64 returns the value of the rational, 0 when the denominator is 0
+func (r ExifRational) Float64() float64 {
+	if r.Denominator == 0 {
+		return 0
+	}
+	return float64(r.Numerator) / float64(r.Denominator)
+}
+
+func (r ExifRational) String() string {
+	return fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
+}
+
+const exifFieldPrefix = "exif-ifd"
+
+// exifThumbnailIFD holds the tags of the embedded thumbnail, which duplicate tags of the main image
+const exifThumbnailIFD = "1"
+
+// exifDateFormat is the layout of EXIF date and time tags
+const exifDateFormat = "2006:01:02 15:04:05"
+
+// exifSubIFDTags are common tags of the EXIF sub-IFD (ifd2), GPS tags live in ifd3 and the others in ifd0
+var exifSubIFDTags = map[string]bool{
+	"ExposureTime": true, "FNumber": true, "ExposureProgram": true, "ISOSpeedRatings": true,
+	"ExifVersion": true, "DateTimeOriginal": true, "DateTimeDigitized": true, "OffsetTime": true,
+	"OffsetTimeOriginal": true, "OffsetTimeDigitized": true, "ShutterSpeedValue": true, "ApertureValue": true,
+	"BrightnessValue": true, "ExposureBiasValue": true, "MaxApertureValue": true, "SubjectDistance": true,
+	"MeteringMode": true, "LightSource": true, "Flash": true, "FocalLength": true, "UserComment": true,
+	"SubSecTime": true, "SubSecTimeOriginal": true, "SubSecTimeDigitized": true, "ColorSpace": true,
+	"PixelXDimension": true, "PixelYDimension": true, "ExposureMode": true, "WhiteBalance": true,
+	"DigitalZoomRatio": true, "FocalLengthIn35mmFilm": true, "SceneCaptureType": true, "Contrast": true,
+	"Saturation": true, "Sharpness": true, "ImageUniqueID": true, "CameraOwnerName": true,
+	"BodySerialNumber": true, "LensSpecification": true, "LensMake": true, "LensModel": true,
+	"LensSerialNumber": true,
+}
+
+// ErrUnsupportedExifValue is returned by SetExifTag for values of a type that cannot be stored in EXIF
+var ErrUnsupportedExifValue = errors.New("unsupported EXIF value type")
+
+// libvips stores EXIF tags as "value (human readable value, format, n components, n bytes)"
+var exifFieldPattern = regexp.MustCompile(`^(.*?) \((.*), ([A-Za-z]+), (\d+) components?, \d+ bytes?\)$`)
+
+// Exif returns the EXIF tags of the image by tag name, e.g. "Model" or "ExposureTime". Values are typed by their
+// EXIF format: string for ASCII and undefined data, int for (signed) bytes, shorts and longs, ExifRational for
+// (signed) rationals and float64 for floats and doubles, as a slice when the tag holds several values.
+// Tags of the main image take precedence over the ones of the embedded thumbnail.
+func (r *ImageRef) Exif() map[string]interface{} {
+	tags := make(map[string]interface{})
+	thumbnailTags := make(map[string]interface{})
+
+	for _, field := range r.ImageFields() {
+		ifd, name, ok := splitExifField(field)
+		if !ok {
+			continue
+		}
+
+		raw, ok := vipsImageGetString(r.image, field)
+		if !ok {
+			continue
+		}
+
+		if ifd == exifThumbnailIFD {
+			thumbnailTags[name] = parseExifValue(raw)
+		} else {
+			tags[name] = parseExifValue(raw)
+		}
+	}
+
+	for name, value := range thumbnailTags {
+		if _, ok := tags[name]; !ok {
+			tags[name] = value
+		}
+	}
+
+	return tags
+}
+
+// SetExifTag sets an EXIF tag, which libvips writes to the EXIF data of the exported image unless metadata is
+// stripped. name is a tag name like "Copyright", kept in the IFD the image already has it in or else put in its
+// standard IFD, or a field name like "exif-ifd0-Copyright". value is a string, an integer, an ExifRational, a float64
+// (stored as rational), a time.Time (stored as EXIF date) or a slice of integers, rationals or float64s.
+// libvips only writes tags that libexif knows for the IFD.
+func (r *ImageRef) SetExifTag(name string, value interface{}) error {
+	s, err := formatExifValue(value)
+	if err != nil {
+		return err
+	}
+
+	field := r.exifFieldName(name)
+	if field == "" {
+		return fmt.Errorf("invalid EXIF tag name %q", name)
+	}
+
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+
+	vipsImageSetString(out, field, s)
+
+	r.setImage(out)
+	return nil
+}
+
+// RemoveExifTag removes an EXIF tag, given by tag name from every IFD including the thumbnail one, or by field name
+// like "exif-ifd0-Copyright". Removing a tag the image doesn't have is not an error.
+func (r *ImageRef) RemoveExifTag(name string) error {
+	var fields []string
+	for _, field := range r.ImageFields() {
+		_, tag, ok := splitExifField(field)
+		if ok && (field == name || tag == name) {
+			fields = append(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		vipsRemoveField(out, field)
+	}
+
+	r.setImage(out)
+	return nil
+}
+
+// exifFieldName returns the libvips field of an EXIF tag name, empty for an invalid name
+func (r *ImageRef) exifFieldName(name string) string {
+	if strings.HasPrefix(name, exifFieldPrefix) {
+		if _, _, ok := splitExifField(name); !ok {
+			return ""
+		}
+		return name
+	}
+
+	if name == "" || strings.ContainsAny(name, " -") {
+		return ""
+	}
+
+	for _, field := range r.ImageFields() {
+		if ifd, tag, ok := splitExifField(field); ok && tag == name && ifd != exifThumbnailIFD {
+			return field
+		}
+	}
+
+	switch {
+	case exifSubIFDTags[name]:
+		return exifFieldPrefix + "2-" + name
+	case strings.HasPrefix(name, "GPS"):
+		return exifFieldPrefix + "3-" + name
+	default:
+		return exifFieldPrefix + "0-" + name
+	}
+}
+
+// formatExifValue formats a value the way libvips parses EXIF fields, space separating multiple components
+func formatExifValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), nil
+	case ExifRational:
+		return v.String(), nil
+	case float64:
+		return floatToExifRational(v).String(), nil
+	case float32:
+		return floatToExifRational(float64(v)).String(), nil
+	case time.Time:
+		return v.Format(exifDateFormat), nil
+	case []int:
+		parts := make([]string, len(v))
+		for i, n := range v {
+			parts[i] = strconv.Itoa(n)
+		}
+		return strings.Join(parts, " "), nil
+	case []ExifRational:
+		parts := make([]string, len(v))
+		for i, n := range v {
+			parts[i] = n.String()
+		}
+		return strings.Join(parts, " "), nil
+	case []float64:
+		parts := make([]string, len(v))
+		for i, n := range v {
+			parts[i] = floatToExifRational(n).String()
+		}
+		return strings.Join(parts, " "), nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedExifValue, value)
+	}
+}
+
+// floatToExifRational approximates f by a rational with a power of 10 denominator of up to 10^9, reduced
+func floatToExifRational(f float64) ExifRational {
+	denominator := int64(1)
+	for denominator < 1e9 && math.Abs(f*float64(denominator)-math.Round(f*float64(denominator))) > 1e-9 {
+		denominator *= 10
+	}
+	numerator := int64(math.Round(f * float64(denominator)))
+
+	a, b := numerator, denominator
+	if a < 0 {
+		a = -a
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a > 1 {
+		numerator /= a
+		denominator /= a
+	}
+
+	return ExifRational{Numerator: numerator, Denominator: denominator}
+}
+
+// splitExifField splits a field like "exif-ifd0-Model" into its IFD number and tag name
+func splitExifField(field string) (ifd, name string, ok bool) {
+	if !strings.HasPrefix(field, exifFieldPrefix) {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(field, exifFieldPrefix), "-", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// parseExifValue converts an EXIF field as formatted by libvips to its typed value, falling back to the raw string
+func parseExifValue(raw string) interface{} {
+	match := exifFieldPattern.FindStringSubmatch(raw)
+	if match == nil {
+		return raw
+	}
+
+	value, format := match[1], match[3]
+	components, _ := strconv.Atoi(match[4])
+
+	switch format {
+	case "Byte", "SByte", "Short", "SShort", "Long", "SLong":
+		return parseExifNumbers(value, components, func(s string) (interface{}, error) {
+			return strconv.Atoi(s)
+		})
+	case "Rational", "SRational":
+		return parseExifNumbers(value, components, func(s string) (interface{}, error) {
+			return parseExifRational(s)
+		})
+	case "Float", "Double":
+		return parseExifNumbers(value, components, func(s string) (interface{}, error) {
+			return strconv.ParseFloat(s, 64)
+		})
+	case "ASCII":
+		// the value and its human readable form are the same for strings, which may contain " (" themselves
+		prefix := raw[:strings.LastIndex(raw, ", ASCII, ")]
+		if n := len(prefix) - 2; n > 0 && n%2 == 0 && prefix[n/2:n/2+2] == " (" && prefix[:n/2] == prefix[n/2+2:] {
+			return prefix[:n/2]
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// parseExifNumbers parses the space separated values of a numeric tag, a single one unwrapped
+func parseExifNumbers(value string, components int, parse func(string) (interface{}, error)) interface{} {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) != components {
+		return value
+	}
+
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		v, err := parse(field)
+		if err != nil {
+			return value
+		}
+		values[i] = v
+	}
+
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	switch values[0].(type) {
+	case int:
+		ints := make([]int, len(values))
+		for i, v := range values {
+			ints[i] = v.(int)
+		}
+		return ints
+	case ExifRational:
+		rationals := make([]ExifRational, len(values))
+		for i, v := range values {
+			rationals[i] = v.(ExifRational)
+		}
+		return rationals
+	default:
+		floats := make([]float64, len(values))
+		for i, v := range values {
+			floats[i] = v.(float64)
+		}
+		return floats
+	}
+}
+
+func parseExifRational(s string) (ExifRational, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return ExifRational{}, fmt.Errorf("invalid rational %q", s)
+	}
+
+	numerator, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ExifRational{}, err
+	}
+	denominator, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return ExifRational{}, err
+	}
+
+	return ExifRational{Numerator: numerator, Denominator: denominator}, nil
+}
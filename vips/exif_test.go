@@ -0,0 +1,95 @@
+package vips
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExifValue(t *testing.T) {
+	tests := map[string]interface{}{
+		"Canon (Canon, ASCII, 6 components, 6 bytes)":                             "Canon",
+		"EOS (5D) (EOS (5D), ASCII, 9 components, 9 bytes)":                       "EOS (5D)",
+		"6 (Right-top, Short, 1 components, 2 bytes)":                             6,
+		"1 2 3 4 (1 2 3 4, Short, 4 components, 8 bytes)":                         []int{1, 2, 3, 4},
+		"1/200 (1/200 sec., Rational, 1 components, 8 bytes)":                     ExifRational{1, 200},
+		"-1/3 (-0.33 EV, SRational, 1 components, 8 bytes)":                       ExifRational{-1, 3},
+		"52/1 31/1 1234/100 (52, 31, 12.34, Rational, 3 components, 24 bytes)":    []ExifRational{{52, 1}, {31, 1}, {1234, 100}},
+		"Exif Version 2.21 (Exif Version 2.21, Undefined, 4 components, 4 bytes)": "Exif Version 2.21",
+		"not formatted by libvips":                                                "not formatted by libvips",
+	}
+	for raw, want := range tests {
+		assert.Equal(t, want, parseExifValue(raw), raw)
+	}
+
+	assert.Equal(t, 0.005, ExifRational{1, 200}.Float64())
+	assert.Equal(t, float64(0), ExifRational{1, 0}.Float64())
+}
+
+func TestImageRef_Exif(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-orientation-6.jpg")
+	require.NoError(t, err)
+
+	exif := img.Exif()
+	assert.Equal(t, "samsung", exif["Make"])
+	assert.Equal(t, "SM-G950F", exif["Model"])
+	assert.Equal(t, 6, exif["Orientation"])
+	assert.IsType(t, ExifRational{}, exif["XResolution"])
+
+	img, err = NewImageFromFile(resources + "without_exif.jpg")
+	require.NoError(t, err)
+	assert.Empty(t, img.Exif())
+}
+
+func TestFormatExifValue(t *testing.T) {
+	tests := map[string]interface{}{
+		"© govips":            "© govips",
+		"6":                   uint16(6),
+		"1/200":               ExifRational{1, 200},
+		"28/5":                5.6,
+		"1 2 3":               []int{1, 2, 3},
+		"52/1 31/1 617/50":    []float64{52, 31, 12.34},
+		"2021:04:17 17:12:11": time.Date(2021, 4, 17, 17, 12, 11, 0, time.UTC),
+	}
+	for want, value := range tests {
+		s, err := formatExifValue(value)
+		require.NoError(t, err)
+		assert.Equal(t, want, s)
+	}
+
+	_, err := formatExifValue(struct{}{})
+	assert.True(t, errors.Is(err, ErrUnsupportedExifValue))
+}
+
+func TestImageRef_SetExifTag(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-orientation-6.jpg")
+	require.NoError(t, err)
+
+	require.NoError(t, img.SetExifTag("Copyright", "© govips"))
+	require.NoError(t, img.SetExifTag("Artist", "Jane Doe"))
+	require.NoError(t, img.SetExifTag("DateTimeOriginal", time.Date(2021, 4, 17, 17, 12, 11, 0, time.UTC)))
+	require.NoError(t, img.RemoveExifTag("Make"))
+	assert.Contains(t, img.ImageFields(), "exif-ifd2-DateTimeOriginal")
+
+	buf, _, err := img.ExportJpeg(nil)
+	require.NoError(t, err)
+
+	exported, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+
+	exif := exported.Exif()
+	assert.Equal(t, "© govips", exif["Copyright"])
+	assert.Equal(t, "Jane Doe", exif["Artist"])
+	assert.Equal(t, "2021:04:17 17:12:11", exif["DateTimeOriginal"])
+	assert.Equal(t, "SM-G950F", exif["Model"])
+	assert.NotContains(t, exif, "Make")
+
+	assert.Error(t, img.SetExifTag("Bad Name", "x"))
+}
@@ -0,0 +1,58 @@
+package vips
+
+import (
+	"runtime"
+	"sync"
+)
+
+const exportQueueSize = 1024
+
+var (
+	exportWorkers  = runtime.NumCPU()
+	exportJobs     chan exportJob
+	exportPoolOnce sync.Once
+)
+
+// ExportResult is the outcome of an ExportAsync call
+type ExportResult struct {
+	Buffer   []byte
+	Metadata *ImageMetadata
+	Err      error
+}
+
+type exportJob struct {
+	ref    *ImageRef
+	params *ExportParams
+	result chan<- ExportResult
+}
+
+// ExportAsync queues the image for export on the internal export worker pool and returns a channel
+// receiving the result, which is closed afterwards. The pool is shared by all images and bounds the
+// number of concurrent encodes to Config.ExportWorkers, so heavy encodes such as AVIF can't exhaust the CPU.
+// Calls block only when the queue of pending exports is full. The image must not be modified until the
+// result has been received.
+func (r *ImageRef) ExportAsync(params *ExportParams) <-chan ExportResult {
+	startupIfNeeded()
+	exportPoolOnce.Do(startExportWorkers)
+
+	result := make(chan ExportResult, 1)
+	exportJobs <- exportJob{ref: r, params: params, result: result}
+
+	return result
+}
+
+func startExportWorkers() {
+	exportJobs = make(chan exportJob, exportQueueSize)
+
+	for i := 0; i < exportWorkers; i++ {
+		go exportWorker()
+	}
+}
+
+func exportWorker() {
+	for job := range exportJobs {
+		buf, metadata, err := job.ref.Export(job.params)
+		job.result <- ExportResult{Buffer: buf, Metadata: metadata, Err: err}
+		close(job.result)
+	}
+}
@@ -111,6 +111,7 @@ int set_pngload_options(VipsOperation *operation, LoadParams *params) {
 int set_webpload_options(VipsOperation *operation, LoadParams *params) {
   MAYBE_SET_INT(operation, params->page, "page");
   MAYBE_SET_INT(operation, params->n, "n");
+  MAYBE_SET_DOUBLE(operation, params->webpScale, "scale");
   return 0;
 }
 
@@ -118,6 +119,7 @@ int set_tiffload_options(VipsOperation *operation, LoadParams *params) {
   MAYBE_SET_BOOL(operation, params->autorotate, "autorotate");
   MAYBE_SET_INT(operation, params->page, "page");
   MAYBE_SET_INT(operation, params->n, "n");
+  MAYBE_SET_INT(operation, params->tiffSubifd, "subifd");
   return 0;
 }
 
@@ -131,6 +133,19 @@ int set_pdfload_options(VipsOperation *operation, LoadParams *params) {
   MAYBE_SET_INT(operation, params->page, "page");
   MAYBE_SET_INT(operation, params->n, "n");
   MAYBE_SET_DOUBLE(operation, params->dpi, "dpi");
+
+  if (params->pdfPassword) {
+    vips_object_set(VIPS_OBJECT(operation), "password", params->pdfPassword,
+                    NULL);
+  }
+
+  if (params->pdfBackgroundSet) {
+    VipsArrayDouble *background =
+        vips_array_double_new(params->pdfBackground, 4);
+    vips_object_set(VIPS_OBJECT(operation), "background", background, NULL);
+    vips_area_unref(VIPS_AREA(background));
+  }
+
   return 0;
 }
 
@@ -153,6 +168,10 @@ int set_jp2kload_options(VipsOperation *operation, LoadParams *params) {
   return 0;
 }
 
+int set_jxlload_options(VipsOperation *operation, LoadParams *params) {
+  return 0;
+}
+
 int set_magickload_options(VipsOperation *operation, LoadParams *params) {
   MAYBE_SET_INT(operation, params->page, "page");
   MAYBE_SET_INT(operation, params->n, "n");
@@ -175,6 +194,12 @@ int load_buffer(const char *operationName, void *buf, size_t len,
 
   vips_area_unref(VIPS_AREA(blob));
 
+  // access and revalidate are common to all loaders
+  MAYBE_SET_INT(operation, params->access, "access");
+#if (VIPS_MAJOR_VERSION > 8) || (VIPS_MINOR_VERSION >= 12)
+  MAYBE_SET_BOOL(operation, params->revalidate, "revalidate");
+#endif
+
   if (setLoadOptions(operation, params)) {
     vips_object_unref_outputs(VIPS_OBJECT(operation));
     g_object_unref(operation);
@@ -233,6 +258,36 @@ int save_buffer(const char *operationName, SaveParams *params,
   return 0;
 }
 
+int save_target(const char *operationName, SaveParams *params,
+                SetSaveOptionsFn setSaveOptions) {
+  VipsOperation *operation = vips_operation_new(operationName);
+  if (!operation) {
+    return 1;
+  }
+
+  if (vips_object_set(VIPS_OBJECT(operation), "in", params->inputImage,
+                      "target", params->outputTarget, NULL)) {
+    g_object_unref(operation);
+    return 1;
+  }
+
+  if (setSaveOptions(operation, params)) {
+    g_object_unref(operation);
+    return 1;
+  }
+
+  if (vips_cache_operation_buildp(&operation)) {
+    vips_object_unref_outputs(VIPS_OBJECT(operation));
+    g_object_unref(operation);
+    return 1;
+  }
+
+  vips_object_unref_outputs(VIPS_OBJECT(operation));
+  g_object_unref(operation);
+
+  return 0;
+}
+
 // https://libvips.github.io/libvips/API/current/VipsForeignSave.html#vips-jpegsave-buffer
 int set_jpegsave_options(VipsOperation *operation, SaveParams *params) {
   int ret = vips_object_set(
@@ -296,7 +351,8 @@ int set_webpsave_options(VipsOperation *operation, SaveParams *params) {
 
 // https://github.com/libvips/libvips/blob/master/libvips/foreign/heifsave.c#L653
 int set_heifsave_options(VipsOperation *operation, SaveParams *params) {
-  int ret = vips_object_set(VIPS_OBJECT(operation), "lossless",
+  int ret = vips_object_set(VIPS_OBJECT(operation), "strip",
+                            params->stripMetadata, "lossless",
                             params->heifLossless, NULL);
 
   if (!ret && params->quality) {
@@ -319,6 +375,23 @@ int set_tiffsave_options(VipsOperation *operation, SaveParams *params) {
     ret = vips_object_set(VIPS_OBJECT(operation), "Q", params->quality, NULL);
   }
 
+  if (!ret && params->tiffBitdepth) {
+    ret = vips_object_set(VIPS_OBJECT(operation), "bitdepth",
+                          params->tiffBitdepth, NULL);
+  }
+
+#if (VIPS_MAJOR_VERSION > 8) || (VIPS_MINOR_VERSION >= 12)
+  // deflate and zstd compression level, lossless webp tiles
+  if (!ret && params->tiffLevel) {
+    ret = vips_object_set(VIPS_OBJECT(operation), "level", params->tiffLevel,
+                          NULL);
+  }
+
+  if (!ret && params->tiffLossless) {
+    ret = vips_object_set(VIPS_OBJECT(operation), "lossless", TRUE, NULL);
+  }
+#endif
+
   return ret;
 }
 
@@ -334,7 +407,8 @@ int set_magicksave_options(VipsOperation *operation, SaveParams *params) {
 
 // https://libvips.github.io/libvips/API/current/VipsForeignSave.html#vips-gifsave-buffer
 int set_gifsave_options(VipsOperation *operation, SaveParams *params) {
-  int ret = 0;
+  int ret = vips_object_set(VIPS_OBJECT(operation), "strip",
+                            params->stripMetadata, NULL);
   // See for argument values: https://www.libvips.org/API/current/VipsForeignSave.html#vips-gifsave
   if (params->gifDither > 0.0 && params->gifDither <= 1.0) {
     ret = vips_object_set(VIPS_OBJECT(operation), "dither", params->gifDither, NULL);
@@ -350,8 +424,9 @@ int set_gifsave_options(VipsOperation *operation, SaveParams *params) {
 
 int set_avifsave_options(VipsOperation *operation, SaveParams *params) {
   int ret = vips_object_set(
-      VIPS_OBJECT(operation), "compression", VIPS_FOREIGN_HEIF_COMPRESSION_AV1,
-      "lossless", params->heifLossless, "speed", params->avifSpeed, NULL);
+      VIPS_OBJECT(operation), "strip", params->stripMetadata, "compression",
+      VIPS_FOREIGN_HEIF_COMPRESSION_AV1, "lossless", params->heifLossless,
+      "speed", params->avifSpeed, NULL);
 
   if (!ret && params->quality) {
     ret = vips_object_set(VIPS_OBJECT(operation), "Q", params->quality, NULL);
@@ -373,6 +448,25 @@ int set_jp2ksave_options(VipsOperation *operation, SaveParams *params) {
   return ret;
 }
 
+// https://www.libvips.org/API/current/VipsForeignSave.html#vips-jxlsave-buffer
+int set_jxlsave_options(VipsOperation *operation, SaveParams *params) {
+  return vips_object_set(VIPS_OBJECT(operation), "distance",
+                         params->jxlDistance, "effort", params->jxlEffort,
+                         "lossless", params->jxlLossless, "tier",
+                         params->jxlTier, NULL);
+}
+
+// https://www.libvips.org/API/current/VipsForeignLoad.html#vips-foreign-find-load
+const char *find_load_nickname(const char *filename) {
+  const char *name = vips_foreign_find_load(filename);
+  if (!name) {
+    vips_error_clear();
+    return NULL;
+  }
+
+  return vips_nickname_find(g_type_from_name(name));
+}
+
 int load_from_buffer(LoadParams *params, void *buf, size_t len) {
   switch (params->inputFormat) {
     case JPEG:
@@ -408,6 +502,9 @@ int load_from_buffer(LoadParams *params, void *buf, size_t len) {
    case JP2K:
       return load_buffer("jp2kload_buffer", buf, len, params,
                           set_jp2kload_options);
+    case JXL:
+      return load_buffer("jxlload_buffer", buf, len, params,
+                         set_jxlload_options);
     default:
       g_warning("Unsupported input type given: %d", params->inputFormat);
   }
@@ -436,12 +533,29 @@ int save_to_buffer(SaveParams *params) {
       return save_buffer("heifsave_buffer", params, set_avifsave_options);
     case JP2K:
       return save_buffer("jp2ksave_buffer", params, set_jp2ksave_options);
+    case JXL:
+      return save_buffer("jxlsave_buffer", params, set_jxlsave_options);
     default:
       g_warning("Unsupported output type given: %d", params->outputFormat);
   }
   return 1;
 }
 
+int save_to_target(SaveParams *params) {
+  switch (params->outputFormat) {
+    case JPEG:
+      return save_target("jpegsave_target", params, set_jpegsave_options);
+    case PNG:
+      return save_target("pngsave_target", params, set_pngsave_options);
+    case WEBP:
+      return save_target("webpsave_target", params, set_webpsave_options);
+    default:
+      g_warning("Unsupported target output type given: %d",
+                params->outputFormat);
+  }
+  return 1;
+}
+
 LoadParams create_load_params(ImageType inputFormat) {
   Param defaultParam = {};
   LoadParams p = {
@@ -456,6 +570,12 @@ LoadParams create_load_params(ImageType inputFormat) {
       .jpegShrink = defaultParam,
       .heifThumbnail = defaultParam,
       .svgUnlimited = defaultParam,
+      .webpScale = defaultParam,
+      .tiffSubifd = defaultParam,
+      .access = defaultParam,
+      .revalidate = defaultParam,
+      .pdfPassword = NULL,
+      .pdfBackgroundSet = FALSE,
   };
   return p;
 }
@@ -467,6 +587,7 @@ static SaveParams defaultSaveParams = {
     .outputBuffer = NULL,
     .outputFormat = JPEG,
     .outputLen = 0,
+    .outputTarget = NULL,
 
     .interlace = FALSE,
     .quality = 0,
@@ -504,15 +625,41 @@ static SaveParams defaultSaveParams = {
     .tiffTileWidth = 256,
     .tiffXRes = 1.0,
     .tiffYRes = 1.0,
+    .tiffBitdepth = 0,
+    .tiffLevel = 0,
+    .tiffLossless = FALSE,
 
     .avifSpeed = 5,
 
     .jp2kLossless = FALSE,
     .jp2kTileHeight = 512,
-    .jp2kTileWidth = 512};
+    .jp2kTileWidth = 512,
+
+    .jxlDistance = 1.0,
+    .jxlEffort = 7,
+    .jxlLossless = FALSE,
+    .jxlTier = 0};
 
 SaveParams create_save_params(ImageType outputFormat) {
   SaveParams params = defaultSaveParams;
   params.outputFormat = outputFormat;
   return params;
 }
+
+// https://www.libvips.org/API/current/VipsForeignSave.html#vips-dzsave
+int dzsave(VipsImage *in, const char *name, DzSaveParams *params) {
+  return vips_dzsave(in, name, "tile_size", params->tileSize, "overlap",
+                     params->overlap, "depth", params->depth, "suffix",
+                     params->suffix, "layout", params->layout, "container",
+                     params->container, "strip", params->stripMetadata, NULL);
+}
+
+// https://www.libvips.org/API/current/VipsForeignSave.html#vips-dzsave-buffer
+int dzsave_buffer(VipsImage *in, void **buf, size_t *len,
+                  DzSaveParams *params) {
+  return vips_dzsave_buffer(in, buf, len, "tile_size", params->tileSize,
+                            "overlap", params->overlap, "depth", params->depth,
+                            "suffix", params->suffix, "layout", params->layout,
+                            "container", params->container, "strip",
+                            params->stripMetadata, NULL);
+}
@@ -4,9 +4,11 @@ package vips
 import "C"
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/xml"
 	"fmt"
 	"image/png"
+	"io"
 	"math"
 	"runtime"
 	"unsafe"
@@ -44,6 +46,10 @@ const (
 	ImageTypeBMP     ImageType = C.BMP
 	ImageTypeAVIF    ImageType = C.AVIF
 	ImageTypeJP2K    ImageType = C.JP2K
+	ImageTypeJXL     ImageType = C.JXL
+	ImageTypeICO     ImageType = C.ICO
+	ImageTypePSD     ImageType = C.PSD // loaded through ImageMagick
+	ImageTypeRAW     ImageType = C.RAW // camera RAW, loaded through ImportParams.RawDecoder or ImageMagick
 )
 
 var imageTypeExtensionMap = map[ImageType]string{
@@ -59,6 +65,10 @@ var imageTypeExtensionMap = map[ImageType]string{
 	ImageTypeBMP:    ".bmp",
 	ImageTypeAVIF:   ".avif",
 	ImageTypeJP2K:   ".jp2",
+	ImageTypeJXL:    ".jxl",
+	ImageTypeICO:    ".ico",
+	ImageTypePSD:    ".psd",
+	ImageTypeRAW:    ".raw",
 }
 
 // ImageTypes defines the various image types supported by govips
@@ -75,21 +85,25 @@ var ImageTypes = map[ImageType]string{
 	ImageTypeBMP:    "bmp",
 	ImageTypeAVIF:   "heif",
 	ImageTypeJP2K:   "jp2k",
+	ImageTypeJXL:    "jxl",
+	ImageTypeICO:    "ico",
+	ImageTypePSD:    "psd",
+	ImageTypeRAW:    "cameraraw", // not libvips' rawload, RAW files are loaded through ImageMagick
 }
 
 // TiffCompression represents method for compressing a tiff at export
 type TiffCompression int
 
-// TiffCompression enum
+// TiffCompression enum. libvips writes no CCITT Group 3, bitonal images use Group 4 (TiffCompressionFax4).
 const (
 	TiffCompressionNone     TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_NONE
-	TiffCompressionJpeg     TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_JPEG
+	TiffCompressionJpeg     TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_JPEG // quality set by TiffExportParams.Quality
 	TiffCompressionDeflate  TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_DEFLATE
 	TiffCompressionPackbits TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_PACKBITS
-	TiffCompressionFax4     TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_CCITTFAX4
+	TiffCompressionFax4     TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_CCITTFAX4 // CCITT Group 4, 1-bit only
 	TiffCompressionLzw      TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_LZW
-	TiffCompressionWebp     TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_WEBP
-	TiffCompressionZstd     TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_ZSTD
+	TiffCompressionWebp     TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_WEBP // needs libtiff built with webp
+	TiffCompressionZstd     TiffCompression = C.VIPS_FOREIGN_TIFF_COMPRESSION_ZSTD // needs libtiff built with zstd
 )
 
 // TiffPredictor represents method for compressing a tiff at export
@@ -102,6 +116,17 @@ const (
 	TiffPredictorFloat      TiffPredictor = C.VIPS_FOREIGN_TIFF_PREDICTOR_FLOAT
 )
 
+// BitdepthReduction represents how an image is reduced to greyscale with 1, 2 or 4 bits per pixel at export
+type BitdepthReduction int
+
+// BitdepthReduction enum
+const (
+	// BitdepthReductionThreshold rounds to the nearest grey level, 1-bit output is split at the given threshold
+	BitdepthReductionThreshold BitdepthReduction = iota
+	// BitdepthReductionDither approximates the grey levels in between with ordered dithering
+	BitdepthReductionDither
+)
+
 // PngFilter represents filter algorithms that can be applied before compression.
 // See https://www.w3.org/TR/PNG-Filters.html
 type PngFilter int
@@ -116,6 +141,53 @@ const (
 	PngFilterAll   PngFilter = C.VIPS_FOREIGN_PNG_FILTER_ALL
 )
 
+// DeepZoomLayout represents the directory layout of a Deep Zoom pyramid
+type DeepZoomLayout int
+
+// DeepZoomLayout enum
+const (
+	DeepZoomLayoutDZ      DeepZoomLayout = C.VIPS_FOREIGN_DZ_LAYOUT_DZ
+	DeepZoomLayoutZoomify DeepZoomLayout = C.VIPS_FOREIGN_DZ_LAYOUT_ZOOMIFY
+	DeepZoomLayoutGoogle  DeepZoomLayout = C.VIPS_FOREIGN_DZ_LAYOUT_GOOGLE
+	DeepZoomLayoutIIIF    DeepZoomLayout = C.VIPS_FOREIGN_DZ_LAYOUT_IIIF
+)
+
+// DeepZoomDepth represents how deep a Deep Zoom pyramid goes
+type DeepZoomDepth int
+
+// DeepZoomDepth enum
+const (
+	DeepZoomDepthOnePixel DeepZoomDepth = C.VIPS_FOREIGN_DZ_DEPTH_ONEPIXEL
+	DeepZoomDepthOneTile  DeepZoomDepth = C.VIPS_FOREIGN_DZ_DEPTH_ONETILE
+	DeepZoomDepthOne      DeepZoomDepth = C.VIPS_FOREIGN_DZ_DEPTH_ONE
+)
+
+// DeepZoomContainer represents what a Deep Zoom pyramid is written to
+type DeepZoomContainer int
+
+// DeepZoomContainer enum
+const (
+	DeepZoomContainerFS  DeepZoomContainer = C.VIPS_FOREIGN_DZ_CONTAINER_FS
+	DeepZoomContainerZip DeepZoomContainer = C.VIPS_FOREIGN_DZ_CONTAINER_ZIP
+	DeepZoomContainerSZI DeepZoomContainer = C.VIPS_FOREIGN_DZ_CONTAINER_SZI
+)
+
+// Access represents VIPS_ACCESS type, the pixel access pattern a loader should prepare for
+type Access int
+
+// Access enum
+const (
+	AccessRandom               Access = C.VIPS_ACCESS_RANDOM
+	AccessSequential           Access = C.VIPS_ACCESS_SEQUENTIAL
+	AccessSequentialUnbuffered Access = C.VIPS_ACCESS_SEQUENTIAL_UNBUFFERED
+)
+
+var accessNames = map[Access]string{
+	AccessRandom:               "random",
+	AccessSequential:           "sequential",
+	AccessSequentialUnbuffered: "sequential-unbuffered",
+}
+
 // FileExt returns the canonical extension for the ImageType
 func (i ImageType) FileExt() string {
 	if ext, ok := imageTypeExtensionMap[i]; ok {
@@ -141,6 +213,9 @@ func DetermineImageType(buf []byte) ImageType {
 		return ImageTypePNG
 	} else if isGIF(buf) {
 		return ImageTypeGIF
+	} else if isRAW(buf) {
+		// only the proprietary containers libvips' TIFF loader can't read, checked before the TIFF signature they share
+		return ImageTypeRAW
 	} else if isTIFF(buf) {
 		return ImageTypeTIFF
 	} else if isWEBP(buf) {
@@ -157,6 +232,12 @@ func DetermineImageType(buf []byte) ImageType {
 		return ImageTypeBMP
 	} else if isJP2K(buf) {
 		return ImageTypeJP2K
+	} else if isJXL(buf) {
+		return ImageTypeJXL
+	} else if isICO(buf) {
+		return ImageTypeICO
+	} else if isPSD(buf) {
+		return ImageTypePSD
 	} else {
 		// BJG CHANGE: Use magick by default if everything fails
 		return ImageTypeMagick
@@ -212,6 +293,20 @@ func isAVIF(buf []byte) bool {
 	return bytes.Equal(buf[4:8], ftyp) && bytes.Equal(buf[8:12], avif)
 }
 
+var icoHeader = []byte("\x00\x00\x01\x00")
+
+func isICO(buf []byte) bool {
+	return bytes.HasPrefix(buf, icoHeader) && binary.LittleEndian.Uint16(buf[4:6]) > 0
+}
+
+// https://www.adobe.com/devnet-apps/photoshop/fileformatashtml/
+var psdHeader = []byte("8BPS")
+
+// isPSD matches Photoshop documents, version 1, and large documents (PSB), version 2
+func isPSD(buf []byte) bool {
+	return bytes.HasPrefix(buf, psdHeader) && (buf[4] == 0 && (buf[5] == 1 || buf[5] == 2))
+}
+
 var svg = []byte("<svg")
 
 func isSVG(buf []byte) bool {
@@ -253,6 +348,22 @@ func isJP2K(buf []byte) bool {
 	return bytes.HasPrefix(buf, jp2kHeader)
 }
 
+// https://github.com/libjxl/libjxl/blob/main/doc/format_overview.md
+var jxlCodestreamHeader = []byte("\xFF\x0A")
+var jxlContainerHeader = []byte("\x00\x00\x00\x0CJXL \x0D\x0A\x87\x0A")
+
+func isJXL(buf []byte) bool {
+	return bytes.HasPrefix(buf, jxlCodestreamHeader) || bytes.HasPrefix(buf, jxlContainerHeader)
+}
+
+// vipsFindLoad determines the image type of a file from the loader libvips picks for it
+func vipsFindLoad(filename string) ImageType {
+	cFileName := C.CString(filename)
+	defer freeCString(cFileName)
+
+	return imageTypeFromLoader(C.GoString(C.find_load_nickname(cFileName)))
+}
+
 func vipsLoadFromBuffer(buf []byte, params *ImportParams) (*C.VipsImage, ImageType, ImageType, error) {
 	src := buf
 	// Reference src here so it's not garbage collected during image initialization.
@@ -270,6 +381,40 @@ func vipsLoadFromBuffer(buf []byte, params *ImportParams) (*C.VipsImage, ImageTy
 		}
 
 		currentType = ImageTypePNG
+	} else if originalType == ImageTypeICO {
+		src, err = icoToPNG(src)
+		if err != nil {
+			return nil, currentType, originalType, err
+		}
+
+		currentType = ImageTypePNG
+	} else if (originalType == ImageTypeHEIF || originalType == ImageTypeAVIF) && params.HeifAuxiliary.IsSet() {
+		src, err = selectHeifAuxiliary(src, params.HeifAuxiliary.Get())
+		if err != nil {
+			return nil, currentType, originalType, err
+		}
+	} else if originalType == ImageTypePSD {
+		currentType = ImageTypeMagick
+		params = psdImportParams(params)
+	} else if originalType == ImageTypeRAW {
+		currentType = ImageTypeMagick
+		if params.RawDecoder != nil {
+			raw, err := params.RawDecoder(src)
+			if err != nil {
+				return nil, currentType, originalType, err
+			}
+			if raw == nil {
+				return nil, currentType, originalType, ErrInvalidRawImage
+			}
+
+			if len(raw.Encoded) == 0 {
+				img, err := vipsImageFromRaw(raw)
+				return img, ImageTypeUnknown, originalType, err
+			}
+
+			src = raw.Encoded
+			currentType = DetermineImageType(src)
+		}
 	}
 
 	if !IsTypeSupported(currentType) {
@@ -278,6 +423,7 @@ func vipsLoadFromBuffer(buf []byte, params *ImportParams) (*C.VipsImage, ImageTy
 	}
 
 	importParams := createImportParams(currentType, params)
+	defer freeImportParams(&importParams)
 
 	if err := C.load_from_buffer(&importParams, unsafe.Pointer(&src[0]), C.size_t(len(src))); err != 0 {
 		return nil, currentType, originalType, handleImageError(importParams.outputImage)
@@ -286,6 +432,17 @@ func vipsLoadFromBuffer(buf []byte, params *ImportParams) (*C.VipsImage, ImageTy
 	return importParams.outputImage, currentType, originalType, nil
 }
 
+// psdImportParams maps PsdLayer to the page ImageMagick loads, the flattened composite being its first page
+func psdImportParams(params *ImportParams) *ImportParams {
+	if !params.PsdLayer.IsSet() || params.Page.IsSet() {
+		return params
+	}
+
+	psdParams := *params
+	psdParams.Page.Set(params.PsdLayer.Get() + 1)
+	return &psdParams
+}
+
 func bmpToPNG(src []byte) ([]byte, error) {
 	i, err := bmp.Decode(bytes.NewReader(src))
 	if err != nil {
@@ -322,20 +479,66 @@ func createImportParams(format ImageType, params *ImportParams) C.LoadParams {
 	maybeSetBoolParam(params.AutoRotate, &p.autorotate)
 	maybeSetBoolParam(params.FailOnError, &p.fail)
 	maybeSetIntParam(params.Page, &p.page)
+	if format == ImageTypeJP2K {
+		// jp2kload uses page to select the resolution level of the pyramid
+		maybeSetIntParam(params.Jp2kReduction, &p.page)
+	}
+	if format == ImageTypeTIFF {
+		maybeSetBoolParam(params.TiffAutorotate, &p.autorotate)
+		maybeSetIntParam(params.TiffSubifd, &p.tiffSubifd)
+	}
 	maybeSetIntParam(params.NumPages, &p.n)
 	maybeSetIntParam(params.JpegShrinkFactor, &p.jpegShrink)
 	maybeSetBoolParam(params.HeifThumbnail, &p.heifThumbnail)
 	maybeSetBoolParam(params.SvgUnlimited, &p.svgUnlimited)
+	if params.Access.IsSet() {
+		C.set_int_param(&p.access, C.gint(params.Access.Get()))
+	}
+	maybeSetBoolParam(params.Revalidate, &p.revalidate)
+
+	if params.WebpScale.IsSet() {
+		C.set_double_param(&p.webpScale, C.gdouble(params.WebpScale.Get()))
+	} else if params.WebpShrink.IsSet() && params.WebpShrink.Get() > 0 {
+		C.set_double_param(&p.webpScale, C.gdouble(1/float64(params.WebpShrink.Get())))
+	}
 
 	if params.Density.IsSet() {
 		C.set_double_param(&p.dpi, C.gdouble(params.Density.Get()))
 	}
+
+	if format == ImageTypePDF {
+		if params.PdfPassword.IsSet() {
+			p.pdfPassword = C.CString(params.PdfPassword.Get())
+		}
+		if params.PdfBackground.IsSet() {
+			c := params.PdfBackground.Get()
+			p.pdfBackgroundSet = C.TRUE
+			p.pdfBackground = [4]C.double{C.double(c.R), C.double(c.G), C.double(c.B), C.double(c.A)}
+		}
+	}
 	return p
 }
 
+// freeImportParams releases the memory allocated by createImportParams
+func freeImportParams(p *C.LoadParams) {
+	if p.pdfPassword != nil {
+		freeCString(p.pdfPassword)
+	}
+}
+
 func vipsSaveJPEGToBuffer(in *C.VipsImage, params JpegExportParams) ([]byte, error) {
 	incOpCounter("save_jpeg_buffer")
 
+	return vipsSaveToBuffer(createJpegSaveParams(in, params))
+}
+
+func vipsSaveJPEGToWriter(in *C.VipsImage, params JpegExportParams, w io.Writer) error {
+	incOpCounter("save_jpeg_target")
+
+	return vipsSaveToWriter(createJpegSaveParams(in, params), w)
+}
+
+func createJpegSaveParams(in *C.VipsImage, params JpegExportParams) C.struct_SaveParams {
 	p := C.create_save_params(C.JPEG)
 	p.inputImage = in
 	p.stripMetadata = C.int(boolToInt(params.StripMetadata))
@@ -348,12 +551,22 @@ func vipsSaveJPEGToBuffer(in *C.VipsImage, params JpegExportParams) ([]byte, err
 	p.jpegOptimizeScans = C.int(boolToInt(params.OptimizeScans))
 	p.jpegQuantTable = C.int(params.QuantTable)
 
-	return vipsSaveToBuffer(p)
+	return p
 }
 
 func vipsSavePNGToBuffer(in *C.VipsImage, params PngExportParams) ([]byte, error) {
 	incOpCounter("save_png_buffer")
 
+	return vipsSaveToBuffer(createPngSaveParams(in, params))
+}
+
+func vipsSavePNGToWriter(in *C.VipsImage, params PngExportParams, w io.Writer) error {
+	incOpCounter("save_png_target")
+
+	return vipsSaveToWriter(createPngSaveParams(in, params), w)
+}
+
+func createPngSaveParams(in *C.VipsImage, params PngExportParams) C.struct_SaveParams {
 	p := C.create_save_params(C.PNG)
 	p.inputImage = in
 	p.quality = C.int(params.Quality)
@@ -365,12 +578,33 @@ func vipsSavePNGToBuffer(in *C.VipsImage, params PngExportParams) ([]byte, error
 	p.pngDither = C.double(params.Dither)
 	p.pngBitdepth = C.int(params.Bitdepth)
 
-	return vipsSaveToBuffer(p)
+	return p
 }
 
 func vipsSaveWebPToBuffer(in *C.VipsImage, params WebpExportParams) ([]byte, error) {
 	incOpCounter("save_webp_buffer")
 
+	p := createWebpSaveParams(in, params)
+	if p.webpIccProfile != nil {
+		defer C.free(unsafe.Pointer(p.webpIccProfile))
+	}
+
+	return vipsSaveToBuffer(p)
+}
+
+func vipsSaveWebPToWriter(in *C.VipsImage, params WebpExportParams, w io.Writer) error {
+	incOpCounter("save_webp_target")
+
+	p := createWebpSaveParams(in, params)
+	if p.webpIccProfile != nil {
+		defer C.free(unsafe.Pointer(p.webpIccProfile))
+	}
+
+	return vipsSaveToWriter(p, w)
+}
+
+// createWebpSaveParams allocates webpIccProfile, which the caller must free
+func createWebpSaveParams(in *C.VipsImage, params WebpExportParams) C.struct_SaveParams {
 	p := C.create_save_params(C.WEBP)
 	p.inputImage = in
 	p.stripMetadata = C.int(boolToInt(params.StripMetadata))
@@ -380,11 +614,10 @@ func vipsSaveWebPToBuffer(in *C.VipsImage, params WebpExportParams) ([]byte, err
 	p.webpReductionEffort = C.int(params.ReductionEffort)
 
 	if params.IccProfile != "" {
-		p.webpIccProfile = C.CString(params.IccProfile)
-		defer C.free(unsafe.Pointer(p.webpIccProfile))
+		p.webpIccProfile = C.CString(iccProfilePath(params.IccProfile))
 	}
 
-	return vipsSaveToBuffer(p)
+	return p
 }
 
 func vipsSaveTIFFToBuffer(in *C.VipsImage, params TiffExportParams) ([]byte, error) {
@@ -395,6 +628,13 @@ func vipsSaveTIFFToBuffer(in *C.VipsImage, params TiffExportParams) ([]byte, err
 	p.stripMetadata = C.int(boolToInt(params.StripMetadata))
 	p.quality = C.int(params.Quality)
 	p.tiffCompression = C.VipsForeignTiffCompression(params.Compression)
+	// the zero value is no valid predictor, libvips' default applies
+	if params.Predictor != 0 {
+		p.tiffPredictor = C.VipsForeignTiffPredictor(params.Predictor)
+	}
+	p.tiffBitdepth = C.int(params.Bitdepth)
+	p.tiffLevel = C.int(params.Level)
+	p.tiffLossless = C.int(boolToInt(params.Lossless))
 
 	return vipsSaveToBuffer(p)
 }
@@ -404,6 +644,7 @@ func vipsSaveHEIFToBuffer(in *C.VipsImage, params HeifExportParams) ([]byte, err
 
 	p := C.create_save_params(C.HEIF)
 	p.inputImage = in
+	p.stripMetadata = C.int(boolToInt(params.StripMetadata))
 	p.outputFormat = C.HEIF
 	p.quality = C.int(params.Quality)
 	p.heifLossless = C.int(boolToInt(params.Lossless))
@@ -416,6 +657,7 @@ func vipsSaveAVIFToBuffer(in *C.VipsImage, params AvifExportParams) ([]byte, err
 
 	p := C.create_save_params(C.AVIF)
 	p.inputImage = in
+	p.stripMetadata = C.int(boolToInt(params.StripMetadata))
 	p.outputFormat = C.AVIF
 	p.quality = C.int(params.Quality)
 	p.heifLossless = C.int(boolToInt(params.Lossless))
@@ -439,11 +681,37 @@ func vipsSaveJP2KToBuffer(in *C.VipsImage, params Jp2kExportParams) ([]byte, err
 	return vipsSaveToBuffer(p)
 }
 
+func vipsSaveJXLToBuffer(in *C.VipsImage, params JxlExportParams) ([]byte, error) {
+	incOpCounter("save_jxl_buffer")
+
+	p := C.create_save_params(C.JXL)
+	p.inputImage = in
+	p.jxlDistance = C.double(params.Distance)
+	if params.Quality > 0 {
+		p.jxlDistance = C.double(jxlQualityDistance(params.Quality))
+	}
+	p.jxlEffort = C.int(params.Effort)
+	p.jxlLossless = C.int(boolToInt(params.Lossless))
+	p.jxlTier = C.int(params.Tier)
+
+	return vipsSaveToBuffer(p)
+}
+
+// jxlQualityDistance maps a quality factor to a butteraugli distance, the way libvips and cjxl do
+func jxlQualityDistance(quality int) float64 {
+	q := float64(quality)
+	if q >= 30 {
+		return 0.1 + (100-q)*0.09
+	}
+	return 53.0/3000.0*q*q - 23.0/20.0*q + 25.0
+}
+
 func vipsSaveGIFToBuffer(in *C.VipsImage, params GifExportParams) ([]byte, error) {
 	incOpCounter("save_gif_buffer")
 
 	p := C.create_save_params(C.GIF)
 	p.inputImage = in
+	p.stripMetadata = C.int(boolToInt(params.StripMetadata))
 	p.quality = C.int(params.Quality)
 	p.gifDither = C.double(params.Dither)
 	p.gifEffort = C.int(params.Effort)
@@ -452,6 +720,52 @@ func vipsSaveGIFToBuffer(in *C.VipsImage, params GifExportParams) ([]byte, error
 	return vipsSaveToBuffer(p)
 }
 
+func createDzSaveParams(params DeepZoomExportParams) C.DzSaveParams {
+	return C.DzSaveParams{
+		tileSize:      C.int(params.TileSize),
+		overlap:       C.int(params.Overlap),
+		depth:         C.VipsForeignDzDepth(params.Depth),
+		suffix:        C.CString(params.Suffix),
+		layout:        C.VipsForeignDzLayout(params.Layout),
+		container:     C.VipsForeignDzContainer(params.Container),
+		stripMetadata: C.int(boolToInt(params.StripMetadata)),
+	}
+}
+
+// https://www.libvips.org/API/current/VipsForeignSave.html#vips-dzsave
+func vipsDzSave(in *C.VipsImage, name string, params DeepZoomExportParams) error {
+	incOpCounter("dzsave")
+
+	p := createDzSaveParams(params)
+	defer freeCString(p.suffix)
+
+	cName := C.CString(name)
+	defer freeCString(cName)
+
+	if err := C.dzsave(in, cName, &p); err != 0 {
+		return handleVipsError()
+	}
+
+	return nil
+}
+
+// https://www.libvips.org/API/current/VipsForeignSave.html#vips-dzsave-buffer
+func vipsDzSaveToBuffer(in *C.VipsImage, params DeepZoomExportParams) ([]byte, error) {
+	incOpCounter("dzsave_buffer")
+	var ptr unsafe.Pointer
+	var length C.size_t
+
+	p := createDzSaveParams(params)
+	defer freeCString(p.suffix)
+
+	if err := C.dzsave_buffer(in, &ptr, &length, &p); err != 0 {
+		return nil, handleSaveBufferError(ptr)
+	}
+	defer gFreePointer(ptr)
+
+	return C.GoBytes(ptr, C.int(length)), nil
+}
+
 func vipsSaveToBuffer(params C.struct_SaveParams) ([]byte, error) {
 	if err := C.save_to_buffer(&params); err != 0 {
 		return nil, handleSaveBufferError(params.outputBuffer)
@@ -462,3 +776,21 @@ func vipsSaveToBuffer(params C.struct_SaveParams) ([]byte, error) {
 
 	return buf, nil
 }
+
+func vipsSaveToWriter(params C.struct_SaveParams, w io.Writer) error {
+	target, writer := newWriterTarget(w)
+	defer C.g_object_unref(C.gpointer(target))
+
+	params.outputTarget = (*C.VipsTarget)(unsafe.Pointer(target))
+
+	if err := C.save_to_target(&params); err != 0 {
+		if writer.err != nil {
+			// drain the libvips error buffer, the write error is more useful
+			_ = handleVipsError()
+			return writer.err
+		}
+		return handleVipsError()
+	}
+
+	return writer.err
+}
@@ -25,7 +25,11 @@ typedef enum types {
   HEIF,
   BMP,
   AVIF,
-  JP2K
+  JP2K,
+  JXL,
+  ICO,
+  PSD,
+  RAW
 } ImageType;
 
 typedef enum ParamType {
@@ -65,17 +69,28 @@ typedef struct LoadParams {
   Param jpegShrink;
   Param heifThumbnail;
   Param svgUnlimited;
+  Param webpScale;
+  Param tiffSubifd;
+  Param access;
+  Param revalidate;
+
+  // PDF, pdfPassword is NULL when unset
+  char *pdfPassword;
+  gboolean pdfBackgroundSet;
+  double pdfBackground[4];
 
 } LoadParams;
 
 LoadParams create_load_params(ImageType inputFormat);
 int load_from_buffer(LoadParams *params, void *buf, size_t len);
+const char *find_load_nickname(const char *filename);
 
 typedef struct SaveParams {
   VipsImage *inputImage;
   void *outputBuffer;
   ImageType outputFormat;
   size_t outputLen;
+  VipsTarget *outputTarget;
 
   BOOL stripMetadata;
   int quality;
@@ -119,6 +134,9 @@ typedef struct SaveParams {
   int tiffTileWidth;
   double tiffXRes;
   double tiffYRes;
+  int tiffBitdepth;
+  int tiffLevel;
+  BOOL tiffLossless;
 
   // AVIF
   int avifSpeed;
@@ -127,8 +145,29 @@ typedef struct SaveParams {
   BOOL jp2kLossless;
   int jp2kTileWidth;
   int	jp2kTileHeight;
+
+  // JPEG XL
+  double jxlDistance;
+  int jxlEffort;
+  BOOL jxlLossless;
+  int jxlTier;
 } SaveParams;
 
 SaveParams create_save_params(ImageType outputFormat);
 int save_to_buffer(SaveParams *params);
+int save_to_target(SaveParams *params);
+
+
+typedef struct DzSaveParams {
+  int tileSize;
+  int overlap;
+  VipsForeignDzDepth depth;
+  const char *suffix;
+  VipsForeignDzLayout layout;
+  VipsForeignDzContainer container;
+  BOOL stripMetadata;
+} DzSaveParams;
 
+int dzsave(VipsImage *in, const char *name, DzSaveParams *params);
+int dzsave_buffer(VipsImage *in, void **buf, size_t *len,
+                  DzSaveParams *params);
@@ -138,3 +138,31 @@ func Test_DetermineImageType__JP2K(t *testing.T) {
 	imageType := DetermineImageType(buf)
 	assert.Equal(t, ImageTypeJP2K, imageType)
 }
+
+func Test_DetermineImageType__JXL(t *testing.T) {
+	Startup(&Config{})
+
+	codestream := append([]byte{0xFF, 0x0A}, make([]byte, 16)...)
+	assert.Equal(t, ImageTypeJXL, DetermineImageType(codestream))
+
+	container := append([]byte("\x00\x00\x00\x0CJXL \x0D\x0A\x87\x0A"), make([]byte, 16)...)
+	assert.Equal(t, ImageTypeJXL, DetermineImageType(container))
+}
+
+func Test_DetermineImageType__PSD(t *testing.T) {
+	Startup(&Config{})
+
+	psd := append([]byte("8BPS\x00\x01"), make([]byte, 20)...)
+	assert.Equal(t, ImageTypePSD, DetermineImageType(psd))
+
+	psb := append([]byte("8BPS\x00\x02"), make([]byte, 20)...)
+	assert.Equal(t, ImageTypePSD, DetermineImageType(psb))
+
+	params := NewImportParams()
+	params.PsdLayer.Set(2)
+	assert.Equal(t, 3, psdImportParams(params).Page.Get())
+	assert.False(t, params.Page.IsSet())
+
+	params.Page.Set(0)
+	assert.Equal(t, 0, psdImportParams(params).Page.Get())
+}
@@ -0,0 +1,22 @@
+#include "freqfilt.h"
+
+int fwfft_image(VipsImage *in, VipsImage **out) {
+  return vips_fwfft(in, out, NULL);
+}
+
+int invfft_image(VipsImage *in, VipsImage **out, gboolean real) {
+  return vips_invfft(in, out, "real", real, NULL);
+}
+
+int freqmult_image(VipsImage *in, VipsImage *mask, VipsImage **out) {
+  return vips_freqmult(in, mask, out, NULL);
+}
+
+int spectrum_image(VipsImage *in, VipsImage **out) {
+  return vips_spectrum(in, out, NULL);
+}
+
+int complexget_image(VipsImage *in, VipsImage **out,
+                     VipsOperationComplexget get) {
+  return vips_complexget(in, out, get, NULL);
+}
@@ -0,0 +1,140 @@
+package vips
+
+// #include "freqfilt.h"
+import "C"
+import "fmt"
+
+// ComplexGet represents VIPS_OPERATION_COMPLEXGET type, a part of complex pixels
+type ComplexGet int
+
+// ComplexGet enum
+const (
+	ComplexGetReal ComplexGet = C.VIPS_OPERATION_COMPLEXGET_REAL
+	ComplexGetImag ComplexGet = C.VIPS_OPERATION_COMPLEXGET_IMAG
+)
+
+// IsComplex returns whether the pixels of the image are complex numbers, e.g. after Fwfft. Most operations and all
+// savers need real pixels, see ComplexGet and Invfft.
+func (r *ImageRef) IsComplex() bool {
+	format := r.BandFormat()
+	return format == BandFormatComplex || format == BandFormatDpComplex
+}
+
+// Fwfft replaces the image by its Fourier transform, computed for each band. The result is a double complex image,
+// with the zero frequency at the top left, see Spectrum to display it. Requires libvips built with FFTW.
+func (r *ImageRef) Fwfft() error {
+	out, err := vipsFwfft(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Invfft replaces a Fourier transform by the image it is the transform of. With realOnly the result is a double
+// image of the real parts, otherwise a double complex image. Requires libvips built with FFTW.
+func (r *ImageRef) Invfft(realOnly bool) error {
+	out, err := vipsInvfft(r.image, realOnly)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// FreqMult filters the image in the frequency domain: its Fourier transform, computed unless the image is already
+// complex, is multiplied by mask and transformed back. The mask must have the size of the image, with the zero
+// frequency at the top left, e.g. 1 everywhere but 0 on the peaks of a periodic noise pattern found with Spectrum.
+// The result is a double image. Requires libvips built with FFTW.
+func (r *ImageRef) FreqMult(mask *ImageRef) error {
+	if mask.Width() != r.Width() || mask.Height() != r.Height() {
+		return fmt.Errorf("mask size %dx%d does not match image size %dx%d", mask.Width(), mask.Height(), r.Width(), r.Height())
+	}
+	out, err := vipsFreqMult(r.image, mask.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Spectrum replaces the image by the displayable power spectrum of its Fourier transform, an 8-bit image scaled
+// logarithmically with the zero frequency moved to the center. Requires libvips built with FFTW.
+func (r *ImageRef) Spectrum() error {
+	out, err := vipsSpectrum(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// ComplexGet replaces a complex image by the real or imaginary part of its pixels
+func (r *ImageRef) ComplexGet(part ComplexGet) error {
+	out, err := vipsComplexGet(r.image, part)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-freqfilt.html#vips-fwfft
+func vipsFwfft(in *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("fwfft")
+	var out *C.VipsImage
+
+	if err := C.fwfft_image(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-freqfilt.html#vips-invfft
+func vipsInvfft(in *C.VipsImage, realOnly bool) (*C.VipsImage, error) {
+	incOpCounter("invfft")
+	var out *C.VipsImage
+
+	if err := C.invfft_image(in, &out, toGboolean(realOnly)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-freqfilt.html#vips-freqmult
+func vipsFreqMult(in, mask *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("freqmult")
+	var out *C.VipsImage
+
+	if err := C.freqmult_image(in, mask, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-freqfilt.html#vips-spectrum
+func vipsSpectrum(in *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("spectrum")
+	var out *C.VipsImage
+
+	if err := C.spectrum_image(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-arithmetic.html#vips-complexget
+func vipsComplexGet(in *C.VipsImage, part ComplexGet) (*C.VipsImage, error) {
+	incOpCounter("complexget")
+	var out *C.VipsImage
+
+	if err := C.complexget_image(in, &out, C.VipsOperationComplexget(part)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,11 @@
+// https://libvips.github.io/libvips/API/current/libvips-freqfilt.html
+
+#include <stdlib.h>
+#include <vips/vips.h>
+
+int fwfft_image(VipsImage *in, VipsImage **out);
+int invfft_image(VipsImage *in, VipsImage **out, gboolean real);
+int freqmult_image(VipsImage *in, VipsImage *mask, VipsImage **out);
+int spectrum_image(VipsImage *in, VipsImage **out);
+int complexget_image(VipsImage *in, VipsImage **out,
+                     VipsOperationComplexget get);
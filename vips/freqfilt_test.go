@@ -0,0 +1,89 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRef_FFT(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+	err = img.ToColorSpace(InterpretationBW)
+	require.NoError(t, err)
+
+	original, err := img.GetPoint(30, 40)
+	require.NoError(t, err)
+
+	transform, err := img.Copy()
+	require.NoError(t, err)
+	defer transform.Close()
+
+	err = transform.Fwfft()
+	require.NoError(t, err)
+	assert.True(t, transform.IsComplex())
+	assert.Equal(t, BandFormatDpComplex, transform.BandFormat())
+
+	spectrum, err := transform.Copy()
+	require.NoError(t, err)
+	defer spectrum.Close()
+	err = spectrum.Spectrum()
+	require.NoError(t, err)
+	assert.False(t, spectrum.IsComplex())
+
+	err = transform.Invfft(true)
+	require.NoError(t, err)
+	assert.False(t, transform.IsComplex())
+
+	point, err := transform.GetPoint(30, 40)
+	require.NoError(t, err)
+	assert.InDelta(t, original[0], point[0], 0.5)
+
+	mask, err := Black(img.Width(), img.Height())
+	require.NoError(t, err)
+	defer mask.Close()
+	err = mask.Linear([]float64{1}, []float64{1})
+	require.NoError(t, err)
+
+	err = img.FreqMult(mask)
+	require.NoError(t, err)
+
+	point, err = img.GetPoint(30, 40)
+	require.NoError(t, err)
+	assert.InDelta(t, original[0], point[0], 0.5)
+
+	small, err := Black(10, 10)
+	require.NoError(t, err)
+	defer small.Close()
+	err = img.FreqMult(small)
+	assert.Error(t, err)
+}
+
+func TestImageRef_ComplexGet(t *testing.T) {
+	Startup(nil)
+
+	img, err := Black(8, 8)
+	require.NoError(t, err)
+	err = img.Linear([]float64{1}, []float64{5})
+	require.NoError(t, err)
+
+	err = img.Fwfft()
+	require.NoError(t, err)
+
+	realPart, err := img.Copy()
+	require.NoError(t, err)
+	defer realPart.Close()
+	err = realPart.ComplexGet(ComplexGetReal)
+	require.NoError(t, err)
+	assert.False(t, realPart.IsComplex())
+
+	err = img.ComplexGet(ComplexGetImag)
+	require.NoError(t, err)
+
+	point, err := img.GetPoint(3, 3)
+	require.NoError(t, err)
+	assert.InDelta(t, 0, point[0], 0.001)
+}
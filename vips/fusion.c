@@ -0,0 +1,232 @@
+#include "fusion.h"
+
+// sigma of the gaussian curve rating how close a value is to mid-grey
+#define FUSION_EXPOSURE_SIGMA 0.2
+
+// keeps every pixel weighted, so that the weights can always be normalized
+#define FUSION_WEIGHT_EPSILON 1e-12
+
+// the sRGB bands of an image as float between 0 and 1, dropping any alpha
+static int fusion_rgb(VipsObject *base, VipsImage *in, VipsImage **out) {
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 3);
+
+  if (
+    vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL) ||
+    vips_extract_band(t[0], &t[1], 0, "n", 3, NULL) ||
+    vips_cast(t[1], &t[2], VIPS_FORMAT_FLOAT, NULL) ||
+    vips_linear1(t[2], out, 1.0 / 255.0, 0.0, NULL)
+  ) {
+    return -1;
+  }
+
+  return 0;
+}
+
+// raises a quality measure to its weight, 1 for a weight of 0
+static int fusion_pow(VipsImage *in, VipsImage **out, double weight) {
+  if (weight == 1.0) {
+    return vips_copy(in, out, NULL);
+  }
+  return vips_pow_const1(in, out, weight, NULL);
+}
+
+// the product of the contrast, saturation and well-exposedness measures of
+// an image, each raised to its weight
+static int fusion_weight(VipsObject *base, VipsImage *rgb, VipsImage **out,
+                         double contrast_weight, double saturation_weight,
+                         double exposure_weight) {
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 20);
+
+  t[0] = vips_image_new_matrixv(3, 3,
+    0.0,  1.0, 0.0,
+    1.0, -4.0, 1.0,
+    0.0,  1.0, 0.0);
+
+  if (
+    // contrast: absolute response of a Laplacian filter on the grey level
+    vips_bandmean(rgb, &t[1], NULL) ||
+    vips_conv(t[1], &t[2], t[0], "precision", VIPS_PRECISION_FLOAT, NULL) ||
+    vips_abs(t[2], &t[3], NULL) ||
+    fusion_pow(t[3], &t[4], contrast_weight) ||
+    // saturation: standard deviation across the bands
+    vips_subtract(rgb, t[1], &t[5], NULL) ||
+    vips_multiply(t[5], t[5], &t[6], NULL) ||
+    vips_bandmean(t[6], &t[7], NULL) ||
+    vips_pow_const1(t[7], &t[8], 0.5, NULL) ||
+    fusion_pow(t[8], &t[9], saturation_weight) ||
+    // well-exposedness: product of a gaussian curve around 0.5 over the
+    // bands, computed as the exponential of the sum of the exponents
+    vips_linear1(rgb, &t[10], 1.0, -0.5, NULL) ||
+    vips_multiply(t[10], t[10], &t[11], NULL) ||
+    vips_bandmean(t[11], &t[12], NULL) ||
+    vips_linear1(t[12], &t[13],
+                 -3.0 / (2.0 * FUSION_EXPOSURE_SIGMA * FUSION_EXPOSURE_SIGMA),
+                 0.0, NULL) ||
+    vips_exp(t[13], &t[14], NULL) ||
+    fusion_pow(t[14], &t[15], exposure_weight) ||
+    vips_multiply(t[4], t[9], &t[16], NULL) ||
+    vips_multiply(t[16], t[15], &t[17], NULL) ||
+    vips_linear1(t[17], out, 1.0, FUSION_WEIGHT_EPSILON, NULL)
+  ) {
+    return -1;
+  }
+
+  return 0;
+}
+
+// blurs and halves an image, the next level of a gaussian pyramid
+static int fusion_down(VipsObject *base, VipsImage *in, VipsImage **out) {
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 1);
+
+  if (
+    vips_gaussblur(in, &t[0], 1.0, NULL) ||
+    vips_subsample(t[0], out, 2, 2, NULL)
+  ) {
+    return -1;
+  }
+
+  return 0;
+}
+
+// scales an image up to exactly the given size
+static int fusion_up(VipsImage *in, VipsImage **out, int width, int height) {
+  return vips_resize(in, out, (double) width / in->Xsize,
+                     "vscale", (double) height / in->Ysize,
+                     "kernel", VIPS_KERNEL_LINEAR, NULL);
+}
+
+// gaussian pyramid of an image, levels[0] being the image itself
+static int gaussian_pyramid(VipsObject *base, VipsImage *in,
+                            VipsImage **levels, int n) {
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, n);
+  int i;
+
+  levels[0] = in;
+  for (i = 1; i < n; i++) {
+    if (fusion_down(base, levels[i - 1], &t[i])) {
+      return -1;
+    }
+    levels[i] = t[i];
+  }
+
+  return 0;
+}
+
+// laplacian pyramid of an image, the last level being the coarsest gaussian
+// level
+static int laplacian_pyramid(VipsObject *base, VipsImage *in,
+                             VipsImage **levels, int n) {
+  VipsImage *gaussian[FUSION_MAX_LEVELS];
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 2 * n);
+  int i;
+
+  if (gaussian_pyramid(base, in, gaussian, n)) {
+    return -1;
+  }
+
+  for (i = 0; i < n - 1; i++) {
+    if (
+      fusion_up(gaussian[i + 1], &t[2 * i], gaussian[i]->Xsize,
+                gaussian[i]->Ysize) ||
+      vips_subtract(gaussian[i], t[2 * i], &t[2 * i + 1], NULL)
+    ) {
+      return -1;
+    }
+    levels[i] = t[2 * i + 1];
+  }
+  levels[n - 1] = gaussian[n - 1];
+
+  return 0;
+}
+
+// Blends the laplacian pyramids of the images weighted by the gaussian
+// pyramids of their normalized quality measures, then collapses the result.
+// The images must have the same size. The result is 8-bit sRGB.
+int merge_exposures(VipsImage **in, int n, VipsImage **out,
+                    double contrast_weight, double saturation_weight,
+                    double exposure_weight, int levels) {
+  VipsImage *base = vips_image_new();
+  VipsImage **rgb = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), n);
+  VipsImage **weight = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), n);
+  VipsImage **normalized = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), n);
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3 * FUSION_MAX_LEVELS + 4);
+  VipsImage *acc[FUSION_MAX_LEVELS];
+  VipsImage *result;
+  int i, k;
+
+  if (levels > FUSION_MAX_LEVELS) {
+    levels = FUSION_MAX_LEVELS;
+  }
+
+  for (i = 0; i < n; i++) {
+    if (
+      fusion_rgb(VIPS_OBJECT(base), in[i], &rgb[i]) ||
+      fusion_weight(VIPS_OBJECT(base), rgb[i], &weight[i], contrast_weight,
+                    saturation_weight, exposure_weight)
+    ) {
+      g_object_unref(base);
+      return -1;
+    }
+  }
+
+  if (vips_sum(weight, &t[0], n, NULL)) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  for (i = 0; i < n; i++) {
+    VipsImage *lap[FUSION_MAX_LEVELS];
+    VipsImage *gauss[FUSION_MAX_LEVELS];
+    VipsImage **blended = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2 * levels);
+
+    if (
+      vips_divide(weight[i], t[0], &normalized[i], NULL) ||
+      laplacian_pyramid(VIPS_OBJECT(base), rgb[i], lap, levels) ||
+      gaussian_pyramid(VIPS_OBJECT(base), normalized[i], gauss, levels)
+    ) {
+      g_object_unref(base);
+      return -1;
+    }
+
+    for (k = 0; k < levels; k++) {
+      if (vips_multiply(lap[k], gauss[k], &blended[2 * k], NULL)) {
+        g_object_unref(base);
+        return -1;
+      }
+
+      if (i == 0) {
+        acc[k] = blended[2 * k];
+      } else {
+        if (vips_add(acc[k], blended[2 * k], &blended[2 * k + 1], NULL)) {
+          g_object_unref(base);
+          return -1;
+        }
+        acc[k] = blended[2 * k + 1];
+      }
+    }
+  }
+
+  // collapse the pyramid from the coarsest level
+  result = acc[levels - 1];
+  for (k = levels - 2; k >= 0; k--) {
+    if (
+      fusion_up(result, &t[1 + 2 * k], acc[k]->Xsize, acc[k]->Ysize) ||
+      vips_add(acc[k], t[1 + 2 * k], &t[2 + 2 * k], NULL)
+    ) {
+      g_object_unref(base);
+      return -1;
+    }
+    result = t[2 + 2 * k];
+  }
+
+  if (
+    vips_linear1(result, &t[2 * FUSION_MAX_LEVELS + 1], 255.0, 0.5, "uchar", TRUE, NULL) ||
+    vips_copy(t[2 * FUSION_MAX_LEVELS + 1], out, "interpretation", VIPS_INTERPRETATION_sRGB, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
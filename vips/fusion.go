@@ -0,0 +1,95 @@
+package vips
+
+// #include "fusion.h"
+import "C"
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// MergeExposuresParams are the weights of the quality measures rating the pixels of each exposure, 0 ignoring a
+// measure, and the number of pyramid levels blending them
+type MergeExposuresParams struct {
+	// ContrastWeight favors detailed areas, measured by a Laplacian filter
+	ContrastWeight float64
+	// SaturationWeight favors vivid colors, measured by the standard deviation across the bands
+	SaturationWeight float64
+	// ExposureWeight favors values close to mid-grey
+	ExposureWeight float64
+	// Levels of the pyramids, 0 for as many as the image size allows; fewer levels blend less smoothly
+	Levels int
+}
+
+// NewMergeExposuresParams creates default parameters, weighting all measures equally
+func NewMergeExposuresParams() *MergeExposuresParams {
+	return &MergeExposuresParams{
+		ContrastWeight:   1,
+		SaturationWeight: 1,
+		ExposureWeight:   1,
+	}
+}
+
+// fusionMinLevelSize is the smallest size of the coarsest pyramid level when choosing the number of levels
+const fusionMinLevelSize = 8
+
+// MergeExposures fuses bracketed exposures of the same scene into a single well exposed image, following Mertens,
+// Kautz and Van Reeth, "Exposure Fusion": each pixel is weighted by its contrast, saturation and well-exposedness,
+// and the images are blended through Laplacian pyramids to avoid seams. Unlike HDR merging it needs no exposure
+// times or tone mapping. The images must be aligned and have the same size; alpha is ignored.
+// The result is an 8-bit sRGB image. params may be nil for the defaults.
+func MergeExposures(images []*ImageRef, params *MergeExposuresParams) (*ImageRef, error) {
+	if len(images) < 2 {
+		return nil, errors.New("at least two exposures are needed")
+	}
+	if params == nil {
+		params = NewMergeExposuresParams()
+	}
+	if params.ContrastWeight < 0 || params.SaturationWeight < 0 || params.ExposureWeight < 0 {
+		return nil, errors.New("exposure fusion weights must not be negative")
+	}
+
+	width, height := images[0].Width(), images[0].Height()
+	ins := make([]*C.VipsImage, len(images))
+	for i, img := range images {
+		if img.Width() != width || img.Height() != height {
+			return nil, fmt.Errorf("exposure %d size %dx%d does not match %dx%d", i, img.Width(), img.Height(), width, height)
+		}
+		ins[i] = img.image
+	}
+
+	levels := params.Levels
+	if levels <= 0 {
+		levels = fusionLevels(width, height)
+	}
+	if levels > C.FUSION_MAX_LEVELS {
+		levels = C.FUSION_MAX_LEVELS
+	}
+
+	out, err := vipsMergeExposures(ins, params.ContrastWeight, params.SaturationWeight, params.ExposureWeight, levels)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(out, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// fusionLevels returns the number of pyramid levels halving the image down to at least fusionMinLevelSize pixels
+func fusionLevels(width, height int) int {
+	size := minInt(width, height)
+	if size < 2*fusionMinLevelSize {
+		return 1
+	}
+	return int(math.Log2(float64(size)/fusionMinLevelSize)) + 1
+}
+
+func vipsMergeExposures(ins []*C.VipsImage, contrastWeight, saturationWeight, exposureWeight float64, levels int) (*C.VipsImage, error) {
+	incOpCounter("mergeExposures")
+	var out *C.VipsImage
+
+	if err := C.merge_exposures(&ins[0], C.int(len(ins)), &out, C.double(contrastWeight), C.double(saturationWeight),
+		C.double(exposureWeight), C.int(levels)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
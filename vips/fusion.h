@@ -0,0 +1,10 @@
+// Mertens, Kautz and Van Reeth, "Exposure Fusion"
+
+#include <stdlib.h>
+#include <vips/vips.h>
+
+#define FUSION_MAX_LEVELS 16
+
+int merge_exposures(VipsImage **in, int n, VipsImage **out,
+                    double contrast_weight, double saturation_weight,
+                    double exposure_weight, int levels);
@@ -0,0 +1,46 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeExposures(t *testing.T) {
+	Startup(nil)
+
+	var exposures []*ImageRef
+	for _, gain := range []float64{0.5, 1, 1.5} {
+		img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+		require.NoError(t, err)
+		require.NoError(t, img.Linear([]float64{gain}, []float64{0}))
+		require.NoError(t, img.Cast(BandFormatUchar))
+		exposures = append(exposures, img)
+	}
+
+	merged, err := MergeExposures(exposures, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 100, merged.Width())
+	assert.Equal(t, 100, merged.Height())
+	assert.Equal(t, 3, merged.Bands())
+	assert.Equal(t, BandFormatUchar, merged.BandFormat())
+
+	_, _, err = merged.ExportJpeg(nil)
+	require.NoError(t, err)
+
+	small, err := Black(10, 10)
+	require.NoError(t, err)
+	_, err = MergeExposures([]*ImageRef{exposures[0], small}, nil)
+	assert.Error(t, err)
+
+	_, err = MergeExposures(exposures[:1], nil)
+	assert.Error(t, err)
+}
+
+func TestFusionLevels(t *testing.T) {
+	assert.Equal(t, 1, fusionLevels(10, 100))
+	assert.Equal(t, 2, fusionLevels(16, 16))
+	assert.Equal(t, 4, fusionLevels(100, 100))
+	assert.Equal(t, 8, fusionLevels(1920, 1080))
+}
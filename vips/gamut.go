@@ -0,0 +1,370 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// ColorGamut is the RGB color space of an image, as given by its ICC profile
+type ColorGamut int
+
+// ColorGamut enum
+const (
+	ColorGamutUnknown ColorGamut = iota
+	ColorGamutSRGB
+	ColorGamutDisplayP3
+	ColorGamutRec2020
+)
+
+// rgbColorSpace describes an RGB color space by the chromaticities of its primaries and white point and its transfer
+// function, from encoded to linear values
+type rgbColorSpace struct {
+	description string
+	primaries   [3][2]float64
+	white       [2]float64
+	linear      func(v float64) float64
+}
+
+var (
+	d65White = [2]float64{0.3127, 0.3290}
+
+	srgbColorSpace = rgbColorSpace{
+		description: "sRGB",
+		primaries:   [3][2]float64{{0.64, 0.33}, {0.30, 0.60}, {0.15, 0.06}},
+		white:       d65White,
+		linear:      srgbToLinear,
+	}
+	displayP3ColorSpace = rgbColorSpace{
+		description: "Display P3",
+		primaries:   [3][2]float64{{0.680, 0.320}, {0.265, 0.690}, {0.150, 0.060}},
+		white:       d65White,
+		linear:      srgbToLinear,
+	}
+	rec2020ColorSpace = rgbColorSpace{
+		description: "Rec. ITU-R BT.2020",
+		primaries:   [3][2]float64{{0.708, 0.292}, {0.170, 0.797}, {0.131, 0.046}},
+		white:       d65White,
+		linear:      rec709ToLinear,
+	}
+
+	displayP3ICCProfile = displayP3ColorSpace.iccProfile()
+	rec2020ICCProfile   = rec2020ColorSpace.iccProfile()
+)
+
+// ColorGamut returns the color space of the image from the colorants of its ICC profile. Images without a profile
+// are assumed to be sRGB, as browsers do; images with a profile that doesn't describe one of the known RGB color
+// spaces, e.g. a CMYK or table based profile, return ColorGamutUnknown.
+func (r *ImageRef) ColorGamut() ColorGamut {
+	if !r.HasICCProfile() {
+		if r.Interpretation() == InterpretationCMYK {
+			return ColorGamutUnknown
+		}
+		return ColorGamutSRGB
+	}
+
+	profile, err := r.ICCProfile()
+	if err != nil {
+		return ColorGamutUnknown
+	}
+	return iccProfileGamut(profile)
+}
+
+// IsWideGamut checks whether the image is tagged with a color space wider than sRGB, Display P3 or Rec. 2020
+func (r *ImageRef) IsWideGamut() bool {
+	gamut := r.ColorGamut()
+	return gamut == ColorGamutDisplayP3 || gamut == ColorGamutRec2020
+}
+
+// TagColorGamut embeds the ICC profile of the color space without transforming the pixels, for images known to be
+// in that color space that lack a profile, like SetICCProfile.
+func (r *ImageRef) TagColorGamut(gamut ColorGamut) error {
+	profile, _, err := colorGamutProfile(gamut)
+	if err != nil {
+		return err
+	}
+	return r.SetICCProfile(profile)
+}
+
+// ToColorGamut transforms the image from its embedded ICC profile, or sRGB without one, to the color space and
+// embeds the profile of the color space. 16-bit images stay 16-bit. Converting to a wider color space keeps the
+// colors as they are, which can then be exported without being squeezed into sRGB; HEIF and AVIF exports keep the
+// profile of Display P3 and Rec. 2020 images and also signal their color space with nclx (CICP) values.
+func (r *ImageRef) ToColorGamut(gamut ColorGamut, intent Intent) error {
+	_, path, err := colorGamutProfile(gamut)
+	if err != nil {
+		return err
+	}
+
+	depth := 8
+	if r.BandFormat() == BandFormatUshort {
+		depth = 16
+	}
+
+	out, err := vipsICCTransform(r.image, path, SRGBIEC6196621ICCProfilePath, intent, depth, r.HasICCProfile(), false)
+	if err != nil {
+		govipsLog("govips", LogLevelError, fmt.Sprintf("failed to do icc transform: %v", err.Error()))
+		return err
+	}
+
+	r.setImage(out)
+	return nil
+}
+
+// colorGamutProfile returns the ICC profile of the color space and the path of its temporary file
+func colorGamutProfile(gamut ColorGamut) ([]byte, string, error) {
+	switch gamut {
+	case ColorGamutSRGB:
+		return sRGBIEC6196621ICCProfile, SRGBIEC6196621ICCProfilePath, nil
+	case ColorGamutDisplayP3:
+		return displayP3ICCProfile, DisplayP3ICCProfilePath, nil
+	case ColorGamutRec2020:
+		return rec2020ICCProfile, Rec2020ICCProfilePath, nil
+	}
+	return nil, "", fmt.Errorf("unknown color gamut %d", gamut)
+}
+
+// iccProfileGamut matches the red, green and blue colorants of an RGB ICC profile with the ones of the known color
+// spaces, which is tolerant to the small differences of the profiles shipped by vendors
+func iccProfileGamut(profile []byte) ColorGamut {
+	if !isICCProfile(profile) || !bytes.Equal(profile[16:20], []byte("RGB ")) {
+		return ColorGamutUnknown
+	}
+
+	var colorants [3][3]float64
+	for i, signature := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		data := iccTag(profile, signature)
+		if len(data) < 20 || !bytes.Equal(data[:4], []byte("XYZ ")) {
+			return ColorGamutUnknown
+		}
+		for c := 0; c < 3; c++ {
+			colorants[i][c] = float64(int32(binary.BigEndian.Uint32(data[8+4*c:]))) / 65536
+		}
+	}
+
+	const tolerance = 0.01
+	for gamut, space := range map[ColorGamut]rgbColorSpace{
+		ColorGamutSRGB:      srgbColorSpace,
+		ColorGamutDisplayP3: displayP3ColorSpace,
+		ColorGamutRec2020:   rec2020ColorSpace,
+	} {
+		expected := space.colorants()
+		matches := true
+		for i := range expected {
+			for c := range expected[i] {
+				if math.Abs(expected[i][c]-colorants[i][c]) > tolerance {
+					matches = false
+				}
+			}
+		}
+		if matches {
+			return gamut
+		}
+	}
+	return ColorGamutUnknown
+}
+
+// iccTag returns the data of the tag with the signature, nil if the profile has none
+func iccTag(profile []byte, signature string) []byte {
+	if len(profile) < 132 {
+		return nil
+	}
+
+	count := int(binary.BigEndian.Uint32(profile[128:]))
+	for i := 0; i < count && 132+12*(i+1) <= len(profile); i++ {
+		entry := profile[132+12*i:]
+		if string(entry[:4]) != signature {
+			continue
+		}
+		offset, size := int(binary.BigEndian.Uint32(entry[4:])), int(binary.BigEndian.Uint32(entry[8:]))
+		if offset < 0 || size < 0 || offset+size > len(profile) {
+			return nil
+		}
+		return profile[offset : offset+size]
+	}
+	return nil
+}
+
+// colorants returns the XYZ of the red, green and blue primaries adapted to the D50 white of the ICC profile
+// connection space with the Bradford transform, as stored in the rXYZ, gXYZ and bXYZ tags
+func (s rgbColorSpace) colorants() [3][3]float64 {
+	xyz := func(xy [2]float64) [3]float64 {
+		return [3]float64{xy[0] / xy[1], 1, (1 - xy[0] - xy[1]) / xy[1]}
+	}
+
+	// RGB to XYZ, the primaries scaled so that RGB white is the white point
+	var primaries [3][3]float64
+	for i, p := range s.primaries {
+		c := xyz(p)
+		for row := 0; row < 3; row++ {
+			primaries[row][i] = c[row]
+		}
+	}
+	white := xyz(s.white)
+	scale := mulMatrixVector(invertMatrix(primaries), white)
+	var toXYZ [3][3]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			toXYZ[row][col] = primaries[row][col] * scale[col]
+		}
+	}
+
+	bradford := [3][3]float64{
+		{0.8951, 0.2664, -0.1614},
+		{-0.7502, 1.7135, 0.0367},
+		{0.0389, -0.0685, 1.0296},
+	}
+	source := mulMatrixVector(bradford, white)
+	destination := mulMatrixVector(bradford, iccD50White)
+	var gain [3][3]float64
+	for i := range gain {
+		gain[i][i] = destination[i] / source[i]
+	}
+	adapted := mulMatrices(mulMatrices(mulMatrices(invertMatrix(bradford), gain), bradford), toXYZ)
+
+	var colorants [3][3]float64
+	for i := 0; i < 3; i++ {
+		for c := 0; c < 3; c++ {
+			colorants[i][c] = adapted[c][i]
+		}
+	}
+	return colorants
+}
+
+// iccD50White is the XYZ of the illuminant of the ICC profile connection space
+var iccD50White = [3]float64{0.9642, 1, 0.8249}
+
+// iccProfile builds a version 2 matrix/TRC display profile of the color space, like the compact profiles above
+func (s rgbColorSpace) iccProfile() []byte {
+	s15Fixed16 := func(values ...float64) []byte {
+		buf := make([]byte, 4*len(values))
+		for i, v := range values {
+			binary.BigEndian.PutUint32(buf[4*i:], uint32(int32(math.Round(v*65536))))
+		}
+		return buf
+	}
+	xyzTag := func(xyz [3]float64) []byte {
+		return append([]byte("XYZ \x00\x00\x00\x00"), s15Fixed16(xyz[0], xyz[1], xyz[2])...)
+	}
+
+	description := append([]byte("desc\x00\x00\x00\x00"), make([]byte, 4)...)
+	binary.BigEndian.PutUint32(description[8:], uint32(len(s.description)+1))
+	description = append(description, s.description...)
+	// the terminating null, the empty Unicode and ScriptCode descriptions
+	description = append(description, make([]byte, 1+4+4+2+1+67)...)
+
+	const curveSize = 1024
+	curve := append([]byte("curv\x00\x00\x00\x00"), make([]byte, 4+2*curveSize)...)
+	binary.BigEndian.PutUint32(curve[8:], curveSize)
+	for i := 0; i < curveSize; i++ {
+		v := s.linear(float64(i) / (curveSize - 1))
+		binary.BigEndian.PutUint16(curve[12+2*i:], uint16(math.Round(math.Max(0, math.Min(1, v))*65535)))
+	}
+
+	white := [3]float64{s.white[0] / s.white[1], 1, (1 - s.white[0] - s.white[1]) / s.white[1]}
+	colorants := s.colorants()
+
+	tags := []struct {
+		signature string
+		data      []byte
+	}{
+		{"desc", description},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyzTag(white)},
+		{"rXYZ", xyzTag(colorants[0])},
+		{"gXYZ", xyzTag(colorants[1])},
+		{"bXYZ", xyzTag(colorants[2])},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	header := make([]byte, 128)
+	copy(header[8:], []byte{0x02, 0x10, 0x00, 0x00})
+	copy(header[12:], "mntrRGB XYZ ")
+	copy(header[36:], "acsp")
+	copy(header[68:], s15Fixed16(iccD50White[:]...))
+
+	table := make([]byte, 4+12*len(tags))
+	binary.BigEndian.PutUint32(table, uint32(len(tags)))
+
+	var data []byte
+	offsets := map[string]int{}
+	for i, tag := range tags {
+		// tags with the same data, the curves, share it
+		key := string(tag.data)
+		offset, ok := offsets[key]
+		if !ok {
+			offset = len(header) + len(table) + len(data)
+			offsets[key] = offset
+			data = append(data, tag.data...)
+			for len(data)%4 != 0 {
+				data = append(data, 0)
+			}
+		}
+		entry := table[4+12*i:]
+		copy(entry, tag.signature)
+		binary.BigEndian.PutUint32(entry[4:], uint32(offset))
+		binary.BigEndian.PutUint32(entry[8:], uint32(len(tag.data)))
+	}
+
+	profile := append(append(header, table...), data...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// rec709ToLinear inverts the transfer function of Rec. 709, which Rec. 2020 shares
+func rec709ToLinear(v float64) float64 {
+	const alpha, beta = 1.09929682680944, 0.018053968510807
+	if v < 4.5*beta {
+		return v / 4.5
+	}
+	return math.Pow((v+alpha-1)/alpha, 1/0.45)
+}
+
+func mulMatrices(a, b [3][3]float64) [3][3]float64 {
+	var m [3][3]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			for i := 0; i < 3; i++ {
+				m[row][col] += a[row][i] * b[i][col]
+			}
+		}
+	}
+	return m
+}
+
+func mulMatrixVector(m [3][3]float64, v [3]float64) [3]float64 {
+	var r [3]float64
+	for row := 0; row < 3; row++ {
+		for i := 0; i < 3; i++ {
+			r[row] += m[row][i] * v[i]
+		}
+	}
+	return r
+}
+
+func invertMatrix(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+
+	var inv [3][3]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			// the cofactor of the transposed position
+			r1, r2 := (col+1)%3, (col+2)%3
+			c1, c2 := (row+1)%3, (row+2)%3
+			inv[row][col] = (m[r1][c1]*m[r2][c2] - m[r1][c2]*m[r2][c1]) / det
+		}
+	}
+	return inv
+}
@@ -0,0 +1,103 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_iccProfileGamut(t *testing.T) {
+	assert.Equal(t, ColorGamutSRGB, iccProfileGamut(sRGBIEC6196621ICCProfile))
+	assert.Equal(t, ColorGamutSRGB, iccProfileGamut(sRGBV2MicroICCProfile))
+	assert.Equal(t, ColorGamutDisplayP3, iccProfileGamut(displayP3ICCProfile))
+	assert.Equal(t, ColorGamutRec2020, iccProfileGamut(rec2020ICCProfile))
+	assert.Equal(t, ColorGamutUnknown, iccProfileGamut(sGrayV2MicroICCProfile))
+	assert.Equal(t, ColorGamutUnknown, iccProfileGamut([]byte("not a profile")))
+}
+
+func TestImageRef_ColorGamut(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, ColorGamutSRGB, img.ColorGamut())
+	assert.False(t, img.IsWideGamut())
+
+	err = img.TagColorGamut(ColorGamutDisplayP3)
+	require.NoError(t, err)
+	assert.Equal(t, ColorGamutDisplayP3, img.ColorGamut())
+	assert.True(t, img.IsWideGamut())
+
+	err = img.ToColorGamut(ColorGamutRec2020, IntentRelative)
+	require.NoError(t, err)
+	assert.Equal(t, ColorGamutRec2020, img.ColorGamut())
+	assert.Equal(t, 3, img.Bands())
+	assert.Equal(t, BandFormatUchar, img.BandFormat())
+
+	assert.Error(t, img.TagColorGamut(ColorGamutUnknown))
+
+	if !IsTypeSupported(ImageTypeAVIF) {
+		t.Skip("AVIF is not supported")
+	}
+
+	params := NewAvifExportParams()
+	params.StripMetadata = true
+	buf, _, err := img.ExportAvif(params)
+	require.NoError(t, err)
+
+	color, ok := readHeifNclx(buf)
+	require.True(t, ok)
+	assert.Equal(t, nclxRec2020.primaries, color.primaries)
+	assert.Equal(t, nclxRec2020.transfer, color.transfer)
+
+	exported, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.Equal(t, ColorGamutRec2020, exported.ColorGamut())
+}
+
+func TestImageRef_ColorGamut_StripMetadata(t *testing.T) {
+	Startup(nil)
+
+	exports := map[ImageType]func(img *ImageRef, strip bool) ([]byte, *ImageMetadata, error){
+		ImageTypeAVIF: func(img *ImageRef, strip bool) ([]byte, *ImageMetadata, error) {
+			return img.ExportAvif(&AvifExportParams{StripMetadata: strip, Quality: 80})
+		},
+		ImageTypeHEIF: func(img *ImageRef, strip bool) ([]byte, *ImageMetadata, error) {
+			return img.ExportHeif(&HeifExportParams{StripMetadata: strip, Quality: 80})
+		},
+	}
+
+	for format, export := range exports {
+		t.Run(ImageTypes[format], func(t *testing.T) {
+			if !IsTypeSupported(format) {
+				t.Skip("format is not supported")
+			}
+
+			img, err := NewImageFromFile(resources + "jpg-24bit-icc-iec.jpg")
+			require.NoError(t, err)
+			require.True(t, img.HasICCProfile())
+
+			buf, _, err := export(img, false)
+			require.NoError(t, err)
+			exported, err := NewImageFromBuffer(buf)
+			require.NoError(t, err)
+			assert.True(t, exported.HasICCProfile())
+
+			// stripping removes the profile of sRGB images
+			buf, _, err = export(img, true)
+			require.NoError(t, err)
+			exported, err = NewImageFromBuffer(buf)
+			require.NoError(t, err)
+			assert.False(t, exported.HasICCProfile())
+
+			// but keeps the one of wide gamut images
+			require.NoError(t, img.ToColorGamut(ColorGamutDisplayP3, IntentRelative))
+			buf, _, err = export(img, true)
+			require.NoError(t, err)
+			exported, err = NewImageFromBuffer(buf)
+			require.NoError(t, err)
+			assert.Equal(t, ColorGamutDisplayP3, exported.ColorGamut())
+		})
+	}
+}
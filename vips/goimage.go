@@ -0,0 +1,94 @@
+package vips
+
+// #include <vips/vips.h>
+import "C"
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// NewImageFromGoImage creates an image from the pixels of a Go image without encoding it. *image.RGBA,
+// *image.NRGBA, *image.Gray and *image.YCbCr images are copied directly, any other image is drawn into an
+// *image.NRGBA first. The result is 8-bit sRGB with alpha, 8-bit sRGB without alpha for *image.YCbCr, which has
+// none, or 8-bit grey for *image.Gray, and carries no metadata.
+func NewImageFromGoImage(img image.Image) (*ImageRef, error) {
+	startupIfNeeded()
+
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, errors.New("cannot create an image from an empty Go image")
+	}
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var out *C.VipsImage
+	var err error
+	switch src := img.(type) {
+	case *image.Gray:
+		pix := packedPixels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride, width, height)
+		out, err = vipsImageFromMemory(pix, width, height, 1, InterpretationBW)
+	case *image.NRGBA:
+		pix := packedPixels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride, 4*width, height)
+		out, err = vipsImageFromMemory(pix, width, height, 4, InterpretationSRGB)
+	case *image.RGBA:
+		pix := packedPixels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride, 4*width, height)
+		out, err = vipsImageFromMemory(pix, width, height, 4, InterpretationSRGB)
+		// the channels of image.RGBA are premultiplied by alpha, libvips expects them not to be
+		if err == nil && !src.Opaque() {
+			out, err = vipsUnpremultiplyUchar(out)
+		}
+	case *image.YCbCr:
+		out, err = vipsImageFromMemory(ycbcrToRGB(src), width, height, 3, InterpretationSRGB)
+	default:
+		nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+		out, err = vipsImageFromMemory(nrgba.Pix, width, height, 4, InterpretationSRGB)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newImageRef(out, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// packedPixels returns height rows of rowSize bytes from pix whose rows are stride bytes apart, copying them only when
+// there are gaps between rows
+func packedPixels(pix []byte, stride, rowSize, height int) []byte {
+	if stride == rowSize {
+		return pix[:rowSize*height]
+	}
+
+	packed := make([]byte, rowSize*height)
+	for y := 0; y < height; y++ {
+		copy(packed[y*rowSize:(y+1)*rowSize], pix[y*stride:])
+	}
+	return packed
+}
+
+// ycbcrToRGB converts the pixels of a YCbCr image to interleaved RGB, upsampling its chroma
+func ycbcrToRGB(src *image.YCbCr) []byte {
+	bounds := src.Bounds()
+	pix := make([]byte, 0, 3*bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			yi, ci := src.YOffset(x, y), src.COffset(x, y)
+			r, g, b := color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+			pix = append(pix, r, g, b)
+		}
+	}
+	return pix
+}
+
+// vipsUnpremultiplyUchar unpremultiplies an 8-bit image, taking ownership of in
+func vipsUnpremultiplyUchar(in *C.VipsImage) (*C.VipsImage, error) {
+	defer clearImage(in)
+
+	unpremultiplied, err := vipsUnpremultiplyAlpha(in)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(unpremultiplied)
+
+	return vipsCast(unpremultiplied, BandFormatUchar)
+}
@@ -0,0 +1,66 @@
+package vips
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewImageFromGoImage(t *testing.T) {
+	Startup(nil)
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	nrgba.SetNRGBA(12, 6, color.NRGBA{R: 10, G: 20, B: 30, A: 40})
+
+	// a sub-image has gaps between its rows
+	img, err := NewImageFromGoImage(nrgba.SubImage(image.Rect(10, 5, 15, 8)))
+	require.NoError(t, err)
+	assert.Equal(t, 5, img.Width())
+	assert.Equal(t, 3, img.Height())
+	assert.Equal(t, 4, img.Bands())
+	point, err := img.GetPoint(2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{10, 20, 30, 40}, point)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	rgba.SetRGBA(1, 1, color.RGBA{R: 64, G: 0, B: 0, A: 128})
+	img, err = NewImageFromGoImage(rgba)
+	require.NoError(t, err)
+	point, err = img.GetPoint(1, 1)
+	require.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{127, 0, 0, 128}, point, 1)
+
+	gray := image.NewGray(image.Rect(0, 0, 3, 3))
+	gray.SetGray(1, 2, color.Gray{Y: 99})
+	img, err = NewImageFromGoImage(gray)
+	require.NoError(t, err)
+	assert.Equal(t, 1, img.Bands())
+	assert.Equal(t, InterpretationBW, img.Interpretation())
+
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = 100
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = 90, 200
+	}
+	img, err = NewImageFromGoImage(ycbcr)
+	require.NoError(t, err)
+	assert.Equal(t, 3, img.Bands())
+	r, g, b := color.YCbCrToRGB(100, 90, 200)
+	point, err = img.GetPoint(3, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{float64(r), float64(g), float64(b)}, point)
+
+	// other image types are drawn into an NRGBA image
+	img, err = NewImageFromGoImage(image.NewGray16(image.Rect(0, 0, 6, 7)))
+	require.NoError(t, err)
+	assert.Equal(t, 6, img.Width())
+	assert.Equal(t, 4, img.Bands())
+
+	_, err = NewImageFromGoImage(image.NewGray(image.Rectangle{}))
+	assert.Error(t, err)
+}
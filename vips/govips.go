@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // Version is the full libvips version string (x.y.z)
@@ -52,6 +53,7 @@ type Config struct {
 	ReportLeaks      bool
 	CacheTrace       bool
 	CollectStats     bool
+	ExportWorkers    int // number of workers encoding ExportAsync requests, defaults to the number of CPUs
 }
 
 // Startup sets up the libvips support and ensures the versions are correct. Pass in nil for
@@ -134,6 +136,10 @@ func Startup(config *Config) {
 		if config.CacheTrace {
 			C.vips_cache_set_trace(toGboolean(true))
 		}
+
+		if config.ExportWorkers > 0 {
+			exportWorkers = config.ExportWorkers
+		}
 	} else {
 		C.vips_concurrency_set(defaultConcurrencyLevel)
 		C.vips_cache_set_max(defaultMaxCacheSize)
@@ -203,6 +209,36 @@ func ClearCache() {
 	C.vips_cache_drop_all()
 }
 
+// SetMaxCacheSize sets the maximum number of operations libvips keeps in the operation cache
+func SetMaxCacheSize(max int) {
+	C.vips_cache_set_max(C.int(max))
+}
+
+// MaxCacheSize returns the maximum number of operations libvips keeps in the operation cache
+func MaxCacheSize() int {
+	return int(C.vips_cache_get_max())
+}
+
+// SetMaxCacheMem sets the maximum memory in bytes the operation cache may use before dropping operations
+func SetMaxCacheMem(max int) {
+	C.vips_cache_set_max_mem(C.size_t(max))
+}
+
+// MaxCacheMem returns the maximum memory in bytes the operation cache may use
+func MaxCacheMem() int {
+	return int(C.vips_cache_get_max_mem())
+}
+
+// SetMaxCacheFiles sets the maximum number of files the operation cache may keep open
+func SetMaxCacheFiles(max int) {
+	C.vips_cache_set_max_files(C.int(max))
+}
+
+// MaxCacheFiles returns the maximum number of files the operation cache may keep open
+func MaxCacheFiles() int {
+	return int(C.vips_cache_get_max_files())
+}
+
 // PrintCache prints the whole operation cache to stdout for debugging purposes.
 func PrintCache() {
 	C.vips_cache_print()
@@ -217,10 +253,12 @@ func PrintObjectReport(label string) {
 
 // MemoryStats is a data structure that houses various memory statistics from ReadVipsMemStats()
 type MemoryStats struct {
-	Mem     int64
-	MemHigh int64
-	Files   int64
-	Allocs  int64
+	Mem             int64 // bytes of pixel buffers currently allocated by libvips
+	MemHigh         int64 // highest Mem so far
+	Files           int64 // files currently open
+	Allocs          int64 // pixel buffers currently allocated
+	CacheOperations int64 // operations held by the operation cache, see ClearCache
+	OpenImages      int64 // images not closed yet, see ImageRef.Close
 }
 
 // ReadVipsMemStats returns various memory statistics such as allocated memory and open files.
@@ -229,6 +267,8 @@ func ReadVipsMemStats(stats *MemoryStats) {
 	stats.MemHigh = int64(C.vips_tracked_get_mem_highwater())
 	stats.Allocs = int64(C.vips_tracked_get_allocs())
 	stats.Files = int64(C.vips_tracked_get_files())
+	stats.CacheOperations = int64(C.vips_cache_get_size())
+	stats.OpenImages = atomic.LoadInt64(&openImages)
 }
 
 func startupIfNeeded() {
@@ -261,5 +301,11 @@ func initTypes() {
 				govipsLog("govips", LogLevelInfo, fmt.Sprintf("registered image type loader type=%s", v))
 			}
 		}
+
+		// libvips has no PSD and camera RAW loaders of its own, these files are loaded through ImageMagick
+		supportedImageTypes[ImageTypePSD] = supportedImageTypes[ImageTypeMagick]
+		supportedImageTypes[ImageTypeRAW] = supportedImageTypes[ImageTypeMagick]
+		// ICO files are read and written by govips, their images going through the PNG loader and saver
+		supportedImageTypes[ImageTypeICO] = supportedImageTypes[ImageTypePNG]
 	})
 }
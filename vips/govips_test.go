@@ -2,6 +2,8 @@ package vips
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestInitConfig(t *testing.T) {
@@ -10,3 +12,19 @@ func TestInitConfig(t *testing.T) {
 	running = false
 	startupIfNeeded()
 }
+
+func TestCacheLimits(t *testing.T) {
+	Startup(nil)
+
+	defer SetMaxCacheSize(MaxCacheSize())
+	defer SetMaxCacheMem(MaxCacheMem())
+	defer SetMaxCacheFiles(MaxCacheFiles())
+
+	SetMaxCacheSize(10)
+	SetMaxCacheMem(1 << 20)
+	SetMaxCacheFiles(2)
+
+	assert.Equal(t, 10, MaxCacheSize())
+	assert.Equal(t, 1<<20, MaxCacheMem())
+	assert.Equal(t, 2, MaxCacheFiles())
+}
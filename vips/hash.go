@@ -0,0 +1,104 @@
+package vips
+
+import (
+	"math"
+	"sort"
+)
+
+// HashAlgorithm is a perceptual hash algorithm, producing similar hashes for visually similar images
+type HashAlgorithm int
+
+// HashAlgorithm enum
+const (
+	// HashAverage sets a bit for each pixel of an 8x8 reduction brighter than the mean
+	HashAverage HashAlgorithm = iota
+	// HashDifference sets a bit for each pixel of a 9x8 reduction brighter than its right neighbour
+	HashDifference
+	// HashPerceptual sets a bit for each of the lowest 8x8 DCT frequencies of a 32x32 reduction above their median
+	HashPerceptual
+)
+
+// hashInputSize returns the size of the grey image the algorithm hashes
+func (a HashAlgorithm) hashInputSize() (int, int) {
+	switch a {
+	case HashDifference:
+		return 9, 8
+	case HashPerceptual:
+		return 32, 32
+	default:
+		return 8, 8
+	}
+}
+
+// hash computes the 64 bit hash of width x height grey pixels as returned by hashInputSize
+func (a HashAlgorithm) hash(pixels []byte, width, height int) uint64 {
+	switch a {
+	case HashDifference:
+		return differenceHash(pixels, width, height)
+	case HashPerceptual:
+		return perceptualHash(pixels, width, height)
+	default:
+		return averageHash(pixels)
+	}
+}
+
+func averageHash(pixels []byte) uint64 {
+	sum := 0
+	for _, p := range pixels {
+		sum += int(p)
+	}
+
+	var hash uint64
+	for i, p := range pixels {
+		if int(p)*len(pixels) > sum {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+func differenceHash(pixels []byte, width, height int) uint64 {
+	var hash uint64
+	bit := uint(0)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			if pixels[y*width+x] > pixels[y*width+x+1] {
+				hash |= 1 << bit
+			}
+			bit++
+		}
+	}
+	return hash
+}
+
+func perceptualHash(pixels []byte, width, height int) uint64 {
+	const size = 8
+
+	// the lowest size x size frequencies of the 2D DCT-II
+	coefficients := make([]float64, 0, size*size)
+	for v := 0; v < size; v++ {
+		for u := 0; u < size; u++ {
+			sum := 0.0
+			for y := 0; y < height; y++ {
+				cy := math.Cos(float64((2*y+1)*v) * math.Pi / float64(2*height))
+				for x := 0; x < width; x++ {
+					sum += float64(pixels[y*width+x]) * cy * math.Cos(float64((2*x+1)*u)*math.Pi/float64(2*width))
+				}
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+
+	// the DC coefficient is left out of the median, it only holds the average brightness
+	sorted := append([]float64(nil), coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, c := range coefficients {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
@@ -22,6 +22,19 @@ void remove_field(VipsImage *in, char *field) {
   vips_image_remove(in, field);
 }
 
+int copy_field(VipsImage *from, VipsImage *to, const char *field) {
+  GValue value = {0};
+
+  if (vips_image_get(from, field, &value)) {
+    return -1;
+  }
+
+  vips_image_set(to, field, &value);
+  g_value_unset(&value);
+
+  return 0;
+}
+
 int get_meta_orientation(VipsImage *in) {
   int orientation = 0;
   if (vips_image_get_typeof(in, VIPS_META_ORIENTATION) != 0) {
@@ -61,10 +74,59 @@ void set_page_height(VipsImage *in, int height) {
   vips_image_set_int(in, VIPS_META_PAGE_HEIGHT, height);
 }
 
+int get_meta_int(const VipsImage *in, const char *name, int *out) {
+  if (vips_image_get_typeof(in, name) == 0) {
+    return -1;
+  }
+  return vips_image_get_int(in, name, out);
+}
+
+void set_meta_int(VipsImage *in, const char *name, int value) {
+  vips_image_set_int(in, name, value);
+}
+
 int get_meta_loader(const VipsImage *in, const char **out) {
   return vips_image_get_string(in, VIPS_META_LOADER, out);
 }
 
+int get_meta_string(const VipsImage *in, const char *name, const char **out) {
+  if (vips_image_get_typeof(in, name) == 0) {
+    return -1;
+  }
+  return vips_image_get_string(in, name, out);
+}
+
+void set_meta_string(VipsImage *in, const char *name, const char *value) {
+  vips_image_set_string(in, name, value);
+}
+
+int get_meta_blob(const VipsImage *in, const char *name, const void **out,
+                  size_t *length) {
+  if (vips_image_get_typeof(in, name) == 0) {
+    return -1;
+  }
+  return vips_image_get_blob(in, name, out, length);
+}
+
+// the image gets its own copy of data
+void set_meta_blob(VipsImage *in, const char *name, const void *data,
+                   size_t length) {
+  vips_image_set_blob_copy(in, name, data, length);
+}
+
+int get_meta_array_double(const VipsImage *in, const char *name, double **out,
+                          int *n) {
+  if (vips_image_get_typeof(in, name) == 0) {
+    return -1;
+  }
+  return vips_image_get_array_double((VipsImage *)in, name, out, n);
+}
+
+void set_meta_array_double(VipsImage *in, const char *name, const double *array,
+                           int n) {
+  vips_image_set_array_double(in, name, array, n);
+}
+
 int get_image_delay(VipsImage *in, int **out) {
   return vips_image_get_array_int(in, "delay", out, NULL);
 }
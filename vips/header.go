@@ -54,6 +54,90 @@ func vipsRemoveMetadata(in *C.VipsImage, keep ...string) {
 	}
 }
 
+// vipsKeepMetadata removes all metadata fields but keep and the page layout. Fields ending in * match by prefix.
+// The EXIF blob is kept along with any kept exif-* field, libvips regenerates it from the remaining fields on save.
+func vipsKeepMetadata(in *C.VipsImage, keep []string) {
+	// the page layout and the animation timing are part of the image rather than metadata
+	retain := append([]string{C.VIPS_META_N_PAGES, C.VIPS_META_PAGE_HEIGHT, "delay", "loop", gifDelayField}, keep...)
+	for _, field := range keep {
+		if strings.HasPrefix(field, "exif-") {
+			retain = append(retain, C.VIPS_META_EXIF_NAME)
+			break
+		}
+	}
+
+	for _, field := range vipsImageGetFields(in) {
+		if matchesField(retain, field) {
+			continue
+		}
+
+		cField := C.CString(field)
+
+		C.remove_field(in, cField)
+
+		C.free(unsafe.Pointer(cField))
+	}
+}
+
+// vipsRemoveMatchingFields removes the metadata fields matching patterns. Fields ending in * match by prefix.
+func vipsRemoveMatchingFields(in *C.VipsImage, patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+
+	for _, field := range vipsImageGetFields(in) {
+		if matchesField(patterns, field) && !contains(headerFields, field) {
+			vipsRemoveField(in, field)
+		}
+	}
+}
+
+func matchesField(patterns []string, field string) bool {
+	for _, pattern := range patterns {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(field, prefix) {
+				return true
+			}
+		} else if pattern == field {
+			return true
+		}
+	}
+	return false
+}
+
+// vipsCopyMetadata replaces the fields of to matching patterns by the ones of from. Fields ending in * match by prefix.
+func vipsCopyMetadata(from, to *C.VipsImage, patterns []string) error {
+	vipsRemoveMatchingFields(to, patterns)
+
+	for _, field := range vipsImageGetFields(from) {
+		if !matchesField(patterns, field) || contains(headerFields, field) {
+			continue
+		}
+
+		cField := C.CString(field)
+		code := C.copy_field(from, to, cField)
+		freeCString(cField)
+		if code != 0 {
+			return handleVipsError()
+		}
+	}
+
+	return nil
+}
+
+// headerFields are the fields of the image header, which describe the pixels rather than being metadata
+var headerFields = []string{
+	"width", "height", "bands", "format", "coding", "interpretation", "xoffset", "yoffset", "xres", "yres", "filename",
+}
+
+// provenanceMetadata are the fields copied by CopyMetadataFrom by default
+var provenanceMetadata = []string{
+	"exif-*",
+	C.VIPS_META_XMP_NAME,
+	C.VIPS_META_IPTC_NAME,
+	C.VIPS_META_ICC_NAME,
+}
+
 var technicalMetadata = []string{
 	C.VIPS_META_ICC_NAME,
 	C.VIPS_META_ORIENTATION,
@@ -98,6 +182,94 @@ func vipsSetPageHeight(in *C.VipsImage, height int) {
 	C.set_page_height(in, C.int(height))
 }
 
+func vipsImageGetInt(in *C.VipsImage, name string) (int, bool) {
+	var out C.int
+	cName := C.CString(name)
+	defer freeCString(cName)
+	code := int(C.get_meta_int(in, cName, &out))
+	return int(out), code == 0
+}
+
+func vipsImageSetInt(in *C.VipsImage, name string, value int) {
+	cName := C.CString(name)
+	defer freeCString(cName)
+	C.set_meta_int(in, cName, C.int(value))
+}
+
+// vipsImageGetString returns a string field, the string being owned by the image
+func vipsImageGetString(in *C.VipsImage, name string) (string, bool) {
+	var out *C.char
+	cName := C.CString(name)
+	defer freeCString(cName)
+	code := int(C.get_meta_string(in, cName, &out))
+	return C.GoString(out), code == 0
+}
+
+func vipsImageSetString(in *C.VipsImage, name string, value string) {
+	cName := C.CString(name)
+	defer freeCString(cName)
+	cValue := C.CString(value)
+	defer freeCString(cValue)
+	C.set_meta_string(in, cName, cValue)
+}
+
+// vipsImageGetBlob returns a copy of a blob field, an error if the field is not a blob
+func vipsImageGetBlob(in *C.VipsImage, name string) ([]byte, bool, error) {
+	var out unsafe.Pointer
+	var length C.size_t
+	cName := C.CString(name)
+	defer freeCString(cName)
+
+	switch C.get_meta_blob(in, cName, &out, &length) {
+	case 0:
+		return C.GoBytes(out, C.int(length)), true, nil
+	case -1:
+		return nil, false, nil
+	default:
+		return nil, false, handleVipsError()
+	}
+}
+
+func vipsImageSetBlob(in *C.VipsImage, name string, data []byte) {
+	if len(data) == 0 {
+		vipsRemoveField(in, name)
+		return
+	}
+
+	cName := C.CString(name)
+	defer freeCString(cName)
+	C.set_meta_blob(in, cName, unsafe.Pointer(&data[0]), C.size_t(len(data)))
+}
+
+// vipsImageGetArrayDouble returns a copy of an array of doubles field, an error if the field is of another type
+func vipsImageGetArrayDouble(in *C.VipsImage, name string) ([]float64, bool, error) {
+	var out *C.double
+	var n C.int
+	cName := C.CString(name)
+	defer freeCString(cName)
+
+	switch C.get_meta_array_double(in, cName, &out, &n) {
+	case 0:
+		return fromCArrayDouble(out, int(n)), true, nil
+	case -1:
+		return nil, false, nil
+	default:
+		return nil, false, handleVipsError()
+	}
+}
+
+func vipsImageSetArrayDouble(in *C.VipsImage, name string, values []float64) {
+	cName := C.CString(name)
+	defer freeCString(cName)
+	C.set_meta_array_double(in, cName, (*C.double)(&values[0]), C.int(len(values)))
+}
+
+func vipsRemoveField(in *C.VipsImage, name string) {
+	cName := C.CString(name)
+	defer freeCString(cName)
+	C.remove_field(in, cName)
+}
+
 func vipsImageGetMetaLoader(in *C.VipsImage) (string, bool) {
 	var out *C.char
 	defer freeCString(out)
@@ -127,7 +299,15 @@ func vipsImageSetDelay(in *C.VipsImage, data []C.int) error {
 // vipsDetermineImageTypeFromMetaLoader determine the image type from vips-loader metadata
 func vipsDetermineImageTypeFromMetaLoader(in *C.VipsImage) ImageType {
 	vipsLoader, ok := vipsImageGetMetaLoader(in)
-	if vipsLoader == "" || !ok {
+	if !ok {
+		return ImageTypeUnknown
+	}
+	return imageTypeFromLoader(vipsLoader)
+}
+
+// imageTypeFromLoader maps the name of a libvips loader, e.g. "tiffload", to its image type
+func imageTypeFromLoader(vipsLoader string) ImageType {
+	if vipsLoader == "" {
 		return ImageTypeUnknown
 	}
 	if strings.HasPrefix(vipsLoader, "jpeg") {
@@ -148,6 +328,9 @@ func vipsDetermineImageTypeFromMetaLoader(in *C.VipsImage) ImageType {
 	if strings.HasPrefix(vipsLoader, "jp2k") {
 		return ImageTypeJP2K
 	}
+	if strings.HasPrefix(vipsLoader, "jxl") {
+		return ImageTypeJXL
+	}
 	if strings.HasPrefix(vipsLoader, "magick") {
 		return ImageTypeMagick
 	}
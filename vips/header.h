@@ -9,6 +9,7 @@ int remove_icc_profile(VipsImage *in);
 unsigned long has_iptc(VipsImage *in);
 char** image_get_fields(VipsImage *in);
 void remove_field(VipsImage *in, char *field);
+int copy_field(VipsImage *from, VipsImage *to, const char *field);
 
 int get_meta_orientation(VipsImage *in);
 void remove_meta_orientation(VipsImage *in);
@@ -17,6 +18,18 @@ int get_image_n_pages(VipsImage *in);
 void set_image_n_pages(VipsImage *in, int n_pages);
 int get_page_height(VipsImage *in);
 void set_page_height(VipsImage *in, int height);
+int get_meta_int(const VipsImage *in, const char *name, int *out);
+void set_meta_int(VipsImage *in, const char *name, int value);
 int get_meta_loader(const VipsImage *in, const char **out);
+int get_meta_string(const VipsImage *in, const char *name, const char **out);
+void set_meta_string(VipsImage *in, const char *name, const char *value);
+int get_meta_blob(const VipsImage *in, const char *name, const void **out,
+                  size_t *length);
+void set_meta_blob(VipsImage *in, const char *name, const void *data,
+                   size_t length);
+int get_meta_array_double(const VipsImage *in, const char *name, double **out,
+                          int *n);
+void set_meta_array_double(VipsImage *in, const char *name, const double *array,
+                           int n);
 int get_image_delay(VipsImage *in, int **out);
 void set_image_delay(VipsImage *in, const int *array, int n);
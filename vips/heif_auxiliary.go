@@ -0,0 +1,159 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// HeifAuxiliary is an auxiliary image of the primary image of a HEIF or AVIF file
+type HeifAuxiliary int
+
+// HeifAuxiliary enum
+const (
+	HeifAuxiliaryDepth HeifAuxiliary = iota
+	HeifAuxiliaryAlpha
+	HeifAuxiliaryHDRGainMap
+)
+
+// ErrHeifAuxiliaryNotFound is returned when a HEIF or AVIF file has no auxiliary image of the requested kind
+var ErrHeifAuxiliaryNotFound = errors.New("HEIF auxiliary image not found")
+
+// heifAuxiliaryTypes are the auxC types of each kind of auxiliary image, in HEVC and AV1 files
+var heifAuxiliaryTypes = map[HeifAuxiliary][]string{
+	HeifAuxiliaryDepth:      {"urn:mpeg:hevc:2015:auxid:2", "urn:mpeg:mpegB:cicp:systems:auxiliary:depth"},
+	HeifAuxiliaryAlpha:      {"urn:mpeg:hevc:2015:auxid:1", "urn:mpeg:mpegB:cicp:systems:auxiliary:alpha"},
+	HeifAuxiliaryHDRGainMap: {"urn:com:apple:photo:2020:aux:hdrgainmap"},
+}
+
+// selectHeifAuxiliary makes an auxiliary image of the primary image of a HEIF or AVIF file its primary image, which
+// libheif, and so libvips, otherwise hides. The file is rewritten in place: pitm points at the auxiliary item, whose
+// auxl reference is renamed so that it isn't treated as auxiliary anymore, and whose hidden flag is cleared.
+func selectHeifAuxiliary(buf []byte, kind HeifAuxiliary) ([]byte, error) {
+	h, err := parseHeifItemProperties(buf)
+	if err != nil {
+		return nil, err
+	}
+	iref, ok := findIsobmffBox(h.children, "iref")
+	if !ok {
+		return nil, ErrHeifAuxiliaryNotFound
+	}
+	iinf, ok := findIsobmffBox(h.children, "iinf")
+	if !ok {
+		return nil, errHeifStructure
+	}
+
+	ref, item, ok := findHeifAuxiliary(buf, h, iref, kind)
+	if !ok {
+		return nil, ErrHeifAuxiliaryNotFound
+	}
+
+	out := append([]byte{}, buf...)
+	if out[h.pitm.start+8] == 0 {
+		if item > 0xFFFF {
+			return nil, errHeifStructure
+		}
+		binary.BigEndian.PutUint16(out[h.pitm.start+12:], uint16(item))
+	} else {
+		binary.BigEndian.PutUint32(out[h.pitm.start+12:], uint32(item))
+	}
+	copy(out[ref.start+4:], "xaux")
+	if err := showHeifItem(out, iinf, item); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// findHeifAuxiliary returns the auxl reference of an auxiliary image of the primary item and its item id
+func findHeifAuxiliary(buf []byte, h *heifItemProperties, iref isobmffBox, kind HeifAuxiliary) (isobmffBox, int, bool) {
+	idSize := 2
+	if buf[iref.start+8] > 0 {
+		idSize = 4
+	}
+	readID := func(at int) int {
+		if idSize == 4 {
+			return int(binary.BigEndian.Uint32(buf[at:]))
+		}
+		return int(binary.BigEndian.Uint16(buf[at:]))
+	}
+
+	refs, err := isobmffBoxes(buf, iref.start+12, iref.end)
+	if err != nil {
+		return isobmffBox{}, 0, false
+	}
+	for _, ref := range refs {
+		pos := ref.start + 8
+		if ref.typ != "auxl" || pos+idSize+2 > ref.end {
+			continue
+		}
+		from := readID(pos)
+		count := int(binary.BigEndian.Uint16(buf[pos+idSize:]))
+		pos += idSize + 2
+		for ; count > 0 && pos+idSize <= ref.end; count-- {
+			if readID(pos) == h.primary && h.isAuxiliary(buf, from, kind) {
+				return ref, from, true
+			}
+			pos += idSize
+		}
+	}
+	return isobmffBox{}, 0, false
+}
+
+// isAuxiliary returns whether the auxC property of an item is of the given kind
+func (h *heifItemProperties) isAuxiliary(buf []byte, item int, kind HeifAuxiliary) bool {
+	associations, _, _, ok := itemAssociations(buf, h.ipma, item)
+	if !ok {
+		return false
+	}
+	for _, index := range associations {
+		if index < 1 || index > len(h.properties) {
+			continue
+		}
+		property := h.properties[index-1]
+		if property.typ != "auxC" || property.end-property.start < 13 {
+			continue
+		}
+		// auxC is a full box holding a null terminated URN, optionally followed by subtype data
+		auxType := buf[property.start+12 : property.end]
+		if n := bytes.IndexByte(auxType, 0); n >= 0 {
+			auxType = auxType[:n]
+		}
+		for _, t := range heifAuxiliaryTypes[kind] {
+			if string(auxType) == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// showHeifItem clears the hidden flag of the infe box of an item
+func showHeifItem(buf []byte, iinf isobmffBox, item int) error {
+	start := iinf.start + 14
+	if buf[iinf.start+8] > 0 {
+		start = iinf.start + 16
+	}
+	entries, err := isobmffBoxes(buf, start, iinf.end)
+	if err != nil {
+		return err
+	}
+	for _, infe := range entries {
+		if infe.typ != "infe" || infe.end-infe.start < 16 {
+			continue
+		}
+		// item info entries older than version 2 have no flags libheif reads
+		version := buf[infe.start+8]
+		if version < 2 {
+			continue
+		}
+		id := int(binary.BigEndian.Uint16(buf[infe.start+12:]))
+		if version > 2 {
+			id = int(binary.BigEndian.Uint32(buf[infe.start+12:]))
+		}
+		if id == item {
+			buf[infe.start+11] &^= 1
+			return nil
+		}
+	}
+	return errHeifStructure
+}
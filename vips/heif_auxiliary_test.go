@@ -0,0 +1,58 @@
+package vips
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// heifAuxiliaryTestFile builds a HEIF file with a primary item 1 and a hidden depth map item 2 referencing it
+func heifAuxiliaryTestFile() []byte {
+	ispe := isobmffTestBox("ispe", make([]byte, 12))
+	auxC := isobmffTestBox("auxC", []byte{0, 0, 0, 0}, []byte("urn:mpeg:hevc:2015:auxid:2\x00"))
+	ipma := isobmffTestBox("ipma", []byte{0, 0, 0, 0, 0, 0, 0, 2}, []byte{0, 1, 1, 1}, []byte{0, 2, 2, 1, 2})
+	iprp := isobmffTestBox("iprp", isobmffTestBox("ipco", ispe, auxC), ipma)
+	pitm := isobmffTestBox("pitm", []byte{0, 0, 0, 0, 0, 1})
+	iinf := isobmffTestBox("iinf", []byte{0, 0, 0, 0, 0, 2},
+		isobmffTestBox("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("hvc1\x00")),
+		isobmffTestBox("infe", []byte{2, 0, 0, 1, 0, 2, 0, 0}, []byte("hvc1\x00")))
+	iref := isobmffTestBox("iref", []byte{0, 0, 0, 0}, isobmffTestBox("auxl", []byte{0, 2, 0, 1, 0, 1}))
+	iloc := isobmffTestBox("iloc", []byte{0, 0, 0, 0, 0x44, 0x00, 0, 0})
+	meta := isobmffTestBox("meta", []byte{0, 0, 0, 0}, pitm, iinf, iref, iloc, iprp)
+
+	ftyp := isobmffTestBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	return bytes.Join([][]byte{ftyp, meta}, nil)
+}
+
+func Test_selectHeifAuxiliary(t *testing.T) {
+	buf := heifAuxiliaryTestFile()
+
+	out, err := selectHeifAuxiliary(buf, HeifAuxiliaryDepth)
+	require.NoError(t, err)
+	require.Len(t, out, len(buf))
+
+	h, err := parseHeifItemProperties(out)
+	require.NoError(t, err)
+	assert.Equal(t, 2, h.primary)
+	assert.Equal(t, []int{1, 2}, h.associations)
+
+	assert.NotContains(t, string(out), "auxl")
+	// the hidden flag of item 2 is cleared
+	assert.True(t, bytes.Contains(out, []byte{2, 0, 0, 0, 0, 2}))
+
+	// the input is left as is
+	assert.Contains(t, string(buf), "auxl")
+	assert.Equal(t, byte(1), buf[bytes.Index(buf, []byte{2, 0, 0, 1, 0, 2})+3])
+}
+
+func Test_selectHeifAuxiliary__NotFound(t *testing.T) {
+	buf := heifAuxiliaryTestFile()
+
+	_, err := selectHeifAuxiliary(buf, HeifAuxiliaryHDRGainMap)
+	assert.Equal(t, ErrHeifAuxiliaryNotFound, err)
+
+	_, err = selectHeifAuxiliary(heifTestFile(), HeifAuxiliaryDepth)
+	assert.Equal(t, ErrHeifAuxiliaryNotFound, err)
+}
@@ -0,0 +1,387 @@
+package vips
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// nclxColor is the CICP color description of a HEIF or AVIF image, see ITU-T H.273
+type nclxColor struct {
+	primaries uint16
+	transfer  uint16
+	matrix    uint16
+	fullRange bool
+}
+
+// the matrix and range are the ones libheif encodes with when it's given none
+var (
+	nclxDisplayP3 = nclxColor{primaries: 12, transfer: 13, matrix: 6, fullRange: true}
+	nclxRec2020   = nclxColor{primaries: 9, transfer: 1, matrix: 6, fullRange: true}
+)
+
+var errHeifStructure = errors.New("unsupported HEIF file structure")
+
+// isobmffBox is a box of an ISO base media file, its offsets are absolute
+type isobmffBox struct {
+	typ   string
+	start int
+	end   int
+}
+
+// isobmffBoxes reads the boxes between start and end. Boxes with 64-bit sizes are rejected, as they can't be resized.
+func isobmffBoxes(buf []byte, start, end int) ([]isobmffBox, error) {
+	var boxes []isobmffBox
+	for pos := start; pos+8 <= end; {
+		size := int(binary.BigEndian.Uint32(buf[pos:]))
+		if size == 0 {
+			size = end - pos
+		}
+		if size < 8 || pos+size > end {
+			return nil, errHeifStructure
+		}
+		boxes = append(boxes, isobmffBox{typ: string(buf[pos+4 : pos+8]), start: pos, end: pos + size})
+		pos += size
+	}
+	return boxes, nil
+}
+
+func findIsobmffBox(boxes []isobmffBox, typ string) (isobmffBox, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return isobmffBox{}, false
+}
+
+// heifItemProperties locates the properties of the primary item of a HEIF file
+type heifItemProperties struct {
+	meta, pitm, iloc, iprp, ipco, ipma isobmffBox
+	// the children of the meta box
+	children   []isobmffBox
+	properties []isobmffBox
+	primary    int
+	// the 1-based indexes of the properties of the primary item
+	associations []int
+	// the offset of the association count of the primary item and the end of its associations
+	countOffset, entryEnd int
+}
+
+func parseHeifItemProperties(buf []byte) (*heifItemProperties, error) {
+	h := &heifItemProperties{}
+	top, err := isobmffBoxes(buf, 0, len(buf))
+	if err != nil {
+		return nil, err
+	}
+	var pitm isobmffBox
+	var ok, ok1, ok2 bool
+	if h.meta, ok = findIsobmffBox(top, "meta"); !ok {
+		return nil, errHeifStructure
+	}
+	// meta is a full box, with a version and flags before its children
+	children, err := isobmffBoxes(buf, h.meta.start+12, h.meta.end)
+	if err != nil {
+		return nil, err
+	}
+	pitm, ok = findIsobmffBox(children, "pitm")
+	h.iloc, ok1 = findIsobmffBox(children, "iloc")
+	h.iprp, ok2 = findIsobmffBox(children, "iprp")
+	if !ok || !ok1 || !ok2 || pitm.end-pitm.start < 14 {
+		return nil, errHeifStructure
+	}
+	iprpChildren, err := isobmffBoxes(buf, h.iprp.start+8, h.iprp.end)
+	if err != nil {
+		return nil, err
+	}
+	h.ipco, ok1 = findIsobmffBox(iprpChildren, "ipco")
+	h.ipma, ok2 = findIsobmffBox(iprpChildren, "ipma")
+	if !ok1 || !ok2 {
+		return nil, errHeifStructure
+	}
+	if h.properties, err = isobmffBoxes(buf, h.ipco.start+8, h.ipco.end); err != nil {
+		return nil, err
+	}
+
+	primary := int(binary.BigEndian.Uint16(buf[pitm.start+12:]))
+	if buf[pitm.start+8] != 0 {
+		if pitm.end-pitm.start < 16 {
+			return nil, errHeifStructure
+		}
+		primary = int(binary.BigEndian.Uint32(buf[pitm.start+12:]))
+	}
+
+	h.associations, h.countOffset, h.entryEnd, ok = itemAssociations(buf, h.ipma, primary)
+	if !ok {
+		return nil, errHeifStructure
+	}
+	h.children, h.pitm, h.primary = children, pitm, primary
+	return h, nil
+}
+
+// itemAssociations returns the 1-based indexes of the properties of an item, the offset of its association count and
+// the end of its associations
+func itemAssociations(buf []byte, ipma isobmffBox, item int) ([]int, int, int, bool) {
+	version, large := buf[ipma.start+8], buf[ipma.start+11]&1 != 0
+	idSize, indexSize := 2, 1
+	if version > 0 {
+		idSize = 4
+	}
+	if large {
+		indexSize = 2
+	}
+	pos, end := ipma.start+16, ipma.end
+	if pos > end {
+		return nil, 0, 0, false
+	}
+	for count := int(binary.BigEndian.Uint32(buf[pos-4:])); count > 0; count-- {
+		if pos+idSize+1 > end {
+			break
+		}
+		id := int(binary.BigEndian.Uint16(buf[pos:]))
+		if idSize == 4 {
+			id = int(binary.BigEndian.Uint32(buf[pos:]))
+		}
+		countOffset := pos + idSize
+		n := int(buf[countOffset])
+		pos = countOffset + 1
+		if pos+n*indexSize > end {
+			break
+		}
+		if id != item {
+			pos += n * indexSize
+			continue
+		}
+		var associations []int
+		for ; n > 0; n-- {
+			if large {
+				associations = append(associations, int(binary.BigEndian.Uint16(buf[pos:])&0x7FFF))
+			} else {
+				associations = append(associations, int(buf[pos]&0x7F))
+			}
+			pos += indexSize
+		}
+		return associations, countOffset, pos, true
+	}
+	return nil, 0, 0, false
+}
+
+// nclx returns the colr box of type nclx of the primary item
+func (h *heifItemProperties) nclx(buf []byte) (isobmffBox, bool) {
+	for _, index := range h.associations {
+		if index < 1 || index > len(h.properties) {
+			continue
+		}
+		property := h.properties[index-1]
+		if property.typ == "colr" && property.end-property.start >= 19 &&
+			string(buf[property.start+8:property.start+12]) == "nclx" {
+			return property, true
+		}
+	}
+	return isobmffBox{}, false
+}
+
+// readHeifNclx returns the nclx color of the primary image of a HEIF or AVIF file
+func readHeifNclx(buf []byte) (nclxColor, bool) {
+	h, err := parseHeifItemProperties(buf)
+	if err != nil {
+		return nclxColor{}, false
+	}
+	colr, ok := h.nclx(buf)
+	if !ok {
+		return nclxColor{}, false
+	}
+	return nclxColor{
+		primaries: binary.BigEndian.Uint16(buf[colr.start+12:]),
+		transfer:  binary.BigEndian.Uint16(buf[colr.start+14:]),
+		matrix:    binary.BigEndian.Uint16(buf[colr.start+16:]),
+		fullRange: buf[colr.start+18]&0x80 != 0,
+	}, true
+}
+
+// setHeifNclx signals the color space of the primary image of a HEIF or AVIF file with a colr box of type nclx, which
+// libvips leaves to libheif's sRGB default, or omits, for ICC tagged images. An existing nclx box is updated, keeping
+// the matrix and range the pixels are encoded with; otherwise one is added to the item properties, moving the item
+// data after them.
+func setHeifNclx(buf []byte, color nclxColor) ([]byte, error) {
+	h, err := parseHeifItemProperties(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, buf...)
+	if colr, ok := h.nclx(buf); ok {
+		binary.BigEndian.PutUint16(out[colr.start+12:], color.primaries)
+		binary.BigEndian.PutUint16(out[colr.start+14:], color.transfer)
+		return out, nil
+	}
+
+	colr := make([]byte, 19)
+	binary.BigEndian.PutUint32(colr, uint32(len(colr)))
+	copy(colr[4:], "colrnclx")
+	binary.BigEndian.PutUint16(colr[12:], color.primaries)
+	binary.BigEndian.PutUint16(colr[14:], color.transfer)
+	binary.BigEndian.PutUint16(colr[16:], color.matrix)
+	if color.fullRange {
+		colr[18] = 0x80
+	}
+
+	index := len(h.properties) + 1
+	var association []byte
+	switch large := buf[h.ipma.start+11]&1 != 0; {
+	case large && index <= 0x7FFF:
+		association = []byte{byte(index >> 8), byte(index)}
+	case !large && index <= 0x7F:
+		association = []byte{byte(index)}
+	default:
+		return nil, errHeifStructure
+	}
+	if buf[h.countOffset] == 0xFF {
+		return nil, errHeifStructure
+	}
+
+	// the sizes and offsets are updated before the insertions move them
+	delta := len(colr) + len(association)
+	for _, resized := range []struct {
+		box  isobmffBox
+		grow int
+	}{{h.meta, delta}, {h.iprp, delta}, {h.ipco, len(colr)}, {h.ipma, len(association)}} {
+		binary.BigEndian.PutUint32(out[resized.box.start:], uint32(resized.box.end-resized.box.start+resized.grow))
+	}
+	out[h.countOffset]++
+	if err := shiftIlocOffsets(out, h.iloc, h.meta.end, delta); err != nil {
+		return nil, err
+	}
+
+	// the later insertion is done first, so that it doesn't move the earlier one
+	first, second := insertion{h.entryEnd, association}, insertion{h.ipco.end, colr}
+	if h.ipco.end > h.entryEnd {
+		first, second = second, first
+	}
+	return second.into(first.into(out)), nil
+}
+
+type insertion struct {
+	at   int
+	data []byte
+}
+
+func (i insertion) into(buf []byte) []byte {
+	out := make([]byte, 0, len(buf)+len(i.data))
+	out = append(out, buf[:i.at]...)
+	out = append(out, i.data...)
+	return append(out, buf[i.at:]...)
+}
+
+// shiftIlocOffsets moves the file offsets of the item data after the meta box by delta
+func shiftIlocOffsets(buf []byte, iloc isobmffBox, metaEnd, delta int) error {
+	version := buf[iloc.start+8]
+	pos, end := iloc.start+12, iloc.end
+	if version > 2 || pos+2 > end {
+		return errHeifStructure
+	}
+	offsetSize, lengthSize := int(buf[pos]>>4), int(buf[pos]&0xF)
+	baseOffsetSize, indexSize := int(buf[pos+1]>>4), 0
+	if version > 0 {
+		indexSize = int(buf[pos+1] & 0xF)
+	}
+	pos += 2
+
+	read := func(at, size int) uint64 {
+		switch size {
+		case 4:
+			return uint64(binary.BigEndian.Uint32(buf[at:]))
+		case 8:
+			return binary.BigEndian.Uint64(buf[at:])
+		}
+		return 0
+	}
+	write := func(at, size int, v uint64) {
+		if size == 4 {
+			binary.BigEndian.PutUint32(buf[at:], uint32(v))
+		} else {
+			binary.BigEndian.PutUint64(buf[at:], v)
+		}
+	}
+
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	if pos+idSize > end {
+		return errHeifStructure
+	}
+	count := int(binary.BigEndian.Uint16(buf[pos:]))
+	if idSize == 4 {
+		count = int(binary.BigEndian.Uint32(buf[pos:]))
+	}
+	pos += idSize
+
+	for ; count > 0; count-- {
+		pos += idSize
+		method := 0
+		if version > 0 {
+			if pos+2 > end {
+				return errHeifStructure
+			}
+			method = int(binary.BigEndian.Uint16(buf[pos:]) & 0xF)
+			pos += 2
+		}
+		if pos+4+baseOffsetSize > end {
+			return errHeifStructure
+		}
+		// construction method 0 with data reference 0 locates the data in the file itself
+		inFile := method == 0 && binary.BigEndian.Uint16(buf[pos:]) == 0
+		pos += 2
+		base := read(pos, baseOffsetSize)
+		shiftBase := inFile && baseOffsetSize > 0 && base >= uint64(metaEnd)
+		if shiftBase {
+			write(pos, baseOffsetSize, base+uint64(delta))
+		}
+		pos += baseOffsetSize
+		extents := int(binary.BigEndian.Uint16(buf[pos:]))
+		pos += 2
+
+		for ; extents > 0; extents-- {
+			pos += indexSize
+			if pos+offsetSize+lengthSize > end {
+				return errHeifStructure
+			}
+			if inFile && !shiftBase && base+read(pos, offsetSize) >= uint64(metaEnd) {
+				if offsetSize == 0 {
+					return errHeifStructure
+				}
+				write(pos, offsetSize, read(pos, offsetSize)+uint64(delta))
+			}
+			pos += offsetSize + lengthSize
+		}
+	}
+	return nil
+}
+
+// gamutNclx returns the nclx color of a wide color gamut
+func gamutNclx(gamut ColorGamut) (nclxColor, bool) {
+	switch gamut {
+	case ColorGamutDisplayP3:
+		return nclxDisplayP3, true
+	case ColorGamutRec2020:
+		return nclxRec2020, true
+	}
+	return nclxColor{}, false
+}
+
+// signalHeifGamut sets the nclx color of HEIF and AVIF exports of wide gamut images, for decoders ignoring the ICC
+// profile. The export is returned as is when its structure can't be updated.
+func (r *ImageRef) signalHeifGamut(buf []byte) []byte {
+	color, ok := gamutNclx(r.ColorGamut())
+	if !ok {
+		return buf
+	}
+
+	out, err := setHeifNclx(buf, color)
+	if err != nil {
+		govipsLog("govips", LogLevelWarning, fmt.Sprintf("failed to set the nclx color: %v", err.Error()))
+		return buf
+	}
+	return out
+}
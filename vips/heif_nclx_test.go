@@ -0,0 +1,140 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func isobmffTestBox(typ string, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	box := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(box, uint32(8+len(body)))
+	copy(box[4:], typ)
+	return append(box, body...)
+}
+
+// heifTestFile builds a HEIF file with a primary item 1 whose data is in mdat, and a second item whose data is in idat
+func heifTestFile(properties ...[]byte) []byte {
+	associations := []byte{0, 1, byte(len(properties))}
+	for i := range properties {
+		associations = append(associations, byte(i+1))
+	}
+	ipma := isobmffTestBox("ipma", []byte{0, 0, 0, 0, 0, 0, 0, 1}, associations)
+	iprp := isobmffTestBox("iprp", isobmffTestBox("ipco", properties...), ipma)
+	pitm := isobmffTestBox("pitm", []byte{0, 0, 0, 0, 0, 1})
+	idat := isobmffTestBox("idat", []byte("idat"))
+
+	// iloc version 1 with 4 byte offsets and lengths, the offset of item 1 is set below
+	iloc := isobmffTestBox("iloc", []byte{1, 0, 0, 0, 0x44, 0x00, 0, 2},
+		[]byte{0, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 4},
+		[]byte{0, 2, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 4})
+	meta := isobmffTestBox("meta", []byte{0, 0, 0, 0}, pitm, iloc, iprp, idat)
+
+	ftyp := isobmffTestBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	mdat := isobmffTestBox("mdat", []byte("data"))
+
+	buf := bytes.Join([][]byte{ftyp, meta, mdat}, nil)
+	offset := bytes.Index(buf, []byte{0, 1, 0, 0, 0, 0, 0, 1}) + 8
+	binary.BigEndian.PutUint32(buf[offset:], uint32(len(ftyp)+len(meta)+8))
+	return buf
+}
+
+// heifTestItemData returns the data of item 1 through its iloc entry
+func heifTestItemData(t *testing.T, buf []byte) []byte {
+	offset := bytes.Index(buf, []byte{0, 1, 0, 0, 0, 0, 0, 1}) + 8
+	require.True(t, offset > 8)
+	start := int(binary.BigEndian.Uint32(buf[offset:]))
+	return buf[start : start+4]
+}
+
+func Test_setHeifNclx(t *testing.T) {
+	ispe := isobmffTestBox("ispe", make([]byte, 12))
+	buf := heifTestFile(ispe)
+
+	_, ok := readHeifNclx(buf)
+	assert.False(t, ok)
+	assert.Equal(t, []byte("data"), heifTestItemData(t, buf))
+
+	out, err := setHeifNclx(buf, nclxDisplayP3)
+	require.NoError(t, err)
+	color, ok := readHeifNclx(out)
+	require.True(t, ok)
+	assert.Equal(t, nclxDisplayP3, color)
+	assert.Equal(t, len(buf)+20, len(out))
+	assert.Equal(t, []byte("data"), heifTestItemData(t, out))
+	assert.True(t, bytes.Contains(out, []byte("idat")))
+
+	_, err = isobmffBoxes(out, 0, len(out))
+	assert.NoError(t, err)
+
+	// an existing nclx box is updated in place, keeping its matrix and range
+	out, err = setHeifNclx(out, nclxRec2020)
+	require.NoError(t, err)
+	color, ok = readHeifNclx(out)
+	require.True(t, ok)
+	assert.Equal(t, nclxColor{primaries: 9, transfer: 1, matrix: 6, fullRange: true}, color)
+	assert.Equal(t, len(buf)+20, len(out))
+
+	srgb := isobmffTestBox("colr", []byte("nclx"), []byte{0, 1, 0, 13, 0, 5, 0})
+	out, err = setHeifNclx(heifTestFile(ispe, srgb), nclxDisplayP3)
+	require.NoError(t, err)
+	color, ok = readHeifNclx(out)
+	require.True(t, ok)
+	assert.Equal(t, nclxColor{primaries: 12, transfer: 13, matrix: 5, fullRange: false}, color)
+
+	_, err = setHeifNclx([]byte("not a heif file"), nclxDisplayP3)
+	assert.Error(t, err)
+}
+
+func Test_shiftIlocOffsets(t *testing.T) {
+	be16 := func(v uint16) []byte {
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, v)
+		return b
+	}
+	be32 := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		return b
+	}
+	be64 := func(v uint64) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v)
+		return b
+	}
+
+	// version 1 with 8 byte offsets, 4 byte lengths and 8 byte base offsets
+	buf := isobmffTestBox("iloc", []byte{1, 0, 0, 0, 0x84, 0x80}, be16(3),
+		// item 1 in the file, with an extent before the end of meta and one after it
+		be16(1), be16(0), be16(0), be64(0), be16(2), be64(100), be32(4), be64(300), be32(4),
+		// item 2 in idat, which doesn't move
+		be16(2), be16(1), be16(0), be64(0), be16(1), be64(300), be32(4),
+		// item 3 with a base offset after meta, the extent offsets being relative to it
+		be16(3), be16(0), be16(0), be64(0x100000000), be16(1), be64(8), be32(4))
+	iloc := isobmffBox{typ: "iloc", start: 0, end: len(buf)}
+
+	require.NoError(t, shiftIlocOffsets(buf, iloc, 200, 20))
+	assert.Equal(t, uint64(100), binary.BigEndian.Uint64(buf[32:]))
+	assert.Equal(t, uint64(320), binary.BigEndian.Uint64(buf[44:]))
+	assert.Equal(t, uint64(300), binary.BigEndian.Uint64(buf[72:]))
+	assert.Equal(t, uint64(0x100000014), binary.BigEndian.Uint64(buf[90:]))
+	assert.Equal(t, uint64(8), binary.BigEndian.Uint64(buf[100:]))
+
+	// version 2 with 32-bit item ids and extent indexes
+	buf = isobmffTestBox("iloc", []byte{2, 0, 0, 0, 0x44, 0x04}, be32(1),
+		be32(1), be16(0), be16(0), be16(2), be32(1), be32(250), be32(4), be32(2), be32(260), be32(4))
+	iloc = isobmffBox{typ: "iloc", start: 0, end: len(buf)}
+
+	require.NoError(t, shiftIlocOffsets(buf, iloc, 200, 20))
+	assert.Equal(t, uint32(270), binary.BigEndian.Uint32(buf[32:]))
+	assert.Equal(t, uint32(280), binary.BigEndian.Uint32(buf[44:]))
+
+	// offsets can't be moved without an offset field
+	buf = isobmffTestBox("iloc", []byte{0, 0, 0, 0, 0x04, 0x00}, be16(1),
+		be16(1), be16(0), be16(1), be32(4))
+	assert.Error(t, shiftIlocOffsets(buf, isobmffBox{typ: "iloc", start: 0, end: len(buf)}, 0, 20))
+}
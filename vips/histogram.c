@@ -0,0 +1,73 @@
+#include "histogram.h"
+#include "arithmetic.h"
+
+int hist_find(VipsImage *in, VipsImage **out) {
+  return vips_hist_find(in, out, NULL);
+}
+
+int hist_norm(VipsImage *in, VipsImage **out) {
+  return vips_hist_norm(in, out, NULL);
+}
+
+int hist_equal(VipsImage *in, VipsImage **out) {
+  return vips_hist_equal(in, out, NULL);
+}
+
+int hist_cum(VipsImage *in, VipsImage **out) {
+  return vips_hist_cum(in, out, NULL);
+}
+
+// the histogram of every band of the image, only counting the pixels where
+// the mask is nonzero
+int hist_find_masked(VipsImage *in, VipsImage *mask, VipsImage **out) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+  VipsImage **band = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), in->Bands);
+  VipsImage **hist = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), in->Bands);
+  int i;
+
+  if (mask_weights(VIPS_OBJECT(base), mask, &t[0])) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  for (i = 0; i < in->Bands; i++) {
+    if (
+      vips_extract_band(in, &band[i], i, NULL) ||
+      vips_hist_find_indexed(t[0], band[i], &hist[i], NULL)
+    ) {
+      g_object_unref(base);
+      return -1;
+    }
+  }
+
+  // histograms of fewer bins are padded with zeros
+  if (vips_bandjoin(hist, out, in->Bands, NULL)) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+// the values of a histogram as doubles, interleaved by band, the caller has
+// to g_free them
+int hist_values(VipsImage *hist, double **out, int *width, int *bands) {
+  VipsImage *t;
+  size_t size;
+
+  if (vips_cast(hist, &t, VIPS_FORMAT_DOUBLE, NULL)) {
+    return -1;
+  }
+
+  *out = (double *) vips_image_write_to_memory(t, &size);
+  *width = t->Xsize;
+  *bands = t->Bands;
+
+  g_object_unref(t);
+  if (*out == NULL) {
+    return -1;
+  }
+  return 0;
+}
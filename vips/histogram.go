@@ -0,0 +1,86 @@
+package vips
+
+// #include "histogram.h"
+import "C"
+import "unsafe"
+
+// https://libvips.github.io/libvips/API/current/libvips-histogram.html#vips-hist-find
+func vipsHistFind(in *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("histFind")
+	var out *C.VipsImage
+
+	if err := C.hist_find(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-histogram.html#vips-hist-norm
+func vipsHistNorm(in *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("histNorm")
+	var out *C.VipsImage
+
+	if err := C.hist_norm(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-histogram.html#vips-hist-equal
+func vipsHistEqual(in *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("histEqual")
+	var out *C.VipsImage
+
+	if err := C.hist_equal(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-histogram.html#vips-hist-cum
+func vipsHistCum(in *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("histCum")
+	var out *C.VipsImage
+
+	if err := C.hist_cum(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsHistFindMasked(in, mask *C.VipsImage) (*C.VipsImage, error) {
+	incOpCounter("histFindMasked")
+	var out *C.VipsImage
+
+	if err := C.hist_find_masked(in, mask, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// vipsHistValues returns the counts of each band of a histogram, rounded as masked ones are sums of float weights
+func vipsHistValues(hist *C.VipsImage) ([][]int, error) {
+	var out *C.double
+	var width, bands C.int
+
+	if err := C.hist_values(hist, &out, &width, &bands); err != 0 {
+		return nil, handleVipsError()
+	}
+	defer gFreePointer(unsafe.Pointer(out))
+
+	values := fromCArrayDouble(out, int(width)*int(bands))
+	histograms := make([][]int, int(bands))
+	for b := range histograms {
+		histograms[b] = make([]int, int(width))
+		for i := range histograms[b] {
+			histograms[b][i] = roundFloat(values[i*int(bands)+b])
+		}
+	}
+
+	return histograms, nil
+}
@@ -0,0 +1,11 @@
+// https://libvips.github.io/libvips/API/current/libvips-histogram.html
+
+#include <stdlib.h>
+#include <vips/vips.h>
+
+int hist_find(VipsImage *in, VipsImage **out);
+int hist_norm(VipsImage *in, VipsImage **out);
+int hist_equal(VipsImage *in, VipsImage **out);
+int hist_cum(VipsImage *in, VipsImage **out);
+int hist_find_masked(VipsImage *in, VipsImage *mask, VipsImage **out);
+int hist_values(VipsImage *hist, double **out, int *width, int *bands);
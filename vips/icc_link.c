@@ -0,0 +1,148 @@
+#include "icc_link.h"
+
+#include <lcms2.h>
+
+// the number of bands and the 8 and 16-bit lcms formats of a device color
+// space, FALSE if it isn't supported
+static gboolean link_space(cmsColorSpaceSignature space, int *bands,
+                           cmsUInt32Number *format8,
+                           cmsUInt32Number *format16) {
+  switch (space) {
+    case cmsSigRgbData:
+      *bands = 3;
+      *format8 = TYPE_RGB_8;
+      *format16 = TYPE_RGB_16;
+      return TRUE;
+    case cmsSigCmykData:
+      *bands = 4;
+      *format8 = TYPE_CMYK_8;
+      *format16 = TYPE_CMYK_16;
+      return TRUE;
+    case cmsSigGrayData:
+      *bands = 1;
+      *format8 = TYPE_GRAY_8;
+      *format16 = TYPE_GRAY_16;
+      return TRUE;
+    default:
+      return FALSE;
+  }
+}
+
+// Applies a device link as the single profile of an lcms transform, from the
+// device space of its input to the one of its output. 16-bit images stay
+// 16-bit, everything else is converted as 8-bit. Alpha is kept as is.
+int icc_device_link(VipsImage *in, VipsImage **out, const void *link,
+                    size_t length, VipsIntent intent) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 6);
+  VipsImage *color = in;
+  VipsImage *result;
+  cmsHPROFILE profile;
+  cmsHTRANSFORM transform;
+  cmsUInt32Number in8, in16, out8, out16;
+  int inBands, outBands, bands = in->Bands;
+  gboolean is16 = in->BandFmt == VIPS_FORMAT_USHORT;
+  VipsBandFormat format = is16 ? VIPS_FORMAT_USHORT : VIPS_FORMAT_UCHAR;
+  size_t size, sample = is16 ? 2 : 1;
+  void *pixels, *converted;
+
+  profile = cmsOpenProfileFromMem(link, (cmsUInt32Number)length);
+  if (!profile) {
+    vips_error("icc_device_link", "%s", "unable to read the device link");
+    g_object_unref(base);
+    return -1;
+  }
+  if (cmsGetDeviceClass(profile) != cmsSigLinkClass ||
+      !link_space(cmsGetColorSpace(profile), &inBands, &in8, &in16) ||
+      !link_space(cmsGetPCS(profile), &outBands, &out8, &out16)) {
+    vips_error("icc_device_link", "%s",
+               "not a device link between RGB, CMYK or gray spaces");
+    cmsCloseProfile(profile);
+    g_object_unref(base);
+    return -1;
+  }
+
+  if (vips_image_hasalpha(in)) {
+    bands -= 1;
+    if (vips_extract_band(in, &t[0], 0, "n", bands, NULL) ||
+        vips_extract_band(in, &t[1], bands, NULL)) {
+      cmsCloseProfile(profile);
+      g_object_unref(base);
+      return -1;
+    }
+    color = t[0];
+  }
+  if (bands != inBands) {
+    vips_error("icc_device_link", "the device link expects %d bands, got %d",
+               inBands, bands);
+    cmsCloseProfile(profile);
+    g_object_unref(base);
+    return -1;
+  }
+
+  transform = cmsCreateTransform(profile, is16 ? in16 : in8, NULL,
+                                 is16 ? out16 : out8, intent, 0);
+  cmsCloseProfile(profile);
+  if (!transform) {
+    vips_error("icc_device_link", "%s", "unable to create the transform");
+    g_object_unref(base);
+    return -1;
+  }
+
+  if (vips_cast(color, &t[2], format, NULL) ||
+      !(pixels = vips_image_write_to_memory(t[2], &size))) {
+    cmsDeleteTransform(transform);
+    g_object_unref(base);
+    return -1;
+  }
+
+  converted = g_malloc((size_t)in->Xsize * in->Ysize * outBands * sample);
+  cmsDoTransform(transform, pixels, converted,
+                 (cmsUInt32Number)in->Xsize * in->Ysize);
+  cmsDeleteTransform(transform);
+  g_free(pixels);
+
+  t[3] = vips_image_new_from_memory_copy(
+      converted, (size_t)in->Xsize * in->Ysize * outBands * sample,
+      in->Xsize, in->Ysize, outBands, format);
+  g_free(converted);
+  if (!t[3]) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  // the pixels were converted outside of libvips, take the metadata of in,
+  // e.g. EXIF, orientation, resolution and page-height, but keep the layout
+  // of the converted pixels. The embedded profile no longer describes them.
+  if (vips_image_pipelinev(t[3], VIPS_DEMAND_STYLE_ANY, in, NULL)) {
+    g_object_unref(base);
+    return -1;
+  }
+  t[3]->Bands = outBands;
+  t[3]->BandFmt = format;
+  vips_image_remove(t[3], VIPS_META_ICC_NAME);
+  result = t[3];
+
+  if (color != in) {
+    if (vips_cast(t[1], &t[4], format, NULL) ||
+        vips_bandjoin2(result, t[4], &t[5], NULL)) {
+      g_object_unref(base);
+      return -1;
+    }
+    result = t[5];
+  }
+
+  if (vips_copy(result, out, "interpretation",
+                outBands == 4   ? VIPS_INTERPRETATION_CMYK
+                : outBands == 1 ? (is16 ? VIPS_INTERPRETATION_GREY16
+                                        : VIPS_INTERPRETATION_B_W)
+                : is16          ? VIPS_INTERPRETATION_RGB16
+                                : VIPS_INTERPRETATION_sRGB,
+                NULL)) {
+    g_object_unref(base);
+    return -1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
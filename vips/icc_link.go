@@ -0,0 +1,18 @@
+package vips
+
+// #cgo pkg-config: lcms2
+// #include "icc_link.h"
+import "C"
+import "unsafe"
+
+// vipsICCDeviceLink applies a device link with lcms
+func vipsICCDeviceLink(in *C.VipsImage, link []byte, intent Intent) (*C.VipsImage, error) {
+	incOpCounter("iccDeviceLink")
+	var out *C.VipsImage
+
+	if err := C.icc_device_link(in, &out, unsafe.Pointer(&link[0]), C.size_t(len(link)), C.VipsIntent(intent)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,7 @@
+// https://www.littlecms.com/LittleCMS2.16%20API.pdf
+
+#include <stdlib.h>
+#include <vips/vips.h>
+
+int icc_device_link(VipsImage *in, VipsImage **out, const void *link,
+                    size_t length, VipsIntent intent);
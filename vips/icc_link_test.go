@@ -0,0 +1,46 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRef_TransformICCDeviceLink__Apply(t *testing.T) {
+	Startup(nil)
+
+	newImage := func(alpha bool) *ImageRef {
+		img, err := Black(10, 10)
+		require.NoError(t, err)
+		require.NoError(t, img.ToColorSpace(InterpretationSRGB))
+		require.NoError(t, img.Linear([]float64{1, 1, 1}, []float64{200, 100, 50}))
+		require.NoError(t, img.Cast(BandFormatUchar))
+		if alpha {
+			require.NoError(t, img.AddAlpha())
+		}
+		return img
+	}
+
+	// the fixture swaps the red and blue channels
+	img := newImage(false)
+	err := img.TransformICCDeviceLink(resources+"icc-devicelink-swap-rb.icc", IntentPerceptual)
+	require.NoError(t, err)
+	assert.Equal(t, 3, img.Bands())
+	point, err := img.GetPoint(5, 5)
+	require.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{50, 100, 200}, point, 1)
+
+	img = newImage(true)
+	require.NoError(t, img.SetOrientation(6))
+	require.NoError(t, img.SetPageHeight(5))
+	err = img.TransformICCProfile(resources + "icc-devicelink-swap-rb.icc")
+	require.NoError(t, err)
+	point, err = img.GetPoint(5, 5)
+	require.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{50, 100, 200, 255}, point, 1)
+
+	// the metadata of the image is kept
+	assert.Equal(t, 6, img.Orientation())
+	assert.Equal(t, 5, img.PageHeight())
+}
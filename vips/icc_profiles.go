@@ -1,9 +1,11 @@
 package vips
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sync"
 )
 
 var (
@@ -649,6 +651,11 @@ var (
 	SGrayV2MicroICCProfilePath       = filepath.Join(temporaryDirectory, "sgray_v2_micro.icc")
 	SRGBIEC6196621ICCProfilePath     = filepath.Join(temporaryDirectory, "srgb_iec61966_2_1.icc")
 	GenericGrayGamma22ICCProfilePath = filepath.Join(temporaryDirectory, "generic_gray_gamma_2_2.icc")
+	DisplayP3ICCProfilePath          = filepath.Join(temporaryDirectory, "display_p3.icc")
+	Rec2020ICCProfilePath            = filepath.Join(temporaryDirectory, "rec2020.icc")
+
+	registeredICCProfilesLock sync.RWMutex
+	registeredICCProfiles     = map[string]string{}
 )
 
 func initializeICCProfiles() {
@@ -656,6 +663,46 @@ func initializeICCProfiles() {
 	storeIccProfile(SGrayV2MicroICCProfilePath, sGrayV2MicroICCProfile)
 	storeIccProfile(SRGBIEC6196621ICCProfilePath, sRGBIEC6196621ICCProfile)
 	storeIccProfile(GenericGrayGamma22ICCProfilePath, genericGrayGamma22ICCProfile)
+	storeIccProfile(DisplayP3ICCProfilePath, displayP3ICCProfile)
+	storeIccProfile(Rec2020ICCProfilePath, rec2020ICCProfile)
+}
+
+// RegisterICCProfile registers an ICC profile under a name, so that it can be given by name instead of by path to the
+// functions taking a profile, e.g. TransformICCProfile or SoftProof, without shipping profile files with the
+// deployment. Like the built-in profiles, the profile is stored in a temporary file, which libvips reads. Registering
+// a name again replaces its profile; registered names take precedence over the built-in profile names of libvips,
+// such as "srgb" or "cmyk".
+func RegisterICCProfile(name string, data []byte) error {
+	if name == "" {
+		return errors.New("the name of an ICC profile can't be empty")
+	}
+	if !isICCProfile(data) {
+		return fmt.Errorf("the data registered as %s is not an ICC profile", name)
+	}
+
+	registeredICCProfilesLock.Lock()
+	defer registeredICCProfilesLock.Unlock()
+
+	// the name is hex encoded, as it may contain characters that aren't allowed in file names
+	path := filepath.Join(temporaryDirectory, fmt.Sprintf("registered_%x.icc", name))
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("couldn't store ICC profile %s in '%v': %v", name, path, err)
+	}
+
+	registeredICCProfiles[name] = path
+	return nil
+}
+
+// iccProfilePath returns the path of the ICC profile registered under the name, or the name unchanged if none is, as
+// it is then a path or a built-in profile name of libvips
+func iccProfilePath(name string) string {
+	registeredICCProfilesLock.RLock()
+	defer registeredICCProfilesLock.RUnlock()
+
+	if path, ok := registeredICCProfiles[name]; ok {
+		return path
+	}
+	return name
 }
 
 func storeIccProfile(path string, data []byte) {
@@ -15,6 +15,25 @@ func Test_ICCProfileInitialisation(t *testing.T) {
 	assertIccProfile(t, sGrayV2MicroICCProfile, SGrayV2MicroICCProfilePath)
 	assertIccProfile(t, sRGBIEC6196621ICCProfile, SRGBIEC6196621ICCProfilePath)
 	assertIccProfile(t, genericGrayGamma22ICCProfile, GenericGrayGamma22ICCProfilePath)
+	assertIccProfile(t, displayP3ICCProfile, DisplayP3ICCProfilePath)
+	assertIccProfile(t, rec2020ICCProfile, Rec2020ICCProfilePath)
+}
+
+func TestRegisterICCProfile(t *testing.T) {
+	Startup(nil)
+
+	err := RegisterICCProfile("display/srgb", sRGBIEC6196621ICCProfile)
+	require.NoError(t, err)
+	assertIccProfile(t, sRGBIEC6196621ICCProfile, iccProfilePath("display/srgb"))
+	assert.Equal(t, "cmyk", iccProfilePath("cmyk"))
+
+	image, err := NewImageFromFile(resources + "jpg-24bit-icc-adobe-rgb.jpg")
+	require.NoError(t, err)
+	err = image.TransformICCProfile("display/srgb")
+	require.NoError(t, err)
+
+	assert.Error(t, RegisterICCProfile("", sRGBIEC6196621ICCProfile))
+	assert.Error(t, RegisterICCProfile("invalid", []byte("not a profile")))
 }
 
 func assertIccProfile(t *testing.T, expectedProfile []byte, path string) {
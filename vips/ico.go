@@ -0,0 +1,297 @@
+package vips
+
+// #include <vips/vips.h>
+import "C"
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// DefaultIcoSizes are the icon sizes ExportIco embeds when none are given
+var DefaultIcoSizes = []int{16, 32, 48}
+
+// ErrInvalidIco is returned when an ICO file is malformed or holds an unsupported image
+var ErrInvalidIco = errors.New("invalid ICO file")
+
+// icoEntry is an image of an ICO file, either PNG or BMP (DIB) encoded
+type icoEntry struct {
+	width, height int
+	bitCount      int
+	data          []byte
+}
+
+// parseIco reads the directory of an ICO file
+func parseIco(buf []byte) ([]icoEntry, error) {
+	if !isICO(buf) {
+		return nil, ErrInvalidIco
+	}
+
+	count := int(binary.LittleEndian.Uint16(buf[4:6]))
+	if len(buf) < 6+16*count {
+		return nil, ErrInvalidIco
+	}
+
+	entries := make([]icoEntry, 0, count)
+	for i := 0; i < count; i++ {
+		e := buf[6+16*i : 6+16*(i+1)]
+		size := int(binary.LittleEndian.Uint32(e[8:12]))
+		offset := int(binary.LittleEndian.Uint32(e[12:16]))
+		if offset < 0 || size <= 0 || offset+size > len(buf) {
+			return nil, ErrInvalidIco
+		}
+
+		entry := icoEntry{
+			width:    int(e[0]),
+			height:   int(e[1]),
+			bitCount: int(binary.LittleEndian.Uint16(e[6:8])),
+			data:     buf[offset : offset+size],
+		}
+		// a width or height of 0 stands for 256
+		if entry.width == 0 {
+			entry.width = 256
+		}
+		if entry.height == 0 {
+			entry.height = 256
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// icoToPNG extracts the best resolution image of an ICO file as PNG
+func icoToPNG(src []byte) ([]byte, error) {
+	entries, err := parseIco(src)
+	if err != nil {
+		return nil, err
+	}
+
+	best := entries[0]
+	for _, e := range entries[1:] {
+		if e.width*e.height > best.width*best.height ||
+			e.width*e.height == best.width*best.height && e.bitCount > best.bitCount {
+			best = e
+		}
+	}
+
+	if isPNG(best.data) {
+		return best.data, nil
+	}
+
+	img, err := decodeIcoDIB(best.data)
+	if err != nil {
+		return nil, err
+	}
+
+	var w bytes.Buffer
+	pngEnc := png.Encoder{
+		CompressionLevel: png.NoCompression,
+	}
+	if err := pngEnc.Encode(&w, img); err != nil {
+		return nil, err
+	}
+
+	return w.Bytes(), nil
+}
+
+// decodeIcoDIB decodes an uncompressed 1, 4, 8, 24 or 32-bit BMP without file header as stored in ICO files.
+// The stored height covers the color image followed by the 1-bit transparency (AND) mask.
+func decodeIcoDIB(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, ErrInvalidIco
+	}
+
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:36]))
+
+	if width <= 0 || height <= 0 || headerSize < 40 || headerSize > len(data) {
+		return nil, ErrInvalidIco
+	}
+	// BI_RGB, or BI_BITFIELDS which ICO files only use with the default 32-bit BGRA layout
+	if compression != 0 && !(compression == 3 && bitCount == 32) {
+		return nil, fmt.Errorf("%w: compression %d is not supported", ErrInvalidIco, compression)
+	}
+
+	var palette []color.NRGBA
+	offset := headerSize
+	if compression == 3 {
+		offset += 12
+	}
+	switch bitCount {
+	case 1, 4, 8:
+		if colorsUsed == 0 || colorsUsed > 1<<bitCount {
+			colorsUsed = 1 << bitCount
+		}
+		if offset+4*colorsUsed > len(data) {
+			return nil, ErrInvalidIco
+		}
+		for i := 0; i < colorsUsed; i++ {
+			p := data[offset+4*i:]
+			palette = append(palette, color.NRGBA{R: p[2], G: p[1], B: p[0], A: 255})
+		}
+		offset += 4 * colorsUsed
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("%w: %d bits per pixel are not supported", ErrInvalidIco, bitCount)
+	}
+
+	stride := (width*bitCount + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	if offset+stride*height > len(data) {
+		return nil, ErrInvalidIco
+	}
+	pixels := data[offset : offset+stride*height]
+
+	// the mask is optional for 32-bit images, which carry alpha themselves
+	var mask []byte
+	if maskOffset := offset + stride*height; maskOffset+maskStride*height <= len(data) {
+		mask = data[maskOffset : maskOffset+maskStride*height]
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		// rows are stored bottom-up
+		row := pixels[(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{R: row[4*x+2], G: row[4*x+1], B: row[4*x], A: row[4*x+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{R: row[3*x+2], G: row[3*x+1], B: row[3*x], A: 255}
+			default:
+				perByte := 8 / bitCount
+				shift := uint(8 - bitCount*(x%perByte+1))
+				index := int(row[x/perByte]>>shift) & (1<<bitCount - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// images without alpha channel, and 32-bit ones whose alpha is all 0, are made transparent by the mask
+	if mask != nil && (bitCount != 32 || !hasAlpha) {
+		for y := 0; y < height; y++ {
+			row := mask[(height-1-y)*maskStride:]
+			for x := 0; x < width; x++ {
+				c := img.NRGBAAt(x, y)
+				c.A = 255
+				if row[x/8]&(0x80>>uint(x%8)) != 0 {
+					c.A = 0
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// ExportIco exports the image as ICO holding a PNG encoded variant for each of the given sizes, DefaultIcoSizes
+// when none are given. Every variant is a square of up to 256 pixels, the image being scaled to fit and centered on
+// a transparent background. Only the first page of a multi-page image is used.
+func (r *ImageRef) ExportIco(sizes []int) ([]byte, *ImageMetadata, error) {
+	if len(sizes) == 0 {
+		sizes = DefaultIcoSizes
+	}
+
+	in := r.image
+	if r.Pages() > 1 {
+		page, err := vipsExtractArea(r.image, 0, 0, r.Width(), r.PageHeight())
+		if err != nil {
+			return nil, nil, err
+		}
+		defer clearImage(page)
+		in = page
+	}
+
+	variants := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		if size < 1 || size > 256 {
+			return nil, nil, fmt.Errorf("ICO sizes must be between 1 and 256, got %d", size)
+		}
+
+		buf, err := icoVariant(in, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		variants[i] = buf
+	}
+
+	return encodeIco(sizes, variants), r.newMetadata(ImageTypeICO), nil
+}
+
+// icoVariant scales in to fit a size x size RGBA square and encodes it as PNG
+func icoVariant(in *C.VipsImage, size int) ([]byte, error) {
+	thumbnail, err := vipsThumbnail(in, size, size, InterestingNone, SizeBoth)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(thumbnail)
+
+	srgb, err := vipsToColorSpace(thumbnail, InterpretationSRGB)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(srgb)
+
+	rgba := srgb
+	if !vipsHasAlpha(srgb) {
+		if rgba, err = vipsAddAlpha(srgb); err != nil {
+			return nil, err
+		}
+		defer clearImage(rgba)
+	}
+
+	width, height := int(rgba.Xsize), int(rgba.Ysize)
+	square, err := vipsEmbedBackground(rgba, (size-width)/2, (size-height)/2, size, size, &ColorRGBA{})
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(square)
+
+	return vipsSavePNGToBuffer(square, PngExportParams{StripMetadata: true, Compression: 9})
+}
+
+// encodeIco writes an ICO file of PNG encoded images of the given sizes
+func encodeIco(sizes []int, images [][]byte) []byte {
+	var buf bytes.Buffer
+
+	header := make([]byte, 6)
+	binary.LittleEndian.PutUint16(header[2:4], 1) // icon resource
+	binary.LittleEndian.PutUint16(header[4:6], uint16(len(images)))
+	buf.Write(header)
+
+	offset := 6 + 16*len(images)
+	for i, img := range images {
+		entry := make([]byte, 16)
+		// a width or height of 256 is stored as 0
+		entry[0] = uint8(sizes[i] % 256)
+		entry[1] = uint8(sizes[i] % 256)
+		binary.LittleEndian.PutUint16(entry[4:6], 1)  // color planes
+		binary.LittleEndian.PutUint16(entry[6:8], 32) // bits per pixel
+		binary.LittleEndian.PutUint32(entry[8:12], uint32(len(img)))
+		binary.LittleEndian.PutUint32(entry[12:16], uint32(offset))
+		buf.Write(entry)
+		offset += len(img)
+	}
+
+	for _, img := range images {
+		buf.Write(img)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,44 @@
+package vips
+
+import (
+	"encoding/binary"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeIcoDIB(t *testing.T) {
+	// a 2x2 4-bit image using palette colors 1 and 2, bottom row first, the top left pixel masked out
+	dib := make([]byte, 40)
+	binary.LittleEndian.PutUint32(dib[0:4], 40)
+	binary.LittleEndian.PutUint32(dib[4:8], 2)
+	binary.LittleEndian.PutUint32(dib[8:12], 4)
+	binary.LittleEndian.PutUint16(dib[12:14], 1)
+	binary.LittleEndian.PutUint16(dib[14:16], 4)
+	binary.LittleEndian.PutUint32(dib[32:36], 3)
+	dib = append(dib, 0, 0, 0, 0, 0, 0, 255, 0, 255, 0, 0, 0) // BGRx palette: black, red, blue
+	dib = append(dib, 0x21, 0, 0, 0, 0x12, 0, 0, 0)           // pixels
+	dib = append(dib, 0, 0, 0, 0, 0x80, 0, 0, 0)              // AND mask
+
+	img, err := decodeIcoDIB(dib)
+	require.NoError(t, err)
+	assert.Equal(t, 2, img.Bounds().Dx())
+	assert.Equal(t, 2, img.Bounds().Dy())
+
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	assert.Equal(t, color.NRGBA{R: 255}, img.At(0, 0))
+	assert.Equal(t, blue, img.At(1, 0))
+	assert.Equal(t, blue, img.At(0, 1))
+	assert.Equal(t, red, img.At(1, 1))
+}
+
+func TestParseIco_Invalid(t *testing.T) {
+	_, err := parseIco([]byte("\x00\x00\x01\x00\x01\x00"))
+	assert.Equal(t, ErrInvalidIco, err)
+
+	_, err = icoToPNG([]byte("not an icon at all"))
+	assert.Equal(t, ErrInvalidIco, err)
+}
@@ -6,3 +6,10 @@ void clear_image(VipsImage **image) {
   // https://developer.gnome.org/gobject/stable/gobject-The-Base-Object-Type.html#g-clear-object
   if (G_IS_OBJECT(*image)) g_clear_object(image);
 }
+
+void set_image_kill(VipsImage *image, int kill) {
+  vips_image_set_kill(image, kill);
+}
+
+// vips_image_iskilled() clears the flag and sets an error, read it directly
+int is_image_killed(VipsImage *image) { return image->kill; }
@@ -10,10 +10,14 @@ import (
 	"image"
 	"io"
 	"io/ioutil"
+	"math"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -34,6 +38,8 @@ type ImageRef struct {
 	lock                sync.Mutex
 	preMultiplication   *PreMultiplicationState
 	optimizedIccProfile string
+	// unmodified is set while the pixels are exactly those encoded in buf
+	unmodified bool
 }
 
 // ImageMetadata is a data structure holding the width, height, orientation and other metadata of the picture.
@@ -84,6 +90,30 @@ func (p *IntParameter) Get() int {
 	return p.value.(int)
 }
 
+type AccessParameter struct {
+	Parameter
+}
+
+func (p *AccessParameter) Set(v Access) {
+	p.set(v)
+}
+
+func (p *AccessParameter) Get() Access {
+	return p.value.(Access)
+}
+
+type HeifAuxiliaryParameter struct {
+	Parameter
+}
+
+func (p *HeifAuxiliaryParameter) Set(v HeifAuxiliary) {
+	p.set(v)
+}
+
+func (p *HeifAuxiliaryParameter) Get() HeifAuxiliary {
+	return p.value.(HeifAuxiliary)
+}
+
 type Float64Parameter struct {
 	Parameter
 }
@@ -96,6 +126,30 @@ func (p *Float64Parameter) Get() float64 {
 	return p.value.(float64)
 }
 
+type StringParameter struct {
+	Parameter
+}
+
+func (p *StringParameter) Set(v string) {
+	p.set(v)
+}
+
+func (p *StringParameter) Get() string {
+	return p.value.(string)
+}
+
+type ColorRGBAParameter struct {
+	Parameter
+}
+
+func (p *ColorRGBAParameter) Set(v ColorRGBA) {
+	p.set(v)
+}
+
+func (p *ColorRGBAParameter) Get() ColorRGBA {
+	return p.value.(ColorRGBA)
+}
+
 // ImportParams are options for loading an image. Some are type-specific.
 // For default loading, use NewImportParams() or specify nil
 type ImportParams struct {
@@ -103,11 +157,28 @@ type ImportParams struct {
 	FailOnError BoolParameter
 	Page        IntParameter
 	NumPages    IntParameter
-	Density     IntParameter
+	Density     Float64Parameter
+	Access      AccessParameter // use AccessSequential for streaming pipelines such as resize and encode
+	Revalidate  BoolParameter   // bypass the operation cache, e.g. when a file changed on disk (libvips 8.12+)
 
 	JpegShrinkFactor IntParameter
 	HeifThumbnail    BoolParameter
+	HeifAuxiliary    HeifAuxiliaryParameter // auxiliary image of the primary HEIF/AVIF image to load, see LoadImageFromBuffer
 	SvgUnlimited     BoolParameter
+	WebpScale        Float64Parameter   // scale factor applied on load, e.g. 0.25
+	WebpShrink       IntParameter       // shrink factor applied on load, ignored if WebpScale is set
+	Jp2kReduction    IntParameter       // resolution level to decode, each level halves width and height, overrides Page
+	TiffSubifd       IntParameter       // sub-IFD to load, e.g. a pyramid level, -1 for the main image
+	TiffAutorotate   BoolParameter      // overrides AutoRotate for TIFF images
+	PdfPassword      StringParameter    // password of an encrypted PDF
+	PdfBackground    ColorRGBAParameter // color the PDF pages are rendered on, white by default
+	PsdLayer         IntParameter       // layer (zero based) to load instead of the flattened composite, see LoadImageFromBuffer
+	RawDecoder       RawDecoder         // decodes camera RAW files, which are otherwise loaded through ImageMagick
+
+	// PreferEmbeddedPreview makes LoadThumbnailFromBuffer and LoadThumbnailFromFile use the JPEG preview embedded in
+	// JPEG (EXIF thumbnail) and camera RAW files when it is at least as large as the thumbnail. libvips already uses
+	// the thumbnails of HEIF images on its own.
+	PreferEmbeddedPreview BoolParameter
 }
 
 // NewImportParams creates default ImportParams
@@ -118,35 +189,124 @@ func NewImportParams() *ImportParams {
 }
 
 // OptionString convert import params to option_string
+// The loader being unknown, every param is converted except the TIFF and JPEG2000 only TiffAutorotate, TiffSubifd
+// and Jp2kReduction
 func (i *ImportParams) OptionString() string {
+	return i.optionString(ImageTypeUnknown)
+}
+
+// loaderOptions lists the options the loader of each image type takes besides fail, access and revalidate, which
+// every loader takes, following the set_*load_options functions of foreign.c
+var loaderOptions = map[ImageType][]string{
+	ImageTypeJPEG:   {"autorotate", "shrink"},
+	ImageTypePNG:    {},
+	ImageTypeWEBP:   {"page", "n", "scale"},
+	ImageTypeTIFF:   {"autorotate", "page", "n", "subifd"},
+	ImageTypeGIF:    {"page", "n"},
+	ImageTypePDF:    {"page", "n", "dpi", "password", "background"},
+	ImageTypeSVG:    {"unlimited", "dpi"},
+	ImageTypeHEIF:   {"autorotate", "thumbnail", "page", "n"},
+	ImageTypeAVIF:   {"autorotate", "thumbnail", "page", "n"},
+	ImageTypeJP2K:   {"page"},
+	ImageTypeJXL:    {},
+	ImageTypeMagick: {"page", "n"},
+}
+
+// acceptsOption returns whether the loader of format takes the option, unknown loaders are given every option but
+// the TIFF only subifd
+func acceptsOption(format ImageType, option string) bool {
+	options, ok := loaderOptions[format]
+	if !ok {
+		return option != "subifd"
+	}
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// optionString converts the params the loader of format takes, following the precedence of createImportParams
+func (i *ImportParams) optionString(format ImageType) string {
 	var values []string
+	add := func(option, value string) {
+		if acceptsOption(format, option) {
+			values = append(values, option+"="+value)
+		}
+	}
+
 	if v := i.NumPages; v.IsSet() {
-		values = append(values, "n="+strconv.Itoa(v.Get()))
+		add("n", strconv.Itoa(v.Get()))
 	}
-	if v := i.Page; v.IsSet() {
-		values = append(values, "page="+strconv.Itoa(v.Get()))
+	if v := i.Jp2kReduction; v.IsSet() && format == ImageTypeJP2K {
+		add("page", strconv.Itoa(v.Get()))
+	} else if v := i.Page; v.IsSet() {
+		add("page", strconv.Itoa(v.Get()))
+	} else if v := i.PsdLayer; v.IsSet() {
+		add("page", strconv.Itoa(v.Get()+1))
 	}
 	if v := i.Density; v.IsSet() {
-		values = append(values, "dpi="+strconv.Itoa(v.Get()))
+		add("dpi", strconv.FormatFloat(v.Get(), 'f', -1, 64))
 	}
 	if v := i.FailOnError; v.IsSet() {
 		values = append(values, "fail="+boolToStr(v.Get()))
 	}
 	if v := i.JpegShrinkFactor; v.IsSet() {
-		values = append(values, "shrink="+strconv.Itoa(v.Get()))
+		add("shrink", strconv.Itoa(v.Get()))
+	}
+	if v := i.TiffAutorotate; v.IsSet() && format == ImageTypeTIFF {
+		add("autorotate", boolToStr(v.Get()))
+	} else if v := i.AutoRotate; v.IsSet() {
+		add("autorotate", boolToStr(v.Get()))
+	}
+	if v := i.TiffSubifd; v.IsSet() {
+		add("subifd", strconv.Itoa(v.Get()))
+	}
+	if v := i.Access; v.IsSet() {
+		values = append(values, "access="+accessNames[v.Get()])
 	}
-	if v := i.AutoRotate; v.IsSet() {
-		values = append(values, "autorotate="+boolToStr(v.Get()))
+	if v := i.Revalidate; v.IsSet() {
+		values = append(values, "revalidate="+boolToStr(v.Get()))
 	}
 	if v := i.SvgUnlimited; v.IsSet() {
-		values = append(values, "unlimited="+boolToStr(v.Get()))
+		add("unlimited", boolToStr(v.Get()))
 	}
 	if v := i.HeifThumbnail; v.IsSet() {
-		values = append(values, "thumbnail="+boolToStr(v.Get()))
+		add("thumbnail", boolToStr(v.Get()))
+	}
+	if v := i.WebpScale; v.IsSet() {
+		add("scale", strconv.FormatFloat(v.Get(), 'f', -1, 64))
+	} else if v := i.WebpShrink; v.IsSet() && v.Get() > 0 {
+		add("scale", strconv.FormatFloat(1/float64(v.Get()), 'f', -1, 64))
+	}
+	if v := i.PdfPassword; v.IsSet() {
+		add("password", quoteOption(v.Get()))
+	}
+	if v := i.PdfBackground; v.IsSet() {
+		c := v.Get()
+		add("background", quoteOption(fmt.Sprintf("%d %d %d %d", c.R, c.G, c.B, c.A)))
 	}
 	return strings.Join(values, ",")
 }
 
+// quoteOption quotes an option_string value so it may contain separators such as commas
+func quoteOption(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
+
+// transformsPixels returns whether the params make the loaded pixels differ from the encoded image
+func (i *ImportParams) transformsPixels() bool {
+	return (i.AutoRotate.IsSet() && i.AutoRotate.Get()) ||
+		(i.TiffAutorotate.IsSet() && i.TiffAutorotate.Get()) ||
+		(i.HeifThumbnail.IsSet() && i.HeifThumbnail.Get()) ||
+		(i.JpegShrinkFactor.IsSet() && i.JpegShrinkFactor.Get() > 1) ||
+		i.Page.IsSet() || i.NumPages.IsSet() || i.Density.IsSet() ||
+		i.WebpScale.IsSet() || i.WebpShrink.IsSet() ||
+		i.Jp2kReduction.IsSet() || i.TiffSubifd.IsSet() || i.PdfBackground.IsSet() || i.PsdLayer.IsSet() ||
+		i.HeifAuxiliary.IsSet()
+}
+
 func boolToStr(v bool) string {
 	if v {
 		return "TRUE"
@@ -154,7 +314,8 @@ func boolToStr(v bool) string {
 	return "FALSE"
 }
 
-// ExportParams are options when exporting an image to file or buffer.
+// ExportParams are options when exporting an image to file or buffer. Without a Format, the image is exported in its
+// native format with the default parameters of the format, only the metadata options applying.
 // Deprecated: Use format-specific params
 type ExportParams struct {
 	Format             ImageType
@@ -171,6 +332,10 @@ type ExportParams struct {
 	OptimizeScans      bool          // jpeg param
 	QuantTable         int           // jpeg param
 	Speed              int           // avif param
+	KeepMetadata       []string      // metadata fields to keep, see JpegExportParams
+	StripExif          bool          // removes the EXIF data, see JpegExportParams
+	StripXMP           bool          // removes the XMP data, see JpegExportParams
+	KeepICC            bool          // keeps the ICC profile, see JpegExportParams
 }
 
 // NewDefaultExportParams creates default values for an export when image type is not JPEG, PNG or WEBP.
@@ -225,6 +390,7 @@ func NewDefaultWEBPExportParams() *ExportParams {
 
 // JpegExportParams are options when exporting a JPEG to file or buffer
 type JpegExportParams struct {
+	// StripMetadata removes all metadata, the ICC profile too unless KeepICC is set
 	StripMetadata      bool
 	Quality            int
 	Interlace          bool
@@ -234,6 +400,16 @@ type JpegExportParams struct {
 	OvershootDeringing bool
 	OptimizeScans      bool
 	QuantTable         int
+	// KeepMetadata lists the metadata fields to keep, removing all others, e.g. "orientation",
+	// "exif-ifd0-Copyright" or "icc-profile-data". A trailing * matches any field with that prefix.
+	// Ignored when StripMetadata is set.
+	KeepMetadata []string
+	// StripExif removes the EXIF data and StripXMP the XMP data, keeping all other metadata. libvips still writes
+	// the resolution and orientation of the image as minimal EXIF data to JPEG files.
+	StripExif bool
+	StripXMP  bool
+	// KeepICC keeps the ICC profile when StripMetadata is set or KeepMetadata doesn't list it
+	KeepICC bool
 }
 
 // NewJpegExportParams creates default values for an export of a JPEG image.
@@ -254,8 +430,16 @@ type PngExportParams struct {
 	Quality       int
 	Palette       bool
 	Dither        float64
-	Bitdepth      int
-	Profile       string // TODO: Use this param during save
+	Bitdepth      int               // without Palette, 1, 2 or 4 write greyscale with as many bits per pixel
+	Reduction     BitdepthReduction // how greyscale is reduced to a Bitdepth of 1, 2 or 4
+	Threshold     float64           // grey level from which 1-bit pixels turn white, 128 when 0
+	Profile       string            // TODO: Use this param during save
+	KeepMetadata  []string          // metadata fields to keep, see JpegExportParams
+	FixedPalette  []Color           // maps the image to these colors before export, see ImageRef.MapToPalette
+	FixedDither   float64           // dithering used with FixedPalette, 0 to 1
+	StripExif     bool              // removes the EXIF data, see JpegExportParams
+	StripXMP      bool              // removes the XMP data, see JpegExportParams
+	KeepICC       bool              // keeps the ICC profile, see JpegExportParams
 }
 
 // NewPngExportParams creates default values for an export of a PNG image.
@@ -266,6 +450,7 @@ func NewPngExportParams() *PngExportParams {
 		Filter:      PngFilterNone,
 		Interlace:   false,
 		Palette:     false,
+		FixedDither: 1,
 	}
 }
 
@@ -277,6 +462,10 @@ type WebpExportParams struct {
 	NearLossless    bool
 	ReductionEffort int
 	IccProfile      string
+	KeepMetadata    []string // metadata fields to keep, see JpegExportParams
+	StripExif       bool     // removes the EXIF data, see JpegExportParams
+	StripXMP        bool     // removes the XMP data, see JpegExportParams
+	KeepICC         bool     // keeps the ICC profile, see JpegExportParams
 }
 
 // NewWebpExportParams creates default values for an export of a WEBP image.
@@ -292,8 +481,14 @@ func NewWebpExportParams() *WebpExportParams {
 
 // HeifExportParams are options when exporting a HEIF to file or buffer
 type HeifExportParams struct {
-	Quality  int
-	Lossless bool
+	StripMetadata bool
+	Quality       int
+	Lossless      bool
+	MaxDuration   time.Duration // aborts the export with an ExportTimeoutError when exceeded, 0 for no limit
+	KeepMetadata  []string      // metadata fields to keep, see JpegExportParams
+	StripExif     bool          // removes the EXIF data, see JpegExportParams
+	StripXMP      bool          // removes the XMP data, see JpegExportParams
+	KeepICC       bool          // keeps the ICC profile, see JpegExportParams; always kept for wide gamut images
 }
 
 // NewHeifExportParams creates default values for an export of a HEIF image.
@@ -309,7 +504,16 @@ type TiffExportParams struct {
 	StripMetadata bool
 	Quality       int
 	Compression   TiffCompression
-	Predictor     TiffPredictor
+	Predictor     TiffPredictor     // for deflate, LZW and zstd, TiffPredictorFloat suits float images; 0 for horizontal
+	Level         int               // deflate (1-9) or zstd (1-22) compression level, libvips default when 0
+	Lossless      bool              // lossless webp compression
+	Bitdepth      int               // 1, 2 or 4 write greyscale with as many bits per pixel, TiffCompressionFax4 implies 1
+	Reduction     BitdepthReduction // how greyscale is reduced to a Bitdepth of 1, 2 or 4
+	Threshold     float64           // grey level from which 1-bit pixels turn white, 128 when 0
+	KeepMetadata  []string          // metadata fields to keep, see JpegExportParams
+	StripExif     bool              // removes the EXIF data, see JpegExportParams
+	StripXMP      bool              // removes the XMP data, see JpegExportParams
+	KeepICC       bool              // keeps the ICC profile, see JpegExportParams
 }
 
 // NewTiffExportParams creates default values for an export of a TIFF image.
@@ -327,14 +531,21 @@ type GifExportParams struct {
 	Dither        float64
 	Effort        int
 	Bitdepth      int
+	KeepMetadata  []string // metadata fields to keep, see JpegExportParams
+	FixedPalette  []Color  // maps the image to these colors before export, see ImageRef.MapToPalette
+	FixedDither   float64  // dithering used with FixedPalette, 0 to 1
+	StripExif     bool     // removes the EXIF data, see JpegExportParams
+	StripXMP      bool     // removes the XMP data, see JpegExportParams
+	KeepICC       bool     // keeps the ICC profile, see JpegExportParams
 }
 
 // NewGifExportParams creates default values for an export of a GIF image.
 func NewGifExportParams() *GifExportParams {
 	return &GifExportParams{
-		Quality:  75,
-		Effort:   7,
-		Bitdepth: 8,
+		Quality:     75,
+		Effort:      7,
+		Bitdepth:    8,
+		FixedDither: 1,
 	}
 }
 
@@ -344,6 +555,11 @@ type AvifExportParams struct {
 	Quality       int
 	Lossless      bool
 	Speed         int
+	MaxDuration   time.Duration // aborts the export with an ExportTimeoutError when exceeded, 0 for no limit
+	KeepMetadata  []string      // metadata fields to keep, see JpegExportParams
+	StripExif     bool          // removes the EXIF data, see JpegExportParams
+	StripXMP      bool          // removes the XMP data, see JpegExportParams
+	KeepICC       bool          // keeps the ICC profile, see JpegExportParams; always kept for wide gamut images
 }
 
 // NewAvifExportParams creates default values for an export of an AVIF image.
@@ -362,6 +578,10 @@ type Jp2kExportParams struct {
 	TileWidth     int
 	TileHeight    int
 	SubsampleMode SubsampleMode
+	KeepMetadata  []string // metadata fields to keep, see JpegExportParams
+	StripExif     bool     // removes the EXIF data, see JpegExportParams
+	StripXMP      bool     // removes the XMP data, see JpegExportParams
+	KeepICC       bool     // keeps the ICC profile when KeepMetadata doesn't list it
 }
 
 // NewJp2kExportParams creates default values for an export of an JPEG2000 image.
@@ -374,6 +594,57 @@ func NewJp2kExportParams() *Jp2kExportParams {
 	}
 }
 
+// JxlExportParams are options when exporting a JPEG XL to file or buffer.
+type JxlExportParams struct {
+	Distance     float64 // target butteraugli distance, 1.0 is visually lossless, lower is better
+	Quality      int     // quality factor from 1 to 100, overrides Distance when set, mapped to a distance like cjxl does
+	Effort       int     // encoding effort from 1 (fastest) to 9 (slowest)
+	Lossless     bool
+	Tier         int      // decode speed tier from 0 (slowest to decode, best quality) to 4
+	KeepMetadata []string // metadata fields to keep, see JpegExportParams
+	StripExif    bool     // removes the EXIF data, see JpegExportParams
+	StripXMP     bool     // removes the XMP data, see JpegExportParams
+	KeepICC      bool     // keeps the ICC profile when KeepMetadata doesn't list it
+}
+
+// NewJxlExportParams creates default values for an export of a JPEG XL image.
+func NewJxlExportParams() *JxlExportParams {
+	return &JxlExportParams{
+		Distance: 1.0,
+		Effort:   7,
+		Lossless: false,
+		Tier:     0,
+	}
+}
+
+// DeepZoomExportParams are options when exporting a Deep Zoom (or Zoomify, Google Maps, IIIF) tile pyramid.
+type DeepZoomExportParams struct {
+	TileSize      int // tile width and height in pixels, not counting the overlap
+	Overlap       int // pixels shared by adjacent tiles
+	Depth         DeepZoomDepth
+	Suffix        string // tile file suffix with save options, e.g. ".jpeg[Q=85]" or ".webp"
+	Layout        DeepZoomLayout
+	Container     DeepZoomContainer
+	StripMetadata bool
+	KeepMetadata  []string // metadata fields to keep, see JpegExportParams
+	StripExif     bool     // removes the EXIF data, see JpegExportParams
+	StripXMP      bool     // removes the XMP data, see JpegExportParams
+	KeepICC       bool     // keeps the ICC profile, see JpegExportParams
+}
+
+// NewDeepZoomExportParams creates default values for a Deep Zoom export: 254 pixel JPEG tiles overlapping by
+// one pixel, down to a one pixel level, written to the filesystem.
+func NewDeepZoomExportParams() *DeepZoomExportParams {
+	return &DeepZoomExportParams{
+		TileSize:  254,
+		Overlap:   1,
+		Depth:     DeepZoomDepthOnePixel,
+		Suffix:    ".jpeg",
+		Layout:    DeepZoomLayoutDZ,
+		Container: DeepZoomContainerFS,
+	}
+}
+
 // NewImageFromReader loads an ImageRef from the given reader
 func NewImageFromReader(r io.Reader) (*ImageRef, error) {
 	buf, err := ioutil.ReadAll(r)
@@ -405,7 +676,12 @@ func NewImageFromBuffer(buf []byte) (*ImageRef, error) {
 	return LoadImageFromBuffer(buf, nil)
 }
 
-// LoadImageFromBuffer loads an image buffer and creates a new Image
+// LoadImageFromBuffer loads an image buffer and creates a new Image.
+// Photoshop documents (PSD and PSB) are loaded through ImageMagick, which reads the flattened composite as the first
+// page followed by the layers. The composite is loaded by default; set PsdLayer to load a layer instead, or NumPages
+// to load several layers of the same size as a multi-page image.
+// Set HeifAuxiliary to load an auxiliary image of the primary image of a HEIF or AVIF file, such as its depth map or
+// HDR gain map, instead of the primary image. ErrHeifAuxiliaryNotFound is returned when the file has none of that kind.
 func LoadImageFromBuffer(buf []byte, params *ImportParams) (*ImageRef, error) {
 	startupIfNeeded()
 
@@ -419,6 +695,40 @@ func LoadImageFromBuffer(buf []byte, params *ImportParams) (*ImageRef, error) {
 	}
 
 	ref := newImageRef(vipsImage, currentFormat, originalFormat, buf)
+	ref.unmodified = currentFormat == originalFormat && !params.transformsPixels()
+
+	govipsLog("govips", LogLevelDebug, fmt.Sprintf("created imageRef %p", ref))
+	return ref, nil
+}
+
+// NewImageFromStream loads an image from a reader without buffering the encoded image in memory
+func NewImageFromStream(r io.Reader) (*ImageRef, error) {
+	return LoadImageFromStream(r, nil)
+}
+
+// LoadImageFromStream loads an image from a reader through a libvips source, so large images
+// (e.g. multi-hundred-MB TIFFs or PDFs) are decoded as they are read instead of being held in Go memory.
+// Unless params.Access is set, the image is loaded with AccessSequential. If the reader also implements
+// io.Seeker, loaders that need to jump around the file can seek instead of buffering it.
+// The reader must stay valid until the image and every image derived from it are closed.
+func LoadImageFromStream(r io.Reader, params *ImportParams) (*ImageRef, error) {
+	startupIfNeeded()
+
+	if params == nil {
+		params = NewImportParams()
+	}
+
+	streamParams := *params
+	if !streamParams.Access.IsSet() {
+		streamParams.Access.Set(AccessSequential)
+	}
+
+	vipsImage, format, err := vipsLoadFromReader(r, &streamParams)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := newImageRef(vipsImage, format, format, nil)
 
 	govipsLog("govips", LogLevelDebug, fmt.Sprintf("created imageRef %p", ref))
 	return ref, nil
@@ -443,6 +753,14 @@ func NewThumbnailWithSizeFromFile(file string, width, height int, crop Interesti
 func LoadThumbnailFromFile(file string, width, height int, crop Interesting, size Size, params *ImportParams) (*ImageRef, error) {
 	startupIfNeeded()
 
+	if params != nil && params.PreferEmbeddedPreview.IsSet() && params.PreferEmbeddedPreview.Get() {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return LoadThumbnailFromBuffer(buf, width, height, crop, size, params)
+	}
+
 	vipsImage, format, err := vipsThumbnailFromFile(file, width, height, crop, size, params)
 	if err != nil {
 		return nil, err
@@ -463,6 +781,12 @@ func NewThumbnailWithSizeFromBuffer(buf []byte, width, height int, crop Interest
 func LoadThumbnailFromBuffer(buf []byte, width, height int, crop Interesting, size Size, params *ImportParams) (*ImageRef, error) {
 	startupIfNeeded()
 
+	if params != nil && params.PreferEmbeddedPreview.IsSet() && params.PreferEmbeddedPreview.Get() {
+		if ref := thumbnailFromEmbeddedPreview(buf, width, height, crop, size); ref != nil {
+			return ref, nil
+		}
+	}
+
 	vipsImage, format, err := vipsThumbnailFromBuffer(buf, width, height, crop, size, params)
 	if err != nil {
 		return nil, err
@@ -474,6 +798,184 @@ func LoadThumbnailFromBuffer(buf []byte, width, height int, crop Interesting, si
 	return ref, nil
 }
 
+// thumbnailFromEmbeddedPreview creates a thumbnail from the embedded preview of an image, nil if it has none that
+// covers the requested size
+func thumbnailFromEmbeddedPreview(buf []byte, width, height int, crop Interesting, size Size) *ImageRef {
+	imageType := DetermineImageType(buf)
+	preview, previewWidth, previewHeight, orientation, err := embeddedPreview(buf, imageType)
+	if err != nil || preview == nil {
+		return nil
+	}
+
+	// the preview is stored unrotated, orientations 5 to 8 swap its sides
+	if orientation >= 5 && orientation <= 8 {
+		width, height = height, width
+	}
+	if previewWidth < width || previewHeight < height {
+		return nil
+	}
+
+	// some cameras letterbox EXIF thumbnails to a fixed aspect ratio
+	if imageType == ImageTypeJPEG {
+		imageWidth, imageHeight, ok := jpegSize(buf)
+		if !ok || math.Abs(float64(imageWidth*previewHeight)/float64(imageHeight*previewWidth)-1) > 0.01 {
+			return nil
+		}
+	}
+
+	thumbnail, _, err := vipsThumbnailFromBuffer(preview, width, height, crop, size, nil)
+	if err != nil {
+		govipsLog("govips", LogLevelDebug, fmt.Sprintf("falling back to full decode, failed to thumbnail preview: %v", err))
+		return nil
+	}
+
+	if orientation > 1 {
+		oriented, err := vipsCopyImage(thumbnail)
+		clearImage(thumbnail)
+		if err != nil {
+			return nil
+		}
+		vipsSetMetaOrientation(oriented, orientation)
+
+		thumbnail, err = vipsAutoRotate(oriented)
+		clearImage(oriented)
+		if err != nil {
+			return nil
+		}
+	}
+
+	// the thumbnail may read from the preview until it is closed
+	return newImageRef(thumbnail, ImageTypeJPEG, imageType, preview)
+}
+
+// LoadPDFPages loads count pages of a PDF buffer starting at firstPage (zero based) rendered at the given dpi.
+// The pages are returned as a single multi-page image. Use a count of 0 or less to load all remaining pages.
+func LoadPDFPages(buf []byte, firstPage, count int, dpi float64) (*ImageRef, error) {
+	return LoadPDFPagesWithParams(buf, firstPage, count, dpi, nil)
+}
+
+// LoadPDFPagesWithParams works like LoadPDFPages with other import params such as PdfPassword or PdfBackground.
+// The Page, NumPages and Density of params are ignored.
+func LoadPDFPagesWithParams(buf []byte, firstPage, count int, dpi float64, params *ImportParams) (*ImageRef, error) {
+	if DetermineImageType(buf) != ImageTypePDF {
+		return nil, ErrUnsupportedImageFormat
+	}
+
+	if count <= 0 {
+		count = -1
+	}
+
+	if params == nil {
+		params = NewImportParams()
+	}
+
+	pdfParams := *params
+	pdfParams.Page.Set(firstPage)
+	pdfParams.NumPages.Set(count)
+	pdfParams.Density.Set(dpi)
+
+	return LoadImageFromBuffer(buf, &pdfParams)
+}
+
+// LoadPDFPageImages loads count pages of a PDF buffer starting at firstPage (zero based) rendered at the given dpi
+// and returns every page as a separate image.
+func LoadPDFPageImages(buf []byte, firstPage, count int, dpi float64) ([]*ImageRef, error) {
+	ref, err := LoadPDFPages(buf, firstPage, count, dpi)
+	if err != nil {
+		return nil, err
+	}
+	defer ref.Close()
+
+	return ref.SplitPages()
+}
+
+// LoadPDFPageImagesWithDPI works like LoadPDFPageImages but renders the pages listed in pageDPI (zero based page
+// number to dpi) at their own resolution. Pages are loaded one by one as differently sized pages cannot share
+// a single multi-page image.
+func LoadPDFPageImagesWithDPI(buf []byte, firstPage, count int, dpi float64, pageDPI map[int]float64) ([]*ImageRef, error) {
+	if count <= 0 {
+		first, err := LoadPDFPages(buf, firstPage, 1, dpi)
+		if err != nil {
+			return nil, err
+		}
+		// pdfload reports the page count of the whole document
+		count = vipsGetImageNPages(first.image) - firstPage
+		first.Close()
+	}
+
+	images := make([]*ImageRef, 0, count)
+	for page := firstPage; page < firstPage+count; page++ {
+		pageDensity := dpi
+		if d, ok := pageDPI[page]; ok {
+			pageDensity = d
+		}
+
+		pages, err := LoadPDFPageImages(buf, page, 1, pageDensity)
+		if err != nil {
+			closeImages(images)
+			return nil, err
+		}
+		images = append(images, pages...)
+	}
+
+	return images, nil
+}
+
+// RenderPDFContactSheet renders count pages of a PDF buffer starting at firstPage (zero based) at the given dpi
+// and lays them out in a grid with the given number of pages across, e.g. for document previews.
+func RenderPDFContactSheet(buf []byte, firstPage, count int, dpi float64, across int) (*ImageRef, error) {
+	if across < 1 {
+		return nil, errors.New("across must be at least 1")
+	}
+
+	pages, err := LoadPDFPageImages(buf, firstPage, count, dpi)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("no pages loaded")
+	}
+
+	for _, page := range pages[1:] {
+		defer page.Close()
+	}
+
+	if across > len(pages) {
+		across = len(pages)
+	}
+
+	if err := pages[0].ArrayJoin(pages[1:], across); err != nil {
+		pages[0].Close()
+		return nil, err
+	}
+
+	return pages[0], nil
+}
+
+// LoadJp2kTile decodes a single tile of a JPEG2000 buffer at the given resolution level (0 being full resolution,
+// each level halving width and height). Coordinates are relative to the reduced image. Only the parts of the
+// code stream needed for the tile at that level are decoded, which keeps memory bounded for very large imagery.
+func LoadJp2kTile(buf []byte, reduction, left, top, width, height int) (*ImageRef, error) {
+	if DetermineImageType(buf) != ImageTypeJP2K {
+		return nil, ErrUnsupportedImageFormat
+	}
+
+	params := NewImportParams()
+	params.Jp2kReduction.Set(reduction)
+
+	ref, err := LoadImageFromBuffer(buf, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ref.ExtractArea(left, top, width, height); err != nil {
+		ref.Close()
+		return nil, err
+	}
+
+	return ref, nil
+}
+
 // Metadata returns the metadata (ImageMetadata struct) of the associated ImageRef
 func (r *ImageRef) Metadata() *ImageMetadata {
 	return &ImageMetadata{
@@ -500,20 +1002,72 @@ func (r *ImageRef) Copy() (*ImageRef, error) {
 // and elements in the second band have their y coordinate.
 func XYZ(width, height int) (*ImageRef, error) {
 	vipsImage, err := vipsXYZ(width, height)
-	return &ImageRef{image: vipsImage}, err
+	return newImageRef(vipsImage, ImageTypeUnknown, ImageTypeUnknown, nil), err
 }
 
 // Identity creates an identity lookup table, which will leave an image unchanged when applied with Maplut.
 // Each entry in the table has a value equal to its position.
 func Identity(ushort bool) (*ImageRef, error) {
 	img, err := vipsIdentity(ushort)
-	return &ImageRef{image: img}, err
+	return newImageRef(img, ImageTypeUnknown, ImageTypeUnknown, nil), err
 }
 
 // Black creates a new black image of the specified size
 func Black(width, height int) (*ImageRef, error) {
 	vipsImage, err := vipsBlack(width, height)
-	return &ImageRef{image: vipsImage}, err
+	return newImageRef(vipsImage, ImageTypeUnknown, ImageTypeUnknown, nil), err
+}
+
+// GaussNoise creates a one-band float image of gaussian noise with the given mean and standard deviation, e.g. a
+// mean of 128 and a sigma of 20 for film grain to blend over a picture. The same seed gives the same noise with
+// libvips 8.13+, older versions ignore it.
+func GaussNoise(width, height int, mean, sigma float64, seed int) (*ImageRef, error) {
+	vipsImage, err := vipsGaussNoise(width, height, mean, sigma, seed)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(vipsImage, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// Perlin creates a one-band image of Perlin noise, smooth cloud-like noise whose features are about cellSize pixels
+// wide. The image is float between -1 and 1, or 8-bit between 0 and 255 with uchar. The same seed gives the same
+// noise with libvips 8.13+, older versions ignore it.
+func Perlin(width, height, cellSize int, uchar bool, seed int) (*ImageRef, error) {
+	vipsImage, err := vipsPerlin(width, height, cellSize, uchar, seed)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(vipsImage, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// Worley creates a one-band float image of Worley noise, a cellular pattern of the distance to random points about
+// cellSize pixels apart. The same seed gives the same noise with libvips 8.13+, older versions ignore it.
+func Worley(width, height, cellSize int, seed int) (*ImageRef, error) {
+	vipsImage, err := vipsWorley(width, height, cellSize, seed)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(vipsImage, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// Sines creates a one-band image of a 2D sine wave, with hfreq horizontal and vfreq vertical periods across the
+// image. The image is float between -1 and 1, or 8-bit between 0 and 255 with uchar.
+func Sines(width, height int, hfreq, vfreq float64, uchar bool) (*ImageRef, error) {
+	vipsImage, err := vipsSines(width, height, hfreq, vfreq, uchar)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(vipsImage, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// Zone creates a one-band zone plate, concentric rings of increasing frequency useful to test resampling and
+// aliasing. The image is float between -1 and 1, or 8-bit between 0 and 255 with uchar.
+func Zone(width, height int, uchar bool) (*ImageRef, error) {
+	vipsImage, err := vipsZone(width, height, uchar)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(vipsImage, ImageTypeUnknown, ImageTypeUnknown, nil), nil
 }
 
 func newImageRef(vipsImage *C.VipsImage, currentFormat ImageType, originalFormat ImageType, buf []byte) *ImageRef {
@@ -524,6 +1078,9 @@ func newImageRef(vipsImage *C.VipsImage, currentFormat ImageType, originalFormat
 		buf:            buf,
 	}
 	runtime.SetFinalizer(imageRef, finalizeImage)
+	if vipsImage != nil {
+		atomic.AddInt64(&openImages, 1)
+	}
 
 	return imageRef
 }
@@ -542,6 +1099,7 @@ func (r *ImageRef) Close() {
 	if r.image != nil {
 		clearImage(r.image)
 		r.image = nil
+		atomic.AddInt64(&openImages, -1)
 	}
 
 	r.buf = nil
@@ -549,6 +1107,36 @@ func (r *ImageRef) Close() {
 	r.lock.Unlock()
 }
 
+// Cancel flags the image as killed so that any subsequent evaluation of its pipeline, including pipelines
+// derived from it, aborts quickly with an error, e.g. to enforce a per-request CPU budget. The image may be
+// shared with other images through the libvips operation cache, so a private copy is killed and held instead.
+func (r *ImageRef) Cancel() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.image == nil {
+		return nil
+	}
+
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+	C.set_image_kill(out, C.int(1))
+
+	clearImage(r.image)
+	r.image = out
+	return nil
+}
+
+// IsCancelled returns whether Cancel was called on the image currently held by the ImageRef
+func (r *ImageRef) IsCancelled() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.image != nil && int(C.is_image_killed(r.image)) != 0
+}
+
 // Format returns the current format of the vips image.
 func (r *ImageRef) Format() ImageType {
 	return r.format
@@ -703,12 +1291,21 @@ func (r *ImageRef) SetPages(pages int) error {
 		return err
 	}
 
-	vipsSetImageNPages(r.image, pages)
+	vipsSetImageNPages(out, pages)
 
 	r.setImage(out)
 	return nil
 }
 
+// HeifPrimaryPage returns the index of the primary image of a HEIF/AVIF file, if the loader recorded one.
+// When neither Page nor NumPages are set on ImportParams, libvips loads the primary image by default;
+// set Page to select any of the other top-level images in the container.
+// N.B. auxiliary images such as depth maps or HDR gain maps are not exposed by libvips' heifload and
+// therefore cannot be selected.
+func (r *ImageRef) HeifPrimaryPage() (int, bool) {
+	return vipsImageGetInt(r.image, "heif-primary")
+}
+
 // PageHeight return the height of a single page
 func (r *ImageRef) PageHeight() int {
 	return vipsGetPageHeight(r.image)
@@ -734,34 +1331,160 @@ func (r *ImageRef) SetPageHeight(height int) error {
 	return nil
 }
 
-// PageDelay get the page delay array for animation
+// SplitPages splits a multi-page image (e.g. the frames of an animation or the pages of a document)
+// into one image per page. The associated image is left unchanged.
+func (r *ImageRef) SplitPages() ([]*ImageRef, error) {
+	pageHeight := r.PageHeight()
+	pages := r.Height() / pageHeight
+
+	images := make([]*ImageRef, 0, pages)
+	for i := 0; i < pages; i++ {
+		page, err := vipsExtractArea(r.image, 0, i*pageHeight, r.Width(), pageHeight)
+		if err != nil {
+			closeImages(images)
+			return nil, err
+		}
+
+		// copy before modifying metadata
+		out, err := vipsCopyImage(page)
+		clearImage(page)
+		if err != nil {
+			closeImages(images)
+			return nil, err
+		}
+
+		vipsSetImageNPages(out, 1)
+		vipsSetPageHeight(out, pageHeight)
+
+		images = append(images, newImageRef(out, r.format, r.originalFormat, r.buf))
+	}
+
+	return images, nil
+}
+
+func closeImages(images []*ImageRef) {
+	for _, img := range images {
+		if img != nil {
+			img.Close()
+		}
+	}
+}
+
+// PageDelay get the page delay array for animation in milliseconds.
+// If the image only carries the legacy gif-delay field (in centiseconds), it is used for every page.
 func (r *ImageRef) PageDelay() ([]int, error) {
 	n := vipsGetImageNPages(r.image)
 	if n <= 1 {
 		// should not call if not multi page
 		return nil, nil
 	}
-	return vipsImageGetDelay(r.image, n)
+
+	delay, err := vipsImageGetDelay(r.image, n)
+	if err != nil {
+		gifDelay, ok := vipsImageGetInt(r.image, gifDelayField)
+		if !ok {
+			return nil, err
+		}
+
+		delay = make([]int, n)
+		for i := range delay {
+			delay[i] = gifDelay * 10
+		}
+	}
+
+	return delay, nil
 }
 
-// SetPageDelay set the page delay array for animation
+// SetPageDelay set the page delay array for animation in milliseconds.
+// The legacy gif-delay field (in centiseconds) is set from the first delay as well.
 func (r *ImageRef) SetPageDelay(delay []int) error {
+	r.markModified()
+
 	var data []C.int
 	for _, d := range delay {
 		data = append(data, C.int(d))
 	}
+
+	if len(delay) > 0 {
+		vipsImageSetInt(r.image, gifDelayField, (delay[0]+5)/10)
+	}
+
 	return vipsImageSetDelay(r.image, data)
 }
 
-// Export creates a byte array of the image for use.
-// The function returns a byte array that can be written to a file e.g. via ioutil.WriteFile().
+// PageDelayDuration get the page delay array for animation as durations
+func (r *ImageRef) PageDelayDuration() ([]time.Duration, error) {
+	delay, err := r.PageDelay()
+	if err != nil {
+		return nil, err
+	}
+
+	var durations []time.Duration
+	for _, d := range delay {
+		durations = append(durations, time.Duration(d)*time.Millisecond)
+	}
+	return durations, nil
+}
+
+// SetPageDelayDuration set the page delay array for animation from durations.
+// Durations are rounded to the nearest millisecond.
+func (r *ImageRef) SetPageDelayDuration(durations []time.Duration) error {
+	var delay []int
+	for _, d := range durations {
+		delay = append(delay, int(d.Round(time.Millisecond)/time.Millisecond))
+	}
+	return r.SetPageDelay(delay)
+}
+
+const gifDelayField = "gif-delay"
+
+// backgroundField is read by the GIF and PNG savers, written as the GIF background color index and PNG bKGD chunk
+const backgroundField = "background"
+
+// Background returns the background color stored in the image metadata, nil if there is none
+func (r *ImageRef) Background() (*Color, error) {
+	values, ok, err := vipsImageGetArrayDouble(r.image, backgroundField)
+	if err != nil || !ok || len(values) == 0 {
+		return nil, err
+	}
+
+	// a single value is a grey level
+	for len(values) < 3 {
+		values = append(values, values[0])
+	}
+	channel := func(v float64) uint8 {
+		return uint8(clampInt(roundFloat(v), 0, 255))
+	}
+	return &Color{R: channel(values[0]), G: channel(values[1]), B: channel(values[2])}, nil
+}
+
+// SetBackground stores the background color in the image metadata, which the GIF and PNG savers write to the file.
+// The pixels are not changed: viewers may show transparent areas on this color instead of flattening the image.
+func (r *ImageRef) SetBackground(color Color) error {
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+
+	vipsImageSetArrayDouble(out, backgroundField, []float64{float64(color.R), float64(color.G), float64(color.B)})
+
+	r.setImage(out)
+	return nil
+}
+
+// Export creates a byte array of the image for use.
+// The function returns a byte array that can be written to a file e.g. via ioutil.WriteFile().
 // N.B. govips does not currently have built-in support for directly exporting to a file.
 // The function also returns a copy of the image metadata as well as an error.
 // Deprecated: Use ExportNative or format-specific Export methods
 func (r *ImageRef) Export(params *ExportParams) ([]byte, *ImageMetadata, error) {
-	if params == nil || params.Format == ImageTypeUnknown {
+	if params == nil {
 		return r.ExportNative()
 	}
+	if params.Format == ImageTypeUnknown {
+		// the native format with its default parameters, only the metadata options apply
+		return r.exportNative(params)
+	}
 
 	format := params.Format
 
@@ -772,7 +1495,12 @@ func (r *ImageRef) Export(params *ExportParams) ([]byte, *ImageMetadata, error)
 	switch format {
 	case ImageTypeGIF:
 		return r.ExportGIF(&GifExportParams{
-			Quality: params.Quality,
+			StripMetadata: params.StripMetadata,
+			Quality:       params.Quality,
+			KeepMetadata:  params.KeepMetadata,
+			StripExif:     params.StripExif,
+			StripXMP:      params.StripXMP,
+			KeepICC:       params.KeepICC,
 		})
 	case ImageTypeWEBP:
 		return r.ExportWebp(&WebpExportParams{
@@ -780,12 +1508,20 @@ func (r *ImageRef) Export(params *ExportParams) ([]byte, *ImageMetadata, error)
 			Quality:         params.Quality,
 			Lossless:        params.Lossless,
 			ReductionEffort: params.Effort,
+			KeepMetadata:    params.KeepMetadata,
+			StripExif:       params.StripExif,
+			StripXMP:        params.StripXMP,
+			KeepICC:         params.KeepICC,
 		})
 	case ImageTypePNG:
 		return r.ExportPng(&PngExportParams{
 			StripMetadata: params.StripMetadata,
 			Compression:   params.Compression,
 			Interlace:     params.Interlaced,
+			KeepMetadata:  params.KeepMetadata,
+			StripExif:     params.StripExif,
+			StripXMP:      params.StripXMP,
+			KeepICC:       params.KeepICC,
 		})
 	case ImageTypeTIFF:
 		compression := TiffCompressionLzw
@@ -796,11 +1532,20 @@ func (r *ImageRef) Export(params *ExportParams) ([]byte, *ImageMetadata, error)
 			StripMetadata: params.StripMetadata,
 			Quality:       params.Quality,
 			Compression:   compression,
+			KeepMetadata:  params.KeepMetadata,
+			StripExif:     params.StripExif,
+			StripXMP:      params.StripXMP,
+			KeepICC:       params.KeepICC,
 		})
 	case ImageTypeHEIF:
 		return r.ExportHeif(&HeifExportParams{
-			Quality:  params.Quality,
-			Lossless: params.Lossless,
+			StripMetadata: params.StripMetadata,
+			Quality:       params.Quality,
+			Lossless:      params.Lossless,
+			KeepMetadata:  params.KeepMetadata,
+			StripExif:     params.StripExif,
+			StripXMP:      params.StripXMP,
+			KeepICC:       params.KeepICC,
 		})
 	case ImageTypeAVIF:
 		return r.ExportAvif(&AvifExportParams{
@@ -808,7 +1553,23 @@ func (r *ImageRef) Export(params *ExportParams) ([]byte, *ImageMetadata, error)
 			Quality:       params.Quality,
 			Lossless:      params.Lossless,
 			Speed:         params.Speed,
+			KeepMetadata:  params.KeepMetadata,
+			StripExif:     params.StripExif,
+			StripXMP:      params.StripXMP,
+			KeepICC:       params.KeepICC,
 		})
+	case ImageTypeJXL:
+		jxlParams := NewJxlExportParams()
+		jxlParams.Quality = params.Quality
+		jxlParams.Lossless = params.Lossless
+		jxlParams.KeepMetadata = params.KeepMetadata
+		jxlParams.StripExif, jxlParams.StripXMP, jxlParams.KeepICC = params.StripExif, params.StripXMP, params.KeepICC
+		if params.Effort > 0 {
+			jxlParams.Effort = params.Effort
+		}
+		return r.ExportJxl(jxlParams)
+	case ImageTypeICO:
+		return r.ExportIco(nil)
 	default:
 		format = ImageTypeJPEG
 		return r.ExportJpeg(&JpegExportParams{
@@ -821,41 +1582,85 @@ func (r *ImageRef) Export(params *ExportParams) ([]byte, *ImageMetadata, error)
 			OvershootDeringing: params.OvershootDeringing,
 			OptimizeScans:      params.OptimizeScans,
 			QuantTable:         params.QuantTable,
+			KeepMetadata:       params.KeepMetadata,
+			StripExif:          params.StripExif,
+			StripXMP:           params.StripXMP,
+			KeepICC:            params.KeepICC,
 		})
 	}
 }
 
 // ExportNative exports the image to a buffer based on its native format with default parameters.
 func (r *ImageRef) ExportNative() ([]byte, *ImageMetadata, error) {
+	return r.exportNative(&ExportParams{})
+}
+
+// exportNative exports the image in its native format with default parameters and the metadata options of m
+func (r *ImageRef) exportNative(m *ExportParams) ([]byte, *ImageMetadata, error) {
 	switch r.format {
-	case ImageTypeJPEG:
-		return r.ExportJpeg(NewJpegExportParams())
 	case ImageTypePNG:
-		return r.ExportPng(NewPngExportParams())
+		p := NewPngExportParams()
+		p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+		return r.ExportPng(p)
 	case ImageTypeWEBP:
-		return r.ExportWebp(NewWebpExportParams())
+		p := NewWebpExportParams()
+		p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+		return r.ExportWebp(p)
 	case ImageTypeHEIF:
-		return r.ExportHeif(NewHeifExportParams())
+		p := NewHeifExportParams()
+		p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+		return r.ExportHeif(p)
 	case ImageTypeTIFF:
-		return r.ExportTiff(NewTiffExportParams())
+		p := NewTiffExportParams()
+		p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+		return r.ExportTiff(p)
 	case ImageTypeAVIF:
-		return r.ExportAvif(NewAvifExportParams())
+		p := NewAvifExportParams()
+		p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+		return r.ExportAvif(p)
 	case ImageTypeJP2K:
-		return r.ExportJp2k(NewJp2kExportParams())
+		// JPEG 2000 and JPEG XL exports have no StripMetadata
+		p := NewJp2kExportParams()
+		_, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+		return r.ExportJp2k(p)
+	case ImageTypeJXL:
+		p := NewJxlExportParams()
+		_, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+		return r.ExportJxl(p)
 	case ImageTypeGIF:
-		return r.ExportGIF(NewGifExportParams())
+		p := NewGifExportParams()
+		p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+		return r.ExportGIF(p)
 	default:
-		return r.ExportJpeg(NewJpegExportParams())
+		p := NewJpegExportParams()
+		p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+		return r.ExportJpeg(p)
 	}
 }
 
+// metadataOptions returns the StripMetadata, KeepMetadata, StripExif, StripXMP and KeepICC options
+func (p *ExportParams) metadataOptions() (bool, []string, bool, bool, bool) {
+	return p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC
+}
+
 // ExportJpeg exports the image as JPEG to a buffer.
 func (r *ImageRef) ExportJpeg(params *JpegExportParams) ([]byte, *ImageMetadata, error) {
 	if params == nil {
 		params = NewJpegExportParams()
 	}
 
-	buf, err := vipsSaveJPEGToBuffer(r.image, *params)
+	jpegParams := *params
+	var keep, remove []string
+	jpegParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	buf, err := vipsSaveJPEGToBuffer(in, jpegParams)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -869,7 +1674,30 @@ func (r *ImageRef) ExportPng(params *PngExportParams) ([]byte, *ImageMetadata, e
 		params = NewPngExportParams()
 	}
 
-	buf, err := vipsSavePNGToBuffer(r.image, *params)
+	pngParams := *params
+	var keep, remove []string
+	pngParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	in, releasePalette, err := fixedPaletteImage(in, params.FixedPalette, params.FixedDither)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer releasePalette()
+
+	in, releaseBitdepth, err := pngBitdepthImage(in, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer releaseBitdepth()
+
+	buf, err := vipsSavePNGToBuffer(in, pngParams)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -885,8 +1713,17 @@ func (r *ImageRef) ExportWebp(params *WebpExportParams) ([]byte, *ImageMetadata,
 
 	paramsWithIccProfile := *params
 	paramsWithIccProfile.IccProfile = r.optimizedIccProfile
+	var keep, remove []string
+	paramsWithIccProfile.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
 
-	buf, err := vipsSaveWebPToBuffer(r.image, paramsWithIccProfile)
+	buf, err := vipsSaveWebPToBuffer(in, paramsWithIccProfile)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -894,18 +1731,195 @@ func (r *ImageRef) ExportWebp(params *WebpExportParams) ([]byte, *ImageMetadata,
 	return buf, r.newMetadata(ImageTypeWEBP), nil
 }
 
+// ExportToWriter exports the image to w, streaming the encoded output as it is produced when the format
+// supports it (JPEG, PNG and WEBP), e.g. straight to an http.ResponseWriter or a file.
+// Other formats are encoded to a buffer first and then written to w.
+func (r *ImageRef) ExportToWriter(w io.Writer, params *ExportParams) (*ImageMetadata, error) {
+	if params == nil || params.Format == ImageTypeUnknown {
+		// the native format with its default parameters, only the metadata options apply
+		m := params
+		if m == nil {
+			m = &ExportParams{}
+		}
+		switch r.format {
+		case ImageTypeJPEG:
+			p := NewJpegExportParams()
+			p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+			return r.ExportJpegToWriter(w, p)
+		case ImageTypePNG:
+			p := NewPngExportParams()
+			p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+			return r.ExportPngToWriter(w, p)
+		case ImageTypeWEBP:
+			p := NewWebpExportParams()
+			p.StripMetadata, p.KeepMetadata, p.StripExif, p.StripXMP, p.KeepICC = m.metadataOptions()
+			return r.ExportWebpToWriter(w, p)
+		}
+	} else {
+		switch params.Format {
+		case ImageTypeJPEG:
+			return r.ExportJpegToWriter(w, &JpegExportParams{
+				Quality:            params.Quality,
+				StripMetadata:      params.StripMetadata,
+				Interlace:          params.Interlaced,
+				OptimizeCoding:     params.OptimizeCoding,
+				SubsampleMode:      params.SubsampleMode,
+				TrellisQuant:       params.TrellisQuant,
+				OvershootDeringing: params.OvershootDeringing,
+				OptimizeScans:      params.OptimizeScans,
+				QuantTable:         params.QuantTable,
+				KeepMetadata:       params.KeepMetadata,
+				StripExif:          params.StripExif,
+				StripXMP:           params.StripXMP,
+				KeepICC:            params.KeepICC,
+			})
+		case ImageTypePNG:
+			return r.ExportPngToWriter(w, &PngExportParams{
+				StripMetadata: params.StripMetadata,
+				Compression:   params.Compression,
+				Interlace:     params.Interlaced,
+				KeepMetadata:  params.KeepMetadata,
+				StripExif:     params.StripExif,
+				StripXMP:      params.StripXMP,
+				KeepICC:       params.KeepICC,
+			})
+		case ImageTypeWEBP:
+			return r.ExportWebpToWriter(w, &WebpExportParams{
+				StripMetadata:   params.StripMetadata,
+				Quality:         params.Quality,
+				Lossless:        params.Lossless,
+				ReductionEffort: params.Effort,
+				KeepMetadata:    params.KeepMetadata,
+				StripExif:       params.StripExif,
+				StripXMP:        params.StripXMP,
+				KeepICC:         params.KeepICC,
+			})
+		}
+	}
+
+	buf, metadata, err := r.Export(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// ExportJpegToWriter exports the image as JPEG, streaming the output to w.
+func (r *ImageRef) ExportJpegToWriter(w io.Writer, params *JpegExportParams) (*ImageMetadata, error) {
+	if params == nil {
+		params = NewJpegExportParams()
+	}
+
+	jpegParams := *params
+	var keep, remove []string
+	jpegParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := vipsSaveJPEGToWriter(in, jpegParams, w); err != nil {
+		return nil, err
+	}
+
+	return r.newMetadata(ImageTypeJPEG), nil
+}
+
+// ExportPngToWriter exports the image as PNG, streaming the output to w.
+func (r *ImageRef) ExportPngToWriter(w io.Writer, params *PngExportParams) (*ImageMetadata, error) {
+	if params == nil {
+		params = NewPngExportParams()
+	}
+
+	pngParams := *params
+	var keep, remove []string
+	pngParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	in, releasePalette, err := fixedPaletteImage(in, params.FixedPalette, params.FixedDither)
+	if err != nil {
+		return nil, err
+	}
+	defer releasePalette()
+
+	in, releaseBitdepth, err := pngBitdepthImage(in, params)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseBitdepth()
+
+	if err := vipsSavePNGToWriter(in, pngParams, w); err != nil {
+		return nil, err
+	}
+
+	return r.newMetadata(ImageTypePNG), nil
+}
+
+// ExportWebpToWriter exports the image as WEBP, streaming the output to w.
+func (r *ImageRef) ExportWebpToWriter(w io.Writer, params *WebpExportParams) (*ImageMetadata, error) {
+	if params == nil {
+		params = NewWebpExportParams()
+	}
+
+	paramsWithIccProfile := *params
+	paramsWithIccProfile.IccProfile = r.optimizedIccProfile
+	var keep, remove []string
+	paramsWithIccProfile.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := vipsSaveWebPToWriter(in, paramsWithIccProfile, w); err != nil {
+		return nil, err
+	}
+
+	return r.newMetadata(ImageTypeWEBP), nil
+}
+
 // ExportHeif exports the image as HEIF to a buffer.
 func (r *ImageRef) ExportHeif(params *HeifExportParams) ([]byte, *ImageMetadata, error) {
 	if params == nil {
 		params = NewHeifExportParams()
 	}
 
-	buf, err := vipsSaveHEIFToBuffer(r.image, *params)
+	// the ICC profile is how the color space of wide gamut images is signalled, it's not stripped with the metadata
+	heifParams := *params
+	var keep, remove []string
+	heifParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC || r.IsWideGamut())
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	buf, err := exportWithTimeout(in, ImageTypeHEIF, params.MaxDuration, func(in *C.VipsImage) ([]byte, error) {
+		return vipsSaveHEIFToBuffer(in, heifParams)
+	})
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return buf, r.newMetadata(ImageTypeHEIF), nil
+	return r.signalHeifGamut(buf), r.newMetadata(ImageTypeHEIF), nil
 }
 
 // ExportTiff exports the image as TIFF to a buffer.
@@ -914,7 +1928,31 @@ func (r *ImageRef) ExportTiff(params *TiffExportParams) ([]byte, *ImageMetadata,
 		params = NewTiffExportParams()
 	}
 
-	buf, err := vipsSaveTIFFToBuffer(r.image, *params)
+	tiffParams := *params
+	var keep, remove []string
+	tiffParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	if tiffParams.Compression == TiffCompressionFax4 && tiffParams.Bitdepth == 0 {
+		tiffParams.Bitdepth = 1
+	}
+
+	if tiffParams.Bitdepth > 0 && tiffParams.Bitdepth < 8 {
+		reduced, err := vipsReduceBitdepth(in, tiffParams.Bitdepth, tiffParams.Reduction, tiffParams.Threshold)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer clearImage(reduced)
+		in = reduced
+	}
+
+	buf, err := vipsSaveTIFFToBuffer(in, tiffParams)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -928,7 +1966,24 @@ func (r *ImageRef) ExportGIF(params *GifExportParams) ([]byte, *ImageMetadata, e
 		params = NewGifExportParams()
 	}
 
-	buf, err := vipsSaveGIFToBuffer(r.image, *params)
+	gifParams := *params
+	var keep, remove []string
+	gifParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	in, releasePalette, err := fixedPaletteImage(in, params.FixedPalette, params.FixedDither)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer releasePalette()
+
+	buf, err := vipsSaveGIFToBuffer(in, gifParams)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -942,12 +1997,26 @@ func (r *ImageRef) ExportAvif(params *AvifExportParams) ([]byte, *ImageMetadata,
 		params = NewAvifExportParams()
 	}
 
-	buf, err := vipsSaveAVIFToBuffer(r.image, *params)
+	// the ICC profile is how the color space of wide gamut images is signalled, it's not stripped with the metadata
+	avifParams := *params
+	var keep, remove []string
+	avifParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC || r.IsWideGamut())
+
+	in, release, err := r.exportImage(keep, remove...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	buf, err := exportWithTimeout(in, ImageTypeAVIF, params.MaxDuration, func(in *C.VipsImage) ([]byte, error) {
+		return vipsSaveAVIFToBuffer(in, avifParams)
+	})
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return buf, r.newMetadata(ImageTypeAVIF), nil
+	return r.signalHeifGamut(buf), r.newMetadata(ImageTypeAVIF), nil
 }
 
 // ExportJp2k exports the image as JPEG2000 to a buffer.
@@ -956,117 +2025,745 @@ func (r *ImageRef) ExportJp2k(params *Jp2kExportParams) ([]byte, *ImageMetadata,
 		params = NewJp2kExportParams()
 	}
 
-	buf, err := vipsSaveJP2KToBuffer(r.image, *params)
+	_, keep, remove := exportMetadata(false, params.KeepMetadata, params.StripExif, params.StripXMP, params.KeepICC)
+	in, release, err := r.exportImage(keep, remove...)
 	if err != nil {
 		return nil, nil, err
 	}
+	defer release()
 
-	return buf, r.newMetadata(ImageTypeJP2K), nil
-}
-
-// CompositeMulti composites the given overlay image on top of the associated image with provided blending mode.
-func (r *ImageRef) CompositeMulti(ins []*ImageComposite) error {
-	out, err := vipsComposite(toVipsCompositeStructs(r, ins))
+	buf, err := vipsSaveJP2KToBuffer(in, *params)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	r.setImage(out)
-	return nil
+
+	return buf, r.newMetadata(ImageTypeJP2K), nil
 }
 
-// Composite composites the given overlay image on top of the associated image with provided blending mode.
-func (r *ImageRef) Composite(overlay *ImageRef, mode BlendMode, x, y int) error {
-	out, err := vipsComposite2(r.image, overlay.image, mode, x, y)
-	if err != nil {
-		return err
+// ExportJxl exports the image as JPEG XL to a buffer.
+func (r *ImageRef) ExportJxl(params *JxlExportParams) ([]byte, *ImageMetadata, error) {
+	if params == nil {
+		params = NewJxlExportParams()
 	}
-	r.setImage(out)
-	return nil
-}
 
-// Insert draws the image on top of the associated image at the given coordinates.
-func (r *ImageRef) Insert(sub *ImageRef, x, y int, expand bool, background *ColorRGBA) error {
-	out, err := vipsInsert(r.image, sub.image, x, y, expand, background)
+	_, keep, remove := exportMetadata(false, params.KeepMetadata, params.StripExif, params.StripXMP, params.KeepICC)
+	in, release, err := r.exportImage(keep, remove...)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	r.setImage(out)
-	return nil
-}
+	defer release()
 
-// Join joins this image with another in the direction specified
-func (r *ImageRef) Join(in *ImageRef, dir Direction) error {
-	out, err := vipsJoin(r.image, in.image, dir)
+	buf, err := vipsSaveJXLToBuffer(in, *params)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	r.setImage(out)
-	return nil
+
+	return buf, r.newMetadata(ImageTypeJXL), nil
 }
 
-// ArrayJoin joins an array of images together wrapping at each n images
-func (r *ImageRef) ArrayJoin(images []*ImageRef, across int) error {
-	allImages := append([]*ImageRef{r}, images...)
-	inputs := make([]*C.VipsImage, len(allImages))
-	for i := range inputs {
-		inputs[i] = allImages[i].image
+// ExportDeepZoom writes the image as a tile pyramid for zoomable image viewers. name is the output path without
+// extension, e.g. with the DZ layout "out/image" produces out/image.dzi and the tiles in out/image_files,
+// with the zip container it produces out/image.zip.
+func (r *ImageRef) ExportDeepZoom(name string, params *DeepZoomExportParams) error {
+	if params == nil {
+		params = NewDeepZoomExportParams()
 	}
-	out, err := vipsArrayJoin(inputs, across)
+
+	dzParams := *params
+	var keep, remove []string
+	dzParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
+
+	in, release, err := r.exportImage(keep, remove...)
 	if err != nil {
 		return err
 	}
-	r.setImage(out)
-	return nil
+	defer release()
+
+	return vipsDzSave(in, name, dzParams)
 }
 
-// Mapim resamples an image using index to look up pixels
-func (r *ImageRef) Mapim(index *ImageRef) error {
-	out, err := vipsMapim(r.image, index.image)
-	if err != nil {
-		return err
+// ExportDeepZoomToBuffer exports the image as a tile pyramid packed in a zip (or SZI) archive.
+// The filesystem container can't be written to a buffer, it is replaced by zip.
+func (r *ImageRef) ExportDeepZoomToBuffer(params *DeepZoomExportParams) ([]byte, error) {
+	if params == nil {
+		params = NewDeepZoomExportParams()
 	}
-	r.setImage(out)
-	return nil
-}
 
-// Maplut maps an image through another image acting as a LUT (Look Up Table)
-func (r *ImageRef) Maplut(lut *ImageRef) error {
-	out, err := vipsMaplut(r.image, lut.image)
-	if err != nil {
-		return err
+	zipParams := *params
+	if zipParams.Container == DeepZoomContainerFS {
+		zipParams.Container = DeepZoomContainerZip
 	}
-	r.setImage(out)
-	return nil
-}
+	var keep, remove []string
+	zipParams.StripMetadata, keep, remove = exportMetadata(params.StripMetadata, params.KeepMetadata,
+		params.StripExif, params.StripXMP, params.KeepICC)
 
-// ExtractBand extracts one or more bands out of the image (replacing the associated ImageRef)
-func (r *ImageRef) ExtractBand(band int, num int) error {
-	out, err := vipsExtractBand(r.image, band, num)
+	in, release, err := r.exportImage(keep, remove...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	r.setImage(out)
-	return nil
+	defer release()
+
+	return vipsDzSaveToBuffer(in, zipParams)
 }
 
-// BandJoin joins a set of images together, bandwise.
-func (r *ImageRef) BandJoin(images ...*ImageRef) error {
-	vipsImages := []*C.VipsImage{r.image}
-	for _, vipsImage := range images {
-		vipsImages = append(vipsImages, vipsImage.image)
+const tinyPreviewMaxBytes = 2048
+
+// ExportTinyPreview exports a heavily compressed preview fitting in maxDim x maxDim, meant as a low quality
+// image placeholder (LQIP). It is encoded as WebP when supported, otherwise as JPEG, lowering the quality
+// until it fits in 2KB, then halving the dimensions if it still doesn't. An error is returned if no preview fits.
+// Only the first page of animated images is used. The image itself is left untouched.
+func (r *ImageRef) ExportTinyPreview(maxDim int) ([]byte, *ImageMetadata, error) {
+	if maxDim < 1 {
+		return nil, nil, errors.New("maxDim must be at least 1")
 	}
 
-	out, err := vipsBandJoin(vipsImages)
+	page, err := r.Copy()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	r.setImage(out)
-	return nil
-}
+	defer page.Close()
 
-// BandJoinConst appends a set of constant bands to an image.
-func (r *ImageRef) BandJoinConst(constants []float64) error {
-	out, err := vipsBandJoinConst(r.image, constants)
+	if page.Height() > page.PageHeight() {
+		out, err := vipsExtractArea(page.image, 0, 0, page.Width(), page.PageHeight())
+		if err != nil {
+			return nil, nil, err
+		}
+		page.setImage(out)
+	}
+
+	useWebp := IsTypeSupported(ImageTypeWEBP)
+	if !useWebp && page.HasAlpha() {
+		if err := page.Flatten(&Color{R: 255, G: 255, B: 255}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for dim := maxDim; dim >= 1; dim /= 2 {
+		buf, metadata, err := page.encodeTinyPreview(dim, useWebp)
+		if err != nil || len(buf) <= tinyPreviewMaxBytes {
+			return buf, metadata, err
+		}
+	}
+
+	return nil, nil, fmt.Errorf("no preview fits in %d bytes", tinyPreviewMaxBytes)
+}
+
+// encodeTinyPreview encodes a thumbnail of at most dim x dim with the lowest quality that fits the budget of
+// ExportTinyPreview, or the lowest quality tried if none does
+func (r *ImageRef) encodeTinyPreview(dim int, useWebp bool) ([]byte, *ImageMetadata, error) {
+	preview, err := r.Copy()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer preview.Close()
+
+	if err := preview.Thumbnail(dim, dim, InterestingNone); err != nil {
+		return nil, nil, err
+	}
+
+	// a slight blur drops detail the encoder would otherwise spend bytes on
+	if err := preview.GaussianBlur(0.5); err != nil {
+		return nil, nil, err
+	}
+
+	var buf []byte
+	var metadata *ImageMetadata
+	for quality := 40; quality >= 10; quality -= 10 {
+		if useWebp {
+			buf, metadata, err = preview.ExportWebp(&WebpExportParams{
+				StripMetadata:   true,
+				Quality:         quality,
+				ReductionEffort: 6,
+			})
+		} else {
+			buf, metadata, err = preview.ExportJpeg(&JpegExportParams{
+				StripMetadata:  true,
+				Quality:        quality,
+				OptimizeCoding: true,
+				SubsampleMode:  VipsForeignSubsampleOn,
+			})
+		}
+		if err != nil || len(buf) <= tinyPreviewMaxBytes {
+			break
+		}
+	}
+
+	return buf, metadata, err
+}
+
+// SizeQualityConstraints are the constraints ExportBest applies to its candidates
+type SizeQualityConstraints struct {
+	Quality  int  // quality of lossy candidates, defaults to 80
+	Lossless bool // encode candidates losslessly where the format supports it
+	MaxBytes int  // candidates larger than this are rejected, 0 for no limit
+}
+
+// ExportBest encodes the image in parallel in every supported format of accept, e.g. the types
+// listed in an HTTP Accept header, and returns the smallest result satisfying the constraints.
+// Ties are resolved in favour of the format listed first in accept.
+func (r *ImageRef) ExportBest(accept []ImageType, constraints SizeQualityConstraints) ([]byte, *ImageMetadata, error) {
+	if constraints.Quality == 0 {
+		constraints.Quality = 80
+	}
+
+	var candidates []ImageType
+	for _, format := range accept {
+		if IsTypeSupported(format) && isExportBestCandidate(format) {
+			candidates = append(candidates, format)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil, ErrUnsupportedImageFormat
+	}
+
+	results := make([]ExportResult, len(candidates))
+	var wg sync.WaitGroup
+	for i, format := range candidates {
+		wg.Add(1)
+		go func(i int, format ImageType) {
+			defer wg.Done()
+			buf, metadata, err := r.exportCandidate(format, constraints)
+			results[i] = ExportResult{Buffer: buf, Metadata: metadata, Err: err}
+		}(i, format)
+	}
+	wg.Wait()
+
+	best := -1
+	var lastErr error
+	for i, result := range results {
+		if result.Err != nil {
+			lastErr = result.Err
+			continue
+		}
+		if constraints.MaxBytes > 0 && len(result.Buffer) > constraints.MaxBytes {
+			continue
+		}
+		if best < 0 || len(result.Buffer) < len(results[best].Buffer) {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		if lastErr != nil {
+			return nil, nil, lastErr
+		}
+		return nil, nil, fmt.Errorf("no candidate fits in %d bytes", constraints.MaxBytes)
+	}
+
+	return results[best].Buffer, results[best].Metadata, nil
+}
+
+func isExportBestCandidate(format ImageType) bool {
+	switch format {
+	case ImageTypeJPEG, ImageTypePNG, ImageTypeWEBP, ImageTypeAVIF, ImageTypeHEIF, ImageTypeGIF, ImageTypeJP2K:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *ImageRef) exportCandidate(format ImageType, constraints SizeQualityConstraints) ([]byte, *ImageMetadata, error) {
+	switch format {
+	case ImageTypePNG:
+		return r.ExportPng(NewPngExportParams())
+	case ImageTypeWEBP:
+		params := NewWebpExportParams()
+		params.Quality = constraints.Quality
+		params.Lossless = constraints.Lossless
+		return r.ExportWebp(params)
+	case ImageTypeAVIF:
+		params := NewAvifExportParams()
+		params.Quality = constraints.Quality
+		params.Lossless = constraints.Lossless
+		return r.ExportAvif(params)
+	case ImageTypeHEIF:
+		params := NewHeifExportParams()
+		params.Quality = constraints.Quality
+		params.Lossless = constraints.Lossless
+		return r.ExportHeif(params)
+	case ImageTypeGIF:
+		return r.ExportGIF(NewGifExportParams())
+	case ImageTypeJP2K:
+		params := NewJp2kExportParams()
+		params.Quality = constraints.Quality
+		params.Lossless = constraints.Lossless
+		return r.ExportJp2k(params)
+	default:
+		params := NewJpegExportParams()
+		params.Quality = constraints.Quality
+		return r.ExportJpeg(params)
+	}
+}
+
+// exportImage returns the image to export, a copy holding only the keep metadata fields if any are given and
+// without the remove ones. Fields ending in * match by prefix. release must be called once the export is done.
+func (r *ImageRef) exportImage(keep []string, remove ...string) (*C.VipsImage, func(), error) {
+	if len(keep) == 0 && len(remove) == 0 {
+		return r.image, func() {}, nil
+	}
+
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(keep) > 0 {
+		vipsKeepMetadata(out, keep)
+	}
+	vipsRemoveMatchingFields(out, remove)
+
+	return out, func() { clearImage(out) }, nil
+}
+
+// fixedPaletteImage maps the image to export to palette, it is returned as is when no palette is given
+func fixedPaletteImage(in *C.VipsImage, palette []Color, dither float64) (*C.VipsImage, func(), error) {
+	if len(palette) == 0 {
+		return in, func() {}, nil
+	}
+
+	out, err := vipsMapToPalette(in, palette, dither)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, func() { clearImage(out) }, nil
+}
+
+// pngBitdepthImage reduces the image to export to greyscale for PNG bit depths below 8, palette PNGs are
+// reduced by the quantizer instead
+func pngBitdepthImage(in *C.VipsImage, params *PngExportParams) (*C.VipsImage, func(), error) {
+	if params.Palette || params.Bitdepth <= 0 || params.Bitdepth >= 8 {
+		return in, func() {}, nil
+	}
+
+	out, err := vipsReduceBitdepth(in, params.Bitdepth, params.Reduction, params.Threshold)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, func() { clearImage(out) }, nil
+}
+
+// exportMetadata resolves the metadata options of export params to whether the encoder strips all metadata and the
+// fields to keep and to remove before export
+func exportMetadata(strip bool, keep []string, stripExif, stripXMP, keepICC bool) (bool, []string, []string) {
+	if strip {
+		if keepICC {
+			return false, []string{C.VIPS_META_ICC_NAME}, nil
+		}
+		return true, nil, nil
+	}
+
+	if len(keep) > 0 && keepICC {
+		keep = append(keep[:len(keep):len(keep)], C.VIPS_META_ICC_NAME)
+	}
+
+	var remove []string
+	if stripExif {
+		// the EXIF blob and the exif-ifd* fields libvips regenerates it from
+		remove = append(remove, "exif-*")
+	}
+	if stripXMP {
+		remove = append(remove, C.VIPS_META_XMP_NAME)
+	}
+
+	return false, keep, remove
+}
+
+// exportWithTimeout runs export and kills the image pipeline once maxDuration has passed, so that libvips aborts
+// at its next pixel request. Encoder work that happens after all pixels were read cannot be interrupted.
+// The export reads from a private copy of in, so killing it doesn't affect other users of the image.
+func exportWithTimeout(in *C.VipsImage, format ImageType, maxDuration time.Duration, export func(*C.VipsImage) ([]byte, error)) ([]byte, error) {
+	if maxDuration <= 0 {
+		return export(in)
+	}
+
+	private, err := vipsCopyImage(in)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(private)
+
+	timedOut := false
+	fired := make(chan struct{})
+	timer := time.AfterFunc(maxDuration, func() {
+		timedOut = true
+		C.set_image_kill(private, C.int(1))
+		close(fired)
+	})
+
+	buf, err := export(private)
+
+	if !timer.Stop() {
+		<-fired
+	}
+	if timedOut && err != nil {
+		return nil, &ExportTimeoutError{Format: format, MaxDuration: maxDuration}
+	}
+
+	return buf, err
+}
+
+// CompositeMulti composites the given overlay image on top of the associated image with provided blending mode.
+// Each overlay may be cropped to a source rectangle and faded by an opacity, see ImageComposite.
+func (r *ImageRef) CompositeMulti(ins []*ImageComposite) error {
+	images, modes, xs, ys, intermediates, err := toVipsCompositeStructs(r, ins)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, in := range intermediates {
+			clearImage(in)
+		}
+	}()
+
+	out, err := vipsComposite(images, modes, xs, ys)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Composite composites the given overlay image on top of the associated image with provided blending mode.
+func (r *ImageRef) Composite(overlay *ImageRef, mode BlendMode, x, y int) error {
+	out, err := vipsComposite2(r.image, overlay.image, mode, x, y)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// CompositeWithOpacity composites the given overlay image on top of the associated image with provided blending mode,
+// scaling the alpha of the overlay by opacity from 0 (transparent) to 1 (unchanged), e.g. for watermarks.
+func (r *ImageRef) CompositeWithOpacity(overlay *ImageRef, mode BlendMode, x, y int, opacity float64) error {
+	in := &ImageComposite{Image: overlay, BlendMode: mode, X: x, Y: y}
+	in.Opacity.Set(opacity)
+	return r.CompositeMulti([]*ImageComposite{in})
+}
+
+// CompositeSVG rasterizes the SVG document at the given width, keeping its aspect ratio, and composites it
+// over the image with its top left corner at x, y. The SVG is rendered at the target size rather than
+// resized afterwards, so vector content such as logos stays sharp.
+func (r *ImageRef) CompositeSVG(svg []byte, x, y, width int) error {
+	if !isSVG(svg) {
+		return ErrUnsupportedImageFormat
+	}
+
+	// VIPS_MAX_COORD, so that only the width constrains the size
+	overlay, _, err := vipsThumbnailFromBuffer(svg, width, 10000000, InterestingNone, SizeBoth, nil)
+	if err != nil {
+		return err
+	}
+	defer clearImage(overlay)
+
+	out, err := vipsComposite2(r.image, overlay, BlendModeOver, x, y)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Insert draws the image on top of the associated image at the given coordinates.
+// With expand the image is enlarged to hold sub, the new area being filled with background, opaque black when nil.
+// See InsertWithConstant for per-band backgrounds.
+func (r *ImageRef) Insert(sub *ImageRef, x, y int, expand bool, background *ColorRGBA) error {
+	out, err := vipsInsert(r.image, sub.image, x, y, expand, background)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// InsertWithConstant draws sub on top of the image at the given coordinates like Insert, with a per-band constant
+// background filling the area added when expand enlarges the image to hold sub, see EmbedWithConstant. A single value
+// is used for every band; otherwise one value per band of the result, including alpha, is expected.
+func (r *ImageRef) InsertWithConstant(sub *ImageRef, x, y int, expand bool, background []float64) error {
+	bands := maxInt(r.Bands(), sub.Bands())
+	if len(background) == 0 {
+		return errors.New("background must have at least one value")
+	}
+	if len(background) != 1 && len(background) != bands {
+		return fmt.Errorf("background must have 1 or %d values, got %d", bands, len(background))
+	}
+
+	out, err := vipsInsertConstant(r.image, sub.image, x, y, expand, background)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Join joins this image with another in the direction specified
+func (r *ImageRef) Join(in *ImageRef, dir Direction) error {
+	out, err := vipsJoin(r.image, in.image, dir)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// ArrayJoin joins an array of images together wrapping at each n images
+func (r *ImageRef) ArrayJoin(images []*ImageRef, across int) error {
+	allImages := append([]*ImageRef{r}, images...)
+	inputs := make([]*C.VipsImage, len(allImages))
+	for i := range inputs {
+		inputs[i] = allImages[i].image
+	}
+	out, err := vipsArrayJoin(inputs, across)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// ProcessTiles walks the image in tiles of tileWidth x tileHeight, left to right and top to bottom,
+// passing each tile and its position to fn, and reassembles the returned tiles into the image with a single join.
+// Tiles on the right and bottom edges may be smaller. fn may return the tile it was given or a new image of the same
+// size; since libvips evaluates lazily, only the regions needed while writing the result are computed, which
+// keeps memory bounded for very large inputs as long as fn does not force evaluation of the whole tile.
+func (r *ImageRef) ProcessTiles(tileWidth, tileHeight int, fn func(tile *ImageRef, x, y int) (*ImageRef, error)) error {
+	if tileWidth < 1 || tileHeight < 1 {
+		return errors.New("tile width and height must be at least 1")
+	}
+
+	width, height := r.Width(), r.Height()
+	across := (width + tileWidth - 1) / tileWidth
+
+	var tiles []*ImageRef
+	defer func() {
+		closeImages(tiles)
+	}()
+	for y := 0; y < height; y += tileHeight {
+		for x := 0; x < width; x += tileWidth {
+			tile, err := r.processTile(x, y, minInt(tileWidth, width-x), minInt(tileHeight, height-y), fn)
+			if err != nil {
+				return err
+			}
+			tiles = append(tiles, tile)
+		}
+	}
+
+	inputs := make([]*C.VipsImage, len(tiles))
+	for i, tile := range tiles {
+		inputs[i] = tile.image
+	}
+	out, err := vipsJoinTiles(inputs, across, tileWidth, tileHeight, width, height)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+func (r *ImageRef) processTile(x, y, width, height int, fn func(tile *ImageRef, x, y int) (*ImageRef, error)) (*ImageRef, error) {
+	area, err := vipsExtractArea(r.image, x, y, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	tile := newImageRef(area, r.format, r.originalFormat, nil)
+	out, err := fn(tile, x, y)
+	if out != tile {
+		tile.Close()
+	}
+	if err != nil {
+		if out != nil {
+			out.Close()
+		}
+		return nil, err
+	}
+	if out == nil {
+		return nil, fmt.Errorf("tile at %d,%d: callback returned no image", x, y)
+	}
+	if out.Width() != width || out.Height() != height {
+		out.Close()
+		return nil, fmt.Errorf("tile at %d,%d: callback returned a %dx%d image for a %dx%d tile", x, y,
+			out.Width(), out.Height(), width, height)
+	}
+
+	return out, nil
+}
+
+// Mapim resamples an image using index to look up pixels
+func (r *ImageRef) Mapim(index *ImageRef) error {
+	out, err := vipsMapim(r.image, index.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// CylindricalProject warps the image onto a cylinder around its center, the projection panorama stitchers work in,
+// so that overlapping wide-angle shots taken by rotating the camera line up by a horizontal shift. focalLengthPx is
+// the focal length in pixels, i.e. the focal length divided by the pixel pitch of the sensor. The size of the image is
+// kept; the corners the projection moves inwards are filled with black.
+func (r *ImageRef) CylindricalProject(focalLengthPx float64) error {
+	if focalLengthPx <= 0 {
+		return fmt.Errorf("focal length must be positive, got %g", focalLengthPx)
+	}
+
+	out, err := vipsCylindricalProject(r.image, focalLengthPx)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// CorrectChromaticAberration corrects lateral chromatic aberration, the colored fringes towards the edges of images
+// from cheap lenses, by scaling the red and blue bands about the center relative to the green one, e.g. by 0.999 and
+// 1.001. A scale of 1 leaves a band unchanged. The image must be RGB, with or without alpha; its size is kept.
+func (r *ImageRef) CorrectChromaticAberration(redScale, blueScale float64) error {
+	if redScale <= 0 || blueScale <= 0 {
+		return fmt.Errorf("band scales must be positive, got %g and %g", redScale, blueScale)
+	}
+	if r.Bands() < 3 {
+		return fmt.Errorf("chromatic aberration correction needs an RGB image, got %d bands", r.Bands())
+	}
+
+	out, err := vipsCorrectChromaticAberration(r.image, redScale, blueScale)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Maplut maps an image through another image acting as a LUT (Look Up Table)
+func (r *ImageRef) Maplut(lut *ImageRef) error {
+	out, err := vipsMaplut(r.image, lut.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// HistogramFind replaces the image by its histogram: a one pixel high image with one column per value, 256 for 8-bit
+// and 65536 for 16-bit images, and one band per image band counting the pixels of that value.
+// See https://libvips.github.io/libvips/API/current/libvips-histogram.html#vips-hist-find
+func (r *ImageRef) HistogramFind() error {
+	out, err := vipsHistFind(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// HistogramNormalize scales a histogram, e.g. from HistogramFind or HistogramCumulative, so that its largest value is
+// the number of columns minus one, which makes a cumulative histogram usable as a LUT with Maplut.
+// See https://libvips.github.io/libvips/API/current/libvips-histogram.html#vips-hist-norm
+func (r *ImageRef) HistogramNormalize() error {
+	out, err := vipsHistNorm(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// HistogramCumulative replaces a histogram, e.g. from HistogramFind, by its cumulative histogram.
+// See https://libvips.github.io/libvips/API/current/libvips-histogram.html#vips-hist-cum
+func (r *ImageRef) HistogramCumulative() error {
+	out, err := vipsHistCum(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// HistogramEqualize spreads the values of the image over the whole range of its format, for global contrast
+// correction. Each band is equalized separately.
+// See https://libvips.github.io/libvips/API/current/libvips-histogram.html#vips-hist-equal
+func (r *ImageRef) HistogramEqualize() error {
+	out, err := vipsHistEqual(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// HistogramData returns the histogram of each band of the image, counting the pixels of every value, without changing
+// the image. The image must be 8 or 16-bit unsigned.
+func (r *ImageRef) HistogramData() ([][]int, error) {
+	hist, err := vipsHistFind(r.image)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(hist)
+
+	return vipsHistValues(hist)
+}
+
+// HistogramMasked returns the histogram of each band of the image like HistogramData, only counting the pixels where
+// the first band of mask is nonzero, e.g. for a region of interest. The mask must have the size of the image. The
+// histograms of 16-bit images stop at the largest value found.
+func (r *ImageRef) HistogramMasked(mask *ImageRef) ([][]int, error) {
+	if mask.Width() != r.Width() || mask.Height() != r.Height() {
+		return nil, fmt.Errorf("mask size %dx%d does not match image size %dx%d", mask.Width(), mask.Height(), r.Width(), r.Height())
+	}
+
+	hist, err := vipsHistFindMasked(r.image, mask.image)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(hist)
+
+	return vipsHistValues(hist)
+}
+
+// MapToPalette replaces every pixel with its nearest color of a fixed palette, e.g. for e-ink displays,
+// LED boards or brand constrained assets. dither ranges from 0 (no dithering) to 1 (full ordered
+// dithering) and controls how much the palette colors are mixed to approximate the ones in between.
+// The image is converted to sRGB, an alpha channel is kept as is.
+func (r *ImageRef) MapToPalette(palette []Color, dither float64) error {
+	out, err := vipsMapToPalette(r.image, palette, dither)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// ExtractBand extracts one or more bands out of the image (replacing the associated ImageRef)
+func (r *ImageRef) ExtractBand(band int, num int) error {
+	out, err := vipsExtractBand(r.image, band, num)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// BandJoin joins a set of images together, bandwise.
+func (r *ImageRef) BandJoin(images ...*ImageRef) error {
+	vipsImages := []*C.VipsImage{r.image}
+	for _, vipsImage := range images {
+		vipsImages = append(vipsImages, vipsImage.image)
+	}
+
+	out, err := vipsBandJoin(vipsImages)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// BandJoinConst appends a set of constant bands to an image.
+func (r *ImageRef) BandJoinConst(constants []float64) error {
+	out, err := vipsBandJoinConst(r.image, constants)
 	if err != nil {
 		return err
 	}
@@ -1131,6 +2828,26 @@ func (r *ImageRef) UnpremultiplyAlpha() error {
 	return nil
 }
 
+// IsPremultiplied returns whether the alpha of the image was premultiplied by PremultiplyAlpha or marked as such by
+// SetPremultiplied, so that UnpremultiplyAlpha reverts it.
+func (r *ImageRef) IsPremultiplied() bool {
+	return r.preMultiplication != nil
+}
+
+// SetPremultiplied records whether the alpha of the image is premultiplied, e.g. by operations run on it outside of
+// govips. originalFormat is the band format UnpremultiplyAlpha casts back to; it is ignored when state is false.
+// Neither the pixels nor the band format are changed.
+func (r *ImageRef) SetPremultiplied(state bool, originalFormat BandFormat) {
+	if !state {
+		r.preMultiplication = nil
+		return
+	}
+
+	r.preMultiplication = &PreMultiplicationState{
+		bandFormat: originalFormat,
+	}
+}
+
 // Cast converts the image to a target band format
 func (r *ImageRef) Cast(format BandFormat) error {
 	out, err := vipsCast(r.image, format)
@@ -1161,13 +2878,85 @@ func (r *ImageRef) Multiply(multiplier *ImageRef) error {
 	return nil
 }
 
-// Divide calculates the product of the image / denominator and stores it back in the image
-func (r *ImageRef) Divide(denominator *ImageRef) error {
-	out, err := vipsDivide(r.image, denominator.image)
-	if err != nil {
-		return err
+// Divide calculates the product of the image / denominator and stores it back in the image
+func (r *ImageRef) Divide(denominator *ImageRef) error {
+	out, err := vipsDivide(r.image, denominator.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Blend linearly interpolates between the image and other, weight 0 keeping the image and 1 giving other, e.g. for
+// cross-fades. Both images must have the same size and number of bands. The band format of the image is kept.
+func (r *ImageRef) Blend(other *ImageRef, weight float64) error {
+	if weight < 0 || weight > 1 {
+		return fmt.Errorf("blend weight must be between 0 and 1, got %g", weight)
+	}
+	if err := r.checkSameShape(other); err != nil {
+		return err
+	}
+
+	out, err := vipsBlend(r.image, other.image, weight)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// BlendWithMask linearly interpolates between the image and other per pixel, weighted by mask: black keeps the image,
+// white gives other. The mask is a single band image, or has one band per image band, of the same size as the images.
+// White is 255 for uchar masks, 65535 for ushort and 1.0 for float masks.
+func (r *ImageRef) BlendWithMask(other, mask *ImageRef) error {
+	if err := r.checkSameShape(other); err != nil {
+		return err
+	}
+	if mask.Width() != r.Width() || mask.Height() != r.Height() {
+		return fmt.Errorf("blend mask size %dx%d does not match image size %dx%d", mask.Width(), mask.Height(), r.Width(), r.Height())
+	}
+	if mask.Bands() != 1 && mask.Bands() != r.Bands() {
+		return fmt.Errorf("blend mask must have 1 or %d bands, got %d", r.Bands(), mask.Bands())
+	}
+
+	out, err := vipsBlendMask(r.image, other.image, mask.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// CalibrateFlatField corrects the image for the dark current and uneven illumination or sensor response of the
+// capture device, for microscopy and astrophotography: dark is a capture without light and flat one of a uniformly lit
+// field, both taken with the same settings. The image becomes (image - dark) * mean(flat - dark) / (flat - dark),
+// the mean being taken band by band, computed in float and cast back to the band format of the image. All images must
+// have the same size and bands.
+func (r *ImageRef) CalibrateFlatField(dark, flat *ImageRef) error {
+	if err := r.checkSameShape(dark); err != nil {
+		return err
+	}
+	if err := r.checkSameShape(flat); err != nil {
+		return err
+	}
+
+	out, err := vipsCalibrateFlatField(r.image, dark.image, flat.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// checkSameShape checks that other has the size and number of bands of the image
+func (r *ImageRef) checkSameShape(other *ImageRef) error {
+	if other.Width() != r.Width() || other.Height() != r.Height() {
+		return fmt.Errorf("image size %dx%d does not match %dx%d", other.Width(), other.Height(), r.Width(), r.Height())
+	}
+	if other.Bands() != r.Bands() {
+		return fmt.Errorf("image has %d bands instead of %d", other.Bands(), r.Bands())
 	}
-	r.setImage(out)
 	return nil
 }
 
@@ -1250,6 +3039,145 @@ func (r *ImageRef) ExtractArea(left, top, width, height int) error {
 	return nil
 }
 
+// CropMode controls how Crop treats rectangles reaching outside the image bounds
+type CropMode int
+
+// CropMode enum
+const (
+	// CropModeClamp intersects the rectangle with the image bounds
+	CropModeClamp CropMode = iota
+	// CropModeError fails if the rectangle is not fully contained in the image
+	CropModeError
+)
+
+// Crop crops the image to the given rectangle, clamping it against the image bounds.
+// Rectangles may use negative coordinates and need not be canonical.
+// For multi-page images the rectangle applies to every page.
+func (r *ImageRef) Crop(rect image.Rectangle) error {
+	return r.CropWithMode(rect, CropModeClamp)
+}
+
+// CropWithMode crops the image to the given rectangle, either clamping it against
+// the image bounds or failing when it reaches outside of them.
+func (r *ImageRef) CropWithMode(rect image.Rectangle, mode CropMode) error {
+	area, err := cropArea(rect, image.Rect(0, 0, r.Width(), r.PageHeight()), mode)
+	if err != nil {
+		return err
+	}
+	return r.ExtractArea(area.Min.X, area.Min.Y, area.Dx(), area.Dy())
+}
+
+// CropWithGravity crops the image to width x height, keeping the part given by gravity, e.g. the bottom right corner
+// for GravitySouthEast or the middle for GravityCentre. Unlike SmartCrop the result does not depend on the content.
+// A width or height larger than the image keeps that dimension unchanged. For multi-page images every page is
+// cropped the same way.
+func (r *ImageRef) CropWithGravity(width, height int, gravity Gravity) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid crop size %dx%d", width, height)
+	}
+
+	pageWidth, pageHeight := r.Width(), r.PageHeight()
+	width, height = minInt(width, pageWidth), minInt(height, pageHeight)
+	left, top := gravityOffsets(pageWidth, pageHeight, width, height, gravity, 0, 0)
+	return r.ExtractArea(left, top, width, height)
+}
+
+func cropArea(rect, bounds image.Rectangle, mode CropMode) (image.Rectangle, error) {
+	rect = rect.Canon()
+
+	if mode == CropModeError && !rect.In(bounds) {
+		return image.Rectangle{}, fmt.Errorf("crop rectangle %v is outside of image bounds %v", rect, bounds)
+	}
+
+	area := rect.Intersect(bounds)
+	if area.Empty() {
+		return image.Rectangle{}, fmt.Errorf("crop rectangle %v does not intersect image bounds %v", rect, bounds)
+	}
+	return area, nil
+}
+
+// LosslessRotateJpeg rotates a JPEG image clockwise by rearranging its DCT blocks instead of decoding and
+// re-encoding the pixels, so there is no generation loss. The image must be unmodified since it was loaded and
+// its width and height must be multiples of the MCU size, i.e. 8 or 16 pixels depending on chroma subsampling.
+// Metadata is kept as is, except the EXIF orientation which is composed with the rotation so that the image is
+// displayed as before, e.g. rotating an image with orientation 6 by 90 degrees bakes its orientation into the pixels
+// and leaves orientation 1. Images without an EXIF orientation are displayed rotated.
+func (r *ImageRef) LosslessRotateJpeg(angle Angle) error {
+	var degrees int
+	switch angle {
+	case Angle0:
+		return nil
+	case Angle90:
+		degrees = 90
+	case Angle180:
+		degrees = 180
+	case Angle270:
+		degrees = 270
+	default:
+		return fmt.Errorf("unsupported angle %d", angle)
+	}
+
+	if err := r.checkLosslessJpeg(); err != nil {
+		return err
+	}
+
+	buf, err := losslessTransformJpeg(r.buf, degrees, image.Rectangle{})
+	if err != nil {
+		return err
+	}
+	return r.reloadLosslessJpeg(rotateJpegOrientation(buf, degrees))
+}
+
+// LosslessCropJpeg crops a JPEG image to rect by copying its DCT blocks instead of decoding and re-encoding
+// the pixels, so there is no generation loss. The image must be unmodified since it was loaded, rect must be
+// within the image and its origin a multiple of the MCU size, i.e. 8 or 16 pixels depending on chroma subsampling.
+func (r *ImageRef) LosslessCropJpeg(rect image.Rectangle) error {
+	if err := r.checkLosslessJpeg(); err != nil {
+		return err
+	}
+
+	area, err := cropArea(rect, image.Rect(0, 0, r.Width(), r.Height()), CropModeError)
+	if err != nil {
+		return err
+	}
+
+	buf, err := losslessTransformJpeg(r.buf, 0, area)
+	if err != nil {
+		return err
+	}
+	return r.reloadLosslessJpeg(buf)
+}
+
+// EstimatedQuality approximates the quality setting the source JPEG was encoded with, on the usual 1 to 100
+// scale, by comparing its quantization tables with the standard ones. Re-encoding at a higher quality than
+// this only wastes bytes. Files written by encoders with custom tables yield a rough equivalent.
+func (r *ImageRef) EstimatedQuality() (int, error) {
+	if r.originalFormat != ImageTypeJPEG || len(r.buf) == 0 {
+		return 0, errors.New("quality can only be estimated for JPEG images")
+	}
+
+	return estimateJpegQuality(r.buf)
+}
+
+func (r *ImageRef) checkLosslessJpeg() error {
+	if r.format != ImageTypeJPEG || !r.unmodified || len(r.buf) == 0 {
+		return errors.New("lossless transforms require an unmodified JPEG image")
+	}
+	return nil
+}
+
+func (r *ImageRef) reloadLosslessJpeg(buf []byte) error {
+	out, _, _, err := vipsLoadFromBuffer(buf, NewImportParams())
+	if err != nil {
+		return err
+	}
+
+	r.setImage(out)
+	r.buf = buf
+	r.unmodified = true
+	return nil
+}
+
 // RemoveICCProfile removes the ICC Profile information from the image.
 // Typically, browsers and other software assume images without profile to be in the sRGB color space.
 func (r *ImageRef) RemoveICCProfile() error {
@@ -1264,14 +3192,71 @@ func (r *ImageRef) RemoveICCProfile() error {
 	return nil
 }
 
-// TransformICCProfile transforms from the embedded ICC profile of the image to the icc profile at the given path.
+// ICCProfile returns a copy of the embedded ICC profile, nil if the image has none.
+func (r *ImageRef) ICCProfile() ([]byte, error) {
+	profile, _, err := vipsImageGetBlob(r.image, C.VIPS_META_ICC_NAME)
+	return profile, err
+}
+
+// SetICCProfile embeds the given ICC profile, replacing any existing one. The pixels are not transformed, so it
+// tags the image with the color space it actually uses; use TransformICCProfile afterwards to convert from it.
+func (r *ImageRef) SetICCProfile(profile []byte) error {
+	if !isICCProfile(profile) {
+		return ErrInvalidICCProfile
+	}
+
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+
+	vipsImageSetBlob(out, C.VIPS_META_ICC_NAME, profile)
+
+	r.setImage(out)
+	return nil
+}
+
+// isICCProfile checks the size of the ICC profile header and its "acsp" signature
+func isICCProfile(profile []byte) bool {
+	return len(profile) >= 128 && bytes.Equal(profile[36:40], []byte("acsp"))
+}
+
+// isICCDeviceLink checks whether the device class of an ICC profile is a device link
+func isICCDeviceLink(profile []byte) bool {
+	return isICCProfile(profile) && bytes.Equal(profile[12:16], []byte("link"))
+}
+
+// isICCDeviceLinkFile checks whether the file at path is a device link ICC profile, false if it can't be read, e.g.
+// for the built-in profile names of libvips
+func isICCDeviceLinkFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, 128)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false
+	}
+	return isICCDeviceLink(header)
+}
+
+// TransformICCProfile transforms from the embedded ICC profile of the image to the icc profile at the given path, or
+// registered with RegisterICCProfile under the given name.
+// If the profile at the given path is a device link, the image is converted with it as with TransformICCDeviceLink.
 func (r *ImageRef) TransformICCProfile(outputProfilePath string) error {
+	outputProfilePath = iccProfilePath(outputProfilePath)
+	if isICCDeviceLinkFile(outputProfilePath) {
+		return r.TransformICCDeviceLink(outputProfilePath, IntentPerceptual)
+	}
+
 	// If the image has an embedded profile, that will be used and the input profile ignored.
 	// Otherwise, images without an input profile are assumed to use a standard RGB profile.
 	embedded := r.HasICCProfile()
 	inputProfile := SRGBIEC6196621ICCProfilePath
 
-	out, err := vipsICCTransform(r.image, outputProfilePath, inputProfile, IntentPerceptual, 0, embedded)
+	out, err := vipsICCTransform(r.image, outputProfilePath, inputProfile, IntentPerceptual, 0, embedded, false)
 	if err != nil {
 		govipsLog("govips", LogLevelError, fmt.Sprintf("failed to do icc transform: %v", err.Error()))
 		return err
@@ -1281,10 +3266,91 @@ func (r *ImageRef) TransformICCProfile(outputProfilePath string) error {
 	return nil
 }
 
+// TransformICCDeviceLink converts the image with an ICC device link profile, a single transform from the color space
+// of the image straight to the color space of an output device, such as the proofing profiles supplied by print
+// providers, instead of a pair of input and output profiles. The embedded profile of the image is not used. The
+// device link is applied on its own with lcms, the metadata of the image is kept but its ICC profile is removed.
+// 16-bit images stay 16-bit, others are converted as 8-bit. The device link may also be the name of a profile
+// registered with RegisterICCProfile.
+func (r *ImageRef) TransformICCDeviceLink(deviceLinkPath string, intent Intent) error {
+	deviceLinkPath = iccProfilePath(deviceLinkPath)
+	link, err := ioutil.ReadFile(deviceLinkPath)
+	if err != nil || !isICCDeviceLink(link) {
+		return fmt.Errorf("%s is not a device link ICC profile", deviceLinkPath)
+	}
+
+	out, err := vipsICCDeviceLink(r.image, link, intent)
+	if err != nil {
+		govipsLog("govips", LogLevelError, fmt.Sprintf("failed to do icc device link transform: %v", err.Error()))
+		return err
+	}
+
+	r.setImage(out)
+	return nil
+}
+
+// SoftProof simulates on screen how the image would print with the ICC profile of a printer and paper: the colors are
+// converted to the printer profile with the given intent and back, so that colors the printer can't reproduce are
+// shown as it would print them. With gamutWarning these colors are painted grey instead, as image editors do. The
+// result is an 8-bit sRGB image; alpha is kept. The printer profile may also be the name of a profile registered with
+// RegisterICCProfile.
+func (r *ImageRef) SoftProof(printerProfilePath string, intent Intent, gamutWarning bool) error {
+	in := r.image
+	if r.Bands() < 3 || (r.Bands() == 3 && r.HasAlpha()) {
+		rgb, err := vipsToColorSpace(r.image, InterpretationSRGB)
+		if err != nil {
+			return err
+		}
+		defer clearImage(rgb)
+		in = rgb
+	}
+
+	inputProfile := SRGBIEC6196621ICCProfilePath
+	if r.Interpretation() == InterpretationCMYK {
+		inputProfile = "cmyk"
+	}
+
+	out, err := vipsSoftProof(in, inputProfile, iccProfilePath(printerProfilePath), SRGBIEC6196621ICCProfilePath, intent,
+		gamutWarning)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// OptimizeICCProfileParams are options of OptimizeICCProfileWithParams
+type OptimizeICCProfileParams struct {
+	Intent                 Intent
+	BlackPointCompensation bool // requires libvips 8.12+
+	// TargetProfile replaces the compact sRGB profile of color images, e.g. with a Display P3 profile for wide gamut
+	// outputs. It is a path or the name of a profile registered with RegisterICCProfile or built into libvips.
+	// Grayscale images keep the grayscale profile.
+	TargetProfile string
+}
+
+// NewOptimizeICCProfileParams creates the default options of OptimizeICCProfile: perceptual intent, no black point
+// compensation and the compact sRGB profile
+func NewOptimizeICCProfileParams() *OptimizeICCProfileParams {
+	return &OptimizeICCProfileParams{
+		Intent: IntentPerceptual,
+	}
+}
+
 // OptimizeICCProfile optimizes the ICC color profile of the image.
 // For two color channel images, it sets a grayscale profile.
 // For color images, it sets a CMYK or non-CMYK profile based on the image metadata.
 func (r *ImageRef) OptimizeICCProfile() error {
+	return r.OptimizeICCProfileWithParams(NewOptimizeICCProfileParams())
+}
+
+// OptimizeICCProfileWithParams optimizes the ICC color profile of the image as OptimizeICCProfile, with the given
+// rendering intent, black point compensation and target profile.
+func (r *ImageRef) OptimizeICCProfileWithParams(params *OptimizeICCProfileParams) error {
+	if params == nil {
+		params = NewOptimizeICCProfileParams()
+	}
+
 	inputProfile := r.determineInputICCProfile()
 	if !r.HasICCProfile() && (inputProfile == "") {
 		//No embedded ICC profile in the input image and no input profile determined, nothing to do.
@@ -1292,6 +3358,9 @@ func (r *ImageRef) OptimizeICCProfile() error {
 	}
 
 	r.optimizedIccProfile = SRGBV2MicroICCProfilePath
+	if params.TargetProfile != "" {
+		r.optimizedIccProfile = iccProfilePath(params.TargetProfile)
+	}
 	if r.Bands() <= 2 {
 		r.optimizedIccProfile = SGrayV2MicroICCProfilePath
 	}
@@ -1304,7 +3373,8 @@ func (r *ImageRef) OptimizeICCProfile() error {
 		depth = 8
 	}
 
-	out, err := vipsICCTransform(r.image, r.optimizedIccProfile, inputProfile, IntentPerceptual, depth, embedded)
+	out, err := vipsICCTransform(r.image, r.optimizedIccProfile, inputProfile, params.Intent, depth, embedded,
+		params.BlackPointCompensation)
 	if err != nil {
 		govipsLog("govips", LogLevelError, fmt.Sprintf("failed to do icc transform: %v", err.Error()))
 		return err
@@ -1330,6 +3400,29 @@ func (r *ImageRef) RemoveMetadata(keep ...string) error {
 	return nil
 }
 
+// CopyMetadataFrom copies metadata fields of src onto the image, for pipelines that regenerate the pixels but must
+// preserve provenance metadata. fields are names like "xmp-data" or "exif-ifd0-Copyright", a trailing * matching any
+// field with that prefix; the EXIF, XMP, IPTC and ICC profile fields are copied when none are given.
+// Fields of the image matching fields but missing in src are removed, so EXIF tags of both images are never mixed.
+func (r *ImageRef) CopyMetadataFrom(src *ImageRef, fields ...string) error {
+	if len(fields) == 0 {
+		fields = provenanceMetadata
+	}
+
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+
+	if err := vipsCopyMetadata(src.image, out, fields); err != nil {
+		clearImage(out)
+		return err
+	}
+
+	r.setImage(out)
+	return nil
+}
+
 func (r *ImageRef) ImageFields() []string {
 	return vipsImageGetFields(r.image)
 }
@@ -1350,36 +3443,255 @@ func (r *ImageRef) ToColorSpace(interpretation Interpretation) error {
 	if err != nil {
 		return err
 	}
-	r.setImage(out)
-	return nil
+	r.setImage(out)
+	return nil
+}
+
+// Flatten removes the alpha channel from the image and replaces it with the background color, which is converted to
+// inks without a profile for CMYK images
+func (r *ImageRef) Flatten(backgroundColor *Color) error {
+	out, err := vipsFlatten(r.image, backgroundColor)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// GaussianBlur blurs the image
+func (r *ImageRef) GaussianBlur(sigma float64) error {
+	out, err := vipsGaussianBlur(r.image, sigma)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// FeatherAlpha softens the edges of a cutout by blurring only its alpha channel with a gaussian of the given sigma,
+// so that it blends into the background when composited instead of showing a hard edge. The color bands are not
+// changed. The image must have an alpha channel.
+func (r *ImageRef) FeatherAlpha(sigma float64) error {
+	if !r.HasAlpha() {
+		return errors.New("cannot feather an image without alpha")
+	}
+	out, err := vipsFeatherAlpha(r.image, sigma)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Sharpen sharpens the image
+// sigma: sigma of the gaussian
+// x1: flat/jaggy threshold
+// m2: slope for jaggy areas
+func (r *ImageRef) Sharpen(sigma float64, x1 float64, m2 float64) error {
+	out, err := vipsSharpen(r.image, sigma, x1, m2)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// SharpenParams are the parameters of vips_sharpen, which sharpens the L* band of the image, between 0 and 100, by
+// the difference with a gaussian blur of it. Differences below X1 are flat areas and scaled by M1, larger ones are
+// jaggy areas and scaled by M2; the result is limited to brightening by Y2 and darkening by Y3.
+type SharpenParams struct {
+	// Sigma of the gaussian
+	Sigma float64
+	// X1 is the flat/jaggy threshold
+	X1 float64
+	// Y2 is the maximum brightening
+	Y2 float64
+	// Y3 is the maximum darkening
+	Y3 float64
+	// M1 is the slope for flat areas
+	M1 float64
+	// M2 is the slope for jaggy areas
+	M2 float64
+}
+
+// NewSharpenParams creates default parameters, the defaults of vips_sharpen
+func NewSharpenParams() *SharpenParams {
+	return &SharpenParams{
+		Sigma: 0.5,
+		X1:    2,
+		Y2:    10,
+		Y3:    20,
+		M1:    0,
+		M2:    3,
+	}
+}
+
+// SharpenWithParams sharpens the image with the full set of vips_sharpen parameters
+func (r *ImageRef) SharpenWithParams(params *SharpenParams) error {
+	if params == nil {
+		params = NewSharpenParams()
+	}
+	out, err := vipsSharpenParams(r.image, params)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// UnsharpMask sharpens the image like the unsharp mask filter of image editors: radius is the radius of the blur in
+// pixels, amount the strength of the sharpening, 1 for 100%, and threshold the smallest difference with the blurred
+// image, in 8-bit levels, which is sharpened, so that 0 sharpens everything and higher values leave smooth areas and
+// noise alone.
+func (r *ImageRef) UnsharpMask(radius, amount, threshold float64) error {
+	if radius <= 0 || amount < 0 || threshold < 0 {
+		return fmt.Errorf("invalid unsharp mask radius %g, amount %g or threshold %g", radius, amount, threshold)
+	}
+	return r.SharpenWithParams(&SharpenParams{
+		Sigma: radius,
+		X1:    threshold * 100 / 255,
+		Y2:    100,
+		Y3:    100,
+		M1:    0,
+		M2:    amount,
+	})
+}
+
+// Conv convolves the image with a kernel given row by row, e.g. {{-2, -1, 0}, {-1, 1, 1}, {0, 1, 2}} to emboss it.
+// With PrecisionFloat the result is a float image, with PrecisionInteger and PrecisionApproximate the result of an
+// integer image is rounded back to its format.
+func (r *ImageRef) Conv(kernel [][]float64, opts ConvOptions) error {
+	out, err := vipsConv(r.image, kernel, opts)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Sobel replaces each band of the image by the magnitude of its gradient computed with the Sobel operator, an edge
+// map that is bright on edges and dark in flat areas. 8-bit images stay 8-bit, others become float.
+func (r *ImageRef) Sobel() error {
+	out, err := vipsSobel(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Scharr is like Sobel with the Scharr operator, which estimates the gradient direction more accurately.
+// Requires libvips 8.13+.
+func (r *ImageRef) Scharr() error {
+	out, err := vipsScharr(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Canny detects the edges of the image with the Canny edge detector: the image is blurred with a gaussian of the given
+// sigma, e.g. 1.4, larger values keeping only stronger edges, and the gradient is thinned to one pixel wide lines.
+func (r *ImageRef) Canny(sigma float64, precision Precision) error {
+	out, err := vipsCanny(r.image, sigma, precision)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// BlurScore returns the variance of the Laplacian of the image luminance. Sharp images with well defined
+// edges score high, while blurry or out of focus images score low; a useful threshold depends on the content
+// and size of the images, e.g. around 100 for downscaled photos.
+func (r *ImageRef) BlurScore() (float64, error) {
+	return vipsBlurScore(r.image)
+}
+
+// NoiseScore estimates the standard deviation of the noise in the image luminance, in pixel values.
+// Clean images score close to 0, grainy or heavily compressed images score higher.
+func (r *ImageRef) NoiseScore() (float64, error) {
+	return vipsNoiseScore(r.image)
+}
+
+// TileHashes splits the image into a grid of tileSize x tileSize tiles and computes a perceptual hash of each,
+// indexed by tile row and column. Tiles on the right and bottom edges may be smaller. Comparing the hashes of
+// two images, e.g. by Hamming distance, finds regions they have in common even when cropped or recompressed.
+func (r *ImageRef) TileHashes(tileSize int, algo HashAlgorithm) ([][]uint64, error) {
+	if tileSize < 1 {
+		return nil, errors.New("tile size must be at least 1")
+	}
+
+	grey, err := r.hashableImage()
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(grey)
+
+	hashWidth, hashHeight := algo.hashInputSize()
+	width, height := r.Width(), r.Height()
+
+	var hashes [][]uint64
+	for y := 0; y < height; y += tileSize {
+		var row []uint64
+		for x := 0; x < width; x += tileSize {
+			pixels, err := vipsTilePixels(grey, x, y, minInt(tileSize, width-x), minInt(tileSize, height-y), hashWidth, hashHeight)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, algo.hash(pixels, hashWidth, hashHeight))
+		}
+		hashes = append(hashes, row)
+	}
+
+	return hashes, nil
+}
+
+// hashableImage returns an 8-bit single band luminance version of the image
+func (r *ImageRef) hashableImage() (*C.VipsImage, error) {
+	srgb, err := vipsToColorSpace(r.image, InterpretationSRGB)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(srgb)
+
+	bw, err := vipsToColorSpace(srgb, InterpretationBW)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(bw)
+
+	band, err := vipsExtractBand(bw, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(band)
+
+	// float images keep their format through the conversions, the pixels are read as bytes
+	return vipsCast(band, BandFormatUchar)
 }
 
-// Flatten removes the alpha channel from the image and replaces it with the background color
-func (r *ImageRef) Flatten(backgroundColor *Color) error {
-	out, err := vipsFlatten(r.image, backgroundColor)
+func vipsTilePixels(in *C.VipsImage, left, top, width, height, outWidth, outHeight int) ([]byte, error) {
+	tile, err := vipsExtractArea(in, left, top, width, height)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	r.setImage(out)
-	return nil
-}
+	defer clearImage(tile)
 
-// GaussianBlur blurs the image
-func (r *ImageRef) GaussianBlur(sigma float64) error {
-	out, err := vipsGaussianBlur(r.image, sigma)
+	reduced, err := vipsThumbnail(tile, outWidth, outHeight, InterestingNone, SizeForce)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	r.setImage(out)
-	return nil
+	defer clearImage(reduced)
+
+	return vipsImageToMemory(reduced)
 }
 
-// Sharpen sharpens the image
-// sigma: sigma of the gaussian
-// x1: flat/jaggy threshold
-// m2: slope for jaggy areas
-func (r *ImageRef) Sharpen(sigma float64, x1 float64, m2 float64) error {
-	out, err := vipsSharpen(r.image, sigma, x1, m2)
+// EnergyMap replaces the image with its gradient magnitude, normalized to a single 0-255 band.
+// Bright areas mark edges and detail, which can be used to drive content-aware cropping decisions.
+func (r *ImageRef) EnergyMap() error {
+	out, err := vipsEnergyMap(r.image)
 	if err != nil {
 		return err
 	}
@@ -1480,12 +3792,99 @@ func (r *ImageRef) Average() (float64, error) {
 	return out, nil
 }
 
+// AverageMasked finds the average of the bands of the pixels where the first band of mask is nonzero, e.g. for a
+// region of interest. The mask must have the size of the image and select at least one pixel.
+func (r *ImageRef) AverageMasked(mask *ImageRef) (float64, error) {
+	if mask.Width() != r.Width() || mask.Height() != r.Height() {
+		return 0, fmt.Errorf("mask size %dx%d does not match image size %dx%d", mask.Width(), mask.Height(), r.Width(), r.Height())
+	}
+
+	return vipsAverageMasked(r.image, mask.image)
+}
+
 // FindTrim returns the bounding box of the non-border part of the image
 // Returned values are left, top, width, height
 func (r *ImageRef) FindTrim(threshold float64, backgroundColor *Color) (int, int, int, int, error) {
 	return vipsFindTrim(r.image, threshold, backgroundColor)
 }
 
+// TrimMargins are the widths of the borders removed from each side of an image by Trim
+type TrimMargins struct {
+	Left   int
+	Top    int
+	Right  int
+	Bottom int
+}
+
+// Trim removes the borders of the image whose pixels differ from backgroundColor by at most threshold, like FindTrim
+// followed by ExtractArea, and returns the widths of the removed borders. With a nil backgroundColor, images with
+// alpha are trimmed of their transparent borders and other images of their white borders; with a backgroundColor,
+// images with alpha are flattened on it first. For multi-page images the content of all pages is kept, so that
+// every page is cropped the same way. An image which is all background is left unchanged.
+func (r *ImageRef) Trim(threshold float64, backgroundColor *Color) (*TrimMargins, error) {
+	in := r.image
+	switch {
+	case backgroundColor == nil && r.HasAlpha():
+		alpha, err := vipsExtractBand(r.image, r.Bands()-1, 1)
+		if err != nil {
+			return nil, err
+		}
+		defer clearImage(alpha)
+		in, backgroundColor = alpha, &Color{}
+	case r.HasAlpha():
+		flattened, err := vipsFlatten(r.image, backgroundColor)
+		if err != nil {
+			return nil, err
+		}
+		defer clearImage(flattened)
+		in = flattened
+	case backgroundColor == nil:
+		backgroundColor = &Color{R: 255, G: 255, B: 255}
+	}
+
+	width, pageHeight := r.Width(), r.PageHeight()
+	var content image.Rectangle
+	for top := 0; top < r.Height(); top += pageHeight {
+		bounds, err := pageTrim(in, top, width, pageHeight, r.Height() > pageHeight, threshold, backgroundColor)
+		if err != nil {
+			return nil, err
+		}
+		content = content.Union(bounds)
+	}
+
+	if content.Empty() || content == image.Rect(0, 0, width, pageHeight) {
+		return &TrimMargins{}, nil
+	}
+
+	if err := r.ExtractArea(content.Min.X, content.Min.Y, content.Dx(), content.Dy()); err != nil {
+		return nil, err
+	}
+	return &TrimMargins{
+		Left:   content.Min.X,
+		Top:    content.Min.Y,
+		Right:  width - content.Max.X,
+		Bottom: pageHeight - content.Max.Y,
+	}, nil
+}
+
+// pageTrim returns the bounds of the content of the page starting at row pageTop, empty if it is all background
+func pageTrim(in *C.VipsImage, pageTop, width, pageHeight int, multiPage bool, threshold float64, backgroundColor *Color) (image.Rectangle, error) {
+	page := in
+	if multiPage {
+		var err error
+		if page, err = vipsExtractArea(in, 0, pageTop, width, pageHeight); err != nil {
+			return image.Rectangle{}, err
+		}
+		defer clearImage(page)
+	}
+
+	left, top, w, h, err := vipsFindTrim(page, threshold, backgroundColor)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return image.Rect(left, top, left+w, top+h), nil
+}
+
 // GetPoint reads a single pixel on an image.
 // The pixel values are returned in a slice of length n.
 func (r *ImageRef) GetPoint(x int, y int) ([]float64, error) {
@@ -1498,6 +3897,7 @@ func (r *ImageRef) GetPoint(x int, y int) ([]float64, error) {
 
 // DrawRect draws an (optionally filled) rectangle with a single colour
 func (r *ImageRef) DrawRect(ink ColorRGBA, left int, top int, width int, height int, fill bool) error {
+	r.markModified()
 	err := vipsDrawRect(r.image, ink, left, top, width, height, fill)
 	if err != nil {
 		return err
@@ -1505,6 +3905,37 @@ func (r *ImageRef) DrawRect(ink ColorRGBA, left int, top int, width int, height
 	return nil
 }
 
+// DrawCircle draws an (optionally filled) circle with a single colour, centred on cx, cy. Like DrawRect, it draws on
+// the image in place.
+func (r *ImageRef) DrawCircle(ink ColorRGBA, cx int, cy int, radius int, fill bool) error {
+	r.markModified()
+	return vipsDrawCircle(r.image, ink, cx, cy, radius, fill)
+}
+
+// DrawLine draws a one pixel wide line with a single colour from x1, y1 to x2, y2. Like DrawRect, it draws on the
+// image in place.
+func (r *ImageRef) DrawLine(ink ColorRGBA, x1 int, y1 int, x2 int, y2 int) error {
+	r.markModified()
+	return vipsDrawLine(r.image, ink, x1, y1, x2, y2)
+}
+
+// DrawFlood flood fills with a single colour from x, y, like the paint bucket of image editors, and returns the
+// rectangle enclosing the pixels it changed. With equal, it fills the pixels equal to the one at x, y, otherwise the
+// pixels up to a boundary of the ink colour. Like DrawRect, it draws on the image in place.
+func (r *ImageRef) DrawFlood(x int, y int, ink ColorRGBA, equal bool) (image.Rectangle, error) {
+	r.markModified()
+	return vipsDrawFlood(r.image, ink, x, y, equal)
+}
+
+// DrawImage paints sub onto the image at x, y, replacing the pixels with CombineModeSet or adding to them with
+// CombineModeAdd; sub is cast to the band format and number of bands of the image. Like DrawRect, it draws on the
+// image in place, which is cheaper than Insert or Composite when pasting many images onto a canvas, e.g. to assemble
+// a sprite sheet, but doesn't blend with alpha.
+func (r *ImageRef) DrawImage(sub *ImageRef, x int, y int, mode CombineMode) error {
+	r.markModified()
+	return vipsDrawImage(r.image, sub.image, x, y, mode)
+}
+
 // Rank does rank filtering on an image. A window of size width by height is passed over the image.
 // At each position, the pixels inside the window are sorted into ascending order and the pixel at position
 // index is output. index numbers from 0.
@@ -1517,6 +3948,76 @@ func (r *ImageRef) Rank(width int, height int, index int) error {
 	return nil
 }
 
+// Morph applies a morphological operation with a structuring element to a binary image, 0 for clear pixels and 255
+// for set ones, such as a thresholded mask. The image must be 8-bit.
+func (r *ImageRef) Morph(element StructuringElement, op Morphology) error {
+	out, err := vipsMorph(r.image, element, op)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Erode shrinks the set areas of a binary image, removing specks smaller than the structuring element
+func (r *ImageRef) Erode(element StructuringElement) error {
+	return r.Morph(element, MorphologyErode)
+}
+
+// Dilate grows the set areas of a binary image, filling holes smaller than the structuring element
+func (r *ImageRef) Dilate(element StructuringElement) error {
+	return r.Morph(element, MorphologyDilate)
+}
+
+// MorphOpen erodes then dilates a binary image, removing specks smaller than the structuring element while keeping the
+// size of larger areas
+func (r *ImageRef) MorphOpen(element StructuringElement) error {
+	return r.morphSequence(element, MorphologyErode, MorphologyDilate)
+}
+
+// MorphClose dilates then erodes a binary image, filling holes and gaps smaller than the structuring element while
+// keeping the size of larger areas
+func (r *ImageRef) MorphClose(element StructuringElement) error {
+	return r.morphSequence(element, MorphologyDilate, MorphologyErode)
+}
+
+func (r *ImageRef) morphSequence(element StructuringElement, first, second Morphology) error {
+	tmp, err := vipsMorph(r.image, element, first)
+	if err != nil {
+		return err
+	}
+	defer clearImage(tmp)
+
+	out, err := vipsMorph(tmp, element, second)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// FillNearest sets every zero pixel of the image to the value of the nearest non-zero pixel, e.g. to fill the holes
+// of a mask or grow the regions of a label image until they meet. Requires libvips 8.13+.
+func (r *ImageRef) FillNearest() error {
+	out, _, err := vipsFillNearest(r.image, false)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// FillNearestWithDistance is like FillNearest and also returns a float image of the distance of each pixel to the
+// non-zero pixel it was filled from, 0 for the non-zero pixels themselves. Requires libvips 8.13+.
+func (r *ImageRef) FillNearestWithDistance() (*ImageRef, error) {
+	out, distance, err := vipsFillNearest(r.image, true)
+	if err != nil {
+		return nil, err
+	}
+	r.setImage(out)
+	return newImageRef(distance, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
 // Resize resizes the image based on the scale, maintaining aspect ratio
 func (r *ImageRef) Resize(scale float64, kernel Kernel) error {
 	return r.ResizeWithVScale(scale, -1, kernel)
@@ -1563,6 +4064,49 @@ func (r *ImageRef) Thumbnail(width, height int, crop Interesting) error {
 	return nil
 }
 
+// ThumbnailWithCropRect resizes the image to the given width and height like Thumbnail and returns the
+// rectangle of the original image that ended up in the thumbnail, so the same crop can be reproduced
+// on the full-size image.
+func (r *ImageRef) ThumbnailWithCropRect(width, height int, crop Interesting) (image.Rectangle, error) {
+	inWidth := r.Width()
+	inHeight := r.Height()
+
+	if crop == InterestingNone {
+		if err := r.Thumbnail(width, height, crop); err != nil {
+			return image.Rectangle{}, err
+		}
+		return image.Rect(0, 0, inWidth, inHeight), nil
+	}
+
+	// shrink so that the image covers the target box, then crop what is left over
+	scale := math.Max(float64(width)/float64(inWidth), float64(height)/float64(inHeight))
+	coverWidth := int(math.Ceil(float64(inWidth) * scale))
+	coverHeight := int(math.Ceil(float64(inHeight) * scale))
+
+	out, err := vipsThumbnail(r.image, coverWidth, coverHeight, InterestingNone, SizeBoth)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	r.setImage(out)
+
+	scaleX := float64(r.Width()) / float64(inWidth)
+	scaleY := float64(r.Height()) / float64(inHeight)
+
+	details, err := r.SmartCropWithDetails(minInt(width, r.Width()), minInt(height, r.Height()), crop)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	rect := image.Rect(
+		roundFloat(float64(details.Left)/scaleX),
+		roundFloat(float64(details.Top)/scaleY),
+		roundFloat(float64(details.Left+details.Width)/scaleX),
+		roundFloat(float64(details.Top+details.Height)/scaleY),
+	)
+
+	return rect.Intersect(image.Rect(0, 0, inWidth, inHeight)), nil
+}
+
 // ThumbnailWithSize resizes the image to the given width and height.
 // crop decides algorithm vips uses to shrink and crop to fill target,
 // size controls upsize, downsize, both or force
@@ -1619,6 +4163,12 @@ func (r *ImageRef) EmbedBackground(left, top, width, height int, backgroundColor
 
 // EmbedBackgroundRGBA embeds the given picture with a background rgba color
 func (r *ImageRef) EmbedBackgroundRGBA(left, top, width, height int, backgroundColor *ColorRGBA) error {
+	if r.Bands() == 2 {
+		// grey with alpha, use the red channel as grey level and keep the alpha of the background
+		return r.EmbedWithConstant(left, top, width, height,
+			[]float64{float64(backgroundColor.R), float64(backgroundColor.A)})
+	}
+
 	if r.Height() > r.PageHeight() {
 		out, err := vipsEmbedMultiPageBackground(r.image, left, top, width, height, backgroundColor)
 		if err != nil {
@@ -1635,6 +4185,95 @@ func (r *ImageRef) EmbedBackgroundRGBA(left, top, width, height int, backgroundC
 	return nil
 }
 
+// EmbedWithConstant embeds the given picture with a per-band constant background, e.g. for scientific images
+// where an RGB color is meaningless. A single value is used for every band; otherwise one value per band,
+// including alpha, is expected and used as-is.
+func (r *ImageRef) EmbedWithConstant(left, top, width, height int, background []float64) error {
+	if len(background) == 0 {
+		return errors.New("background must have at least one value")
+	}
+	if len(background) != 1 && len(background) != r.Bands() {
+		return fmt.Errorf("background must have 1 or %d values, got %d", r.Bands(), len(background))
+	}
+
+	if r.Height() > r.PageHeight() {
+		out, err := vipsEmbedMultiPageConstant(r.image, left, top, width, height, background)
+		if err != nil {
+			return err
+		}
+		r.setImage(out)
+	} else {
+		out, err := vipsEmbedConstant(r.image, left, top, width, height, background)
+		if err != nil {
+			return err
+		}
+		r.setImage(out)
+	}
+	return nil
+}
+
+// PositionOnCanvas places the image on a canvas of canvasWidth x canvasHeight according to gravity.
+// The offsets are margins measured from the edge the image is anchored to, so a south-east gravity
+// with offsets of 20 leaves a 20px margin to the bottom and right. For centred axes the offsets shift
+// the image right and down. A nil background fills the canvas with transparent black, which is plain
+// black for images without an alpha channel.
+func (r *ImageRef) PositionOnCanvas(canvasWidth, canvasHeight int, gravity Gravity, offsetX, offsetY int, backgroundColor *ColorRGBA) error {
+	left, top := gravityOffsets(canvasWidth, canvasHeight, r.Width(), r.PageHeight(), gravity, offsetX, offsetY)
+
+	if backgroundColor == nil {
+		backgroundColor = &ColorRGBA{}
+	}
+	return r.EmbedBackgroundRGBA(left, top, canvasWidth, canvasHeight, backgroundColor)
+}
+
+// Gravity places the image on a canvas of width x height according to gravity, filling the new pixels according to
+// extend, e.g. ExtendBackground with backgroundColor, transparent black when nil, or ExtendCopy to repeat the edges.
+// A smaller canvas crops the image the same way. Every page of animated images is placed on its own canvas.
+func (r *ImageRef) Gravity(gravity Gravity, width, height int, extend ExtendStrategy, backgroundColor *ColorRGBA) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid canvas size %dx%d", width, height)
+	}
+	if backgroundColor == nil {
+		backgroundColor = &ColorRGBA{}
+	}
+
+	if r.Height() > r.PageHeight() {
+		left, top := gravityOffsets(width, height, r.Width(), r.PageHeight(), gravity, 0, 0)
+		if extend == ExtendBackground {
+			return r.EmbedBackgroundRGBA(left, top, width, height, backgroundColor)
+		}
+		return r.Embed(left, top, width, height, extend)
+	}
+
+	out, err := vipsGravity(r.image, gravity, width, height, extend, backgroundColor)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+func gravityOffsets(canvasWidth, canvasHeight, width, height int, gravity Gravity, offsetX, offsetY int) (int, int) {
+	left := (canvasWidth-width)/2 + offsetX
+	top := (canvasHeight-height)/2 + offsetY
+
+	switch gravity {
+	case GravityWest, GravityNorthWest, GravitySouthWest:
+		left = offsetX
+	case GravityEast, GravityNorthEast, GravitySouthEast:
+		left = canvasWidth - width - offsetX
+	}
+
+	switch gravity {
+	case GravityNorth, GravityNorthWest, GravityNorthEast:
+		top = offsetY
+	case GravitySouth, GravitySouthWest, GravitySouthEast:
+		top = canvasHeight - height - offsetY
+	}
+
+	return left, top
+}
+
 // Zoom zooms the image by repeating pixels (fast nearest-neighbour)
 func (r *ImageRef) Zoom(xFactor int, yFactor int) error {
 	out, err := vipsZoom(r.image, xFactor, yFactor)
@@ -1692,6 +4331,32 @@ func (r *ImageRef) Rotate(angle Angle) error {
 	return nil
 }
 
+// RotateAngle rotates the image clockwise by an arbitrary number of degrees, enlarging it to fit the rotated
+// content. New pixels are filled with background, transparent black when nil; its alpha is ignored if the image has
+// no alpha channel. Images with alpha are rotated premultiplied and every page of a multi-page image is rotated on
+// its own. Multiples of 90 degrees are exact rotations, see Rotate.
+func (r *ImageRef) RotateAngle(degrees float64, background *ColorRGBA) error {
+	degrees = math.Mod(degrees, 360)
+	if degrees < 0 {
+		degrees += 360
+	}
+
+	if math.Mod(degrees, 90) == 0 {
+		return r.Rotate([]Angle{Angle0, Angle90, Angle180, Angle270}[int(degrees)/90])
+	}
+
+	if background == nil {
+		background = &ColorRGBA{}
+	}
+
+	out, err := vipsRotateAngle(r.image, degrees, background)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // Similarity lets you scale, offset and rotate images by arbitrary angles in a single operation while defining the
 // color of new background pixels. If the input image has no alpha channel, the alpha on `backgroundColor` will be
 // ignored. You can add an alpha channel to an image with `BandJoinConst` (e.g. `img.BandJoinConst([]float64{255})`) or
@@ -1706,6 +4371,30 @@ func (r *ImageRef) Similarity(scale float64, angle float64, backgroundColor *Col
 	return nil
 }
 
+// Shear slants the image by xDegrees horizontally, moving lower rows right for positive angles like italic text, and by
+// yDegrees vertically, moving right columns down for positive angles. The canvas grows to fit the whole sheared image
+// and new pixels are filled with backgroundColor, transparent black when nil; the alpha of backgroundColor is ignored
+// if the image has no alpha channel.
+func (r *ImageRef) Shear(xDegrees, yDegrees float64, backgroundColor *ColorRGBA) error {
+	if math.Abs(xDegrees) >= 90 || math.Abs(yDegrees) >= 90 {
+		return fmt.Errorf("shear angles %g and %g must be between -90 and 90 degrees", xDegrees, yDegrees)
+	}
+	shearX, shearY := math.Tan(xDegrees*math.Pi/180), math.Tan(yDegrees*math.Pi/180)
+	if math.Abs(1-shearX*shearY) < 1e-6 {
+		return fmt.Errorf("shear angles %g and %g collapse the image", xDegrees, yDegrees)
+	}
+	if backgroundColor == nil {
+		backgroundColor = &ColorRGBA{}
+	}
+
+	out, err := vipsShear(r.image, shearX, shearY, backgroundColor)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // Grid tiles the image pages into a matrix across*down
 func (r *ImageRef) Grid(tileHeight, across, down int) error {
 	out, err := vipsGrid(r.image, tileHeight, across, down)
@@ -1726,6 +4415,41 @@ func (r *ImageRef) SmartCrop(width int, height int, interesting Interesting) err
 	return nil
 }
 
+// SmartCropDetails describes where a smart crop landed in the original image.
+// AttentionX and AttentionY are the focal point libvips picked, Left, Top, Width and Height the crop rectangle.
+type SmartCropDetails struct {
+	Left       int
+	Top        int
+	Width      int
+	Height     int
+	AttentionX int
+	AttentionY int
+}
+
+// SmartCropWithDetails crops the image like SmartCrop and additionally returns where the crop landed, so callers
+// can store the focal point and reproduce the crop for other renditions.
+// If the image has been premultiplied by govips, libvips is told so to avoid skewing the attention detection.
+// N.B. the focal point is the one libvips picked for InterestingAttention on libvips 8.12+, and the center of the
+// crop otherwise, including InterestingAll, for which libvips picks none.
+func (r *ImageRef) SmartCropWithDetails(width int, height int, interesting Interesting) (*SmartCropDetails, error) {
+	out, crop, attention, err := vipsSmartCropWithDetails(r.image, width, height, interesting, r.preMultiplication != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &SmartCropDetails{
+		Left:       crop.Min.X,
+		Top:        crop.Min.Y,
+		Width:      crop.Dx(),
+		Height:     crop.Dy(),
+		AttentionX: attention.X,
+		AttentionY: attention.Y,
+	}
+
+	r.setImage(out)
+	return details, nil
+}
+
 // Label overlays a label on top of the image
 func (r *ImageRef) Label(labelParams *LabelParams) error {
 	out, err := labelImage(r.image, labelParams)
@@ -1736,6 +4460,18 @@ func (r *ImageRef) Label(labelParams *LabelParams) error {
 	return nil
 }
 
+// Labels draws many text labels in one call, in order, so that later labels are drawn over earlier ones. The labels
+// are blended over the image by a single composite rather than one pass per label, and the image keeps its bands,
+// format and interpretation.
+func (r *ImageRef) Labels(labelParams []*LabelParams) error {
+	out, err := labelImages(r.image, labelParams)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // Replicate repeats an image many times across and down
 func (r *ImageRef) Replicate(across int, down int) error {
 	out, err := vipsReplicate(r.image, across, down)
@@ -1746,10 +4482,85 @@ func (r *ImageRef) Replicate(across int, down int) error {
 	return nil
 }
 
+// MirrorTile repeats an image many times across and down like Replicate, flipping every other copy horizontally and
+// every other row of copies vertically so that neighboring copies meet at mirrored edges without seams. The result
+// itself tiles seamlessly when across and down are even, e.g. to make a background texture from any image.
+func (r *ImageRef) MirrorTile(across int, down int) error {
+	if across < 1 || down < 1 {
+		return fmt.Errorf("invalid mirror tile count %dx%d", across, down)
+	}
+	out, err := vipsMirrorTile(r.image, across, down)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // ToBytes writes the image to memory in VIPs format and returns the raw bytes, useful for storage.
+// See ExportRaw for the layout of the bytes.
 func (r *ImageRef) ToBytes() ([]byte, error) {
+	return vipsImageToMemory(r.image)
+}
+
+// RawPixels are the uncompressed pixels of an image: Height rows of Stride bytes, each holding Width pixels of Bands
+// interleaved samples in band format Format, in native byte order.
+type RawPixels struct {
+	Pix    []byte
+	Width  int
+	Height int
+	Bands  int
+	Format BandFormat
+	Stride int
+}
+
+// ExportRaw computes the pixels of the image and returns them along with their layout, e.g. to upload them to a GPU
+// or feed them to a machine learning model. Unlike ToBytes, the layout is described rather than implied.
+func (r *ImageRef) ExportRaw() (*RawPixels, error) {
+	pix, err := vipsImageToMemory(r.image)
+	if err != nil {
+		return nil, err
+	}
+
+	format := r.BandFormat()
+	return &RawPixels{
+		Pix:    pix,
+		Width:  r.Width(),
+		Height: r.Height(),
+		Bands:  r.Bands(),
+		Format: format,
+		Stride: r.Width() * r.Bands() * int(C.vips_format_sizeof(C.VipsBandFormat(format))),
+	}, nil
+}
+
+// ToYUV420 converts the image to the planar I420 layout of video encoders: a full resolution Y plane and U and V
+// planes of half the width and height rounded up, each one byte per sample, in 8-bit BT.601 limited range. yStride
+// and uvStride are the lengths of the rows of the planes. Alpha is flattened on black. The image is not changed.
+func (r *ImageRef) ToYUV420() (y, u, v []byte, yStride, uvStride int, err error) {
+	yImage, uImage, vImage, err := vipsToYUV420(r.image)
+	if err != nil {
+		return nil, nil, nil, 0, 0, err
+	}
+	defer clearImage(yImage)
+	defer clearImage(uImage)
+	defer clearImage(vImage)
+
+	if y, err = vipsImageToMemory(yImage); err != nil {
+		return nil, nil, nil, 0, 0, err
+	}
+	if u, err = vipsImageToMemory(uImage); err != nil {
+		return nil, nil, nil, 0, 0, err
+	}
+	if v, err = vipsImageToMemory(vImage); err != nil {
+		return nil, nil, nil, 0, 0, err
+	}
+
+	return y, u, v, r.Width(), (r.Width() + 1) / 2, nil
+}
+
+func vipsImageToMemory(in *C.VipsImage) ([]byte, error) {
 	var cSize C.size_t
-	cData := C.vips_image_write_to_memory(r.image, &cSize)
+	cData := C.vips_image_write_to_memory(in, &cSize)
 	if cData == nil {
 		return nil, errors.New("failed to write image to memory")
 	}
@@ -1759,6 +4570,71 @@ func (r *ImageRef) ToBytes() ([]byte, error) {
 	return data, nil
 }
 
+// toGoImage copies the pixels of the image into a Go image without encoding them
+func (r *ImageRef) toGoImage() (image.Image, error) {
+	in := r.image
+	bounds := image.Rect(0, 0, r.Width(), r.Height())
+
+	if r.Bands() == 1 {
+		if r.Interpretation() == InterpretationGrey16 {
+			grey, err := vipsToColorSpace(in, InterpretationBW)
+			if err != nil {
+				return nil, err
+			}
+			defer clearImage(grey)
+			in = grey
+		}
+
+		pix, err := vipsUcharPixels(in)
+		if err != nil {
+			return nil, err
+		}
+		return &image.Gray{Pix: pix, Stride: bounds.Dx(), Rect: bounds}, nil
+	}
+
+	srgb, err := vipsToColorSpace(in, InterpretationSRGB)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(srgb)
+
+	// images without alpha are made opaque, Go images having 4 channels
+	hasAlpha := vipsHasAlpha(srgb)
+	rgba := srgb
+	if !hasAlpha {
+		if rgba, err = vipsAddAlpha(srgb); err != nil {
+			return nil, err
+		}
+		defer clearImage(rgba)
+	}
+	if bands := int(rgba.Bands); bands != 4 {
+		return nil, fmt.Errorf("cannot convert an image of %d bands to a Go image", bands)
+	}
+
+	pix, err := vipsUcharPixels(rgba)
+	if err != nil {
+		return nil, err
+	}
+	if hasAlpha {
+		return &image.NRGBA{Pix: pix, Stride: 4 * bounds.Dx(), Rect: bounds}, nil
+	}
+	return &image.RGBA{Pix: pix, Stride: 4 * bounds.Dx(), Rect: bounds}, nil
+}
+
+// vipsUcharPixels returns the pixels of the image cast to 8 bits per sample
+func vipsUcharPixels(in *C.VipsImage) ([]byte, error) {
+	if BandFormat(in.BandFmt) != BandFormatUchar {
+		uchar, err := vipsCast(in, BandFormatUchar)
+		if err != nil {
+			return nil, err
+		}
+		defer clearImage(uchar)
+		in = uchar
+	}
+
+	return vipsImageToMemory(in)
+}
+
 func (r *ImageRef) determineInputICCProfile() (inputProfile string) {
 	if r.Interpretation() == InterpretationCMYK {
 		inputProfile = "cmyk"
@@ -1766,8 +4642,15 @@ func (r *ImageRef) determineInputICCProfile() (inputProfile string) {
 	return
 }
 
-// ToImage converts a VIPs image to a golang image.Image object, useful for interoperability with other golang libraries
+// ToImage converts a VIPs image to a golang image.Image object, useful for interoperability with other golang libraries.
+// Without params the pixels are copied as 8-bit sRGB into an *image.Gray for single band images, an *image.NRGBA for
+// images with alpha and an *image.RGBA otherwise. With params the image is exported in the given format and decoded
+// with image.Decode, which is slower and lossy for lossy formats.
 func (r *ImageRef) ToImage(params *ExportParams) (image.Image, error) {
+	if params == nil {
+		return r.toGoImage()
+	}
+
 	imageBytes, _, err := r.Export(params)
 	if err != nil {
 		return nil, err
@@ -1796,6 +4679,13 @@ func (r *ImageRef) setImage(image *C.VipsImage) {
 	}
 
 	r.image = image
+	r.markModified()
+}
+
+// markModified records that the pixels or metadata no longer match the loaded buffer, every mutation of the image,
+// including the ones drawing on it or setting its metadata in place, has to go through it or setImage
+func (r *ImageRef) markModified() {
+	r.unmodified = false
 }
 
 func vipsHasAlpha(in *C.VipsImage) bool {
@@ -6,3 +6,6 @@
 int has_alpha_channel(VipsImage *image);
 
 void clear_image(VipsImage **image);
+
+void set_image_kill(VipsImage *image, int kill);
+int is_image_killed(VipsImage *image);
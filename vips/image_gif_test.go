@@ -2,6 +2,7 @@ package vips
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -133,3 +134,57 @@ func TestImage_GIF_Animated_PageDelay2(t *testing.T) {
 		nil,
 		nil)
 }
+
+func TestImage_GIF_Animated_PageDelayDuration(t *testing.T) {
+	Startup(nil)
+
+	importParams := NewImportParams()
+	importParams.NumPages.Set(-1)
+
+	img, err := LoadImageFromFile(resources+"gif-animated.gif", importParams)
+	require.NoError(t, err)
+
+	durations, err := img.PageDelayDuration()
+	require.NoError(t, err)
+	require.Len(t, durations, 8)
+	assert.Equal(t, 100*time.Millisecond, durations[0])
+
+	for i := range durations {
+		durations[i] = 70 * time.Millisecond
+	}
+	require.NoError(t, img.SetPageDelayDuration(durations))
+
+	delay, err := img.PageDelay()
+	require.NoError(t, err)
+	assert.Equal(t, []int{70, 70, 70, 70, 70, 70, 70, 70}, delay)
+
+	gifDelay, ok := vipsImageGetInt(img.image, gifDelayField)
+	assert.True(t, ok)
+	assert.Equal(t, 7, gifDelay)
+}
+
+func TestImage_GIF_Animated_KeepMetadata(t *testing.T) {
+	Startup(nil)
+
+	importParams := NewImportParams()
+	importParams.NumPages.Set(-1)
+
+	img, err := LoadImageFromFile(resources+"gif-animated.gif", importParams)
+	require.NoError(t, err)
+
+	out, err := vipsCopyImage(img.image)
+	require.NoError(t, err)
+	defer clearImage(out)
+
+	vipsKeepMetadata(out, []string{"exif-*"})
+
+	for _, field := range []string{"n-pages", "page-height", "delay", "loop", gifDelayField} {
+		assert.Contains(t, vipsImageGetFields(out), field)
+	}
+
+	// an empty blob removes the field instead of being set
+	vipsImageSetBlob(out, "govips-test-blob", []byte{1})
+	assert.Contains(t, vipsImageGetFields(out), "govips-test-blob")
+	vipsImageSetBlob(out, "govips-test-blob", nil)
+	assert.NotContains(t, vipsImageGetFields(out), "govips-test-blob")
+}
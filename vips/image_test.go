@@ -2,13 +2,19 @@ package vips
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -69,6 +75,29 @@ func TestImageRef_WebP__NearLossless(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestImageRef_WebP__ShrinkOnLoad(t *testing.T) {
+	Startup(nil)
+
+	srcBytes, err := ioutil.ReadFile(resources + "webp+alpha.webp")
+	require.NoError(t, err)
+
+	full, err := NewImageFromBuffer(srcBytes)
+	require.NoError(t, err)
+
+	params := NewImportParams()
+	params.WebpScale.Set(0.5)
+	scaled, err := LoadImageFromBuffer(srcBytes, params)
+	require.NoError(t, err)
+	assert.Equal(t, roundFloat(float64(full.Width())*0.5), scaled.Width())
+
+	params = NewImportParams()
+	params.WebpShrink.Set(2)
+	shrunk, err := LoadImageFromBuffer(srcBytes, params)
+	require.NoError(t, err)
+	assert.Equal(t, scaled.Width(), shrunk.Width())
+	assert.Equal(t, scaled.Height(), shrunk.Height())
+}
+
 func TestImageRef_PNG(t *testing.T) {
 	Startup(nil)
 
@@ -102,6 +131,26 @@ func TestImageRef_HEIF(t *testing.T) {
 	assert.Equal(t, ImageTypeHEIF, metadata.Format)
 }
 
+func TestImageRef_HEIF_PrimaryPage(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "heic-24bit-exif.heic")
+	require.NoError(t, err)
+
+	img, err := NewImageFromBuffer(raw)
+	require.NoError(t, err)
+
+	primary, ok := img.HeifPrimaryPage()
+	assert.True(t, ok)
+	assert.Equal(t, 0, primary)
+
+	jpg, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	_, ok = jpg.HeifPrimaryPage()
+	assert.False(t, ok)
+}
+
 func TestImageRef_HEIF_MIF1(t *testing.T) {
 	Startup(nil)
 
@@ -469,6 +518,88 @@ func TestImageRef_TransformICCProfile(t *testing.T) {
 	assert.True(t, image.HasICCProfile())
 }
 
+func TestImageRef_TransformICCDeviceLink(t *testing.T) {
+	Startup(nil)
+
+	image, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	err = image.TransformICCDeviceLink(SRGBIEC6196621ICCProfilePath, IntentPerceptual)
+	assert.Error(t, err)
+
+	err = image.TransformICCDeviceLink(resources+"nonexistent.icc", IntentPerceptual)
+	assert.Error(t, err)
+}
+
+func TestImageRef_SoftProof(t *testing.T) {
+	Startup(nil)
+
+	for _, gamutWarning := range []bool{false, true} {
+		image, err := NewImageFromFile(resources + "jpg-24bit-icc-adobe-rgb.jpg")
+		require.NoError(t, err)
+		width, height := image.Width(), image.Height()
+
+		err = image.SoftProof("cmyk", IntentPerceptual, gamutWarning)
+		require.NoError(t, err)
+		assert.Equal(t, width, image.Width())
+		assert.Equal(t, height, image.Height())
+		assert.Equal(t, 3, image.Bands())
+		assert.Equal(t, BandFormatUchar, image.BandFormat())
+	}
+
+	image, err := NewImageFromFile(resources + "png-8bit+alpha.png")
+	require.NoError(t, err)
+	err = image.SoftProof("cmyk", IntentRelative, true)
+	require.NoError(t, err)
+	assert.Equal(t, 4, image.Bands())
+}
+
+func TestImageRef_OptimizeICCProfileWithParams(t *testing.T) {
+	Startup(nil)
+
+	err := RegisterICCProfile("target/srgb", sRGBIEC6196621ICCProfile)
+	require.NoError(t, err)
+
+	image, err := NewImageFromFile(resources + "jpg-24bit-icc-adobe-rgb.jpg")
+	require.NoError(t, err)
+
+	params := NewOptimizeICCProfileParams()
+	params.Intent = IntentRelative
+	params.TargetProfile = "target/srgb"
+	err = image.OptimizeICCProfileWithParams(params)
+	require.NoError(t, err)
+	assert.Equal(t, iccProfilePath("target/srgb"), image.optimizedIccProfile)
+	assert.Equal(t, 3, image.Bands())
+
+	if MajorVersion == 8 && MinorVersion < 12 {
+		t.Skip("black point compensation requires libvips 8.12+")
+	}
+
+	image, err = NewImageFromFile(resources + "jpg-24bit-icc-adobe-rgb.jpg")
+	require.NoError(t, err)
+	params = NewOptimizeICCProfileParams()
+	params.BlackPointCompensation = true
+	err = image.OptimizeICCProfileWithParams(params)
+	require.NoError(t, err)
+	assert.Equal(t, SRGBV2MicroICCProfilePath, image.optimizedIccProfile)
+}
+
+func TestIsICCDeviceLink(t *testing.T) {
+	Startup(nil)
+
+	profile, err := ioutil.ReadFile(SRGBIEC6196621ICCProfilePath)
+	require.NoError(t, err)
+	assert.False(t, isICCDeviceLink(profile))
+	assert.False(t, isICCDeviceLinkFile(SRGBIEC6196621ICCProfilePath))
+	assert.False(t, isICCDeviceLinkFile("srgb"))
+	assert.True(t, isICCDeviceLinkFile(resources+"icc-devicelink-swap-rb.icc"))
+
+	link := append([]byte{}, profile...)
+	copy(link[12:16], "link")
+	assert.True(t, isICCDeviceLink(link))
+	assert.False(t, isICCDeviceLink(link[:64]))
+}
+
 func TestImageRef_Close(t *testing.T) {
 	Startup(nil)
 
@@ -531,6 +662,43 @@ func TestImageRef_Composite(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestImageRef_CompositeMulti__OpacityAndSource(t *testing.T) {
+	Startup(nil)
+
+	base, err := Black(100, 100)
+	require.NoError(t, err)
+	require.NoError(t, base.ToColorSpace(InterpretationSRGB))
+
+	overlay, err := Black(50, 50)
+	require.NoError(t, err)
+	require.NoError(t, overlay.ToColorSpace(InterpretationSRGB))
+	require.NoError(t, overlay.Invert())
+
+	in := &ImageComposite{Image: overlay, BlendMode: BlendModeOver, X: 10, Y: 10, Source: image.Rect(0, 0, 20, 20)}
+	in.Opacity.Set(0.5)
+	require.NoError(t, base.CompositeMulti([]*ImageComposite{in}))
+
+	point, err := base.GetPoint(15, 15)
+	require.NoError(t, err)
+	assert.InDelta(t, 128, point[0], 1)
+
+	point, err = base.GetPoint(40, 40)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, point[0])
+
+	in.Source = image.Rect(40, 40, 60, 60)
+	assert.Error(t, base.CompositeMulti([]*ImageComposite{in}))
+
+	in.Source = image.Rectangle{}
+	in.Opacity.Set(2)
+	assert.Error(t, base.CompositeMulti([]*ImageComposite{in}))
+
+	require.NoError(t, base.CompositeWithOpacity(overlay, BlendModeOver, 0, 0, 1))
+	point, err = base.GetPoint(5, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 255.0, point[0])
+}
+
 func TestImageRef_Insert(t *testing.T) {
 	Startup(nil)
 
@@ -620,6 +788,135 @@ func TestImageRef_Mapim__Error(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestImageRef_CylindricalProject(t *testing.T) {
+	Startup(nil)
+
+	image, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	center, err := image.GetPoint(960, 540)
+	require.NoError(t, err)
+
+	err = image.CylindricalProject(1000)
+	require.NoError(t, err)
+	assert.Equal(t, 1920, image.Width())
+	assert.Equal(t, 1080, image.Height())
+
+	// the center stays in place and the corners are pulled in
+	point, err := image.GetPoint(960, 540)
+	require.NoError(t, err)
+	assert.Equal(t, center, point)
+	point, err = image.GetPoint(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 0, 0}, point)
+
+	assert.Error(t, image.CylindricalProject(0))
+}
+
+func TestImageRef_CorrectChromaticAberration(t *testing.T) {
+	Startup(nil)
+
+	image, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	require.NoError(t, image.AddAlpha())
+
+	center, err := image.GetPoint(960, 540)
+	require.NoError(t, err)
+
+	err = image.CorrectChromaticAberration(0.998, 1.002)
+	require.NoError(t, err)
+	assert.Equal(t, 1920, image.Width())
+	assert.Equal(t, 1080, image.Height())
+	assert.Equal(t, 4, image.Bands())
+	assert.Equal(t, InterpretationSRGB, image.Interpretation())
+
+	// the center hardly moves
+	point, err := image.GetPoint(960, 540)
+	require.NoError(t, err)
+	assert.InDeltaSlice(t, center, point, 2)
+
+	assert.Error(t, image.CorrectChromaticAberration(0, 1))
+
+	grey, err := Black(10, 10)
+	require.NoError(t, err)
+	assert.Error(t, grey.CorrectChromaticAberration(1, 1))
+}
+
+func TestImageRef_Histogram(t *testing.T) {
+	Startup(nil)
+
+	image, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	histograms, err := image.HistogramData()
+	require.NoError(t, err)
+	require.Len(t, histograms, 3)
+	for _, histogram := range histograms {
+		require.Len(t, histogram, 256)
+		total := 0
+		for _, count := range histogram {
+			total += count
+		}
+		assert.Equal(t, 100*100, total)
+	}
+
+	lut, err := image.Copy()
+	require.NoError(t, err)
+	require.NoError(t, lut.HistogramFind())
+	assert.Equal(t, 256, lut.Width())
+	assert.Equal(t, 1, lut.Height())
+	require.NoError(t, lut.HistogramCumulative())
+	require.NoError(t, lut.HistogramNormalize())
+	require.NoError(t, lut.Cast(BandFormatUchar))
+	require.NoError(t, image.Maplut(lut))
+
+	equalized, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+	require.NoError(t, equalized.HistogramEqualize())
+	assert.Equal(t, 100, equalized.Width())
+}
+
+func TestImageRef_Masked(t *testing.T) {
+	Startup(nil)
+
+	constant := func(width int, value float64) *ImageRef {
+		img, err := Black(width, 10)
+		require.NoError(t, err)
+		require.NoError(t, img.Linear([]float64{1}, []float64{value}))
+		require.NoError(t, img.Cast(BandFormatUchar))
+		return img
+	}
+
+	// left half 10, right half 30
+	image := constant(5, 10)
+	require.NoError(t, image.Join(constant(5, 30), DirectionHorizontal))
+
+	// selects the 4 rightmost columns of the left half and the right half
+	mask := constant(1, 0)
+	require.NoError(t, mask.Join(constant(9, 255), DirectionHorizontal))
+
+	avg, err := image.AverageMasked(mask)
+	require.NoError(t, err)
+	assert.InDelta(t, (4*10.0+5*30)/9, avg, 1e-4)
+
+	histograms, err := image.HistogramMasked(mask)
+	require.NoError(t, err)
+	require.Len(t, histograms, 1)
+	assert.Equal(t, 40, histograms[0][10])
+	assert.Equal(t, 50, histograms[0][30])
+	assert.Equal(t, 0, histograms[0][0])
+
+	empty, err := Black(10, 10)
+	require.NoError(t, err)
+	_, err = image.AverageMasked(empty)
+	assert.Error(t, err)
+
+	small, err := Black(5, 5)
+	require.NoError(t, err)
+	_, err = image.HistogramMasked(small)
+	assert.Error(t, err)
+}
+
 func TestImageRef_Maplut(t *testing.T) {
 	Startup(nil)
 
@@ -662,7 +959,7 @@ func TestImageRef_CompositeMulti(t *testing.T) {
 		require.NoError(t, err)
 
 		//add offset test
-		images[i] = &ImageComposite{image, BlendModeOver, (i + 1) * 20, (i + 2) * 20}
+		images[i] = &ImageComposite{Image: image, BlendMode: BlendModeOver, X: (i + 1) * 20, Y: (i + 2) * 20}
 	}
 
 	err = image.CompositeMulti(images)
@@ -728,6 +1025,20 @@ func TestMemstats(t *testing.T) {
 	govipsLog("govips", LogLevelInfo, fmt.Sprintf("MemoryStats: allocs: %d, files: %d, mem: %d, memhigh: %d", stats.Allocs, stats.Files, stats.Mem, stats.MemHigh))
 }
 
+func TestMemstats__OpenImages(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+	defer img.Close()
+
+	// other tests' images may be finalized meanwhile, so only a lower bound holds
+	var stats RuntimeStats
+	ReadRuntimeStats(&stats)
+	assert.GreaterOrEqual(t, stats.Memory.OpenImages, int64(1))
+	assert.GreaterOrEqual(t, stats.Memory.CacheOperations, int64(0))
+}
+
 func TestBands(t *testing.T) {
 	Startup(nil)
 
@@ -800,6 +1111,82 @@ func TestToBytes(t *testing.T) {
 	assert.Equal(t, 6220800, len(buf1))
 }
 
+func TestImageRef_ToImage(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	goImg, err := img.ToImage(nil)
+	require.NoError(t, err)
+	require.IsType(t, &image.RGBA{}, goImg)
+	assert.Equal(t, image.Rect(0, 0, 1920, 1080), goImg.Bounds())
+
+	point, err := img.GetPoint(100, 200)
+	require.NoError(t, err)
+	c := goImg.(*image.RGBA).RGBAAt(100, 200)
+	assert.Equal(t, []float64{float64(c.R), float64(c.G), float64(c.B)}, point)
+	assert.Equal(t, uint8(255), c.A)
+
+	alpha, err := NewImageFromFile(resources + "png-8bit+alpha.png")
+	require.NoError(t, err)
+	goImg, err = alpha.ToImage(nil)
+	require.NoError(t, err)
+	assert.IsType(t, &image.NRGBA{}, goImg)
+
+	grey, err := Black(10, 20)
+	require.NoError(t, err)
+	goImg, err = grey.ToImage(nil)
+	require.NoError(t, err)
+	assert.IsType(t, &image.Gray{}, goImg)
+	assert.Equal(t, image.Rect(0, 0, 10, 20), goImg.Bounds())
+}
+
+func TestImageRef_ExportRaw(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	require.NoError(t, img.Cast(BandFormatUshort))
+
+	raw, err := img.ExportRaw()
+	require.NoError(t, err)
+	assert.Equal(t, 1920, raw.Width)
+	assert.Equal(t, 1080, raw.Height)
+	assert.Equal(t, 3, raw.Bands)
+	assert.Equal(t, BandFormatUshort, raw.Format)
+	assert.Equal(t, 1920*3*2, raw.Stride)
+	assert.Len(t, raw.Pix, raw.Stride*raw.Height)
+}
+
+func TestImageRef_ToYUV420(t *testing.T) {
+	Startup(nil)
+
+	img, err := Black(5, 3)
+	require.NoError(t, err)
+	require.NoError(t, img.Linear([]float64{1}, []float64{255}))
+	require.NoError(t, img.Cast(BandFormatUchar))
+	require.NoError(t, img.ToColorSpace(InterpretationSRGB))
+
+	y, u, v, yStride, uvStride, err := img.ToYUV420()
+	require.NoError(t, err)
+	assert.Equal(t, 5, yStride)
+	assert.Equal(t, 3, uvStride)
+	assert.Equal(t, bytes.Repeat([]byte{235}, 5*3), y)
+	assert.Equal(t, bytes.Repeat([]byte{128}, 3*2), u)
+	assert.Equal(t, bytes.Repeat([]byte{128}, 3*2), v)
+	assert.Equal(t, 3, img.Bands())
+
+	img, err = NewImageFromFile(resources + "png-8bit+alpha.png")
+	require.NoError(t, err)
+
+	y, u, v, yStride, uvStride, err = img.ToYUV420()
+	require.NoError(t, err)
+	assert.Len(t, y, yStride*img.Height())
+	assert.Len(t, u, uvStride*((img.Height()+1)/2))
+	assert.Len(t, v, uvStride*((img.Height()+1)/2))
+}
+
 func TestBandJoin(t *testing.T) {
 	Startup(nil)
 
@@ -867,6 +1254,43 @@ func TestIdentity(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestNoiseAndPatterns(t *testing.T) {
+	Startup(nil)
+
+	tests := []struct {
+		name   string
+		create func() (*ImageRef, error)
+		format BandFormat
+	}{
+		{"gaussnoise", func() (*ImageRef, error) { return GaussNoise(64, 32, 128, 20, 1) }, BandFormatFloat},
+		{"perlin", func() (*ImageRef, error) { return Perlin(64, 32, 16, true, 1) }, BandFormatUchar},
+		{"worley", func() (*ImageRef, error) { return Worley(64, 32, 16, 1) }, BandFormatFloat},
+		{"sines", func() (*ImageRef, error) { return Sines(64, 32, 2, 1, false) }, BandFormatFloat},
+		{"zone", func() (*ImageRef, error) { return Zone(64, 32, true) }, BandFormatUchar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := tt.create()
+			require.NoError(t, err)
+			defer img.Close()
+
+			assert.Equal(t, 64, img.Width())
+			assert.Equal(t, 32, img.Height())
+			assert.Equal(t, 1, img.Bands())
+			assert.Equal(t, tt.format, img.BandFormat())
+
+			row, err := img.Row(16)
+			require.NoError(t, err)
+			varies := false
+			for _, v := range row[0] {
+				varies = varies || v != row[0][0]
+			}
+			assert.True(t, varies)
+		})
+	}
+}
+
 func TestDeprecatedExportParams(t *testing.T) {
 	Startup(nil)
 
@@ -945,6 +1369,43 @@ func TestImageRef_FindTrim_Threshold(t *testing.T) {
 	assert.Equal(t, 256, height)
 }
 
+func TestImageRef_Trim(t *testing.T) {
+	Startup(nil)
+
+	image, err := NewImageFromFile(resources + "find_trim.png")
+	require.NoError(t, err)
+
+	margins, err := image.Trim(17, &Color{R: 255, G: 255, B: 255})
+	require.NoError(t, err)
+	assert.Equal(t, &TrimMargins{Left: 80, Top: 32, Right: 80, Bottom: 32}, margins)
+	assert.Equal(t, 352, image.Width())
+	assert.Equal(t, 256, image.Height())
+
+	margins, err = image.Trim(17, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &TrimMargins{}, margins)
+	assert.Equal(t, 352, image.Width())
+}
+
+func TestImageRef_Trim_Alpha(t *testing.T) {
+	Startup(nil)
+
+	image, err := Black(10, 10)
+	require.NoError(t, err)
+	require.NoError(t, image.Linear([]float64{1}, []float64{255}))
+	require.NoError(t, image.Cast(BandFormatUchar))
+	require.NoError(t, image.ToColorSpace(InterpretationSRGB))
+	require.NoError(t, image.AddAlpha())
+	require.NoError(t, image.Embed(5, 3, 20, 20, ExtendBlack))
+
+	margins, err := image.Trim(0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &TrimMargins{Left: 5, Top: 3, Right: 5, Bottom: 7}, margins)
+	assert.Equal(t, 10, image.Width())
+	assert.Equal(t, 10, image.Height())
+	assert.Equal(t, 4, image.Bands())
+}
+
 func TestImageRef_Height(t *testing.T) {
 	image, err := NewImageFromFile(resources + "gif-animated-2.gif")
 	assert.NoError(t, err)
@@ -993,6 +1454,2044 @@ func TestImageRef_JP2K(t *testing.T) {
 	assert.Equal(t, 1, metadata.Pages)
 }
 
+func TestLoadPDFPages(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "pdf.pdf")
+	require.NoError(t, err)
+
+	img, err := LoadPDFPages(raw, 0, 1, 72)
+	require.NoError(t, err)
+	require.NotNil(t, img)
+	assert.Equal(t, ImageTypePDF, img.Format())
+	assert.Equal(t, img.Height(), img.PageHeight())
+
+	pages, err := LoadPDFPageImages(raw, 0, 1, 72)
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	assert.Equal(t, img.Width(), pages[0].Width())
+	assert.Equal(t, img.Height(), pages[0].Height())
+	assert.Equal(t, 1, pages[0].Pages())
+}
+
+func TestLoadPDFPageImagesWithDPI(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "pdf.pdf")
+	require.NoError(t, err)
+
+	base, err := LoadPDFPages(raw, 0, 1, 72)
+	require.NoError(t, err)
+
+	pages, err := LoadPDFPageImagesWithDPI(raw, 0, 0, 72, map[int]float64{0: 144.5})
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	assert.InDelta(t, float64(base.Width())*144.5/72, pages[0].Width(), 1)
+}
+
+func TestImportParams_OptionString__Density(t *testing.T) {
+	params := &ImportParams{}
+	params.Density.Set(150.5)
+
+	assert.Equal(t, "dpi=150.5", params.OptionString())
+}
+
+func TestImportParams_OptionString__Pdf(t *testing.T) {
+	params := &ImportParams{}
+	params.PdfPassword.Set(`se,cr"et`)
+	params.PdfBackground.Set(ColorRGBA{R: 255, G: 0, B: 0, A: 255})
+
+	assert.Equal(t, `password="se,cr\"et",background="255 0 0 255"`, params.OptionString())
+}
+
+func TestLoadPDFPagesWithParams__Background(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "pdf.pdf")
+	require.NoError(t, err)
+
+	params := NewImportParams()
+	params.PdfBackground.Set(ColorRGBA{R: 255, G: 0, B: 0, A: 255})
+	params.PdfPassword.Set("unused")
+
+	img, err := LoadPDFPagesWithParams(raw, 0, 1, 36, params)
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypePDF, img.Format())
+	assert.Equal(t, 1, img.Pages())
+	assert.False(t, params.Page.IsSet())
+
+	// the page margin shows the background
+	p, err := img.GetPoint(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 0, 0, 255}, p)
+}
+
+func TestLoadPDFPages__NotPDF(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	_, err = LoadPDFPages(raw, 0, 1, 72)
+	assert.Error(t, err)
+}
+
+func TestRenderPDFContactSheet(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "pdf.pdf")
+	require.NoError(t, err)
+
+	page, err := LoadPDFPages(raw, 0, 1, 72)
+	require.NoError(t, err)
+
+	sheet, err := RenderPDFContactSheet(raw, 0, 1, 72, 2)
+	require.NoError(t, err)
+	assert.Equal(t, page.Width(), sheet.Width())
+	assert.Equal(t, page.Height(), sheet.Height())
+
+	_, err = RenderPDFContactSheet(raw, 0, 1, 72, 0)
+	assert.Error(t, err)
+}
+
+func TestImageRef_JP2K__Reduction(t *testing.T) {
+	if MajorVersion == 8 && MinorVersion < 11 {
+		t.Skip("JPEG2000 is only supported in vips 8.11+")
+	}
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "jp2k-orientation-6.jp2")
+	require.NoError(t, err)
+
+	full, err := NewImageFromBuffer(raw)
+	require.NoError(t, err)
+
+	params := NewImportParams()
+	params.Jp2kReduction.Set(1)
+	reduced, err := LoadImageFromBuffer(raw, params)
+	require.NoError(t, err)
+	assert.InDelta(t, full.Width()/2, reduced.Width(), 1)
+
+	// the reduction overrides the page
+	params.Page.Set(0)
+	reduced, err = LoadImageFromBuffer(raw, params)
+	require.NoError(t, err)
+	assert.InDelta(t, full.Width()/2, reduced.Width(), 1)
+
+	tile, err := LoadJp2kTile(raw, 1, 0, 0, 16, 16)
+	require.NoError(t, err)
+	assert.Equal(t, 16, tile.Width())
+	assert.Equal(t, 16, tile.Height())
+}
+
+func TestImportParams_OptionString__Jp2kReduction(t *testing.T) {
+	params := &ImportParams{}
+	params.Page.Set(0)
+	params.Jp2kReduction.Set(2)
+
+	assert.Equal(t, "page=2", params.optionString(ImageTypeJP2K))
+	assert.Equal(t, "page=0", params.optionString(ImageTypeGIF))
+}
+
+func TestImportParams_OptionString__Tiff(t *testing.T) {
+	params := NewImportParams()
+	params.TiffSubifd.Set(2)
+	params.AutoRotate.Set(false)
+	params.TiffAutorotate.Set(true)
+
+	assert.Equal(t, "fail=TRUE,autorotate=TRUE,subifd=2", params.optionString(ImageTypeTIFF))
+	assert.Equal(t, "fail=TRUE,autorotate=FALSE", params.optionString(ImageTypeJPEG))
+	assert.Equal(t, "fail=TRUE,autorotate=FALSE", params.OptionString())
+}
+
+func TestImageRef_Tiff__SubifdMain(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "tif.tif")
+	require.NoError(t, err)
+
+	full, err := NewImageFromBuffer(raw)
+	require.NoError(t, err)
+
+	params := NewImportParams()
+	params.TiffSubifd.Set(-1)
+	params.TiffAutorotate.Set(false)
+	img, err := LoadImageFromBuffer(raw, params)
+	require.NoError(t, err)
+	assert.Equal(t, full.Width(), img.Width())
+	assert.Equal(t, full.Height(), img.Height())
+
+	img, err = LoadImageFromStream(bytes.NewReader(raw), params)
+	require.NoError(t, err)
+	assert.Equal(t, full.Width(), img.Width())
+}
+
+func TestImageRef_Tiff__ParamsIgnoredForOtherFormats(t *testing.T) {
+	Startup(nil)
+
+	params := NewImportParams()
+	params.TiffSubifd.Set(-1)
+	params.TiffAutorotate.Set(false)
+
+	// jpegload has no subifd option, passing it would fail the load
+	img, err := LoadThumbnailFromFile(resources+"jpg-24bit.jpg", 100, 100, InterestingNone, SizeBoth, params)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, img.Width(), 100)
+
+	raw, err := ioutil.ReadFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+	img, err = LoadImageFromStream(bytes.NewReader(raw), params)
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeJPEG, img.Format())
+}
+
+func TestImageRef_SmartCropWithDetails(t *testing.T) {
+	Startup(nil)
+
+	for _, interesting := range []Interesting{InterestingNone, InterestingCentre, InterestingEntropy,
+		InterestingAttention, InterestingLow, InterestingHigh, InterestingAll} {
+		original, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+		require.NoError(t, err)
+		inWidth, inHeight := original.Width(), original.Height()
+
+		img, err := original.Copy()
+		require.NoError(t, err)
+
+		details, err := img.SmartCropWithDetails(60, 40, interesting)
+		require.NoError(t, err)
+		assert.Equal(t, 60, img.Width())
+		assert.Equal(t, 40, img.Height())
+		assert.Equal(t, 60, details.Width)
+		assert.Equal(t, 40, details.Height)
+		assert.True(t, details.Left >= 0 && details.Left+details.Width <= inWidth)
+		assert.True(t, details.Top >= 0 && details.Top+details.Height <= inHeight)
+		assert.True(t, details.AttentionX >= 0 && details.AttentionX <= inWidth)
+		assert.True(t, details.AttentionY >= 0 && details.AttentionY <= inHeight)
+		if interesting != InterestingAttention {
+			assert.Equal(t, details.Left+details.Width/2, details.AttentionX, "interesting %d", interesting)
+			assert.Equal(t, details.Top+details.Height/2, details.AttentionY, "interesting %d", interesting)
+		}
+
+		// the reported rectangle is the area that was cropped
+		err = original.ExtractArea(details.Left, details.Top, details.Width, details.Height)
+		require.NoError(t, err)
+		points := []image.Point{{0, 0}, {30, 20}, {59, 39}}
+		expected, err := original.GetPoints(points)
+		require.NoError(t, err)
+		actual, err := img.GetPoints(points)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, "interesting %d", interesting)
+	}
+}
+
+func TestImageRef_EmbedWithConstant(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-8bit+alpha.png")
+	require.NoError(t, err)
+
+	err = img.EmbedWithConstant(0, 0, 1000, 500, []float64{10, 20, 30, 40})
+	require.NoError(t, err)
+	assert.Equal(t, 1000, img.Width())
+
+	point, err := img.GetPoint(999, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{10, 20, 30, 40}, point)
+
+	err = img.EmbedWithConstant(0, 0, 1000, 500, []float64{1, 2})
+	assert.Error(t, err)
+}
+
+func TestImageRef_InsertWithConstant(t *testing.T) {
+	Startup(nil)
+
+	img, err := Black(10, 10)
+	require.NoError(t, err)
+	require.NoError(t, img.BandJoinConst([]float64{0}))
+	sub, err := Black(10, 10)
+	require.NoError(t, err)
+	require.NoError(t, sub.BandJoinConst([]float64{255}))
+
+	err = img.InsertWithConstant(sub, 15, 0, true, []float64{7, 200})
+	require.NoError(t, err)
+	assert.Equal(t, 25, img.Width())
+	assert.Equal(t, 10, img.Height())
+
+	point, err := img.GetPoint(12, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{7, 200}, point)
+	point, err = img.GetPoint(20, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 255}, point)
+
+	err = img.InsertWithConstant(sub, 0, 0, true, []float64{1, 2, 3})
+	assert.Error(t, err)
+}
+
+func TestImageRef_PositionOnCanvas(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-8bit+alpha.png")
+	require.NoError(t, err)
+	width, height := img.Width(), img.Height()
+
+	err = img.PositionOnCanvas(width+100, height+100, GravitySouthEast, 20, 20, &ColorRGBA{R: 255, G: 0, B: 0, A: 255})
+	require.NoError(t, err)
+	assert.Equal(t, width+100, img.Width())
+	assert.Equal(t, height+100, img.Height())
+
+	point, err := img.GetPoint(img.Width()-1, img.Height()-1)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 0, 0, 255}, point)
+
+	err = img.PositionOnCanvas(img.Width()+10, img.Height()+10, GravityNorthWest, 0, 0, nil)
+	require.NoError(t, err)
+
+	point, err = img.GetPoint(img.Width()-1, img.Height()-1)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 0, 0, 0}, point)
+}
+
+func TestImageRef_Gravity(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	corner, err := img.GetPoint(99, 99)
+	require.NoError(t, err)
+
+	err = img.Gravity(GravitySouthEast, 150, 120, ExtendBackground, &ColorRGBA{R: 255, G: 0, B: 0, A: 255})
+	require.NoError(t, err)
+	assert.Equal(t, 150, img.Width())
+	assert.Equal(t, 120, img.Height())
+
+	points, err := img.GetPoints([]image.Point{{0, 0}, {149, 119}})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 0, 0}, points[0])
+	assert.Equal(t, corner, points[1])
+
+	err = img.Gravity(GravityNorthWest, 50, 50, ExtendCopy, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 50, img.Width())
+	assert.Equal(t, 50, img.Height())
+
+	point, err := img.GetPoint(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 0, 0}, point)
+
+	assert.Error(t, img.Gravity(GravityCentre, 0, 10, ExtendBlack, nil))
+}
+
+func TestGravityOffsets(t *testing.T) {
+	left, top := gravityOffsets(200, 100, 50, 20, GravitySouthEast, 20, 10)
+	assert.Equal(t, 130, left)
+	assert.Equal(t, 70, top)
+
+	left, top = gravityOffsets(200, 100, 50, 20, GravityCentre, 0, 0)
+	assert.Equal(t, 75, left)
+	assert.Equal(t, 40, top)
+
+	left, top = gravityOffsets(200, 100, 50, 20, GravityNorthWest, 5, 5)
+	assert.Equal(t, 5, left)
+	assert.Equal(t, 5, top)
+}
+
+func TestImageRef_EnergyMap(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-8bit+alpha.png")
+	require.NoError(t, err)
+	width, height := img.Width(), img.Height()
+
+	err = img.EnergyMap()
+	require.NoError(t, err)
+	assert.Equal(t, 1, img.Bands())
+	assert.Equal(t, width, img.Width())
+	assert.Equal(t, height, img.Height())
+	assert.Equal(t, BandFormatUchar, img.BandFormat())
+
+	avg, err := img.Average()
+	require.NoError(t, err)
+	assert.Greater(t, avg, 0.0)
+	assert.Less(t, avg, 255.0)
+}
+
+func TestImageRef_Crop(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	width, height := img.Width(), img.Height()
+
+	err = img.CropWithMode(image.Rect(-10, -10, 100, 100), CropModeError)
+	assert.Error(t, err)
+
+	err = img.Crop(image.Rect(-10, -10, 100, width+height))
+	require.NoError(t, err)
+	assert.Equal(t, 100, img.Width())
+	assert.Equal(t, height, img.Height())
+
+	err = img.Crop(image.Rect(width, 0, width+100, 100))
+	assert.Error(t, err)
+}
+
+func TestImageRef_CropWithGravity(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	corner, err := img.GetPoint(99, 99)
+	require.NoError(t, err)
+
+	err = img.CropWithGravity(40, 30, GravitySouthEast)
+	require.NoError(t, err)
+	assert.Equal(t, 40, img.Width())
+	assert.Equal(t, 30, img.Height())
+
+	point, err := img.GetPoint(39, 29)
+	require.NoError(t, err)
+	assert.Equal(t, corner, point)
+
+	err = img.CropWithGravity(100, 10, GravityCentre)
+	require.NoError(t, err)
+	assert.Equal(t, 40, img.Width())
+	assert.Equal(t, 10, img.Height())
+
+	assert.Error(t, img.CropWithGravity(0, 10, GravityCentre))
+
+	params := NewImportParams()
+	params.NumPages.Set(-1)
+	animated, err := LoadImageFromFile(resources+"gif-animated.gif", params)
+	require.NoError(t, err)
+	pages := animated.Pages()
+
+	err = animated.CropWithGravity(20, 20, GravityNorthWest)
+	require.NoError(t, err)
+	assert.Equal(t, pages, animated.Pages())
+	assert.Equal(t, 20, animated.Width())
+	assert.Equal(t, 20, animated.PageHeight())
+}
+
+func TestCropArea(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 50)
+
+	area, err := cropArea(image.Rect(120, 60, -5, 10), bounds, CropModeClamp)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 10, 100, 50), area)
+
+	area, err = cropArea(image.Rect(10, 10, 20, 20), bounds, CropModeError)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(10, 10, 20, 20), area)
+
+	_, err = cropArea(image.Rect(90, 10, 110, 20), bounds, CropModeError)
+	assert.Error(t, err)
+}
+
+func TestImageRef_ProcessTiles(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	width, height := img.Width(), img.Height()
+
+	tiles := 0
+	err = img.ProcessTiles(512, 512, func(tile *ImageRef, x, y int) (*ImageRef, error) {
+		tiles++
+		assert.Equal(t, minInt(512, width-x), tile.Width())
+		assert.Equal(t, minInt(512, height-y), tile.Height())
+		return tile, tile.Invert()
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 12, tiles)
+	assert.Equal(t, width, img.Width())
+	assert.Equal(t, height, img.Height())
+
+	// the tiles are put back in place, the edge tiles being smaller
+	p, err := img.GetPoint(width-1, height-1)
+	require.NoError(t, err)
+	err = img.ProcessTiles(300, 200, func(tile *ImageRef, x, y int) (*ImageRef, error) {
+		return tile, tile.Invert()
+	})
+	require.NoError(t, err)
+	assert.Equal(t, width, img.Width())
+	assert.Equal(t, height, img.Height())
+	inverted, err := img.GetPoint(width-1, height-1)
+	require.NoError(t, err)
+	for i := range p {
+		assert.Equal(t, 255-p[i], inverted[i])
+	}
+
+	err = img.ProcessTiles(512, 512, func(tile *ImageRef, x, y int) (*ImageRef, error) {
+		return nil, errors.New("failed")
+	})
+	assert.Error(t, err)
+
+	err = img.ProcessTiles(512, 512, func(tile *ImageRef, x, y int) (*ImageRef, error) {
+		return tile, tile.Resize(0.5, KernelLinear)
+	})
+	assert.Error(t, err)
+}
+
+func TestImportParams_OptionString__PsdLayer(t *testing.T) {
+	params := &ImportParams{}
+	params.PsdLayer.Set(1)
+
+	assert.Equal(t, "page=2", params.OptionString())
+	assert.True(t, params.transformsPixels())
+}
+
+func TestImportParams_OptionString__Access(t *testing.T) {
+	params := &ImportParams{}
+	params.Access.Set(AccessSequential)
+
+	assert.Equal(t, "access=sequential", params.OptionString())
+}
+
+func TestImportParams_OptionString__Revalidate(t *testing.T) {
+	params := &ImportParams{}
+	params.Revalidate.Set(true)
+
+	assert.Equal(t, "revalidate=TRUE", params.OptionString())
+}
+
+func TestImageRef_Load__SequentialAccess(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	params := NewImportParams()
+	params.Access.Set(AccessSequential)
+
+	img, err := LoadImageFromBuffer(raw, params)
+	require.NoError(t, err)
+
+	err = img.Resize(0.5, KernelLanczos3)
+	require.NoError(t, err)
+
+	_, _, err = img.ExportJpeg(nil)
+	require.NoError(t, err)
+}
+
+func TestImageRef_Cancel(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	assert.False(t, img.IsCancelled())
+
+	other, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	require.NoError(t, img.Cancel())
+	assert.True(t, img.IsCancelled())
+	assert.True(t, img.IsCancelled())
+	assert.False(t, other.IsCancelled())
+
+	// images loaded from the same file may share the cached image, only the cancelled one is killed
+	_, _, err = other.ExportJpeg(nil)
+	require.NoError(t, err)
+
+	err = img.Resize(0.5, KernelLanczos3)
+	require.NoError(t, err)
+
+	_, _, err = img.ExportJpeg(nil)
+	assert.Error(t, err)
+}
+
+func TestImageRef_ExportAsync(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	results := []<-chan ExportResult{
+		img.ExportAsync(&ExportParams{Format: ImageTypeJPEG, Quality: 80}),
+		img.ExportAsync(&ExportParams{Format: ImageTypePNG, Compression: 6}),
+	}
+
+	for i, format := range []ImageType{ImageTypeJPEG, ImageTypePNG} {
+		result := <-results[i]
+		require.NoError(t, result.Err)
+		assert.Equal(t, format, result.Metadata.Format)
+		assert.Equal(t, format, DetermineImageType(result.Buffer))
+
+		_, ok := <-results[i]
+		assert.False(t, ok)
+	}
+}
+
+func TestImageRef_ExportAvif__MaxDuration(t *testing.T) {
+	Startup(nil)
+
+	if !IsTypeSupported(ImageTypeAVIF) {
+		t.Skip("AVIF export not supported")
+	}
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	params := NewAvifExportParams()
+	params.MaxDuration = time.Nanosecond
+
+	_, _, err = img.ExportAvif(params)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrExportTimeout))
+
+	var timeoutErr *ExportTimeoutError
+	require.True(t, errors.As(err, &timeoutErr))
+	assert.Equal(t, ImageTypeAVIF, timeoutErr.Format)
+
+	// the timeout only kills the private copy the export read from
+	assert.False(t, img.IsCancelled())
+	_, _, err = img.ExportPng(nil)
+	assert.NoError(t, err)
+}
+
+func TestExportTimeoutError(t *testing.T) {
+	err := &ExportTimeoutError{Format: ImageTypeHEIF, MaxDuration: time.Second}
+	assert.Equal(t, "heic export exceeded 1s", err.Error())
+	assert.True(t, errors.Is(err, ErrExportTimeout))
+}
+
+func TestImageRef_ExportBest(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	jpeg, _, err := img.ExportJpeg(nil)
+	require.NoError(t, err)
+	png, _, err := img.ExportPng(nil)
+	require.NoError(t, err)
+
+	buf, metadata, err := img.ExportBest([]ImageType{ImageTypePNG, ImageTypeJPEG}, SizeQualityConstraints{})
+	require.NoError(t, err)
+	if len(jpeg) < len(png) {
+		assert.Equal(t, ImageTypeJPEG, metadata.Format)
+		assert.Equal(t, len(jpeg), len(buf))
+	} else {
+		assert.Equal(t, ImageTypePNG, metadata.Format)
+		assert.Equal(t, len(png), len(buf))
+	}
+
+	_, _, err = img.ExportBest([]ImageType{ImageTypeJPEG}, SizeQualityConstraints{MaxBytes: 10})
+	assert.Error(t, err)
+
+	_, _, err = img.ExportBest([]ImageType{ImageTypeUnknown}, SizeQualityConstraints{})
+	assert.Equal(t, ErrUnsupportedImageFormat, err)
+}
+
+func TestImageRef_TranscodeWithoutRecompress(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "jpg-orientation-6.jpg")
+	require.NoError(t, err)
+
+	img, err := NewImageFromBuffer(raw)
+	require.NoError(t, err)
+
+	buf, metadata, err := img.TranscodeWithoutRecompress(ImageTypeJPEG, false)
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeJPEG, metadata.Format)
+	assert.Equal(t, raw, buf)
+
+	buf, _, err = img.TranscodeWithoutRecompress(ImageTypeJPEG, true)
+	require.NoError(t, err)
+	assert.Less(t, len(buf), len(raw))
+
+	stripped, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.Equal(t, img.Width(), stripped.Width())
+	assert.Equal(t, 0, stripped.Orientation())
+
+	err = img.Flip(DirectionHorizontal)
+	require.NoError(t, err)
+
+	buf, _, err = img.TranscodeWithoutRecompress(ImageTypeJPEG, false)
+	require.NoError(t, err)
+	assert.NotEqual(t, raw, buf)
+}
+
+func TestImageRef_TranscodeWithoutRecompress__Drawn(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	err = img.DrawRect(ColorRGBA{R: 255, A: 255}, 10, 10, 20, 20, true)
+	require.NoError(t, err)
+
+	buf, _, err := img.TranscodeWithoutRecompress(ImageTypeJPEG, false)
+	require.NoError(t, err)
+
+	transcoded, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	point, err := transcoded.GetPoint(20, 20)
+	require.NoError(t, err)
+	assert.Greater(t, point[0], 200.0)
+	assert.Less(t, point[1], 60.0)
+	assert.Less(t, point[2], 60.0)
+
+	err = img.LosslessRotateJpeg(Angle90)
+	assert.Error(t, err)
+}
+
+func TestImageRef_TranscodeWithoutRecompress__Cmyk(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "jpg-32bit-cmyk-icc-swop.jpg")
+	require.NoError(t, err)
+
+	img, err := NewImageFromBuffer(raw)
+	require.NoError(t, err)
+
+	buf, _, err := img.TranscodeWithoutRecompress(ImageTypeJPEG, true)
+	require.NoError(t, err)
+	assert.Less(t, len(buf), len(raw))
+	assert.True(t, bytes.Contains(buf, []byte{0xFF, 0xEE}))
+	assert.True(t, bytes.Contains(buf, []byte("Adobe")))
+
+	stripped, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.Equal(t, InterpretationCMYK, stripped.Interpretation())
+
+	expected, err := img.GetPoints([]image.Point{{10, 10}, {img.Width() / 2, img.Height() / 2}})
+	require.NoError(t, err)
+	actual, err := stripped.GetPoints([]image.Point{{10, 10}, {img.Width() / 2, img.Height() / 2}})
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestImageRef_TranscodeWithoutRecompress__Png(t *testing.T) {
+	Startup(nil)
+
+	raw, err := ioutil.ReadFile(resources + "has-icc-profile.png")
+	require.NoError(t, err)
+
+	img, err := NewImageFromBuffer(raw)
+	require.NoError(t, err)
+	require.True(t, img.HasICCProfile())
+
+	buf, _, err := img.TranscodeWithoutRecompress(ImageTypePNG, true)
+	require.NoError(t, err)
+
+	stripped, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.False(t, stripped.HasICCProfile())
+	assert.Equal(t, img.Width(), stripped.Width())
+}
+
+func TestImageRef_ExportTinyPreview(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-orientation-6.jpg")
+	require.NoError(t, err)
+	width := img.Width()
+
+	buf, metadata, err := img.ExportTinyPreview(32)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(buf), 2048)
+	assert.LessOrEqual(t, metadata.Width, 32)
+	assert.LessOrEqual(t, metadata.Height, 32)
+	assert.Equal(t, width, img.Width())
+
+	// noise doesn't compress, the dimensions are reduced until it fits
+	noise, err := GaussNoise(512, 512, 128, 100, 1)
+	require.NoError(t, err)
+	require.NoError(t, noise.Cast(BandFormatUchar))
+	require.NoError(t, noise.ToColorSpace(InterpretationSRGB))
+	buf, metadata, err = noise.ExportTinyPreview(256)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(buf), 2048)
+	assert.Less(t, metadata.Width, 256)
+
+	_, _, err = img.ExportTinyPreview(0)
+	assert.Error(t, err)
+}
+
+func TestImageRef_ExportJpeg__KeepMetadata(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit-icc-iec.jpg")
+	require.NoError(t, err)
+	require.True(t, img.HasICCProfile())
+
+	buf, _, err := img.ExportJpeg(&JpegExportParams{Quality: 80, KeepMetadata: []string{"icc-profile-data"}})
+	require.NoError(t, err)
+
+	kept, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.True(t, kept.HasICCProfile())
+
+	buf, _, err = img.ExportJpeg(&JpegExportParams{Quality: 80, KeepMetadata: []string{"exif-*"}})
+	require.NoError(t, err)
+
+	removed, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.False(t, removed.HasICCProfile())
+
+	assert.True(t, img.HasICCProfile())
+}
+
+func TestMatchesField(t *testing.T) {
+	patterns := []string{"orientation", "exif-ifd0-*"}
+
+	assert.True(t, matchesField(patterns, "orientation"))
+	assert.True(t, matchesField(patterns, "exif-ifd0-Copyright"))
+	assert.False(t, matchesField(patterns, "exif-ifd2-ExposureTime"))
+	assert.False(t, matchesField(patterns, "orientation-extra"))
+}
+
+func TestImageRef_EstimatedQuality(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	_, err = img.EstimatedQuality()
+	assert.Error(t, err)
+
+	for _, quality := range []int{30, 75, 92} {
+		buf, _, err := img.ExportJpeg(&JpegExportParams{Quality: quality})
+		require.NoError(t, err)
+
+		jpeg, err := NewImageFromBuffer(buf)
+		require.NoError(t, err)
+
+		estimated, err := jpeg.EstimatedQuality()
+		require.NoError(t, err)
+		assert.InDelta(t, quality, estimated, 1)
+	}
+}
+
+func TestImageRef_BlurScore(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	sharp, err := img.BlurScore()
+	require.NoError(t, err)
+
+	err = img.GaussianBlur(3)
+	require.NoError(t, err)
+
+	blurry, err := img.BlurScore()
+	require.NoError(t, err)
+	assert.Less(t, blurry, sharp)
+}
+
+func TestImageRef_FeatherAlpha(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-8bit+alpha.png")
+	require.NoError(t, err)
+
+	before, err := img.GetPoints([]image.Point{{0, 0}, {img.Width() / 2, img.Height() / 2}})
+	require.NoError(t, err)
+	bands := img.Bands()
+
+	err = img.FeatherAlpha(3)
+	require.NoError(t, err)
+	assert.Equal(t, bands, img.Bands())
+	assert.True(t, img.HasAlpha())
+
+	after, err := img.GetPoints([]image.Point{{0, 0}, {img.Width() / 2, img.Height() / 2}})
+	require.NoError(t, err)
+	for i := range before {
+		assert.Equal(t, before[i][:bands-1], after[i][:bands-1])
+	}
+
+	opaque, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+	err = opaque.FeatherAlpha(3)
+	assert.Error(t, err)
+}
+
+func TestImageRef_UnsharpMask(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	before, err := img.BlurScore()
+	require.NoError(t, err)
+
+	err = img.UnsharpMask(1.5, 1, 0)
+	require.NoError(t, err)
+
+	after, err := img.BlurScore()
+	require.NoError(t, err)
+	assert.Greater(t, after, before)
+
+	err = img.SharpenWithParams(nil)
+	require.NoError(t, err)
+
+	err = img.UnsharpMask(0, 1, 0)
+	assert.Error(t, err)
+}
+
+func TestImageRef_Conv(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	before, err := img.GetPoint(50, 50)
+	require.NoError(t, err)
+
+	err = img.Conv([][]float64{{0, 0, 0}, {0, 1, 0}, {0, 0, 0}}, ConvOptions{})
+	require.NoError(t, err)
+
+	after, err := img.GetPoint(50, 50)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	constant, err := Black(20, 20)
+	require.NoError(t, err)
+	err = constant.Linear([]float64{1}, []float64{100})
+	require.NoError(t, err)
+	err = constant.Cast(BandFormatUchar)
+	require.NoError(t, err)
+
+	err = constant.Conv([][]float64{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}}, ConvOptions{Scale: 9})
+	require.NoError(t, err)
+	avg, err := constant.Average()
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, avg)
+
+	err = constant.Conv([][]float64{{1, 2, 1}}, ConvOptions{Scale: 4, Precision: PrecisionFloat, Separable: true})
+	require.NoError(t, err)
+	avg, err = constant.Average()
+	require.NoError(t, err)
+	assert.InDelta(t, 100.0, avg, 0.001)
+
+	// the default float precision keeps fractional kernels
+	err = constant.Conv([][]float64{{0.25, 0.25}, {0.25, 0.25}}, ConvOptions{})
+	require.NoError(t, err)
+	avg, err = constant.Average()
+	require.NoError(t, err)
+	assert.InDelta(t, 100.0, avg, 0.001)
+
+	err = constant.Conv([][]float64{{1, 1}, {1, 1}}, ConvOptions{Separable: true})
+	assert.Error(t, err)
+	err = constant.Conv([][]float64{{1, 1}, {1}}, ConvOptions{})
+	assert.Error(t, err)
+}
+
+func TestImageRef_EdgeDetection(t *testing.T) {
+	Startup(nil)
+
+	tests := []struct {
+		name       string
+		minVersion int
+		op         func(img *ImageRef) error
+	}{
+		{"sobel", 10, func(img *ImageRef) error { return img.Sobel() }},
+		{"scharr", 13, func(img *ImageRef) error { return img.Scharr() }},
+		{"canny", 10, func(img *ImageRef) error { return img.Canny(1.4, PrecisionFloat) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if MajorVersion == 8 && MinorVersion < tt.minVersion {
+				t.Skipf("%s is only supported in vips 8.%d+", tt.name, tt.minVersion)
+			}
+
+			flat, err := Black(50, 50)
+			require.NoError(t, err)
+			err = flat.Linear([]float64{1}, []float64{128})
+			require.NoError(t, err)
+			err = flat.Cast(BandFormatUchar)
+			require.NoError(t, err)
+
+			err = tt.op(flat)
+			require.NoError(t, err)
+			avg, err := flat.Average()
+			require.NoError(t, err)
+			assert.InDelta(t, 0, avg, 0.001)
+
+			img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+			require.NoError(t, err)
+
+			err = tt.op(img)
+			require.NoError(t, err)
+			avg, err = img.Average()
+			require.NoError(t, err)
+			assert.Greater(t, avg, 0.0)
+		})
+	}
+}
+
+func TestImageRef_NoiseScore(t *testing.T) {
+	Startup(nil)
+
+	img, err := Black(100, 100)
+	require.NoError(t, err)
+
+	noise, err := img.NoiseScore()
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, noise)
+
+	img, err = NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	noise, err = img.NoiseScore()
+	require.NoError(t, err)
+	assert.Greater(t, noise, 0.0)
+}
+
+func TestImageRef_TileHashes(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	for _, algo := range []HashAlgorithm{HashAverage, HashDifference, HashPerceptual} {
+		hashes, err := img.TileHashes(512, algo)
+		require.NoError(t, err)
+		require.Len(t, hashes, 3)
+		assert.Len(t, hashes[0], 4)
+	}
+
+	hashes, err := img.TileHashes(512, HashDifference)
+	require.NoError(t, err)
+
+	err = img.ExtractArea(512, 512, 512, 512)
+	require.NoError(t, err)
+
+	cropped, err := img.TileHashes(512, HashDifference)
+	require.NoError(t, err)
+	assert.Equal(t, hashes[1][1], cropped[0][0])
+
+	// the pixels are hashed as bytes whatever the band format
+	expected, err := img.TileHashes(256, HashDifference)
+	require.NoError(t, err)
+	require.NoError(t, img.Cast(BandFormatFloat))
+	floats, err := img.TileHashes(256, HashDifference)
+	require.NoError(t, err)
+	assert.Equal(t, expected, floats)
+}
+
+func TestAverageHash(t *testing.T) {
+	pixels := make([]byte, 64)
+	for i := 32; i < 64; i++ {
+		pixels[i] = 255
+	}
+
+	assert.Equal(t, uint64(0xFFFFFFFF00000000), averageHash(pixels))
+}
+
+func TestImageRef_Label__FitStrokeShadow(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	width, height := img.Width(), img.Height()
+
+	err = img.Label(&LabelParams{
+		Text:          "A caption that is far too long to fit the box at its nominal size",
+		Font:          "sans 64",
+		Width:         Scalar{Value: 400},
+		Height:        Scalar{Value: 100},
+		OffsetX:       Scalar{Value: 20},
+		OffsetY:       Scalar{Value: 20},
+		Opacity:       1,
+		Color:         Color{R: 255, G: 255, B: 255},
+		AutoFit:       true,
+		LineSpacing:   4,
+		LetterSpacing: 1,
+		StrokeWidth:   2,
+		StrokeColor:   Color{R: 0, G: 0, B: 0},
+		Shadow:        true,
+		ShadowOffsetX: 3,
+		ShadowOffsetY: 3,
+		ShadowBlur:    2,
+		ShadowColor:   Color{R: 64, G: 64, B: 64},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, width, img.Width())
+	assert.Equal(t, height, img.Height())
+
+	_, _, err = img.ExportPng(nil)
+	require.NoError(t, err)
+}
+
+func TestImageRef_Labels(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	width, height := img.Width(), img.Height()
+
+	var labels []*LabelParams
+	for i := 0; i < 10; i++ {
+		labels = append(labels, &LabelParams{
+			Text:    fmt.Sprintf("Label %d", i),
+			Font:    DefaultFont,
+			OffsetX: Scalar{Value: float64(20 + 100*i)},
+			OffsetY: Scalar{Value: float64(20 + 50*i)},
+			Opacity: 1,
+			Color:   Color{R: 255, G: 0, B: 0},
+		})
+	}
+
+	err = img.Labels(labels)
+	require.NoError(t, err)
+	assert.Equal(t, width, img.Width())
+	assert.Equal(t, height, img.Height())
+	assert.Equal(t, 3, img.Bands())
+	assert.Equal(t, BandFormatUchar, img.BandFormat())
+
+	err = img.Labels(nil)
+	require.NoError(t, err)
+
+	_, _, err = img.ExportPng(nil)
+	require.NoError(t, err)
+}
+
+func TestImageRef_Labels__Composited(t *testing.T) {
+	Startup(nil)
+
+	img, err := Black(200, 100)
+	require.NoError(t, err)
+	require.NoError(t, img.ToColorSpace(InterpretationSRGB))
+
+	err = img.Labels([]*LabelParams{
+		{Text: "One", Font: "sans 24", OffsetX: Scalar{Value: 10}, OffsetY: Scalar{Value: 10}, Opacity: 1,
+			Color: Color{R: 255, G: 255, B: 255}},
+		{Text: "Two", Font: "sans 24", OffsetX: Scalar{Value: 100}, OffsetY: Scalar{Value: 50}, Opacity: 1,
+			Color: Color{R: 255, G: 255, B: 255}, StrokeWidth: 1, StrokeColor: Color{R: 255}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, img.Bands())
+	assert.Equal(t, InterpretationSRGB, img.Interpretation())
+
+	average, err := img.Average()
+	require.NoError(t, err)
+	assert.Greater(t, average, 0.0)
+
+	// outside the labels the image is untouched
+	point, err := img.GetPoint(199, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 0, 0}, point)
+}
+
+func TestImageRef_LoadImageFromStream(t *testing.T) {
+	Startup(nil)
+
+	file, err := os.Open(resources + "png-24bit.png")
+	require.NoError(t, err)
+	defer file.Close()
+
+	img, err := NewImageFromStream(file)
+	require.NoError(t, err)
+	defer img.Close()
+
+	assert.Equal(t, ImageTypePNG, img.Format())
+	assert.Equal(t, 1920, img.Width())
+	assert.Equal(t, 1080, img.Height())
+
+	err = img.Resize(0.25, KernelLinear)
+	require.NoError(t, err)
+
+	_, _, err = img.ExportJpeg(nil)
+	assert.NoError(t, err)
+}
+
+func TestImageRef_LoadImageFromStream__OtherFormatParams(t *testing.T) {
+	Startup(nil)
+
+	buf, err := ioutil.ReadFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	// pngload takes none of these, they are left out of its option string
+	params := NewImportParams()
+	params.JpegShrinkFactor.Set(2)
+	params.HeifThumbnail.Set(true)
+	params.SvgUnlimited.Set(true)
+	params.NumPages.Set(1)
+	params.Density.Set(300)
+	assert.Equal(t, "fail=TRUE", params.optionString(ImageTypePNG))
+
+	img, err := LoadImageFromStream(bytes.NewReader(buf), params)
+	require.NoError(t, err)
+	defer img.Close()
+	assert.Equal(t, ImageTypePNG, img.Format())
+	assert.Equal(t, 1920, img.Width())
+}
+
+func TestImageRef_LoadImageFromStream_NotSeekable(t *testing.T) {
+	Startup(nil)
+
+	buf, err := ioutil.ReadFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	img, err := LoadImageFromStream(struct{ io.Reader }{bytes.NewReader(buf)}, nil)
+	require.NoError(t, err)
+	defer img.Close()
+
+	assert.Equal(t, ImageTypeJPEG, img.Format())
+	assert.Equal(t, 100, img.Width())
+
+	_, err = NewImageFromStream(strings.NewReader("not an image"))
+	assert.Error(t, err)
+}
+
+// emptyReader returns no data and no error, as the io.Reader contract discourages but allows
+type emptyReader struct{}
+
+func (emptyReader) Read([]byte) (int, error) {
+	return 0, nil
+}
+
+func TestImageRef_LoadImageFromStream_NoProgress(t *testing.T) {
+	Startup(nil)
+
+	_, err := LoadImageFromStream(emptyReader{}, nil)
+	assert.Error(t, err)
+}
+
+func TestImageRef_ExportToWriter(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	var jpeg bytes.Buffer
+	metadata, err := img.ExportJpegToWriter(&jpeg, &JpegExportParams{Quality: 80})
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeJPEG, metadata.Format)
+	assert.Equal(t, ImageTypeJPEG, DetermineImageType(jpeg.Bytes()))
+
+	buf, _, err := img.ExportJpeg(&JpegExportParams{Quality: 80})
+	require.NoError(t, err)
+	assert.Equal(t, buf, jpeg.Bytes())
+
+	var png bytes.Buffer
+	_, err = img.ExportToWriter(&png, &ExportParams{Format: ImageTypePNG})
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypePNG, DetermineImageType(png.Bytes()))
+
+	var webp bytes.Buffer
+	_, err = img.ExportWebpToWriter(&webp, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeWEBP, DetermineImageType(webp.Bytes()))
+}
+
+func TestImageRef_ExportToWriter__WriteError(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	errWrite := errors.New("client went away")
+	_, err = img.ExportJpegToWriter(failingWriter{err: errWrite}, nil)
+	assert.Equal(t, errWrite, err)
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func TestImageRef_CompositeSVG(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	width, height := img.Width(), img.Height()
+
+	svg, err := ioutil.ReadFile(resources + "svg.svg")
+	require.NoError(t, err)
+
+	err = img.CompositeSVG(svg, 100, 50, 300)
+	require.NoError(t, err)
+	assert.Equal(t, width, img.Width())
+	assert.Equal(t, height, img.Height())
+
+	_, _, err = img.ExportPng(nil)
+	require.NoError(t, err)
+
+	err = img.CompositeSVG([]byte("not an svg"), 0, 0, 100)
+	assert.Equal(t, ErrUnsupportedImageFormat, err)
+}
+
+func TestImageRef_ExportJxl(t *testing.T) {
+	Startup(nil)
+
+	if !IsTypeSupported(ImageTypeJXL) {
+		t.Skip("JPEG XL is only supported in vips 8.11+ built with libjxl")
+	}
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	buf, metadata, err := img.ExportJxl(&JxlExportParams{Distance: 1.5, Effort: 3})
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeJXL, metadata.Format)
+	assert.Equal(t, ImageTypeJXL, DetermineImageType(buf))
+
+	jxl, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeJXL, jxl.Format())
+	assert.Equal(t, img.Width(), jxl.Width())
+	assert.Equal(t, img.Height(), jxl.Height())
+
+	_, _, err = img.ExportJxl(&JxlExportParams{Lossless: true, Effort: 1})
+	require.NoError(t, err)
+
+	// Export forwards the quality, a lower quality giving a smaller file
+	params := NewDefaultExportParams()
+	params.Format = ImageTypeJXL
+	params.Effort = 3
+	params.Quality = 95
+	high, _, err := img.Export(params)
+	require.NoError(t, err)
+	params.Quality = 40
+	low, _, err := img.Export(params)
+	require.NoError(t, err)
+	assert.Less(t, len(low), len(high))
+}
+
+func TestImageRef_ExportDeepZoom(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "govips-dz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	params := NewDeepZoomExportParams()
+	params.TileSize = 256
+	params.Overlap = 0
+	params.Suffix = ".png"
+
+	err = img.ExportDeepZoom(dir+"/image", params)
+	require.NoError(t, err)
+
+	dzi, err := ioutil.ReadFile(dir + "/image.dzi")
+	require.NoError(t, err)
+	assert.Contains(t, string(dzi), `TileSize="256"`)
+
+	// the deepest level holds the full resolution image, 1920x1080 in 8x5 tiles of 256 pixels
+	levels, err := ioutil.ReadDir(dir + "/image_files")
+	require.NoError(t, err)
+	tiles, err := ioutil.ReadDir(fmt.Sprintf("%s/image_files/%d", dir, len(levels)-1))
+	require.NoError(t, err)
+	assert.Len(t, tiles, 8*5)
+}
+
+func TestImageRef_ExportDeepZoom__StripMetadata(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit-icc-adobe-rgb.jpg")
+	require.NoError(t, err)
+	require.True(t, img.HasICCProfile())
+
+	dir, err := ioutil.TempDir("", "govips-dz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	params := NewDeepZoomExportParams()
+	params.StripMetadata = true
+	err = img.ExportDeepZoom(dir+"/image", params)
+	require.NoError(t, err)
+
+	levels, err := ioutil.ReadDir(dir + "/image_files")
+	require.NoError(t, err)
+	tile, err := NewImageFromFile(fmt.Sprintf("%s/image_files/%d/0_0.jpeg", dir, len(levels)-1))
+	require.NoError(t, err)
+	assert.False(t, tile.HasICCProfile())
+	assert.True(t, img.HasICCProfile())
+}
+
+func TestImageRef_ExportDeepZoomToBuffer(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	buf, err := img.ExportDeepZoomToBuffer(nil)
+	require.NoError(t, err)
+	// zip local file header
+	assert.True(t, bytes.HasPrefix(buf, []byte("PK\x03\x04")))
+}
+
+func TestImageRef_MapToPalette(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	palette := []Color{{R: 0, G: 0, B: 0}, {R: 255, G: 255, B: 255}, {R: 255, G: 0, B: 0}}
+	require.NoError(t, img.MapToPalette(palette, 1))
+	assert.Equal(t, 3, img.Bands())
+	assert.Equal(t, InterpretationSRGB, img.Interpretation())
+
+	buf, err := img.ToBytes()
+	require.NoError(t, err)
+	for i := 0; i < len(buf); i += 3 {
+		assert.Contains(t, palette, Color{R: buf[i], G: buf[i+1], B: buf[i+2]})
+	}
+
+	assert.Error(t, img.MapToPalette(nil, 0))
+}
+
+func TestImageRef_ExportPng_FixedPalette(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	params := NewPngExportParams()
+	params.FixedPalette = []Color{{R: 0, G: 0, B: 0}, {R: 255, G: 255, B: 255}}
+	buf, _, err := img.ExportPng(params)
+	require.NoError(t, err)
+
+	goImg, _, err := image.Decode(bytes.NewReader(buf))
+	require.NoError(t, err)
+	bounds := goImg.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 97 {
+		for x := bounds.Min.X; x < bounds.Max.X; x += 89 {
+			r, g, b, _ := goImg.At(x, y).RGBA()
+			assert.True(t, r == g && g == b && (r == 0 || r == 0xffff))
+		}
+	}
+}
+
+func TestImageRef_ExportPng_1Bit(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	for _, reduction := range []BitdepthReduction{BitdepthReductionThreshold, BitdepthReductionDither} {
+		params := NewPngExportParams()
+		params.Bitdepth = 1
+		params.Reduction = reduction
+		buf, _, err := img.ExportPng(params)
+		require.NoError(t, err)
+
+		cfg, err := png.DecodeConfig(bytes.NewReader(buf))
+		require.NoError(t, err)
+		assert.Equal(t, color.GrayModel, cfg.ColorModel)
+
+		goImg, err := png.Decode(bytes.NewReader(buf))
+		require.NoError(t, err)
+		gray, ok := goImg.(*image.Gray)
+		require.True(t, ok)
+		for _, v := range gray.Pix {
+			assert.True(t, v == 0 || v == 255)
+		}
+	}
+}
+
+func TestImageRef_ExportTiff_Fax4(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	params := NewTiffExportParams()
+	params.Compression = TiffCompressionFax4
+	params.Reduction = BitdepthReductionDither
+	buf, _, err := img.ExportTiff(params)
+	require.NoError(t, err)
+
+	tiff, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tiff.Bands())
+	assert.Equal(t, img.Width(), tiff.Width())
+}
+
+func TestImageRef_RotateAngle(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	require.NoError(t, img.RotateAngle(45, &ColorRGBA{R: 255, G: 0, B: 0, A: 255}))
+	assert.InDelta(t, 142, img.Width(), 2)
+	assert.InDelta(t, 142, img.Height(), 2)
+	assert.Equal(t, 3, img.Bands())
+
+	p, err := img.GetPoint(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 0, 0}, p)
+
+	require.NoError(t, img.AddAlpha())
+	require.NoError(t, img.RotateAngle(-45, nil))
+	assert.Equal(t, 4, img.Bands())
+
+	p, err = img.GetPoint(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), p[3])
+}
+
+func TestImageRef_RotateAngle_CMYK(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-32bit-cmyk-icc-swop.jpg")
+	require.NoError(t, err)
+	require.Equal(t, InterpretationCMYK, img.Interpretation())
+
+	// the background has one value per ink
+	require.NoError(t, img.RotateAngle(45, &ColorRGBA{R: 255, G: 0, B: 0, A: 255}))
+	assert.Equal(t, 4, img.Bands())
+	p, err := img.GetPoint(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 255, 255, 0}, p)
+
+	require.NoError(t, img.AddAlpha())
+	require.NoError(t, img.Flatten(&Color{R: 255, G: 255, B: 255}))
+	assert.Equal(t, 4, img.Bands())
+}
+
+func TestImageRef_RotateAngle_MultiPage(t *testing.T) {
+	Startup(nil)
+
+	params := NewImportParams()
+	params.NumPages.Set(-1)
+	img, err := LoadImageFromFile(resources+"gif-animated.gif", params)
+	require.NoError(t, err)
+	pages := img.Pages()
+	require.Greater(t, pages, 1)
+
+	require.NoError(t, img.RotateAngle(30, nil))
+	assert.Equal(t, pages, img.Pages())
+	assert.Equal(t, img.Height(), img.PageHeight()*pages)
+}
+
+func TestImageRef_Shear(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	require.NoError(t, img.Shear(20, 0, &ColorRGBA{R: 255, G: 0, B: 0, A: 255}))
+	assert.InDelta(t, 136, img.Width(), 2)
+	assert.InDelta(t, 100, img.Height(), 2)
+	assert.Equal(t, 3, img.Bands())
+
+	p, err := img.GetPoint(img.Width()-1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 0, 0}, p)
+
+	require.NoError(t, img.AddAlpha())
+	require.NoError(t, img.Shear(0, -10, nil))
+	assert.Equal(t, 4, img.Bands())
+
+	p, err = img.GetPoint(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), p[3])
+
+	assert.Error(t, img.Shear(90, 0, nil))
+	assert.Error(t, img.Shear(45, 45, nil))
+}
+
+func TestImageRef_MirrorTile(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	original, err := img.GetPoint(10, 20)
+	require.NoError(t, err)
+
+	require.NoError(t, img.MirrorTile(3, 2))
+	assert.Equal(t, 300, img.Width())
+	assert.Equal(t, 200, img.Height())
+
+	points, err := img.GetPoints([]image.Point{{10, 20}, {189, 20}, {210, 20}, {10, 179}, {189, 179}})
+	require.NoError(t, err)
+	for _, p := range points {
+		assert.Equal(t, original, p)
+	}
+
+	assert.Error(t, img.MirrorTile(0, 2))
+}
+
+func TestImageRef_ExportTiff_Compression(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	export := func(modify func(params *TiffExportParams)) []byte {
+		params := NewTiffExportParams()
+		modify(params)
+		buf, _, err := img.ExportTiff(params)
+		require.NoError(t, err)
+		return buf
+	}
+
+	high := export(func(p *TiffExportParams) { p.Compression = TiffCompressionJpeg; p.Quality = 95 })
+	low := export(func(p *TiffExportParams) { p.Compression = TiffCompressionJpeg; p.Quality = 20 })
+	assert.Less(t, len(low), len(high))
+
+	deflate := export(func(p *TiffExportParams) {
+		p.Compression = TiffCompressionDeflate
+		p.Predictor = TiffPredictorNone
+		p.Level = 9
+	})
+	tiff, err := NewImageFromBuffer(deflate)
+	require.NoError(t, err)
+	assert.Equal(t, img.Width(), tiff.Width())
+	assert.Equal(t, ImageTypeTIFF, tiff.Format())
+
+	// zero value params leave the predictor to libvips
+	buf, _, err := img.ExportTiff(&TiffExportParams{Compression: TiffCompressionLzw})
+	require.NoError(t, err)
+	tiff, err = NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.Equal(t, img.Width(), tiff.Width())
+}
+
+func TestText(t *testing.T) {
+	Startup(nil)
+
+	img, err := Text(&TextParams{Text: "a < b & c", Color: &ColorRGBA{R: 255, G: 0, B: 0, A: 255}})
+	require.NoError(t, err)
+	assert.Equal(t, 4, img.Bands())
+	assert.Equal(t, InterpretationSRGB, img.Interpretation())
+	assert.Greater(t, img.Width(), 0)
+
+	// the text box grows to fill the height
+	fitted, err := Text(&TextParams{Text: "<b>hello</b>", Markup: true, Width: 400, Height: 100})
+	require.NoError(t, err)
+	assert.Greater(t, fitted.Height(), img.Height())
+	assert.LessOrEqual(t, fitted.Height(), 100)
+	assert.LessOrEqual(t, fitted.Width(), 400)
+
+	_, err = Text(&TextParams{})
+	assert.Equal(t, ErrEmptyText, err)
+}
+
+func TestImageRef_ExportIco(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	buf, metadata, err := img.ExportIco([]int{16, 32, 256})
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeICO, metadata.Format)
+	assert.Equal(t, ImageTypeICO, DetermineImageType(buf))
+
+	entries, err := parseIco(buf)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, 256, entries[2].width)
+
+	// the generic export writes the default sizes
+	assert.True(t, IsTypeSupported(ImageTypeICO))
+	exported, metadata, err := img.Export(&ExportParams{Format: ImageTypeICO})
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeICO, metadata.Format)
+	entries, err = parseIco(exported)
+	require.NoError(t, err)
+	assert.Len(t, entries, len(DefaultIcoSizes))
+
+	// the largest variant is loaded, centered on a transparent square
+	ico, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeICO, ico.OriginalFormat())
+	assert.Equal(t, 256, ico.Width())
+	assert.Equal(t, 256, ico.Height())
+	assert.Equal(t, 4, ico.Bands())
+
+	p, err := ico.GetPoint(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), p[3])
+
+	_, _, err = img.ExportIco([]int{512})
+	assert.Error(t, err)
+}
+
+func TestImageRef_Watermark(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	mark, err := NewQRCode("govips", 100, nil)
+	require.NoError(t, err)
+
+	params := NewWatermarkParams()
+	params.Image = mark
+	params.Opacity = 1
+	params.Gravity = GravityNorthWest
+	require.NoError(t, img.Watermark(params))
+	assert.Equal(t, 1920, img.Width())
+	assert.Equal(t, 3, img.Bands())
+
+	// the quiet zone of the QR code is white
+	p, err := img.GetPoint(12, 12)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 255, 255}, p)
+
+	params = NewWatermarkParams()
+	params.Text = &TextParams{Text: "© govips", Color: &ColorRGBA{R: 255, G: 255, B: 255, A: 255}}
+	params.Tile = true
+	params.Spacing = 50
+	params.Scale = 0.1
+	require.NoError(t, img.Watermark(params))
+	assert.Equal(t, 1080, img.Height())
+
+	assert.Equal(t, ErrNoWatermark, img.Watermark(&WatermarkParams{}))
+}
+
+func TestImageRef_Watermark_MultiPage(t *testing.T) {
+	Startup(nil)
+
+	importParams := NewImportParams()
+	importParams.NumPages.Set(-1)
+	img, err := LoadImageFromFile(resources+"gif-animated.gif", importParams)
+	require.NoError(t, err)
+	pages := img.Pages()
+
+	params := NewWatermarkParams()
+	params.Text = &TextParams{Text: "govips"}
+	require.NoError(t, img.Watermark(params))
+	assert.Equal(t, pages, img.Pages())
+	assert.True(t, img.HasAlpha())
+}
+
+func TestImageRef_SetICCProfile(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	profile, err := img.ICCProfile()
+	require.NoError(t, err)
+	assert.Nil(t, profile)
+
+	require.NoError(t, img.SetICCProfile(sRGBIEC6196621ICCProfile))
+	assert.True(t, img.HasICCProfile())
+
+	profile, err = img.ICCProfile()
+	require.NoError(t, err)
+	assert.Equal(t, sRGBIEC6196621ICCProfile, profile)
+
+	buf, _, err := img.ExportJpeg(nil)
+	require.NoError(t, err)
+	exported, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	profile, err = exported.ICCProfile()
+	require.NoError(t, err)
+	assert.Equal(t, sRGBIEC6196621ICCProfile, profile)
+
+	assert.Equal(t, ErrInvalidICCProfile, img.SetICCProfile([]byte("not a profile")))
+}
+
+func TestImageRef_CopyMetadataFrom(t *testing.T) {
+	Startup(nil)
+
+	src, err := NewImageFromFile(resources + "copyright.jpeg")
+	require.NoError(t, err)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	require.NoError(t, img.CopyMetadataFrom(src))
+	assert.Equal(t, "Dennis Goodwin", img.Exif()["Artist"])
+
+	buf, _, err := img.ExportJpeg(nil)
+	require.NoError(t, err)
+	exported, err := NewImageFromBuffer(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "Dennis Goodwin", exported.Exif()["Artist"])
+
+	img, err = NewImageFromFile(resources + "jpg-orientation-6.jpg")
+	require.NoError(t, err)
+	require.NoError(t, img.CopyMetadataFrom(src, "exif-ifd0-Artist", "exif-ifd0-Model"))
+	exif := img.Exif()
+	assert.Equal(t, "Dennis Goodwin", exif["Artist"])
+	assert.Equal(t, "samsung", exif["Make"])
+	assert.NotContains(t, exif, "Model")
+	assert.Equal(t, 4032, img.Width())
+}
+
+func TestImageRef_Export__MetadataToggles(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "copyright.jpeg")
+	require.NoError(t, err)
+	require.NoError(t, img.SetICCProfile(sRGBIEC6196621ICCProfile))
+
+	load := func(buf []byte, err error) *ImageRef {
+		require.NoError(t, err)
+		exported, err := NewImageFromBuffer(buf)
+		require.NoError(t, err)
+		return exported
+	}
+
+	params := NewJpegExportParams()
+	params.StripMetadata = true
+	params.KeepICC = true
+	buf, _, err := img.ExportJpeg(params)
+	exported := load(buf, err)
+	assert.True(t, exported.HasICCProfile())
+	assert.NotContains(t, exported.Exif(), "Copyright")
+
+	pngParams := NewPngExportParams()
+	pngParams.StripExif = true
+	buf, _, err = img.ExportPng(pngParams)
+	exported = load(buf, err)
+	assert.True(t, exported.HasICCProfile())
+	assert.NotContains(t, exported.Exif(), "Copyright")
+
+	webpParams := NewWebpExportParams()
+	webpParams.KeepMetadata = []string{"exif-*"}
+	webpParams.KeepICC = true
+	buf, _, err = img.ExportWebp(webpParams)
+	exported = load(buf, err)
+	assert.True(t, exported.HasICCProfile())
+	assert.Contains(t, exported.Exif(), "Copyright")
+
+	// the generic export forwards the toggles, also when exporting to the native format
+	buf, _, err = img.Export(&ExportParams{Format: ImageTypePNG, StripExif: true})
+	exported = load(buf, err)
+	assert.True(t, exported.HasICCProfile())
+	assert.NotContains(t, exported.Exif(), "Copyright")
+
+	buf, _, err = img.Export(&ExportParams{StripMetadata: true, KeepICC: true})
+	exported = load(buf, err)
+	assert.Equal(t, ImageTypeJPEG, exported.Format())
+	assert.True(t, exported.HasICCProfile())
+	assert.NotContains(t, exported.Exif(), "Copyright")
+
+	if IsTypeSupported(ImageTypeHEIF) {
+		heifParams := NewHeifExportParams()
+		heifParams.StripExif = true
+		buf, _, err = img.ExportHeif(heifParams)
+		exported = load(buf, err)
+		assert.True(t, exported.HasICCProfile())
+		assert.NotContains(t, exported.Exif(), "Copyright")
+	}
+
+	strip, keep, remove := exportMetadata(false, nil, true, true, true)
+	assert.False(t, strip)
+	assert.Nil(t, keep)
+	assert.Equal(t, []string{"exif-*", "xmp-data"}, remove)
+
+	strip, keep, remove = exportMetadata(true, []string{"orientation"}, true, false, false)
+	assert.True(t, strip)
+	assert.Nil(t, keep)
+	assert.Nil(t, remove)
+}
+
+func TestImageRef_SetBackground(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	background, err := img.Background()
+	require.NoError(t, err)
+	assert.Nil(t, background)
+
+	require.NoError(t, img.SetBackground(Color{R: 255, G: 128, B: 0}))
+
+	background, err = img.Background()
+	require.NoError(t, err)
+	assert.Equal(t, &Color{R: 255, G: 128, B: 0}, background)
+
+	_, _, err = img.ExportPng(nil)
+	require.NoError(t, err)
+}
+
+func TestImageRef_SetPremultiplied(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	require.NoError(t, img.AddAlpha())
+	assert.False(t, img.IsPremultiplied())
+
+	require.NoError(t, img.PremultiplyAlpha())
+	assert.True(t, img.IsPremultiplied())
+
+	img.SetPremultiplied(false, BandFormatUchar)
+	assert.False(t, img.IsPremultiplied())
+	assert.Equal(t, BandFormatFloat, img.BandFormat())
+
+	img.SetPremultiplied(true, BandFormatUchar)
+	assert.True(t, img.IsPremultiplied())
+	require.NoError(t, img.UnpremultiplyAlpha())
+	assert.False(t, img.IsPremultiplied())
+	assert.Equal(t, BandFormatUchar, img.BandFormat())
+}
+
+func TestImageRef_Blend(t *testing.T) {
+	Startup(nil)
+
+	newImage := func(white bool) *ImageRef {
+		img, err := Black(10, 10)
+		require.NoError(t, err)
+		require.NoError(t, img.ToColorSpace(InterpretationSRGB))
+		if white {
+			require.NoError(t, img.Invert())
+		}
+		return img
+	}
+
+	img := newImage(false)
+	require.NoError(t, img.Blend(newImage(true), 0.25))
+	assert.Equal(t, BandFormatUchar, img.BandFormat())
+	point, err := img.GetPoint(5, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 64.0, point[0])
+
+	mask, err := Black(10, 10)
+	require.NoError(t, err)
+	require.NoError(t, mask.Cast(BandFormatFloat))
+	require.NoError(t, mask.Linear([]float64{1}, []float64{0.5}))
+
+	img = newImage(false)
+	require.NoError(t, img.BlendWithMask(newImage(true), mask))
+	point, err = img.GetPoint(5, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 128.0, point[0])
+
+	small, err := Black(5, 5)
+	require.NoError(t, err)
+	assert.Error(t, img.Blend(small, 0.5))
+	assert.Error(t, img.BlendWithMask(newImage(true), small))
+	assert.Error(t, img.Blend(newImage(true), 1.5))
+}
+
+func TestImageRef_CalibrateFlatField(t *testing.T) {
+	Startup(nil)
+
+	constant := func(values ...float64) *ImageRef {
+		img, err := Black(10, 10)
+		require.NoError(t, err)
+		ones := make([]float64, len(values))
+		for i := range ones {
+			ones[i] = 1
+		}
+		require.NoError(t, img.Linear(ones, values))
+		require.NoError(t, img.Cast(BandFormatUchar))
+		return img
+	}
+
+	img := constant(110)
+	require.NoError(t, img.CalibrateFlatField(constant(10), constant(210)))
+	assert.Equal(t, BandFormatUchar, img.BandFormat())
+	avg, err := img.Average()
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, avg)
+
+	// each band is scaled by the mean of its own flat field
+	img = constant(110, 60)
+	require.NoError(t, img.CalibrateFlatField(constant(10, 10), constant(210, 110)))
+	point, err := img.GetPoint(5, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{100, 50}, point)
+
+	small, err := Black(5, 5)
+	require.NoError(t, err)
+	assert.Error(t, img.CalibrateFlatField(small, constant(210)))
+}
+
+func TestImageRef_DrawCircleAndLine(t *testing.T) {
+	Startup(nil)
+
+	img, err := Black(50, 50)
+	require.NoError(t, err)
+	require.NoError(t, img.ToColorSpace(InterpretationSRGB))
+	require.NoError(t, img.Cast(BandFormatUchar))
+
+	red := ColorRGBA{R: 255, A: 255}
+	err = img.DrawCircle(red, 25, 25, 10, true)
+	require.NoError(t, err)
+	err = img.DrawLine(ColorRGBA{G: 255, A: 255}, 0, 45, 49, 45)
+	require.NoError(t, err)
+
+	points, err := img.GetPoints([]image.Point{{25, 25}, {25, 15}, {2, 2}, {10, 45}})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 0, 0}, points[0])
+	assert.Equal(t, []float64{255, 0, 0}, points[1])
+	assert.Equal(t, []float64{0, 0, 0}, points[2])
+	assert.Equal(t, []float64{0, 255, 0}, points[3])
+
+	outline, err := Black(50, 50)
+	require.NoError(t, err)
+	require.NoError(t, outline.ToColorSpace(InterpretationSRGB))
+	require.NoError(t, outline.Cast(BandFormatUchar))
+	require.NoError(t, outline.AddAlpha())
+
+	err = outline.DrawCircle(red, 25, 25, 10, false)
+	require.NoError(t, err)
+	points, err = outline.GetPoints([]image.Point{{25, 25}, {35, 25}})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 0, 0, 255}, points[0])
+	assert.Equal(t, []float64{255, 0, 0, 255}, points[1])
+}
+
+func TestImageRef_DrawFlood(t *testing.T) {
+	Startup(nil)
+
+	img, err := Black(50, 50)
+	require.NoError(t, err)
+	require.NoError(t, img.ToColorSpace(InterpretationSRGB))
+	require.NoError(t, img.Cast(BandFormatUchar))
+
+	white := ColorRGBA{R: 255, G: 255, B: 255, A: 255}
+	require.NoError(t, img.DrawRect(white, 10, 10, 20, 20, false))
+
+	area, err := img.DrawFlood(20, 20, ColorRGBA{R: 255, A: 255}, true)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(11, 11, 29, 29), area)
+
+	points, err := img.GetPoints([]image.Point{{20, 20}, {10, 10}, {5, 5}})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 0, 0}, points[0])
+	assert.Equal(t, []float64{255, 255, 255}, points[1])
+	assert.Equal(t, []float64{0, 0, 0}, points[2])
+
+	area, err = img.DrawFlood(0, 0, white, false)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 50, 50), area)
+}
+
+func TestImageRef_DrawImage(t *testing.T) {
+	Startup(nil)
+
+	newImage := func(width, height int, value float64) *ImageRef {
+		img, err := Black(width, height)
+		require.NoError(t, err)
+		require.NoError(t, img.Linear([]float64{1}, []float64{value}))
+		require.NoError(t, img.Cast(BandFormatUchar))
+		return img
+	}
+
+	canvas := newImage(40, 40, 10)
+	sprite := newImage(10, 10, 100)
+
+	err := canvas.DrawImage(sprite, 0, 0, CombineModeSet)
+	require.NoError(t, err)
+	err = canvas.DrawImage(sprite, 20, 20, CombineModeAdd)
+	require.NoError(t, err)
+	assert.Equal(t, 40, canvas.Width())
+
+	points, err := canvas.GetPoints([]image.Point{{5, 5}, {25, 25}, {15, 15}})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{100}, points[0])
+	assert.Equal(t, []float64{110}, points[1])
+	assert.Equal(t, []float64{10}, points[2])
+}
+
 // TODO unit tests to cover:
 // NewImageFromReader failing test
 // NewImageFromFile failing test
@@ -1,7 +1,10 @@
 package vips
 
 import (
+	"image"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -155,3 +158,45 @@ func TestThumbnail_GIF_ExportWebP(t *testing.T) {
 		},
 		nil, nil, exportWebp(NewWebpExportParams()))
 }
+
+func TestThumbnail_CropRect(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-8bit-grey-icc-dot-gain.jpg")
+	require.NoError(t, err)
+
+	rect, err := img.ThumbnailWithCropRect(25, 25, InterestingCentre)
+	require.NoError(t, err)
+
+	assert.Equal(t, 25, img.Width())
+	assert.Equal(t, 25, img.Height())
+	// 715x483 original, the centre square is kept
+	assert.InDelta(t, 483, rect.Dy(), 20)
+	assert.InDelta(t, rect.Dy(), rect.Dx(), 20)
+	assert.InDelta(t, (715-rect.Dx())/2, rect.Min.X, 20)
+	assert.Equal(t, 0, rect.Min.Y)
+}
+
+func TestThumbnail_CropRect_Interesting(t *testing.T) {
+	Startup(nil)
+
+	for _, interesting := range []Interesting{InterestingNone, InterestingCentre, InterestingEntropy,
+		InterestingAttention, InterestingLow, InterestingHigh, InterestingAll} {
+		img, err := NewImageFromFile(resources + "jpg-8bit-grey-icc-dot-gain.jpg")
+		require.NoError(t, err)
+
+		rect, err := img.ThumbnailWithCropRect(25, 25, interesting)
+		require.NoError(t, err)
+		assert.True(t, rect.In(image.Rect(0, 0, 715, 483)), "interesting %d", interesting)
+
+		if interesting == InterestingNone {
+			assert.Equal(t, image.Rect(0, 0, 715, 483), rect)
+			continue
+		}
+
+		assert.Equal(t, 25, img.Width())
+		assert.Equal(t, 25, img.Height())
+		assert.InDelta(t, 483, rect.Dy(), 20, "interesting %d", interesting)
+		assert.InDelta(t, rect.Dy(), rect.Dx(), 20, "interesting %d", interesting)
+	}
+}
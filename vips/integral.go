@@ -0,0 +1,52 @@
+package vips
+
+import "fmt"
+
+// IntegralImage replaces the image by its summed-area table: each pixel becomes the sum of the pixels above and to
+// the left of it, itself included, computed for each band. The sum of any rectangle x0 < x <= x1, y0 < y <= y1 is
+// then S(x1, y1) - S(x0, y1) - S(x1, y0) + S(x0, y0), whatever its size, which makes box filters and local
+// statistics cheap; integrate the squared image too for local variances. The result is a double image, which is
+// computed in memory at 8 bytes per band and pixel.
+func (r *ImageRef) IntegralImage() error {
+	double, err := vipsCast(r.image, BandFormatDouble)
+	if err != nil {
+		return err
+	}
+	defer clearImage(double)
+
+	buf, err := vipsImageToMemory(double)
+	if err != nil {
+		return err
+	}
+
+	width, height, bands := r.Width(), r.Height(), r.Bands()
+	pix := bytesAsFloat64s(buf)
+	if len(pix) != width*height*bands {
+		return fmt.Errorf("expected %d pixel values, got %d", width*height*bands, len(pix))
+	}
+	integrateDoubles(pix, width, height, bands)
+
+	out, err := vipsImageFromMemoryFormat(buf, width, height, bands, BandFormatDouble, InterpretationMultiband)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// integrateDoubles turns interleaved pixels into their summed-area table in place
+func integrateDoubles(pix []float64, width, height, bands int) {
+	stride := width * bands
+	for y := 0; y < height; y++ {
+		row := pix[y*stride : (y+1)*stride]
+		for i := bands; i < stride; i++ {
+			row[i] += row[i-bands]
+		}
+		if y > 0 {
+			above := pix[(y-1)*stride : y*stride]
+			for i := range row {
+				row[i] += above[i]
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRef_IntegralImage(t *testing.T) {
+	Startup(nil)
+
+	img, err := Black(10, 8)
+	require.NoError(t, err)
+	err = img.Linear([]float64{1}, []float64{2})
+	require.NoError(t, err)
+
+	err = img.IntegralImage()
+	require.NoError(t, err)
+	assert.Equal(t, BandFormatDouble, img.BandFormat())
+	assert.Equal(t, 10, img.Width())
+	assert.Equal(t, 8, img.Height())
+
+	for _, p := range [][2]int{{0, 0}, {9, 0}, {0, 7}, {4, 3}, {9, 7}} {
+		point, err := img.GetPoint(p[0], p[1])
+		require.NoError(t, err)
+		assert.Equal(t, float64(2*(p[0]+1)*(p[1]+1)), point[0])
+	}
+}
+
+func TestIntegrateDoubles(t *testing.T) {
+	pix := []float64{
+		1, 10, 2, 20, 3, 30,
+		4, 40, 5, 50, 6, 60,
+	}
+	integrateDoubles(pix, 3, 2, 2)
+	assert.Equal(t, []float64{
+		1, 10, 3, 30, 6, 60,
+		5, 50, 12, 120, 21, 210,
+	}, pix)
+}
+
+func TestBytesAsFloat64s(t *testing.T) {
+	buf := make([]byte, 8*3)
+	pix := bytesAsFloat64s(buf)
+	require.Len(t, pix, 3)
+
+	pix[1] = 2.5
+	integrateDoubles(pix, 3, 1, 1)
+	assert.Equal(t, []float64{0, 2.5, 2.5}, bytesAsFloat64s(buf))
+	assert.Nil(t, bytesAsFloat64s(nil))
+}
@@ -0,0 +1,263 @@
+#include "jpeg.h"
+
+#include <jpeglib.h>
+#include <setjmp.h>
+#include <string.h>
+
+typedef struct {
+  struct jpeg_error_mgr pub;
+  jmp_buf setjmp_buffer;
+} ErrorManager;
+
+static void error_exit(j_common_ptr cinfo) {
+  ErrorManager *err = (ErrorManager *)cinfo->err;
+  longjmp(err->setjmp_buffer, 1);
+}
+
+static void output_message(j_common_ptr cinfo) {
+  // warnings are ignored, errors are reported through error_exit
+}
+
+// has_identifier checks whether the data of an APPn marker starts with the
+// identifier, including its terminating null byte where length covers it
+static int has_identifier(jpeg_saved_marker_ptr marker, const char *identifier,
+                          unsigned int length) {
+  return marker->data_length >= length &&
+         memcmp(marker->data, identifier, length) == 0;
+}
+
+static JDIMENSION div_round_up(long a, long b) {
+  return (JDIMENSION)((a + b - 1) / b);
+}
+
+static JDIMENSION round_up(long a, long b) { return div_round_up(a, b) * b; }
+
+// transform_block copies a block of coefficients, rotating it by the given
+// angle. Rotations are combinations of transposition and mirroring, which
+// in the DCT domain only negate the odd frequencies.
+static void transform_block(JCOEFPTR src, JCOEFPTR dst, int rotate) {
+  int i, j;
+
+  for (i = 0; i < DCTSIZE; i++) {
+    for (j = 0; j < DCTSIZE; j++) {
+      JCOEF v = src[i * DCTSIZE + j];
+      switch (rotate) {
+        case 90:
+          dst[j * DCTSIZE + i] = (i & 1) ? -v : v;
+          break;
+        case 180:
+          dst[i * DCTSIZE + j] = ((i ^ j) & 1) ? -v : v;
+          break;
+        case 270:
+          dst[j * DCTSIZE + i] = (j & 1) ? -v : v;
+          break;
+        default:
+          dst[i * DCTSIZE + j] = v;
+      }
+    }
+  }
+}
+
+// lossless_transform_jpeg crops the x, y, width, height area of the image,
+// or rotates it by 90, 180 or 270 degrees clockwise, without decoding the
+// pixels. The crop origin and, for rotations, the image size must be
+// multiples of the iMCU size. Returns 0 on success, writing a malloc'ed
+// buffer to out, otherwise -1 with a message in err.
+int lossless_transform_jpeg(const void *buf, size_t len, int rotate, int x,
+                            int y, int width, int height, void **out,
+                            size_t *out_len, char *err, size_t err_len) {
+  struct jpeg_decompress_struct src;
+  struct jpeg_compress_struct dst;
+  ErrorManager jerr;
+  jvirt_barray_ptr *src_coef;
+  jvirt_barray_ptr dst_coef[MAX_COMPONENTS];
+  unsigned char *out_buf = NULL;
+  unsigned long out_size = 0;
+  jpeg_saved_marker_ptr marker;
+  JDIMENSION imcu_width, imcu_height;
+  int transposed = rotate == 90 || rotate == 270;
+  int ci, m;
+
+  // both objects share the error manager, so one setjmp covers them
+  src.err = jpeg_std_error(&jerr.pub);
+  jerr.pub.error_exit = error_exit;
+  jerr.pub.output_message = output_message;
+  dst.err = &jerr.pub;
+
+  jpeg_create_decompress(&src);
+  jpeg_create_compress(&dst);
+
+  if (setjmp(jerr.setjmp_buffer)) {
+    char message[JMSG_LENGTH_MAX];
+    (*jerr.pub.format_message)((j_common_ptr)&src, message);
+    snprintf(err, err_len, "%s", message);
+    jpeg_destroy_compress(&dst);
+    jpeg_destroy_decompress(&src);
+    free(out_buf);
+    return -1;
+  }
+
+  jpeg_mem_src(&src, (unsigned char *)buf, len);
+  jpeg_save_markers(&src, JPEG_COM, 0xFFFF);
+  for (m = 0; m < 16; m++) {
+    jpeg_save_markers(&src, JPEG_APP0 + m, 0xFFFF);
+  }
+  jpeg_read_header(&src, TRUE);
+
+  imcu_width = src.max_h_samp_factor * DCTSIZE;
+  imcu_height = src.max_v_samp_factor * DCTSIZE;
+
+  if (rotate != 0) {
+    x = 0;
+    y = 0;
+    width = src.image_width;
+    height = src.image_height;
+    if (src.image_width % imcu_width || src.image_height % imcu_height) {
+      snprintf(err, err_len,
+               "image size %dx%d is not a multiple of the %dx%d block size",
+               src.image_width, src.image_height, imcu_width, imcu_height);
+      jpeg_destroy_compress(&dst);
+      jpeg_destroy_decompress(&src);
+      return -1;
+    }
+  } else if (x % imcu_width || y % imcu_height) {
+    snprintf(err, err_len,
+             "crop origin %d,%d is not a multiple of the %dx%d block size", x,
+             y, imcu_width, imcu_height);
+    jpeg_destroy_compress(&dst);
+    jpeg_destroy_decompress(&src);
+    return -1;
+  }
+
+  if (x < 0 || y < 0 || width < 1 || height < 1 ||
+      (JDIMENSION)(x + width) > src.image_width ||
+      (JDIMENSION)(y + height) > src.image_height) {
+    snprintf(err, err_len, "area is outside of the %dx%d image",
+             src.image_width, src.image_height);
+    jpeg_destroy_compress(&dst);
+    jpeg_destroy_decompress(&src);
+    return -1;
+  }
+
+  // request the output coefficient arrays before reading, so that the
+  // memory manager realizes them together with the input arrays
+  for (ci = 0; ci < src.num_components; ci++) {
+    jpeg_component_info *comp = &src.comp_info[ci];
+    int h_samp = transposed ? comp->v_samp_factor : comp->h_samp_factor;
+    int v_samp = transposed ? comp->h_samp_factor : comp->v_samp_factor;
+    int max_h = transposed ? src.max_v_samp_factor : src.max_h_samp_factor;
+    int max_v = transposed ? src.max_h_samp_factor : src.max_v_samp_factor;
+    long out_width = transposed ? height : width;
+    long out_height = transposed ? width : height;
+
+    dst_coef[ci] = (*src.mem->request_virt_barray)(
+        (j_common_ptr)&src, JPOOL_IMAGE, FALSE,
+        round_up(div_round_up(out_width * h_samp, max_h * DCTSIZE), h_samp),
+        round_up(div_round_up(out_height * v_samp, max_v * DCTSIZE), v_samp),
+        (JDIMENSION)v_samp);
+  }
+
+  src_coef = jpeg_read_coefficients(&src);
+
+  jpeg_copy_critical_parameters(&src, &dst);
+  dst.image_width = transposed ? height : width;
+  dst.image_height = transposed ? width : height;
+  if (transposed) {
+    for (ci = 0; ci < dst.num_components; ci++) {
+      int h_samp = dst.comp_info[ci].h_samp_factor;
+      dst.comp_info[ci].h_samp_factor = dst.comp_info[ci].v_samp_factor;
+      dst.comp_info[ci].v_samp_factor = h_samp;
+    }
+    // quantization tables need to follow the transposed coefficients
+    for (m = 0; m < NUM_QUANT_TBLS; m++) {
+      JQUANT_TBL *table = dst.quant_tbl_ptrs[m];
+      int i, j;
+      if (table == NULL) {
+        continue;
+      }
+      for (i = 0; i < DCTSIZE; i++) {
+        for (j = 0; j < i; j++) {
+          UINT16 q = table->quantval[i * DCTSIZE + j];
+          table->quantval[i * DCTSIZE + j] = table->quantval[j * DCTSIZE + i];
+          table->quantval[j * DCTSIZE + i] = q;
+        }
+      }
+    }
+  }
+
+  for (ci = 0; ci < src.num_components; ci++) {
+    jpeg_component_info *comp = &src.comp_info[ci];
+    int h_samp = transposed ? comp->v_samp_factor : comp->h_samp_factor;
+    int v_samp = transposed ? comp->h_samp_factor : comp->v_samp_factor;
+    int max_h = transposed ? src.max_v_samp_factor : src.max_h_samp_factor;
+    int max_v = transposed ? src.max_h_samp_factor : src.max_v_samp_factor;
+    JDIMENSION dst_width = div_round_up(
+        (long)dst.image_width * h_samp, max_h * DCTSIZE);
+    JDIMENSION dst_height = div_round_up(
+        (long)dst.image_height * v_samp, max_v * DCTSIZE);
+    JDIMENSION x_offset = x / imcu_width * comp->h_samp_factor;
+    JDIMENSION y_offset = y / imcu_height * comp->v_samp_factor;
+    JDIMENSION src_width = comp->width_in_blocks;
+    JDIMENSION src_height = comp->height_in_blocks;
+    JDIMENSION dst_x, dst_y;
+
+    for (dst_y = 0; dst_y < dst_height; dst_y++) {
+      JBLOCKARRAY dst_row = (*src.mem->access_virt_barray)(
+          (j_common_ptr)&src, dst_coef[ci], dst_y, 1, TRUE);
+
+      for (dst_x = 0; dst_x < dst_width; dst_x++) {
+        JDIMENSION src_x, src_y;
+        JBLOCKARRAY src_row;
+
+        switch (rotate) {
+          case 90:
+            src_x = dst_y;
+            src_y = src_height - 1 - dst_x;
+            break;
+          case 180:
+            src_x = src_width - 1 - dst_x;
+            src_y = src_height - 1 - dst_y;
+            break;
+          case 270:
+            src_x = src_width - 1 - dst_y;
+            src_y = dst_x;
+            break;
+          default:
+            src_x = x_offset + dst_x;
+            src_y = y_offset + dst_y;
+        }
+
+        src_row = (*src.mem->access_virt_barray)(
+            (j_common_ptr)&src, src_coef[ci], src_y, 1, FALSE);
+        transform_block(src_row[0][src_x], dst_row[0][dst_x], rotate);
+      }
+    }
+  }
+
+  jpeg_mem_dest(&dst, &out_buf, &out_size);
+  jpeg_write_coefficients(&dst, dst_coef);
+
+  for (marker = src.marker_list; marker != NULL; marker = marker->next) {
+    // JFIF and Adobe markers are written by libjpeg itself, other APPn
+    // markers such as JFXX thumbnails are copied, as jcopy_markers_execute
+    // does
+    if ((dst.write_JFIF_header && marker->marker == JPEG_APP0 &&
+         has_identifier(marker, "JFIF", 5)) ||
+        (dst.write_Adobe_marker && marker->marker == JPEG_APP0 + 14 &&
+         has_identifier(marker, "Adobe", 5))) {
+      continue;
+    }
+    jpeg_write_marker(&dst, marker->marker, marker->data,
+                      marker->data_length);
+  }
+
+  jpeg_finish_compress(&dst);
+  jpeg_finish_decompress(&src);
+
+  jpeg_destroy_compress(&dst);
+  jpeg_destroy_decompress(&src);
+
+  *out = out_buf;
+  *out_len = out_size;
+  return 0;
+}
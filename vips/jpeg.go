@@ -0,0 +1,173 @@
+package vips
+
+// #cgo pkg-config: libjpeg
+// #include "jpeg.h"
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"math"
+	"unsafe"
+)
+
+func losslessTransformJpeg(buf []byte, rotate int, area image.Rectangle) ([]byte, error) {
+	incOpCounter("losslessTransformJpeg")
+	var out unsafe.Pointer
+	var outLen C.size_t
+	var errMsg [256]C.char
+
+	if err := C.lossless_transform_jpeg(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), C.int(rotate),
+		C.int(area.Min.X), C.int(area.Min.Y), C.int(area.Dx()), C.int(area.Dy()),
+		&out, &outLen, &errMsg[0], C.size_t(len(errMsg))); err != 0 {
+		return nil, errors.New(C.GoString(&errMsg[0]))
+	}
+	defer C.free(out)
+
+	return C.GoBytes(out, C.int(outLen)), nil
+}
+
+// exifOrientations lists the EXIF orientations by whether they mirror horizontally and by how many quarter turns
+// clockwise they rotate, the mirroring being applied first
+var exifOrientations = [2][4]int{{1, 6, 3, 8}, {2, 7, 4, 5}}
+
+// rotateOrientation returns the orientation that displays an image rotated clockwise by degrees the same as the
+// unrotated image with orientation, i.e. orientation composed with the inverse rotation
+func rotateOrientation(orientation int, degrees int) int {
+	for mirror, turns := range exifOrientations {
+		for turn, o := range turns {
+			if o != orientation {
+				continue
+			}
+			// mirroring reverses the direction of the rotations it's followed by
+			if mirror == 0 {
+				turn -= degrees / 90
+			} else {
+				turn += degrees / 90
+			}
+			return exifOrientations[mirror][(turn%4+4)%4]
+		}
+	}
+	return orientation
+}
+
+// rotateJpegOrientation returns a copy of a JPEG file rotated clockwise by degrees with the orientation tag of its
+// EXIF data composed with the rotation in place, files without the tag are returned unchanged
+func rotateJpegOrientation(buf []byte, degrees int) []byte {
+	out := append([]byte{}, buf...)
+
+	t, ok := newTiffReader(jpegExif(out))
+	if !ok {
+		return out
+	}
+	entries, _ := t.ifd(t.firstIFD())
+	// the entry value is a slice of out
+	if e, ok := entries[tiffTagOrientation]; ok && e.typ == tiffTypeShort && len(e.value) >= 2 {
+		orientation := int(t.order.Uint16(e.value))
+		t.order.PutUint16(e.value, uint16(rotateOrientation(orientation, degrees)))
+	}
+	return out
+}
+
+// stdLuminanceQuantTable is the luminance table of the JPEG specification (Annex K), the base of the IJG quality scale
+var stdLuminanceQuantTable = [64]int{
+	16, 11, 10, 16, 24, 40, 51, 61,
+	12, 12, 14, 19, 26, 58, 60, 55,
+	14, 13, 16, 24, 40, 57, 69, 56,
+	14, 17, 22, 29, 51, 87, 80, 62,
+	18, 22, 37, 56, 68, 109, 103, 77,
+	24, 35, 55, 64, 81, 104, 113, 92,
+	49, 64, 78, 87, 103, 121, 120, 101,
+	72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+var errNoQuantTable = errors.New("no luminance quantization table found")
+
+// estimateJpegQuality compares the luminance quantization table of a JPEG with the IJG tables to approximate
+// the quality it was encoded with on a scale of 1 to 100.
+func estimateJpegQuality(buf []byte) (int, error) {
+	table, err := jpegLuminanceQuantTable(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	sum, stdSum := 0, 0
+	for i, q := range table {
+		sum += q
+		stdSum += stdLuminanceQuantTable[i]
+	}
+	if sum == len(table) {
+		// all ones, nothing is quantized
+		return 100, nil
+	}
+
+	// IJG scales the tables by 5000/quality below 50 and by 200-2*quality above
+	scale := float64(sum) * 100 / float64(stdSum)
+	var quality float64
+	if scale <= 100 {
+		quality = (200 - scale) / 2
+	} else {
+		quality = 5000 / scale
+	}
+
+	return clampInt(int(math.Round(quality)), 1, 100), nil
+}
+
+// jpegLuminanceQuantTable returns the entries of quantization table 0, in zigzag order
+func jpegLuminanceQuantTable(buf []byte) ([]int, error) {
+	if !isJPEG(buf) {
+		return nil, ErrUnsupportedImageFormat
+	}
+
+	pos := 2
+	for pos+4 <= len(buf) {
+		if buf[pos] != 0xFF {
+			return nil, errNoQuantTable
+		}
+		marker := buf[pos+1]
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		if marker == 0xDA {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(buf[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(buf) {
+			return nil, errNoQuantTable
+		}
+
+		if marker == 0xDB {
+			// a DQT segment holds one or more tables of 64 8-bit or 16-bit entries
+			for p := pos + 4; p < end; {
+				precision, id := buf[p]>>4, buf[p]&0x0F
+				size := 64
+				if precision != 0 {
+					size = 128
+				}
+				if p+1+size > end {
+					return nil, errNoQuantTable
+				}
+
+				if id == 0 {
+					table := make([]int, 64)
+					for i := range table {
+						if precision != 0 {
+							table[i] = int(binary.BigEndian.Uint16(buf[p+1+2*i:]))
+						} else {
+							table[i] = int(buf[p+1+i])
+						}
+					}
+					return table, nil
+				}
+				p += 1 + size
+			}
+		}
+		pos = end
+	}
+
+	return nil, errNoQuantTable
+}
@@ -0,0 +1,9 @@
+// Lossless JPEG transforms on DCT coefficients, in the style of jpegtran
+// https://github.com/libjpeg-turbo/libjpeg-turbo/blob/main/libjpeg.txt
+
+#include <stdio.h>
+#include <stdlib.h>
+
+int lossless_transform_jpeg(const void *buf, size_t len, int rotate, int x,
+                            int y, int width, int height, void **out,
+                            size_t *out_len, char *err, size_t err_len);
@@ -0,0 +1,69 @@
+package vips
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRef_LosslessRotateJpeg(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-orientation-6.jpg")
+	require.NoError(t, err)
+	width, height := img.Width(), img.Height()
+
+	err = img.LosslessRotateJpeg(Angle90)
+	require.NoError(t, err)
+	assert.Equal(t, height, img.Width())
+	assert.Equal(t, width, img.Height())
+	assert.Equal(t, 1, img.Orientation())
+
+	buf, _, err := img.TranscodeWithoutRecompress(ImageTypeJPEG, false)
+	require.NoError(t, err)
+	assert.Equal(t, img.buf, buf)
+
+	err = img.Flip(DirectionHorizontal)
+	require.NoError(t, err)
+	err = img.LosslessRotateJpeg(Angle90)
+	assert.Error(t, err)
+}
+
+func TestImageRef_LosslessRotateJpeg__NotAligned(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	err = img.LosslessRotateJpeg(Angle180)
+	assert.Error(t, err)
+}
+
+func TestImageRef_LosslessCropJpeg(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-orientation-6.jpg")
+	require.NoError(t, err)
+
+	err = img.LosslessCropJpeg(image.Rect(32, 48, 133, 125))
+	require.NoError(t, err)
+	assert.Equal(t, 101, img.Width())
+	assert.Equal(t, 77, img.Height())
+
+	err = img.LosslessCropJpeg(image.Rect(3, 0, 50, 50))
+	assert.Error(t, err)
+}
+
+func Test_rotateOrientation(t *testing.T) {
+	assert.Equal(t, 1, rotateOrientation(6, 90))
+	assert.Equal(t, 1, rotateOrientation(3, 180))
+	assert.Equal(t, 1, rotateOrientation(8, 270))
+	assert.Equal(t, 8, rotateOrientation(1, 90))
+	assert.Equal(t, 6, rotateOrientation(1, 270))
+	assert.Equal(t, 2, rotateOrientation(5, 90))
+	assert.Equal(t, 4, rotateOrientation(7, 90))
+	assert.Equal(t, 2, rotateOrientation(4, 180))
+	assert.Equal(t, 0, rotateOrientation(0, 90))
+}
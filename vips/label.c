@@ -6,32 +6,331 @@ int text(VipsImage **out, const char *text, const char *font, int width,
                    "align", align, "dpi", dpi, NULL);
 }
 
-int label(VipsImage *in, VipsImage **out, LabelOptions *o) {
-  double ones[3] = {1, 1, 1};
+// render_text_image renders the text mask of o, vips_text only fits the text
+// to height when dpi isn't set
+static int render_text_image(VipsImage **out, TextOptions *o) {
+  if (o->Height > 0) {
+    return vips_text(out, o->Text, "font", o->Font, "fontfile", o->FontFile,
+                     "width", o->Width, "height", o->Height, "align",
+                     o->Align, "justify", o->Justify, "spacing", o->Spacing,
+                     NULL);
+  }
+  return vips_text(out, o->Text, "font", o->Font, "fontfile", o->FontFile,
+                   "width", o->Width, "dpi", o->DPI, "align", o->Align,
+                   "justify", o->Justify, "spacing", o->Spacing, NULL);
+}
+
+// text_image renders o->Text as an sRGB image in o->Color, the text mask
+// becoming its alpha channel
+int text_image(VipsImage **out, TextOptions *o) {
   VipsImage *base = vips_image_new();
-  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 9);
-  if (vips_text(&t[0], o->Text, "font", o->Font, "width", o->Width, "height",
-                o->Height, "align", o->Align, NULL) ||
-      vips_linear1(t[0], &t[1], o->Opacity, 0.0, NULL) ||
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 7);
+  double ones[3] = {1, 1, 1};
+
+  if (render_text_image(&t[0], o) ||
+      vips_linear1(t[0], &t[1], o->Color[3] / 255.0, 0.0, NULL) ||
       vips_cast(t[1], &t[2], VIPS_FORMAT_UCHAR, NULL) ||
-      vips_embed(t[2], &t[3], o->OffsetX, o->OffsetY, t[2]->Xsize + o->OffsetX,
-                 t[2]->Ysize + o->OffsetY, NULL)) {
+      vips_black(&t[3], t[0]->Xsize, t[0]->Ysize, "bands", 3, NULL) ||
+      vips_linear(t[3], &t[4], ones, o->Color, 3, NULL) ||
+      vips_cast(t[4], &t[5], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_copy(t[5], &t[6], "interpretation", VIPS_INTERPRETATION_sRGB,
+                NULL) ||
+      vips_bandjoin2(t[6], t[2], out, NULL)) {
     g_object_unref(base);
     return 1;
   }
-  if (vips_black(&t[4], 1, 1, NULL) ||
-      vips_linear(t[4], &t[5], ones, o->Color, 3, NULL) ||
-      vips_cast(t[5], &t[6], VIPS_FORMAT_UCHAR, NULL) ||
-      vips_copy(t[6], &t[7], "interpretation", in->Type, NULL) ||
-      vips_embed(t[7], &t[8], 0, 0, in->Xsize, in->Ysize, "extend",
-                 VIPS_EXTEND_COPY, NULL)) {
-    g_object_unref(base);
+
+  g_object_unref(base);
+  return 0;
+}
+
+static int render_text(VipsImage **out, LabelOptions *o, int height, int dpi) {
+  int width = o->WrapWidth > 0 ? o->WrapWidth : o->Width;
+
+  if (o->LineSpacing > 0) {
+    return vips_text(out, o->Text, "font", o->Font, "width", width, "height",
+                     height, "align", o->Align, "dpi", dpi, "spacing",
+                     o->LineSpacing, NULL);
+  }
+  return vips_text(out, o->Text, "font", o->Font, "width", width, "height",
+                   height, "align", o->Align, "dpi", dpi, NULL);
+}
+
+static int fits(VipsImage *text, LabelOptions *o) {
+  return (o->Width <= 0 || text->Xsize <= o->Width) &&
+         (o->Height <= 0 || text->Ysize <= o->Height);
+}
+
+// fit_text renders the text at its nominal size and, if it overflows the
+// width x height box, searches for the largest dpi at which it fits
+static int fit_text(VipsImage **out, LabelOptions *o) {
+  int lo = 1, hi = 71;
+
+  if (render_text(out, o, 0, 72)) {
+    return -1;
+  }
+  if (fits(*out, o)) {
+    return 0;
+  }
+  g_object_unref(*out);
+
+  while (lo < hi) {
+    int mid = (lo + hi + 1) / 2;
+    VipsImage *attempt;
+
+    if (render_text(&attempt, o, 0, mid)) {
+      return -1;
+    }
+    if (fits(attempt, o)) {
+      lo = mid;
+    } else {
+      hi = mid - 1;
+    }
+    g_object_unref(attempt);
+  }
+
+  return render_text(out, o, 0, lo);
+}
+
+// paint blends color into in where mask is set, mask being scaled by opacity
+static int paint(VipsObject *base, VipsImage *in, VipsImage **out,
+                 VipsImage *mask, double *color, float opacity) {
+  double ones[3] = {1, 1, 1};
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 7);
+
+  if (vips_linear1(mask, &t[0], opacity, 0.0, NULL) ||
+      vips_cast(t[0], &t[1], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_black(&t[2], 1, 1, NULL) ||
+      vips_linear(t[2], &t[3], ones, color, 3, NULL) ||
+      vips_cast(t[3], &t[4], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_copy(t[4], &t[5], "interpretation", in->Type, NULL) ||
+      vips_embed(t[5], &t[6], 0, 0, in->Xsize, in->Ysize, "extend",
+                 VIPS_EXTEND_COPY, NULL) ||
+      vips_ifthenelse(t[1], t[6], in, out, "blend", TRUE, NULL)) {
     return 1;
   }
-  if (vips_ifthenelse(t[3], t[8], in, out, "blend", TRUE, NULL)) {
+  return 0;
+}
+
+// place moves a mask rendered with padding pad to the label offset
+static int place(VipsImage *mask, VipsImage **out, LabelOptions *o, int pad,
+                 int width, int height) {
+  return vips_embed(mask, out, o->OffsetX - pad, o->OffsetY - pad, width,
+                    height, NULL);
+}
+
+// label_masks renders the masks of a label placed on a canvas the size of in,
+// appending them and their colors in the order they are painted: shadow,
+// outline and text
+static int label_masks(VipsObject *base, VipsImage *in, LabelOptions *o,
+                       VipsImage **masks, double **colors, int *n) {
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 8);
+  int rendered;
+  int pad = 0;
+
+  if (o->AutoFit) {
+    rendered = fit_text(&t[0], o);
+  } else {
+    rendered = render_text(&t[0], o, o->Height, 72);
+  }
+  if (rendered) {
+    return 1;
+  }
+
+  if (o->StrokeWidth <= 0 && !o->Shadow) {
+    if (place(t[0], &t[1], o, 0, in->Xsize, in->Ysize)) {
+      return 1;
+    }
+    masks[*n] = t[1];
+    colors[(*n)++] = o->Color;
+    return 0;
+  }
+
+  // pad the text mask so that the outline and shadow aren't clipped
+  pad = o->StrokeWidth + abs(o->ShadowOffsetX) + abs(o->ShadowOffsetY) +
+        (int)(3 * o->ShadowBlur + 1);
+  if (vips_embed(t[0], &t[1], pad, pad, t[0]->Xsize + 2 * pad,
+                 t[0]->Ysize + 2 * pad, NULL)) {
+    return 1;
+  }
+
+  // the outline is the text grown by a max filter, the shadow is cast by
+  // the outlined text
+  if (o->StrokeWidth > 0) {
+    int size = 2 * o->StrokeWidth + 1;
+    if (vips_rank(t[1], &t[2], size, size, size * size - 1, NULL)) {
+      return 1;
+    }
+  } else {
+    t[2] = t[1];
+    g_object_ref(t[2]);
+  }
+
+  if (o->Shadow) {
+    if (vips_embed(t[2], &t[3], o->ShadowOffsetX, o->ShadowOffsetY,
+                   t[2]->Xsize, t[2]->Ysize, NULL)) {
+      return 1;
+    }
+    if (o->ShadowBlur > 0) {
+      if (vips_gaussblur(t[3], &t[4], o->ShadowBlur, NULL)) {
+        return 1;
+      }
+    } else {
+      t[4] = t[3];
+      g_object_ref(t[4]);
+    }
+    if (place(t[4], &t[5], o, pad, in->Xsize, in->Ysize)) {
+      return 1;
+    }
+    masks[*n] = t[5];
+    colors[(*n)++] = o->ShadowColor;
+  }
+
+  if (o->StrokeWidth > 0) {
+    if (place(t[2], &t[6], o, pad, in->Xsize, in->Ysize)) {
+      return 1;
+    }
+    masks[*n] = t[6];
+    colors[(*n)++] = o->StrokeColor;
+  }
+
+  if (place(t[1], &t[7], o, pad, in->Xsize, in->Ysize)) {
+    return 1;
+  }
+  masks[*n] = t[7];
+  colors[(*n)++] = o->Color;
+  return 0;
+}
+
+int label(VipsImage *in, VipsImage **out, LabelOptions *o) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 4);
+  VipsImage *masks[3];
+  double *colors[3];
+  VipsImage *current = in;
+  int i, n = 0;
+
+  if (o->StrokeWidth <= 0 && !o->Shadow) {
+    // plain text, placed like it always has been
+    if (render_text(&t[0], o, o->Height, 72) ||
+        vips_embed(t[0], &t[1], o->OffsetX, o->OffsetY,
+                   t[0]->Xsize + o->OffsetX, t[0]->Ysize + o->OffsetY, NULL) ||
+        paint(VIPS_OBJECT(base), in, out, t[1], o->Color, o->Opacity)) {
+      g_object_unref(base);
+      return 1;
+    }
+    g_object_unref(base);
+    return 0;
+  }
+
+  if (label_masks(VIPS_OBJECT(base), in, o, masks, colors, &n)) {
     g_object_unref(base);
     return 1;
   }
+
+  for (i = 0; i < n; i++) {
+    if (paint(VIPS_OBJECT(base), current, i == n - 1 ? out : &t[i], masks[i],
+              colors[i], o->Opacity)) {
+      g_object_unref(base);
+      return 1;
+    }
+    current = t[i];
+  }
+
   g_object_unref(base);
   return 0;
 }
+
+// layer turns a mask into an sRGB image of color with the mask, scaled by
+// opacity, as its alpha channel
+static int layer(VipsObject *base, VipsImage *mask, VipsImage **out,
+                 double *color, float opacity) {
+  double ones[3] = {1, 1, 1};
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 7);
+
+  if (vips_linear1(mask, &t[0], opacity, 0.0, NULL) ||
+      vips_cast(t[0], &t[1], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_black(&t[2], 1, 1, NULL) ||
+      vips_linear(t[2], &t[3], ones, color, 3, NULL) ||
+      vips_cast(t[3], &t[4], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_embed(t[4], &t[5], 0, 0, mask->Xsize, mask->Ysize, "extend",
+                 VIPS_EXTEND_COPY, NULL) ||
+      vips_bandjoin2(t[5], t[1], &t[6], NULL) ||
+      vips_copy(t[6], out, "interpretation", VIPS_INTERPRETATION_sRGB,
+                NULL)) {
+    return 1;
+  }
+  return 0;
+}
+
+// composite_labels turns the masks of the n labels into layers and blends
+// them over in with a single composite, layers, masks and colors having room
+// for three per label and modes for one less than layers
+static int composite_labels(VipsObject *base, VipsImage *in, VipsImage **out,
+                            LabelOptions *o, int n, VipsImage **layers,
+                            VipsImage **masks, double **colors, int *modes) {
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 3);
+  VipsImage *current;
+  int i, j, count = 0;
+
+  for (i = 0; i < n; i++) {
+    int first = count;
+
+    if (label_masks(base, in, &o[i], masks, colors, &count)) {
+      return 1;
+    }
+    for (j = first; j < count; j++) {
+      if (layer(base, masks[j], &layers[j + 1], colors[j], o[i].Opacity)) {
+        return 1;
+      }
+    }
+  }
+
+  if (count == 0) {
+    return vips_copy(in, out, NULL);
+  }
+
+  layers[0] = in;
+  for (i = 0; i < count; i++) {
+    modes[i] = VIPS_BLEND_MODE_OVER;
+  }
+  if (vips_composite(layers, &t[0], count + 1, modes, NULL)) {
+    return 1;
+  }
+  current = t[0];
+
+  // composite adds an alpha channel and works in sRGB, restore the layout
+  if (!vips_image_hasalpha(in)) {
+    if (vips_extract_band(current, &t[1], 0, "n", current->Bands - 1, NULL)) {
+      return 1;
+    }
+    current = t[1];
+  }
+  if (current->Type != in->Type && vips_colourspace_issupported(in)) {
+    if (vips_colourspace(current, &t[2], in->Type, NULL)) {
+      return 1;
+    }
+    current = t[2];
+  }
+  return vips_cast(current, out, in->BandFmt, NULL);
+}
+
+// labels draws n labels in a single pass: the shadow, outline and text of
+// every label become layers over in, blended together by one composite. The
+// result keeps the bands, format and interpretation of in.
+int labels(VipsImage *in, VipsImage **out, LabelOptions *o, int n) {
+  VipsImage *base = vips_image_new();
+  VipsImage **layers = g_new(VipsImage *, 3 * n + 1);
+  VipsImage **masks = g_new(VipsImage *, 3 * n + 1);
+  double **colors = g_new(double *, 3 * n + 1);
+  int *modes = g_new(int, 3 * n + 1);
+  int code = composite_labels(VIPS_OBJECT(base), in, out, o, n, layers, masks,
+                              colors, modes);
+
+  g_free(modes);
+  g_free(colors);
+  g_free(masks);
+  g_free(layers);
+  g_object_unref(base);
+  return code;
+}
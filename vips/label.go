@@ -2,7 +2,12 @@ package vips
 
 // #include "label.h"
 import "C"
-import "unsafe"
+import (
+	"errors"
+	"fmt"
+	"html"
+	"unsafe"
+)
 
 // Align represents VIPS_ALIGN
 type Align int
@@ -28,6 +33,26 @@ type LabelParams struct {
 	Opacity   float32
 	Color     Color
 	Alignment Align
+
+	// AutoFit keeps the font at its nominal size and only shrinks it until the text fits Width x Height.
+	// Without it, libvips scales the font up or down to fill the box whenever Height is set.
+	AutoFit bool
+	// WrapWidth wraps lines at this width instead of Width, e.g. to leave room inside the box
+	WrapWidth Scalar
+	// LineSpacing is the spacing between lines in points, 0 for the font default
+	LineSpacing int
+	// LetterSpacing is the extra spacing between letters in points
+	LetterSpacing float64
+	// StrokeWidth is the width in pixels of an outline drawn around the text with StrokeColor
+	StrokeWidth int
+	StrokeColor Color
+	// Shadow draws a shadow of the text, offset by ShadowOffsetX and ShadowOffsetY pixels
+	// and blurred by ShadowBlur, the sigma of a gaussian blur
+	Shadow        bool
+	ShadowOffsetX int
+	ShadowOffsetY int
+	ShadowBlur    float64
+	ShadowColor   Color
 }
 
 type vipsLabelOptions struct {
@@ -42,17 +67,63 @@ type vipsLabelOptions struct {
 	Margin    C.int
 	Opacity   C.float
 	Color     [3]C.double
+
+	AutoFit       C.int
+	WrapWidth     C.int
+	LineSpacing   C.int
+	StrokeWidth   C.int
+	StrokeColor   [3]C.double
+	Shadow        C.int
+	ShadowOffsetX C.int
+	ShadowOffsetY C.int
+	ShadowBlur    C.double
+	ShadowColor   [3]C.double
 }
 
 func labelImage(in *C.VipsImage, params *LabelParams) (*C.VipsImage, error) {
 	incOpCounter("label")
 	var out *C.VipsImage
 
-	text := C.CString(params.Text)
-	defer freeCString(text)
+	opts := createLabelOptions(in, params)
+	defer freeLabelOptions(&opts)
+
+	// todo: release inline pointer?
+	err := C.label(in, &out, (*C.LabelOptions)(unsafe.Pointer(&opts)))
+	if err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func labelImages(in *C.VipsImage, params []*LabelParams) (*C.VipsImage, error) {
+	incOpCounter("labels")
+	var out *C.VipsImage
+
+	var cOpts *C.LabelOptions
+	opts := make([]vipsLabelOptions, len(params))
+	for i, p := range params {
+		opts[i] = createLabelOptions(in, p)
+		defer freeLabelOptions(&opts[i])
+	}
+	if len(opts) > 0 {
+		cOpts = (*C.LabelOptions)(unsafe.Pointer(&opts[0]))
+	}
+
+	err := C.labels(in, &out, cOpts, C.int(len(opts)))
+	if err != 0 {
+		return nil, handleImageError(out)
+	}
 
-	font := C.CString(params.Font)
-	defer freeCString(font)
+	return out, nil
+}
+
+func createLabelOptions(in *C.VipsImage, params *LabelParams) vipsLabelOptions {
+	markup := params.Text
+	if params.LetterSpacing != 0 {
+		// Pango expresses letter spacing in 1024ths of a point
+		markup = fmt.Sprintf(`<span letter_spacing="%d">%s</span>`, int(params.LetterSpacing*1024), markup)
+	}
 
 	// todo: release color?
 	color := [3]C.double{C.double(params.Color.R), C.double(params.Color.G), C.double(params.Color.B)}
@@ -62,9 +133,9 @@ func labelImage(in *C.VipsImage, params *LabelParams) (*C.VipsImage, error) {
 	offsetX := params.OffsetX.GetRounded(int(in.Xsize))
 	offsetY := params.OffsetY.GetRounded(int(in.Ysize))
 
-	opts := vipsLabelOptions{
-		Text:      text,
-		Font:      font,
+	return vipsLabelOptions{
+		Text:      C.CString(markup),
+		Font:      C.CString(params.Font),
 		Width:     C.int(w),
 		Height:    C.int(h),
 		OffsetX:   C.int(offsetX),
@@ -72,11 +143,130 @@ func labelImage(in *C.VipsImage, params *LabelParams) (*C.VipsImage, error) {
 		Alignment: C.VipsAlign(params.Alignment),
 		Opacity:   C.float(params.Opacity),
 		Color:     color,
+
+		AutoFit:       C.int(boolToInt(params.AutoFit)),
+		WrapWidth:     C.int(params.WrapWidth.GetRounded(int(in.Xsize))),
+		LineSpacing:   C.int(params.LineSpacing),
+		StrokeWidth:   C.int(params.StrokeWidth),
+		StrokeColor:   colorToArray(params.StrokeColor),
+		Shadow:        C.int(boolToInt(params.Shadow)),
+		ShadowOffsetX: C.int(params.ShadowOffsetX),
+		ShadowOffsetY: C.int(params.ShadowOffsetY),
+		ShadowBlur:    C.double(params.ShadowBlur),
+		ShadowColor:   colorToArray(params.ShadowColor),
 	}
+}
 
-	// todo: release inline pointer?
-	err := C.label(in, &out, (*C.LabelOptions)(unsafe.Pointer(&opts)))
-	if err != 0 {
+func freeLabelOptions(opts *vipsLabelOptions) {
+	freeCString(opts.Text)
+	freeCString(opts.Font)
+}
+
+func colorToArray(c Color) [3]C.double {
+	return [3]C.double{C.double(c.R), C.double(c.G), C.double(c.B)}
+}
+
+// TextParams are options for rendering text with Text
+type TextParams struct {
+	Text string
+	// Markup interprets Text as Pango markup, e.g. `<b>bold</b> and <i>italic</i>`, otherwise it is rendered as is
+	Markup bool
+	// Font is a Pango font description, e.g. "sans bold 12", DefaultFont when empty
+	Font string
+	// FontFile is a font file to load, Font then has to name a font it contains
+	FontFile string
+	// Width wraps lines at this width in pixels, 0 for no wrapping
+	Width int
+	// Height makes the text fill the Width x Height box by picking the DPI, overriding DPI
+	Height int
+	// DPI is the resolution the font size is rendered at, 72 when 0
+	DPI int
+	// Alignment aligns wrapped lines, Justify stretches them to the full Width
+	Alignment Align
+	Justify   bool
+	// Spacing is the spacing between lines in points, 0 for the font default
+	Spacing int
+	// Color is the text color, opaque black when unset
+	Color *ColorRGBA
+}
+
+// ErrEmptyText is returned by Text when there is nothing to render
+var ErrEmptyText = errors.New("text is empty")
+
+type vipsTextOptions struct {
+	Text      *C.char
+	Font      *C.char
+	FontFile  *C.char
+	Width     C.int
+	Height    C.int
+	DPI       C.int
+	Alignment C.VipsAlign
+	Justify   C.int
+	Spacing   C.int
+	Color     [4]C.double
+}
+
+// Text renders text into a new sRGB image with an alpha channel, sized to fit the text, for compositing it
+// onto other images. See https://libvips.github.io/libvips/API/current/libvips-create.html#vips-text
+func Text(params *TextParams) (*ImageRef, error) {
+	startupIfNeeded()
+
+	if params == nil || params.Text == "" {
+		return nil, ErrEmptyText
+	}
+
+	out, err := vipsText(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return newImageRef(out, ImageTypePNG, ImageTypeUnknown, nil), nil
+}
+
+func vipsText(params *TextParams) (*C.VipsImage, error) {
+	incOpCounter("text")
+	var out *C.VipsImage
+
+	text := params.Text
+	if !params.Markup {
+		text = html.EscapeString(text)
+	}
+
+	font := params.Font
+	if font == "" {
+		font = DefaultFont
+	}
+
+	dpi := params.DPI
+	if dpi == 0 {
+		dpi = 72
+	}
+
+	color := ColorRGBA{A: 255}
+	if params.Color != nil {
+		color = *params.Color
+	}
+
+	opts := vipsTextOptions{
+		Text:      C.CString(text),
+		Font:      C.CString(font),
+		Width:     C.int(params.Width),
+		Height:    C.int(params.Height),
+		DPI:       C.int(dpi),
+		Alignment: C.VipsAlign(params.Alignment),
+		Justify:   C.int(boolToInt(params.Justify)),
+		Spacing:   C.int(params.Spacing),
+		Color:     [4]C.double{C.double(color.R), C.double(color.G), C.double(color.B), C.double(color.A)},
+	}
+	defer freeCString(opts.Text)
+	defer freeCString(opts.Font)
+
+	if params.FontFile != "" {
+		opts.FontFile = C.CString(params.FontFile)
+		defer freeCString(opts.FontFile)
+	}
+
+	if err := C.text_image(&out, (*C.TextOptions)(unsafe.Pointer(&opts))); err != 0 {
 		return nil, handleImageError(out)
 	}
 
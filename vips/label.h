@@ -13,9 +13,35 @@ typedef struct {
   int Margin;
   float Opacity;
   double Color[3];
+  int AutoFit;
+  int WrapWidth;
+  int LineSpacing;
+  int StrokeWidth;
+  double StrokeColor[3];
+  int Shadow;
+  int ShadowOffsetX;
+  int ShadowOffsetY;
+  double ShadowBlur;
+  double ShadowColor[3];
 } LabelOptions;
 
 int label(VipsImage *in, VipsImage **out, LabelOptions *o);
+int labels(VipsImage *in, VipsImage **out, LabelOptions *o, int n);
 
 int text(VipsImage **out, const char *text, const char *font, int width,
          int height, VipsAlign align, int dpi);
+
+typedef struct {
+  const char *Text;
+  const char *Font;
+  const char *FontFile;
+  int Width;
+  int Height;
+  int DPI;
+  VipsAlign Align;
+  int Justify;
+  int Spacing;
+  double Color[4];
+} TextOptions;
+
+int text_image(VipsImage **out, TextOptions *o);
@@ -35,6 +35,21 @@ func fromGboolean(b C.gboolean) bool {
 	return b != 0
 }
 
+// bytesAsFloat64s views native endian doubles, e.g. the pixels of a double image, as a slice without copying them,
+// whatever their number
+func bytesAsFloat64s(buf []byte) []float64 {
+	if len(buf) < 8 {
+		return nil
+	}
+
+	var data []float64
+	sh := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	sh.Data = uintptr(unsafe.Pointer(&buf[0]))
+	sh.Len = len(buf) / 8
+	sh.Cap = len(buf) / 8
+	return data
+}
+
 func fromCArrayInt(out *C.int, n int) []int {
 	var result = make([]int, n)
 	var data []C.int
@@ -47,3 +62,16 @@ func fromCArrayInt(out *C.int, n int) []int {
 	}
 	return result
 }
+
+func fromCArrayDouble(out *C.double, n int) []float64 {
+	var result = make([]float64, n)
+	var data []C.double
+	sh := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	sh.Data = uintptr(unsafe.Pointer(out))
+	sh.Len = n
+	sh.Cap = n
+	for i := range data {
+		result[i] = float64(data[i])
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package vips
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaskAlpha multiplies the alpha channel of the image by mask, so that the image is transparent where the mask is
+// black and unchanged where it is white, e.g. to cut an image to a shape. The first band of the mask is used, with
+// the full range of its band format. An alpha channel is added to images without one. The mask must have the size
+// of the image or, for multi-page images, of a page, in which case it is applied to every page.
+func (r *ImageRef) MaskAlpha(mask *ImageRef) error {
+	if mask.Width() != r.Width() || (mask.Height() != r.Height() && mask.Height() != r.PageHeight()) {
+		return fmt.Errorf("mask size %dx%d does not match image size %dx%d", mask.Width(), mask.Height(), r.Width(), r.PageHeight())
+	}
+
+	in := mask.image
+	if pages := r.Height() / mask.Height(); pages > 1 {
+		replicated, err := vipsReplicate(mask.image, 1, pages)
+		if err != nil {
+			return err
+		}
+		defer clearImage(replicated)
+		in = replicated
+	}
+
+	out, err := vipsMaskAlpha(r.image, in)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// RoundCorners makes the corners of the image transparent outside of antialiased quarter circles, e.g. for avatars
+// and thumbnails. One radius rounds all corners the same way, four set the top left, top right, bottom right and
+// bottom left corners in this order; radii are limited to half the size of the image. An alpha channel is added to
+// images without one. Every page of multi-page images is rounded.
+func (r *ImageRef) RoundCorners(radius ...int) error {
+	var radii [4]int
+	switch len(radius) {
+	case 1:
+		radii = [4]int{radius[0], radius[0], radius[0], radius[0]}
+	case 4:
+		copy(radii[:], radius)
+	default:
+		return fmt.Errorf("expected 1 or 4 corner radii, got %d", len(radius))
+	}
+
+	width, height := r.Width(), r.PageHeight()
+	for i, rad := range radii {
+		if rad < 0 {
+			return fmt.Errorf("invalid corner radius %d", rad)
+		}
+		radii[i] = minInt(rad, minInt(width, height)/2)
+	}
+
+	buf := roundedCornersMask(width, height, radii)
+	mask, err := vipsImageFromMemory(buf, width, height, 1, InterpretationBW)
+	if err != nil {
+		return err
+	}
+	maskRef := newImageRef(mask, ImageTypeUnknown, ImageTypeUnknown, nil)
+	defer maskRef.Close()
+
+	return r.MaskAlpha(maskRef)
+}
+
+// roundedCornersMask returns an 8-bit mask of a rounded rectangle with the top left, top right, bottom right and
+// bottom left radii, antialiased by the distance of the pixel centers to the arcs
+func roundedCornersMask(width, height int, radii [4]int) []byte {
+	mask := make([]byte, width*height)
+	for i := range mask {
+		mask[i] = 255
+	}
+
+	for corner, rad := range radii {
+		if rad == 0 {
+			continue
+		}
+		for y := 0; y < rad; y++ {
+			for x := 0; x < rad; x++ {
+				// distance of the pixel center to the center of the arc, measured in the top left corner
+				d := math.Hypot(float64(rad)-float64(x)-0.5, float64(rad)-float64(y)-0.5)
+				coverage := math.Max(0, math.Min(1, float64(rad)-d+0.5))
+
+				// mirror the position to the corner
+				mx, my := x, y
+				if corner == 1 || corner == 2 {
+					mx = width - 1 - x
+				}
+				if corner == 2 || corner == 3 {
+					my = height - 1 - y
+				}
+				mask[my*width+mx] = uint8(roundFloat(coverage * 255))
+			}
+		}
+	}
+	return mask
+}
@@ -0,0 +1,75 @@
+package vips
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRef_RoundCorners(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	before, err := img.GetPoint(50, 50)
+	require.NoError(t, err)
+
+	err = img.RoundCorners(20)
+	require.NoError(t, err)
+	assert.Equal(t, 4, img.Bands())
+
+	points, err := img.GetPoints([]image.Point{{0, 0}, {99, 0}, {99, 99}, {0, 99}, {50, 50}, {50, 0}})
+	require.NoError(t, err)
+	for _, p := range points[:4] {
+		assert.Equal(t, 0.0, p[3])
+	}
+	assert.Equal(t, append(before, 255), points[4])
+	assert.Equal(t, 255.0, points[5][3])
+
+	assert.Error(t, img.RoundCorners(1, 2))
+	assert.Error(t, img.RoundCorners(-1))
+}
+
+func TestImageRef_MaskAlpha(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-8bit+alpha.png")
+	require.NoError(t, err)
+
+	mask, err := Black(img.Width(), img.Height())
+	require.NoError(t, err)
+	require.NoError(t, mask.Linear([]float64{1}, []float64{128}))
+	require.NoError(t, mask.Cast(BandFormatUchar))
+
+	before, err := img.GetPoint(10, 10)
+	require.NoError(t, err)
+
+	err = img.MaskAlpha(mask)
+	require.NoError(t, err)
+
+	after, err := img.GetPoint(10, 10)
+	require.NoError(t, err)
+	assert.Equal(t, before[:3], after[:3])
+	assert.InDelta(t, before[3]*128/255, after[3], 0.5)
+
+	small, err := Black(10, 10)
+	require.NoError(t, err)
+	assert.Error(t, img.MaskAlpha(small))
+}
+
+func TestRoundedCornersMask(t *testing.T) {
+	mask := roundedCornersMask(10, 8, [4]int{4, 0, 4, 0})
+
+	assert.Equal(t, uint8(0), mask[0])
+	assert.Equal(t, uint8(255), mask[9])
+	assert.Equal(t, uint8(0), mask[7*10+9])
+	assert.Equal(t, uint8(255), mask[7*10])
+	assert.Equal(t, uint8(255), mask[4*10+5])
+
+	// the arcs are symmetric and the coverage grows towards the inside
+	assert.Equal(t, mask[1*10+3], mask[3*10+1])
+	assert.Less(t, mask[1*10+1], mask[2*10+2])
+}
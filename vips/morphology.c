@@ -4,3 +4,29 @@ int rank(VipsImage *in, VipsImage **out, int width, int height, int index) {
   return vips_rank(in, out, width, height, index, NULL);
 }
 
+int morph(VipsImage *in, VipsImage **out, double *mask, int width, int height,
+          VipsOperationMorphology op) {
+  VipsImage *m = vips_image_new_matrix_from_array(width, height, mask,
+                                                  width * height);
+  int err;
+
+  if (!m) {
+    return -1;
+  }
+
+  err = vips_morph(in, out, m, op, NULL);
+  g_object_unref(m);
+  return err;
+}
+
+int fill_nearest(VipsImage *in, VipsImage **out, VipsImage **distance) {
+#if (VIPS_MAJOR_VERSION > 8) || (VIPS_MINOR_VERSION >= 13)
+  if (distance) {
+    return vips_fill_nearest(in, out, "distance", distance, NULL);
+  }
+  return vips_fill_nearest(in, out, NULL);
+#else
+  vips_error("fill_nearest", "%s", "requires libvips 8.13+");
+  return -1;
+#endif
+}
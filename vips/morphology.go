@@ -2,6 +2,73 @@ package vips
 
 // #include "morphology.h"
 import "C"
+import "fmt"
+
+// Morphology represents VIPS_OPERATION_MORPHOLOGY type
+type Morphology int
+
+// Morphology enum
+const (
+	MorphologyErode  Morphology = C.VIPS_OPERATION_MORPHOLOGY_ERODE
+	MorphologyDilate Morphology = C.VIPS_OPERATION_MORPHOLOGY_DILATE
+)
+
+// Values of the pixels of a StructuringElement
+const (
+	ElementClear  = 0
+	ElementIgnore = 128
+	ElementSet    = 255
+)
+
+// StructuringElement is the mask of a morphological operation, given row by row. When eroding, a pixel is kept set
+// only if the image pixels under the ElementSet pixels of the element are all set and those under its ElementClear
+// pixels are all clear; when dilating, a pixel is set if any of them match. ElementIgnore pixels match anything.
+type StructuringElement [][]int
+
+// NewSquareElement creates a size by size structuring element of set pixels
+func NewSquareElement(size int) StructuringElement {
+	element := make(StructuringElement, size)
+	for y := range element {
+		element[y] = make([]int, size)
+		for x := range element[y] {
+			element[y][x] = ElementSet
+		}
+	}
+	return element
+}
+
+// NewDiskElement creates a structuring element of set pixels within radius of its center, ignoring the others
+func NewDiskElement(radius int) StructuringElement {
+	size := 2*radius + 1
+	element := make(StructuringElement, size)
+	for y := range element {
+		element[y] = make([]int, size)
+		for x := range element[y] {
+			dx, dy := x-radius, y-radius
+			if dx*dx+dy*dy <= radius*radius {
+				element[y][x] = ElementSet
+			} else {
+				element[y][x] = ElementIgnore
+			}
+		}
+	}
+	return element
+}
+
+// values returns the pixels of the element row by row, checking that it is a non-empty rectangle of valid values
+func (e StructuringElement) values() ([]float64, int, int, error) {
+	kernel := make([][]float64, len(e))
+	for y, row := range e {
+		kernel[y] = make([]float64, len(row))
+		for x, v := range row {
+			if v != ElementClear && v != ElementIgnore && v != ElementSet {
+				return nil, 0, 0, fmt.Errorf("invalid structuring element value %d", v)
+			}
+			kernel[y][x] = float64(v)
+		}
+	}
+	return flattenKernel(kernel)
+}
 
 // https://libvips.github.io/libvips/API/current/libvips-morphology.html#vips-rank
 func vipsRank(in *C.VipsImage, width int, height int, index int) (*C.VipsImage, error) {
@@ -15,3 +82,38 @@ func vipsRank(in *C.VipsImage, width int, height int, index int) (*C.VipsImage,
 
 	return out, nil
 }
+
+// https://libvips.github.io/libvips/API/current/libvips-morphology.html#vips-morph
+func vipsMorph(in *C.VipsImage, element StructuringElement, op Morphology) (*C.VipsImage, error) {
+	incOpCounter("morph")
+	var out *C.VipsImage
+
+	values, width, height, err := element.values()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := C.morph(in, &out, (*C.double)(&values[0]), C.int(width), C.int(height), C.VipsOperationMorphology(op)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-morphology.html#vips-fill-nearest
+func vipsFillNearest(in *C.VipsImage, withDistance bool) (*C.VipsImage, *C.VipsImage, error) {
+	incOpCounter("fillNearest")
+	var out, distance *C.VipsImage
+
+	distancePtr := &distance
+	if !withDistance {
+		distancePtr = nil
+	}
+
+	if err := C.fill_nearest(in, &out, distancePtr); err != 0 {
+		clearImage(distance)
+		return nil, nil, handleImageError(out)
+	}
+
+	return out, distance, nil
+}
@@ -4,3 +4,6 @@
 #include <vips/vips.h>
 
 int rank(VipsImage *in, VipsImage **out, int width, int height, int index);
+int morph(VipsImage *in, VipsImage **out, double *mask, int width, int height,
+          VipsOperationMorphology op);
+int fill_nearest(VipsImage *in, VipsImage **out, VipsImage **distance);
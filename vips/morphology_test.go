@@ -0,0 +1,99 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRef_Morph(t *testing.T) {
+	Startup(nil)
+
+	square := func() *ImageRef {
+		img, err := Black(6, 6)
+		require.NoError(t, err)
+		require.NoError(t, img.Linear([]float64{1}, []float64{255}))
+		require.NoError(t, img.Cast(BandFormatUchar))
+		require.NoError(t, img.Embed(7, 7, 20, 20, ExtendBlack))
+		return img
+	}
+	setPixels := func(img *ImageRef) int {
+		avg, err := img.Average()
+		require.NoError(t, err)
+		return int(avg*400/255 + 0.5)
+	}
+
+	tests := []struct {
+		name     string
+		op       func(img *ImageRef) error
+		expected int
+	}{
+		{"erode", func(img *ImageRef) error { return img.Erode(NewSquareElement(3)) }, 16},
+		{"dilate", func(img *ImageRef) error { return img.Dilate(NewSquareElement(3)) }, 64},
+		{"open", func(img *ImageRef) error { return img.MorphOpen(NewSquareElement(3)) }, 36},
+		{"close", func(img *ImageRef) error { return img.MorphClose(NewSquareElement(3)) }, 36},
+		{"open larger", func(img *ImageRef) error { return img.MorphOpen(NewSquareElement(7)) }, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := square()
+			defer img.Close()
+
+			require.NoError(t, tt.op(img))
+			assert.Equal(t, tt.expected, setPixels(img))
+		})
+	}
+}
+
+func TestImageRef_FillNearest(t *testing.T) {
+	if MajorVersion == 8 && MinorVersion < 13 {
+		t.Skip("fill nearest is only supported in vips 8.13+")
+	}
+	Startup(nil)
+
+	img, err := Black(5, 10)
+	require.NoError(t, err)
+	require.NoError(t, img.Linear([]float64{1}, []float64{50}))
+	require.NoError(t, img.Cast(BandFormatUchar))
+	empty, err := Black(5, 10)
+	require.NoError(t, err)
+	require.NoError(t, img.Join(empty, DirectionHorizontal))
+
+	distance, err := img.FillNearestWithDistance()
+	require.NoError(t, err)
+	defer distance.Close()
+
+	avg, err := img.Average()
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, avg)
+
+	point, err := distance.GetPoint(9, 5)
+	require.NoError(t, err)
+	assert.InDelta(t, 5.0, point[0], 0.001)
+	point, err = distance.GetPoint(2, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, point[0])
+}
+
+func TestStructuringElement(t *testing.T) {
+	assert.Equal(t, StructuringElement{
+		{ElementIgnore, ElementSet, ElementIgnore},
+		{ElementSet, ElementSet, ElementSet},
+		{ElementIgnore, ElementSet, ElementIgnore},
+	}, NewDiskElement(1))
+
+	values, width, height, err := NewSquareElement(2).values()
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 255, 255, 255}, values)
+	assert.Equal(t, 2, width)
+	assert.Equal(t, 2, height)
+
+	_, _, _, err = StructuringElement{}.values()
+	assert.Error(t, err)
+	_, _, _, err = StructuringElement{{255, 255}, {255}}.values()
+	assert.Error(t, err)
+	_, _, _, err = StructuringElement{{1}}.values()
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package vips
+
+import "sync"
+
+// ImagePool tracks images so that they are all closed at once, e.g. at the end of a request, instead of closing each
+// one or waiting for the garbage collector. It is safe for concurrent use.
+type ImagePool struct {
+	lock   sync.Mutex
+	images []*ImageRef
+	closed bool
+}
+
+// NewImagePool creates an empty image pool
+func NewImagePool() *ImagePool {
+	return &ImagePool{}
+}
+
+// Add tracks an image created elsewhere and returns it. Images added to a closed pool are closed right away.
+func (p *ImagePool) Add(img *ImageRef) *ImageRef {
+	if img == nil {
+		return nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		img.Close()
+		return img
+	}
+
+	p.images = append(p.images, img)
+	return img
+}
+
+// Track adds the image returned by a constructor unless it failed, e.g. pool.Track(vips.Black(100, 100))
+func (p *ImagePool) Track(img *ImageRef, err error) (*ImageRef, error) {
+	if err != nil {
+		return nil, err
+	}
+	return p.Add(img), nil
+}
+
+// NewImageFromFile loads an image from file into the pool
+func (p *ImagePool) NewImageFromFile(file string) (*ImageRef, error) {
+	return p.Track(NewImageFromFile(file))
+}
+
+// LoadImageFromFile loads an image from file with the given import parameters into the pool
+func (p *ImagePool) LoadImageFromFile(file string, params *ImportParams) (*ImageRef, error) {
+	return p.Track(LoadImageFromFile(file, params))
+}
+
+// NewImageFromBuffer loads an image buffer into the pool
+func (p *ImagePool) NewImageFromBuffer(buf []byte) (*ImageRef, error) {
+	return p.Track(NewImageFromBuffer(buf))
+}
+
+// LoadImageFromBuffer loads an image buffer with the given import parameters into the pool
+func (p *ImagePool) LoadImageFromBuffer(buf []byte, params *ImportParams) (*ImageRef, error) {
+	return p.Track(LoadImageFromBuffer(buf, params))
+}
+
+// LoadThumbnailFromFile loads a thumbnail of an image file into the pool, see LoadThumbnailFromFile
+func (p *ImagePool) LoadThumbnailFromFile(file string, width, height int, crop Interesting, size Size, params *ImportParams) (*ImageRef, error) {
+	return p.Track(LoadThumbnailFromFile(file, width, height, crop, size, params))
+}
+
+// LoadThumbnailFromBuffer loads a thumbnail of an image buffer into the pool, see LoadThumbnailFromBuffer
+func (p *ImagePool) LoadThumbnailFromBuffer(buf []byte, width, height int, crop Interesting, size Size, params *ImportParams) (*ImageRef, error) {
+	return p.Track(LoadThumbnailFromBuffer(buf, width, height, crop, size, params))
+}
+
+// Copy copies an image into the pool
+func (p *ImagePool) Copy(img *ImageRef) (*ImageRef, error) {
+	return p.Track(img.Copy())
+}
+
+// Len returns the number of images tracked by the pool
+func (p *ImagePool) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.images)
+}
+
+// Close closes every image of the pool. Images closed before are skipped, and the pool can't be used afterwards.
+func (p *ImagePool) Close() {
+	p.lock.Lock()
+	images := p.images
+	p.images = nil
+	p.closed = true
+	p.lock.Unlock()
+
+	for _, img := range images {
+		img.Close()
+	}
+}
@@ -0,0 +1,39 @@
+package vips
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImagePool(t *testing.T) {
+	Startup(nil)
+
+	pool := NewImagePool()
+
+	img, err := pool.NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+	copied, err := pool.Copy(img)
+	require.NoError(t, err)
+	black, err := pool.Track(Black(10, 10))
+	require.NoError(t, err)
+
+	_, err = pool.Track(nil, errors.New("load failed"))
+	assert.Error(t, err)
+	assert.Equal(t, 3, pool.Len())
+
+	// closing an image of the pool early is fine
+	black.Close()
+
+	pool.Close()
+	assert.Equal(t, 0, pool.Len())
+	assert.Nil(t, img.image)
+	assert.Nil(t, copied.image)
+
+	late, err := Black(10, 10)
+	require.NoError(t, err)
+	pool.Add(late)
+	assert.Nil(t, late.image)
+}
@@ -0,0 +1,304 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// TIFF tags of camera RAW files and their embedded previews
+const (
+	tiffTagRW2JpgFromRaw = 0x002E
+	tiffTagCompression   = 0x0103
+	tiffTagPhotometric   = 0x0106
+	tiffTagMake          = 0x010F
+	tiffTagStripOffsets  = 0x0111
+	tiffTagOrientation   = 0x0112
+	tiffTagStripCounts   = 0x0117
+	tiffTagSubIFDs       = 0x014A
+	tiffTagJPEGOffset    = 0x0201
+	tiffTagJPEGLength    = 0x0202
+	tiffTagDNGVersion    = 0xC612
+)
+
+// TIFF field types holding offsets
+const (
+	tiffTypeShort = 3
+	tiffTypeLong  = 4
+	tiffTypeIFD   = 13
+)
+
+// tiffMaxIFDs limits the IFDs read, guarding against loops in malformed files
+const tiffMaxIFDs = 16
+
+// rafPreviewOffset is the position of the offset and length of the JPEG preview in the header of RAF files
+const rafPreviewOffset = 84
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// tiffEntry is a tag of a TIFF IFD with its raw value bytes
+type tiffEntry struct {
+	typ   uint16
+	count int
+	value []byte
+}
+
+// tiffReader reads IFDs of TIFF structured data, e.g. an EXIF block or a TIFF based camera RAW file
+type tiffReader struct {
+	buf   []byte
+	order binary.ByteOrder
+}
+
+// newTiffReader reads the byte order of the header, not checking the magic number which some RAW formats change
+func newTiffReader(buf []byte) (*tiffReader, bool) {
+	if len(buf) < 8 {
+		return nil, false
+	}
+
+	switch string(buf[:2]) {
+	case "II":
+		return &tiffReader{buf: buf, order: binary.LittleEndian}, true
+	case "MM":
+		return &tiffReader{buf: buf, order: binary.BigEndian}, true
+	default:
+		return nil, false
+	}
+}
+
+// firstIFD returns the offset of the first IFD
+func (t *tiffReader) firstIFD() int {
+	return int(t.order.Uint32(t.buf[4:8]))
+}
+
+// ifd reads the entries of the IFD at offset and the offset of the next IFD, 0 for the last one
+func (t *tiffReader) ifd(offset int) (map[uint16]tiffEntry, int) {
+	if offset < 8 || offset+2 > len(t.buf) {
+		return nil, 0
+	}
+
+	count := int(t.order.Uint16(t.buf[offset : offset+2]))
+	entries := make(map[uint16]tiffEntry, count)
+	for i := 0; i < count; i++ {
+		e := offset + 2 + 12*i
+		if e+12 > len(t.buf) {
+			return entries, 0
+		}
+
+		entry := tiffEntry{
+			typ:   t.order.Uint16(t.buf[e+2 : e+4]),
+			count: int(t.order.Uint32(t.buf[e+4 : e+8])),
+		}
+		size := entry.count * tiffTypeSize(entry.typ)
+		value := e + 8
+		if size > 4 {
+			value = int(t.order.Uint32(t.buf[e+8 : e+12]))
+		}
+		if size >= 0 && value >= 0 && value+size <= len(t.buf) {
+			entry.value = t.buf[value : value+size]
+		}
+		entries[t.order.Uint16(t.buf[e:e+2])] = entry
+	}
+
+	next := offset + 2 + 12*count
+	if next+4 > len(t.buf) {
+		return entries, 0
+	}
+	return entries, int(t.order.Uint32(t.buf[next : next+4]))
+}
+
+// uints returns the values of a SHORT, LONG or IFD entry
+func (t *tiffReader) uints(e tiffEntry) []int {
+	var values []int
+	switch e.typ {
+	case tiffTypeShort:
+		for i := 0; i+2 <= len(e.value); i += 2 {
+			values = append(values, int(t.order.Uint16(e.value[i:i+2])))
+		}
+	case tiffTypeLong, tiffTypeIFD:
+		for i := 0; i+4 <= len(e.value); i += 4 {
+			values = append(values, int(t.order.Uint32(e.value[i:i+4])))
+		}
+	}
+	return values
+}
+
+// intValue returns the first value of a SHORT, LONG or IFD entry
+func (t *tiffReader) intValue(entries map[uint16]tiffEntry, tag uint16) (int, bool) {
+	e, ok := entries[tag]
+	if !ok {
+		return 0, false
+	}
+	if values := t.uints(e); len(values) > 0 {
+		return values[0], true
+	}
+	return 0, false
+}
+
+// data returns count bytes at offset, nil when out of bounds
+func (t *tiffReader) data(offset, count int) []byte {
+	if offset < 0 || count <= 0 || offset+count > len(t.buf) {
+		return nil
+	}
+	return t.buf[offset : offset+count]
+}
+
+// tiffTypeSize returns the size in bytes of a value of a TIFF field type
+func tiffTypeSize(typ uint16) int {
+	switch typ {
+	case 3, 8: // SHORT, SSHORT
+		return 2
+	case 4, 9, 11, 13: // LONG, SLONG, FLOAT, IFD
+		return 4
+	case 5, 10, 12: // RATIONAL, SRATIONAL, DOUBLE
+		return 8
+	default: // BYTE, ASCII, SBYTE, UNDEFINED
+		return 1
+	}
+}
+
+// previewJPEGs returns the JPEG images referenced by the IFDs of t, and the orientation tag of the first IFD
+func (t *tiffReader) previewJPEGs() (previews [][]byte, orientation int) {
+	visited := make(map[int]bool)
+	offsets := []int{t.firstIFD()}
+	for len(offsets) > 0 && len(visited) < tiffMaxIFDs {
+		offset := offsets[0]
+		offsets = offsets[1:]
+		if offset == 0 || visited[offset] {
+			continue
+		}
+		first := len(visited) == 0
+		visited[offset] = true
+
+		entries, next := t.ifd(offset)
+		if entries == nil {
+			continue
+		}
+		offsets = append(offsets, next)
+		if e, ok := entries[tiffTagSubIFDs]; ok {
+			offsets = append(offsets, t.uints(e)...)
+		}
+		if first {
+			orientation, _ = t.intValue(entries, tiffTagOrientation)
+		}
+
+		if jpegOffset, ok := t.intValue(entries, tiffTagJPEGOffset); ok {
+			length, _ := t.intValue(entries, tiffTagJPEGLength)
+			previews = append(previews, t.data(jpegOffset, length))
+		}
+		// old-style and new-style JPEG compressed strips
+		if compression, _ := t.intValue(entries, tiffTagCompression); compression == 6 || compression == 7 {
+			stripOffsets, stripCounts := t.uints(entries[tiffTagStripOffsets]), t.uints(entries[tiffTagStripCounts])
+			if len(stripOffsets) == 1 && len(stripCounts) == 1 {
+				previews = append(previews, t.data(stripOffsets[0], stripCounts[0]))
+			}
+		}
+		if e, ok := entries[tiffTagRW2JpgFromRaw]; ok {
+			previews = append(previews, e.value)
+		}
+	}
+
+	return previews, orientation
+}
+
+// jpegSegments calls fn with the marker and payload of every segment of a JPEG file before the image data, until fn
+// returns false
+func jpegSegments(buf []byte, fn func(marker byte, segment []byte) bool) {
+	for offset := 2; offset+4 <= len(buf) && buf[offset] == 0xFF; {
+		marker := buf[offset+1]
+		// start of scan, the image data follows
+		if marker == 0xDA {
+			return
+		}
+
+		length := int(binary.BigEndian.Uint16(buf[offset+2 : offset+4]))
+		end := offset + 2 + length
+		if length < 2 || end > len(buf) || !fn(marker, buf[offset+4:end]) {
+			return
+		}
+		offset = end
+	}
+}
+
+// jpegExif returns the TIFF structured EXIF data of a JPEG file
+func jpegExif(buf []byte) (exif []byte) {
+	jpegSegments(buf, func(marker byte, segment []byte) bool {
+		if marker == 0xE1 && bytes.HasPrefix(segment, exifHeader) {
+			exif = segment[len(exifHeader):]
+			return false
+		}
+		return true
+	})
+	return exif
+}
+
+// jpegSize returns the size of a baseline, extended or progressive Huffman coded JPEG image, the ones libjpeg decodes
+func jpegSize(buf []byte) (width, height int, ok bool) {
+	jpegSegments(buf, func(marker byte, segment []byte) bool {
+		switch marker {
+		case 0xC0, 0xC1, 0xC2: // SOF0, SOF1, SOF2
+			if len(segment) >= 5 {
+				height = int(binary.BigEndian.Uint16(segment[1:3]))
+				width = int(binary.BigEndian.Uint16(segment[3:5]))
+				ok = width > 0 && height > 0
+			}
+			return false
+		case 0xC3, 0xC5, 0xC6, 0xC7, 0xC9, 0xCA, 0xCB, 0xCD, 0xCE, 0xCF: // lossless, hierarchical or arithmetic
+			return false
+		}
+		return true
+	})
+	return width, height, ok
+}
+
+// embeddedPreview returns the largest baseline or progressive JPEG preview embedded in an image, the EXIF thumbnail
+// of a JPEG file or a preview of a camera RAW file, along with its size and the orientation of the image.
+func embeddedPreview(buf []byte, imageType ImageType) (preview []byte, width, height, orientation int, err error) {
+	var candidates [][]byte
+	var tiff []byte
+
+	switch imageType {
+	case ImageTypeJPEG:
+		tiff = jpegExif(buf)
+	case ImageTypeRAW:
+		tiff = buf
+		if bytes.HasPrefix(buf, rafHeader) && len(buf) >= rafPreviewOffset+8 {
+			offset := int(binary.BigEndian.Uint32(buf[rafPreviewOffset:]))
+			length := int(binary.BigEndian.Uint32(buf[rafPreviewOffset+4:]))
+			if offset >= 0 && length > 0 && offset+length <= len(buf) {
+				candidates = append(candidates, buf[offset:offset+length])
+			}
+			// the embedded JPEG carries the EXIF data
+			if len(candidates) > 0 {
+				tiff = jpegExif(candidates[0])
+			}
+		}
+	case ImageTypeTIFF:
+		// DNG files are loaded as TIFF, their previews are read like the ones of other RAW files
+		if !isDNG(buf) {
+			return nil, 0, 0, 0, fmt.Errorf("%w: no embedded previews in %s images", ErrUnsupportedImageFormat,
+				ImageTypes[imageType])
+		}
+		tiff = buf
+	default:
+		return nil, 0, 0, 0, fmt.Errorf("%w: no embedded previews in %s images", ErrUnsupportedImageFormat, ImageTypes[imageType])
+	}
+
+	if t, ok := newTiffReader(tiff); ok {
+		previews, o := t.previewJPEGs()
+		candidates = append(candidates, previews...)
+		orientation = o
+	}
+
+	for _, candidate := range candidates {
+		if !isJPEG(candidate) {
+			continue
+		}
+		// skips e.g. the lossless JPEG compressed sensor data of a RAW file
+		w, h, ok := jpegSize(candidate)
+		if ok && w*h > width*height {
+			preview, width, height = candidate, w, h
+		}
+	}
+
+	return preview, width, height, orientation, nil
+}
@@ -0,0 +1,58 @@
+package vips
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedPreview(t *testing.T) {
+	buf, err := ioutil.ReadFile(resources + "orientation-issue-1.jpg")
+	require.NoError(t, err)
+
+	preview, width, height, orientation, err := embeddedPreview(buf, ImageTypeJPEG)
+	require.NoError(t, err)
+	assert.True(t, isJPEG(preview))
+	assert.Equal(t, 512, width)
+	assert.Equal(t, 384, height)
+	assert.Equal(t, 6, orientation)
+
+	width, height, ok := jpegSize(buf)
+	assert.True(t, ok)
+	assert.Equal(t, 4032, width)
+	assert.Equal(t, 3024, height)
+
+	buf, err = ioutil.ReadFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+	preview, _, _, _, err = embeddedPreview(buf, ImageTypeJPEG)
+	require.NoError(t, err)
+	assert.Nil(t, preview)
+
+	_, _, _, _, err = embeddedPreview(buf, ImageTypePNG)
+	assert.Error(t, err)
+}
+
+func TestLoadThumbnailFromBuffer__PreferEmbeddedPreview(t *testing.T) {
+	Startup(nil)
+
+	buf, err := ioutil.ReadFile(resources + "orientation-issue-1.jpg")
+	require.NoError(t, err)
+
+	params := NewImportParams()
+	params.PreferEmbeddedPreview.Set(true)
+
+	// the 512x384 preview covers the thumbnail and is rotated like the image
+	img, err := LoadThumbnailFromBuffer(buf, 200, 200, InterestingNone, SizeBoth, params)
+	require.NoError(t, err)
+	assert.Equal(t, 150, img.Width())
+	assert.Equal(t, 200, img.Height())
+	assert.Equal(t, ImageTypeJPEG, img.OriginalFormat())
+
+	// larger thumbnails decode the image itself
+	img, err = LoadThumbnailFromBuffer(buf, 1000, 1000, InterestingNone, SizeBoth, params)
+	require.NoError(t, err)
+	assert.Equal(t, 750, img.Width())
+	assert.Equal(t, 1000, img.Height())
+}
@@ -0,0 +1,31 @@
+#include "progress.h"
+
+#include "_cgo_export.h"
+
+static void go_image_eval(VipsImage *image, VipsProgress *progress,
+                          void *handle) {
+  goImageProgress(handle, progress->percent, progress->eta);
+}
+
+static void go_image_posteval(VipsImage *image, VipsProgress *progress,
+                              void *handle) {
+  goImageProgress(handle, 100, 0);
+}
+
+static void go_image_finalize(void *handle, GObject *image) {
+  goImageProgressRelease(handle);
+}
+
+// Images derived from image inherit its progress signal, so the callback also
+// reports the evaluation of later pipelines, e.g. during export. Resetting
+// progress first drops the progress signal image inherited itself.
+void set_progress_callback(VipsImage *image, void *handle) {
+  vips_image_set_progress(image, FALSE);
+  vips_image_set_progress(image, TRUE);
+
+  g_signal_connect(image, "eval", G_CALLBACK(go_image_eval), handle);
+  g_signal_connect(image, "posteval", G_CALLBACK(go_image_posteval), handle);
+  g_object_weak_ref(G_OBJECT(image), go_image_finalize, handle);
+}
+
+void unset_progress(VipsImage *image) { vips_image_set_progress(image, FALSE); }
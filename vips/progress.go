@@ -0,0 +1,70 @@
+package vips
+
+// #include "progress.h"
+import "C"
+import (
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// ProgressCallback receives the percentage of an image computed so far and the estimated time left
+type ProgressCallback func(percent int, eta time.Duration)
+
+var (
+	progressCallbacksLock sync.Mutex
+	progressCallbacks     = make(map[unsafe.Pointer]ProgressCallback)
+)
+
+// SetProgressCallback calls fn while the pixels of the image are computed, e.g. when decoding and encoding a large
+// image on export, with the percentage done and the estimated time left; it is called with 100 once done.
+// Images derived from this one by later operations report their evaluation to fn too.
+// fn is called from libvips worker threads and must not block. A nil fn stops reporting progress.
+func (r *ImageRef) SetProgressCallback(fn ProgressCallback) error {
+	// the image may be shared through the operation cache, signals are connected to a copy
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+
+	if fn == nil {
+		C.unset_progress(out)
+	} else {
+		C.set_progress_callback(out, registerProgressCallback(fn))
+	}
+
+	r.setImage(out)
+	return nil
+}
+
+// Go pointers can't be retained by C, so each callback is registered under a
+// small C allocation that serves as its handle.
+func registerProgressCallback(fn ProgressCallback) unsafe.Pointer {
+	handle := C.malloc(1)
+
+	progressCallbacksLock.Lock()
+	progressCallbacks[handle] = fn
+	progressCallbacksLock.Unlock()
+
+	return handle
+}
+
+//export goImageProgress
+func goImageProgress(handle unsafe.Pointer, percent C.int, eta C.int) {
+	progressCallbacksLock.Lock()
+	fn := progressCallbacks[handle]
+	progressCallbacksLock.Unlock()
+
+	if fn != nil {
+		fn(int(percent), time.Duration(eta)*time.Second)
+	}
+}
+
+//export goImageProgressRelease
+func goImageProgressRelease(handle unsafe.Pointer) {
+	progressCallbacksLock.Lock()
+	delete(progressCallbacks, handle)
+	progressCallbacksLock.Unlock()
+
+	C.free(handle)
+}
@@ -0,0 +1,7 @@
+// https://www.libvips.org/API/current/VipsImage.html#vips-image-set-progress
+
+#include <stdlib.h>
+#include <vips/vips.h>
+
+void set_progress_callback(VipsImage *image, void *handle);
+void unset_progress(VipsImage *image);
@@ -0,0 +1,49 @@
+package vips
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRef_SetProgressCallback(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	var percents []int
+	reported := func() []int {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]int(nil), percents...)
+	}
+	err = img.SetProgressCallback(func(percent int, eta time.Duration) {
+		lock.Lock()
+		defer lock.Unlock()
+		percents = append(percents, percent)
+	})
+	require.NoError(t, err)
+
+	// the progress of derived images is reported too
+	require.NoError(t, img.Resize(0.5, KernelLanczos3))
+	_, _, err = img.ExportJpeg(nil)
+	require.NoError(t, err)
+
+	got := reported()
+	require.NotEmpty(t, got)
+	assert.Equal(t, 100, got[len(got)-1])
+
+	require.NoError(t, img.SetProgressCallback(nil))
+	lock.Lock()
+	percents = nil
+	lock.Unlock()
+
+	_, _, err = img.ExportJpeg(nil)
+	require.NoError(t, err)
+	assert.Empty(t, reported())
+}
@@ -0,0 +1,584 @@
+package vips
+
+import (
+	"errors"
+	"fmt"
+)
+
+// QRCodeLevel is the error correction level of a QR code, i.e. how much of the code can be damaged
+// or covered (e.g. by a logo) while remaining readable
+type QRCodeLevel int
+
+// QRCodeLevel enum
+const (
+	QRCodeLevelLow      QRCodeLevel = iota // ~7% recovery
+	QRCodeLevelMedium                      // ~15% recovery
+	QRCodeLevelQuartile                    // ~25% recovery
+	QRCodeLevelHigh                        // ~30% recovery
+)
+
+// QRCodeGenerator encodes content as a square matrix of modules, true being a dark module.
+// The matrix must not include the quiet zone. Implement it to plug in another encoder, e.g. for
+// other barcode symbologies or encoding modes.
+type QRCodeGenerator interface {
+	Encode(content string, level QRCodeLevel) ([][]bool, error)
+}
+
+// QRCodeOptions are options when creating a QR code with NewQRCode
+type QRCodeOptions struct {
+	Level      QRCodeLevel
+	QuietZone  int // light border around the code, in modules
+	Foreground ColorRGBA
+	Background ColorRGBA
+	Generator  QRCodeGenerator // nil for the built-in QR code encoder
+}
+
+// NewQRCodeOptions creates default QRCodeOptions: medium error correction and a 4 modules quiet zone,
+// black on white
+func NewQRCodeOptions() *QRCodeOptions {
+	return &QRCodeOptions{
+		Level:      QRCodeLevelMedium,
+		QuietZone:  4,
+		Foreground: ColorRGBA{R: 0, G: 0, B: 0, A: 255},
+		Background: ColorRGBA{R: 255, G: 255, B: 255, A: 255},
+	}
+}
+
+// ErrQRCodeTooLong is returned when the content doesn't fit in the largest QR code
+var ErrQRCodeTooLong = errors.New("content too long to encode as QR code")
+
+// NewQRCode creates a size x size sRGB image with alpha holding a QR code of content, ready to be
+// composited over another image or exported
+func NewQRCode(content string, size int, opts *QRCodeOptions) (*ImageRef, error) {
+	startupIfNeeded()
+
+	if opts == nil {
+		opts = NewQRCodeOptions()
+	}
+	if opts.QuietZone < 0 {
+		return nil, fmt.Errorf("invalid QR code quiet zone %d", opts.QuietZone)
+	}
+
+	generator := opts.Generator
+	if generator == nil {
+		generator = qrEncoder{}
+	}
+
+	modules, err := generator.Encode(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	if len(modules) == 0 {
+		return nil, errors.New("QR code generator returned no modules")
+	}
+	for _, row := range modules {
+		if len(row) != len(modules) {
+			return nil, fmt.Errorf("QR code generator returned a non-square matrix of %d rows", len(modules))
+		}
+	}
+
+	n := len(modules) + 2*opts.QuietZone
+	if size < n {
+		return nil, fmt.Errorf("size %d is smaller than the %d modules of the QR code", size, n)
+	}
+
+	fg := []byte{opts.Foreground.R, opts.Foreground.G, opts.Foreground.B, opts.Foreground.A}
+	bg := []byte{opts.Background.R, opts.Background.G, opts.Background.B, opts.Background.A}
+
+	pixels := make([]byte, 0, size*size*4)
+	for y := 0; y < size; y++ {
+		my := y*n/size - opts.QuietZone
+		for x := 0; x < size; x++ {
+			mx := x*n/size - opts.QuietZone
+			if my >= 0 && my < len(modules) && mx >= 0 && mx < len(modules) && modules[my][mx] {
+				pixels = append(pixels, fg...)
+			} else {
+				pixels = append(pixels, bg...)
+			}
+		}
+	}
+
+	vipsImage, err := vipsImageFromMemory(pixels, size, size, 4, InterpretationSRGB)
+	if err != nil {
+		return nil, err
+	}
+
+	return newImageRef(vipsImage, ImageTypePNG, ImageTypeUnknown, nil), nil
+}
+
+// qrEncoder is the built-in QR code encoder (ISO/IEC 18004), encoding content in byte mode
+// in the smallest version that fits
+type qrEncoder struct{}
+
+// qrEccCodewordsPerBlock and qrEccBlocks are indexed by level then version
+var qrEccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var qrEccBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// qrFormatLevelBits are the error correction bits of the format information, indexed by level
+var qrFormatLevelBits = [4]int{1, 0, 3, 2}
+
+func (qrEncoder) Encode(content string, level QRCodeLevel) ([][]bool, error) {
+	if level < QRCodeLevelLow || level > QRCodeLevelHigh {
+		return nil, fmt.Errorf("invalid QR code level %d", level)
+	}
+
+	data := []byte(content)
+
+	version := 1
+	for ; ; version++ {
+		if version > 40 {
+			return nil, ErrQRCodeTooLong
+		}
+		if 4+qrCharCountBits(version)+8*len(data) <= 8*qrDataCodewords(version, level) {
+			break
+		}
+	}
+
+	codewords := qrAddEcc(qrDataBits(data, version, level), version, level)
+
+	q := newQRMatrix(version)
+	q.drawFunctionPatterns()
+	q.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(level, mask)
+		if penalty := q.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormatBits(level, best)
+
+	return q.modules, nil
+}
+
+func qrCharCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// qrRawDataModules is the number of modules available for data and error correction codewords
+func qrRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func qrDataCodewords(version int, level QRCodeLevel) int {
+	return qrRawDataModules(version)/8 - qrEccCodewordsPerBlock[level][version]*qrEccBlocks[level][version]
+}
+
+// qrDataBits encodes data as a byte mode segment, terminated and padded to the data capacity
+func qrDataBits(data []byte, version int, level QRCodeLevel) []byte {
+	capacity := qrDataCodewords(version, level)
+
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (value>>uint(i))&1 != 0)
+		}
+	}
+
+	appendBits(0x4, 4)
+	appendBits(len(data), qrCharCountBits(version))
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	appendBits(0, minInt(4, capacity*8-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+
+	codewords := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << uint(7-j)
+			}
+		}
+		codewords = append(codewords, b)
+	}
+	for pad := byte(0xEC); len(codewords) < capacity; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+
+	return codewords
+}
+
+// qrAddEcc splits data in blocks, appends the Reed-Solomon error correction codewords of each block
+// and interleaves the blocks
+func qrAddEcc(data []byte, version int, level QRCodeLevel) []byte {
+	numBlocks := qrEccBlocks[level][version]
+	eccLen := qrEccCodewordsPerBlock[level][version]
+	rawCodewords := qrRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(eccLen)
+
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			n++
+		}
+		block := append([]byte{}, data[k:k+n]...)
+		k += n
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			// placeholder so that all blocks have the same length, skipped when interleaving
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	return result
+}
+
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = reedSolomonMultiply(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = reedSolomonMultiply(root, 0x02)
+	}
+
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= reedSolomonMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// reedSolomonMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func reedSolomonMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+type qrMatrix struct {
+	version    int
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newQRMatrix(version int) *qrMatrix {
+	size := version*4 + 17
+	q := &qrMatrix{version: version, size: size}
+	q.modules = make([][]bool, size)
+	q.isFunction = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.isFunction[i] = make([]bool, size)
+	}
+	return q
+}
+
+func (q *qrMatrix) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunction[y][x] = true
+}
+
+func (q *qrMatrix) drawFunctionPatterns() {
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+
+	q.drawFinderPattern(3, 3)
+	q.drawFinderPattern(q.size-4, 3)
+	q.drawFinderPattern(3, q.size-4)
+
+	positions := q.alignmentPatternPositions()
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// skip the corners taken by finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			q.drawAlignmentPattern(x, y)
+		}
+	}
+
+	// reserve the format bits, drawn once the mask is known
+	q.drawFormatBits(QRCodeLevelLow, 0)
+	q.drawVersion()
+}
+
+func (q *qrMatrix) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			dist := maxInt(absInt(dx), absInt(dy))
+			xx, yy := x+dx, y+dy
+			if xx >= 0 && xx < q.size && yy >= 0 && yy < q.size {
+				q.set(xx, yy, dist != 2 && dist != 4)
+			}
+		}
+	}
+}
+
+func (q *qrMatrix) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.set(x+dx, y+dy, maxInt(absInt(dx), absInt(dy)) != 1)
+		}
+	}
+}
+
+func (q *qrMatrix) alignmentPatternPositions() []int {
+	if q.version == 1 {
+		return nil
+	}
+
+	numAlign := q.version/7 + 2
+	step := (q.version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, q.size-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+func (q *qrMatrix) drawFormatBits(level QRCodeLevel, mask int) {
+	data := qrFormatLevelBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool {
+		return (bits>>uint(i))&1 != 0
+	}
+
+	// around the top left finder pattern
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	// along the other two finder patterns
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+func (q *qrMatrix) drawVersion() {
+	if q.version < 7 {
+		return
+	}
+
+	rem := q.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := q.version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 != 0
+		a, b := q.size-11+i%3, i/3
+		q.set(a, b, dark)
+		q.set(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in the zigzag pattern of two modules wide columns,
+// going up and down from the bottom right corner
+func (q *qrMatrix) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.isFunction[y][x] && i < len(codewords)*8 {
+					q.modules[y][x] = (codewords[i>>3]>>uint(7-i&7))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask XORs the data modules with the mask pattern, applying it twice undoes it
+func (q *qrMatrix) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.isFunction[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the symbol is to read, the mask with the lowest score is used
+func (q *qrMatrix) penalty() int {
+	result := 0
+
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return q.modules[x][y]
+		}
+		return q.modules[y][x]
+	}
+
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < q.size; y++ {
+			// runs of five or more modules of the same color
+			run := 1
+			for x := 1; x < q.size; x++ {
+				if at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				result += run - 2
+			}
+
+			// patterns looking like finder patterns: 1011101 next to four light modules
+			for x := 0; x+7 <= q.size; x++ {
+				if !at(x, y, transpose) || at(x+1, y, transpose) || !at(x+2, y, transpose) ||
+					!at(x+3, y, transpose) || !at(x+4, y, transpose) || at(x+5, y, transpose) ||
+					!at(x+6, y, transpose) {
+					continue
+				}
+				if q.isLight(x-4, x, y, transpose) || q.isLight(x+7, x+11, y, transpose) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	// 2x2 blocks of the same color
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+
+	// imbalance between dark and light modules
+	total := q.size * q.size
+	result += absInt(dark*20-total*10) / total * 10
+
+	return result
+}
+
+// isLight returns whether the modules from..to (excluded) of a row, or column if transposed, are light,
+// modules outside of the symbol being light
+func (q *qrMatrix) isLight(from, to, y int, transpose bool) bool {
+	for x := from; x < to; x++ {
+		if x < 0 || x >= q.size {
+			continue
+		}
+		if (transpose && q.modules[x][y]) || (!transpose && q.modules[y][x]) {
+			return false
+		}
+	}
+	return true
+}
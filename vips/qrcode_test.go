@@ -0,0 +1,111 @@
+package vips
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewQRCode(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewQRCode("https://github.com/bjg2/govips", 300, nil)
+	require.NoError(t, err)
+	defer img.Close()
+
+	assert.Equal(t, 300, img.Width())
+	assert.Equal(t, 300, img.Height())
+	assert.Equal(t, 4, img.Bands())
+
+	// the quiet zone is light, the top left finder pattern dark
+	light, err := img.GetPoint(2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 255, 255, 255}, light)
+
+	dark, err := img.GetPoint(img.Width()*5/37, img.Height()*5/37)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 0, 0, 255}, dark)
+
+	_, _, err = img.ExportPng(nil)
+	require.NoError(t, err)
+}
+
+func TestNewQRCode_Errors(t *testing.T) {
+	Startup(nil)
+
+	_, err := NewQRCode("content", 10, nil)
+	assert.Error(t, err)
+
+	_, err = NewQRCode(strings.Repeat("x", 3000), 1000, nil)
+	assert.Equal(t, ErrQRCodeTooLong, err)
+
+	opts := NewQRCodeOptions()
+	opts.QuietZone = -1
+	_, err = NewQRCode("content", 1000, opts)
+	assert.Error(t, err)
+
+	for _, modules := range [][][]bool{nil, {{true, false}, {true}}, {{true, false}}} {
+		opts = NewQRCodeOptions()
+		opts.Generator = matrixGenerator(modules)
+		_, err = NewQRCode("content", 1000, opts)
+		assert.Error(t, err)
+	}
+}
+
+type matrixGenerator [][]bool
+
+func (g matrixGenerator) Encode(content string, level QRCodeLevel) ([][]bool, error) {
+	return g, nil
+}
+
+type checkerboardGenerator struct{}
+
+func (checkerboardGenerator) Encode(content string, level QRCodeLevel) ([][]bool, error) {
+	modules := make([][]bool, 2)
+	for y := range modules {
+		modules[y] = []bool{y == 0, y == 1}
+	}
+	return modules, nil
+}
+
+func TestNewQRCode_Generator(t *testing.T) {
+	Startup(nil)
+
+	opts := NewQRCodeOptions()
+	opts.QuietZone = 0
+	opts.Generator = checkerboardGenerator{}
+	opts.Foreground = ColorRGBA{R: 255, G: 0, B: 0, A: 255}
+
+	img, err := NewQRCode("ignored", 4, opts)
+	require.NoError(t, err)
+
+	p, err := img.GetPoint(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 0, 0, 255}, p)
+
+	p, err = img.GetPoint(3, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{255, 255, 255, 255}, p)
+}
+
+func TestQREncoder_Versions(t *testing.T) {
+	modules, err := qrEncoder{}.Encode("HELLO WORLD", QRCodeLevelQuartile)
+	require.NoError(t, err)
+	assert.Len(t, modules, 21)
+
+	modules, err = qrEncoder{}.Encode(strings.Repeat("x", 2953), QRCodeLevelLow)
+	require.NoError(t, err)
+	assert.Len(t, modules, 177)
+
+	_, err = qrEncoder{}.Encode(strings.Repeat("x", 2954), QRCodeLevelLow)
+	assert.Equal(t, ErrQRCodeTooLong, err)
+}
+
+func TestReedSolomonRemainder(t *testing.T) {
+	// data codewords of HELLO WORLD as a 1-M symbol
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ecc)
+}
@@ -0,0 +1,143 @@
+package vips
+
+// #include <vips/vips.h>
+import "C"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// RawImage is a camera RAW file decoded by a RawDecoder: either an encoded image libvips can load, e.g. the
+// embedded JPEG preview or a 16-bit TIFF rendering, or demosaiced pixels.
+type RawImage struct {
+	// Encoded is loaded like any other image buffer, Pixels are used when it is empty
+	Encoded []byte
+
+	// Pixels are interleaved samples of BitDepth 8 or 16 bits, the latter in native byte order
+	Pixels   []byte
+	Width    int
+	Height   int
+	Bands    int
+	BitDepth int
+}
+
+// RawDecoder decodes a camera RAW file such as CR2, NEF or ARW, e.g. with LibRaw.
+// Set it as ImportParams.RawDecoder to use it instead of ImageMagick for RAW files.
+type RawDecoder func(buf []byte) (*RawImage, error)
+
+// ErrInvalidRawImage is returned when a RawDecoder returns neither an encoded image nor valid pixels
+var ErrInvalidRawImage = errors.New("invalid decoded RAW image")
+
+var cr3Brand = []byte("crx ")
+var orfHeaders = [][]byte{[]byte("IIRO"), []byte("IIRS"), []byte("MMOR")}
+var rw2Header = []byte("IIU\x00")
+var rafHeader = []byte("FUJIFILMCCD-RAW")
+
+// TIFF based RAW formats are told apart from plain TIFF files written by the software of the same makers by their
+// sensor data, stored as a color filter array
+var rawTiffMakes = [][]byte{[]byte("NIKON"), []byte("SONY"), []byte("PENTAX")}
+
+// tiffPhotometricCFA is the photometric interpretation of color filter array sensor data
+const tiffPhotometricCFA = 32803
+
+// isRAW matches CR2, CR3, ORF, RW2, RAF and the TIFF based NEF, ARW and PEF files, the proprietary containers libvips
+// can't read. DNG files are left to the TIFF loader.
+func isRAW(buf []byte) bool {
+	switch {
+	case bytes.HasPrefix(buf, tifII) && bytes.Equal(buf[8:10], []byte("CR")):
+		return true
+	case bytes.Equal(buf[4:8], ftyp) && bytes.Equal(buf[8:12], cr3Brand):
+		return true
+	case bytes.HasPrefix(buf, rw2Header) || bytes.HasPrefix(buf, rafHeader):
+		return true
+	}
+
+	for _, header := range orfHeaders {
+		if bytes.HasPrefix(buf, header) {
+			return true
+		}
+	}
+
+	if !isTIFF(buf) {
+		return false
+	}
+
+	maker, dng, cfa := tiffCameraTags(buf)
+	if dng || !cfa {
+		return false
+	}
+	for _, m := range rawTiffMakes {
+		if bytes.HasPrefix(maker, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDNG checks for the DNGVersion tag in the first IFD of a TIFF file
+func isDNG(buf []byte) bool {
+	_, dng, _ := tiffCameraTags(buf)
+	return dng
+}
+
+// tiffCameraTags reads the Make and whether there is a DNGVersion tag from the first IFD of a TIFF file, and whether
+// any of its IFDs or SubIFDs holds color filter array sensor data
+func tiffCameraTags(buf []byte) (maker []byte, dng bool, cfa bool) {
+	t, ok := newTiffReader(buf)
+	if !ok {
+		return nil, false, false
+	}
+
+	entries, _ := t.ifd(t.firstIFD())
+	_, dng = entries[tiffTagDNGVersion]
+	maker = entries[tiffTagMake].value
+
+	visited := make(map[int]bool)
+	offsets := []int{t.firstIFD()}
+	for len(offsets) > 0 && len(visited) < tiffMaxIFDs {
+		offset := offsets[0]
+		offsets = offsets[1:]
+		if offset == 0 || visited[offset] {
+			continue
+		}
+		visited[offset] = true
+
+		ifd, next := t.ifd(offset)
+		if ifd == nil {
+			continue
+		}
+		if photometric, _ := t.intValue(ifd, tiffTagPhotometric); photometric == tiffPhotometricCFA {
+			return maker, dng, true
+		}
+		offsets = append(offsets, next)
+		if e, ok := ifd[tiffTagSubIFDs]; ok {
+			offsets = append(offsets, t.uints(e)...)
+		}
+	}
+	return maker, dng, false
+}
+
+// vipsImageFromRaw creates an image from the demosaiced pixels of a decoded RAW file
+func vipsImageFromRaw(raw *RawImage) (*C.VipsImage, error) {
+	if raw.Width <= 0 || raw.Height <= 0 || raw.Bands <= 0 {
+		return nil, ErrInvalidRawImage
+	}
+
+	format, interpretation := BandFormatUchar, InterpretationSRGB
+	switch raw.BitDepth {
+	case 8:
+		if raw.Bands < 3 {
+			interpretation = InterpretationBW
+		}
+	case 16:
+		format, interpretation = BandFormatUshort, InterpretationRGB16
+		if raw.Bands < 3 {
+			interpretation = InterpretationGrey16
+		}
+	default:
+		return nil, fmt.Errorf("%w: bit depth %d is not supported", ErrInvalidRawImage, raw.BitDepth)
+	}
+
+	return vipsImageFromMemoryFormat(raw.Pixels, raw.Width, raw.Height, raw.Bands, format, interpretation)
+}
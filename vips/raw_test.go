@@ -0,0 +1,100 @@
+package vips
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cameraTiff builds a little endian TIFF header whose first IFD holds a PhotometricInterpretation, a Make and,
+// for DNG files, a DNGVersion tag
+func cameraTiff(maker string, photometric int, dng bool) []byte {
+	count := 2
+	if dng {
+		count++
+	}
+	buf := make([]byte, 8+2+12*count+4)
+	copy(buf, tifII)
+	binary.LittleEndian.PutUint32(buf[4:8], 8)
+	binary.LittleEndian.PutUint16(buf[8:10], uint16(count))
+
+	entry := buf[10:]
+	binary.LittleEndian.PutUint16(entry[0:2], tiffTagPhotometric)
+	binary.LittleEndian.PutUint16(entry[2:4], 3) // SHORT
+	binary.LittleEndian.PutUint32(entry[4:8], 1)
+	binary.LittleEndian.PutUint16(entry[8:10], uint16(photometric))
+
+	entry = buf[22:]
+	binary.LittleEndian.PutUint16(entry[0:2], tiffTagMake)
+	binary.LittleEndian.PutUint16(entry[2:4], 2) // ASCII
+	binary.LittleEndian.PutUint32(entry[4:8], uint32(len(maker)+1))
+	binary.LittleEndian.PutUint32(entry[8:12], uint32(len(buf)))
+
+	if dng {
+		entry = buf[34:]
+		binary.LittleEndian.PutUint16(entry[0:2], tiffTagDNGVersion)
+		binary.LittleEndian.PutUint16(entry[2:4], 1) // BYTE
+		binary.LittleEndian.PutUint32(entry[4:8], 4)
+		copy(entry[8:12], []byte{1, 4, 0, 0})
+	}
+
+	return append(buf, maker+"\x00"...)
+}
+
+func Test_DetermineImageType__RAW(t *testing.T) {
+	cr2 := append([]byte("II\x2A\x00\x10\x00\x00\x00CR\x02\x00"), make([]byte, 16)...)
+	assert.Equal(t, ImageTypeRAW, DetermineImageType(cr2))
+
+	cr3 := append([]byte("\x00\x00\x00\x18ftypcrx "), make([]byte, 16)...)
+	assert.Equal(t, ImageTypeRAW, DetermineImageType(cr3))
+
+	assert.Equal(t, ImageTypeRAW, DetermineImageType(cameraTiff("NIKON CORPORATION", tiffPhotometricCFA, false)))
+	assert.Equal(t, ImageTypeRAW, DetermineImageType(cameraTiff("SONY", tiffPhotometricCFA, false)))
+	assert.Equal(t, ImageTypeTIFF, DetermineImageType(cameraTiff("Epson", tiffPhotometricCFA, false)))
+
+	// TIFF files written by the software of camera makers and DNG files are read by libvips
+	assert.Equal(t, ImageTypeTIFF, DetermineImageType(cameraTiff("NIKON CORPORATION", 2, false)))
+	assert.Equal(t, ImageTypeTIFF, DetermineImageType(cameraTiff("SONY", tiffPhotometricCFA, true)))
+
+	tif, err := ioutil.ReadFile(resources + "tif.tif")
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeTIFF, DetermineImageType(tif))
+}
+
+func TestLoadImageFromBuffer__RawDecoder(t *testing.T) {
+	Startup(nil)
+
+	preview, err := ioutil.ReadFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	raw := cameraTiff("SONY", tiffPhotometricCFA, false)
+
+	params := NewImportParams()
+	params.RawDecoder = func(buf []byte) (*RawImage, error) {
+		return &RawImage{Encoded: preview}, nil
+	}
+	img, err := LoadImageFromBuffer(raw, params)
+	require.NoError(t, err)
+	assert.Equal(t, ImageTypeRAW, img.OriginalFormat())
+	assert.Equal(t, ImageTypeJPEG, img.Format())
+	assert.Equal(t, 100, img.Width())
+
+	params.RawDecoder = func(buf []byte) (*RawImage, error) {
+		return &RawImage{Pixels: make([]byte, 4*3*2*3), Width: 4, Height: 3, Bands: 3, BitDepth: 16}, nil
+	}
+	img, err = LoadImageFromBuffer(raw, params)
+	require.NoError(t, err)
+	assert.Equal(t, 4, img.Width())
+	assert.Equal(t, BandFormatUshort, img.BandFormat())
+	assert.Equal(t, InterpretationRGB16, img.Interpretation())
+
+	params.RawDecoder = func(buf []byte) (*RawImage, error) {
+		return &RawImage{Pixels: make([]byte, 10), Width: 4, Height: 3, Bands: 3, BitDepth: 12}, nil
+	}
+	_, err = LoadImageFromBuffer(raw, params)
+	assert.True(t, errors.Is(err, ErrInvalidRawImage))
+}
@@ -0,0 +1,48 @@
+#include "region.h"
+
+// computes only the pixels of the area, the caller has to g_free them
+int region_fetch(VipsImage *in, int left, int top, int width, int height,
+                 void **out, size_t *length) {
+  VipsRegion *region = vips_region_new(in);
+
+  *out = vips_region_fetch(region, left, top, width, height, length);
+  g_object_unref(region);
+
+  if (*out == NULL) {
+    return -1;
+  }
+  return 0;
+}
+
+// reads the pixels at the given coordinates as doubles, bands interleaved
+int region_points(VipsImage *in, const int *xs, const int *ys, int n,
+                  double *out) {
+  VipsImage *t;
+  VipsRegion *region;
+  int i, b;
+
+  if (vips_cast(in, &t, VIPS_FORMAT_DOUBLE, NULL)) {
+    return -1;
+  }
+  region = vips_region_new(t);
+
+  for (i = 0; i < n; i++) {
+    VipsRect rect = {xs[i], ys[i], 1, 1};
+    double *pixel;
+
+    if (vips_region_prepare(region, &rect)) {
+      g_object_unref(region);
+      g_object_unref(t);
+      return -1;
+    }
+
+    pixel = (double *) VIPS_REGION_ADDR(region, xs[i], ys[i]);
+    for (b = 0; b < t->Bands; b++) {
+      out[i * t->Bands + b] = pixel[b];
+    }
+  }
+
+  g_object_unref(region);
+  g_object_unref(t);
+  return 0;
+}
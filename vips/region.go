@@ -0,0 +1,121 @@
+package vips
+
+// #include "region.h"
+import "C"
+import (
+	"fmt"
+	"image"
+	"unsafe"
+)
+
+// Region computes the pixels of a rectangle of the image without computing the rest of it, e.g. to serve tiles of a
+// large TIFF or JPEG 2000 image. The pixels are returned row by row with interleaved bands, each sample in the band
+// format of the image in native byte order, so a row is width * Bands() * the sample size bytes long.
+// Load the image with AccessRandom when fetching many regions of it.
+func (r *ImageRef) Region(left, top, width, height int) ([]byte, error) {
+	rect := image.Rect(left, top, left+width, top+height)
+	if width <= 0 || height <= 0 || !rect.In(image.Rect(0, 0, r.Width(), r.Height())) {
+		return nil, fmt.Errorf("region %v is outside of image bounds %dx%d", rect, r.Width(), r.Height())
+	}
+
+	return vipsRegionFetch(r.image, left, top, width, height)
+}
+
+// GetPoints reads the pixels at the given points in one go, which is much faster than calling GetPoint for each of
+// them. Unlike GetPoint, every band of the image is returned, so each pixel has Bands() values.
+func (r *ImageRef) GetPoints(points []image.Point) ([][]float64, error) {
+	bounds := image.Rect(0, 0, r.Width(), r.Height())
+	for _, p := range points {
+		if !p.In(bounds) {
+			return nil, fmt.Errorf("point %v is outside of image bounds %dx%d", p, r.Width(), r.Height())
+		}
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	return vipsRegionPoints(r.image, points)
+}
+
+// Row returns the values of row y of the image, one slice per band, e.g. to plot an intensity profile
+func (r *ImageRef) Row(y int) ([][]float64, error) {
+	if y < 0 || y >= r.Height() {
+		return nil, fmt.Errorf("row %d is outside of image height %d", y, r.Height())
+	}
+	return r.bandValues(0, y, r.Width(), 1)
+}
+
+// Column returns the values of column x of the image, one slice per band, e.g. to plot an intensity profile
+func (r *ImageRef) Column(x int) ([][]float64, error) {
+	if x < 0 || x >= r.Width() {
+		return nil, fmt.Errorf("column %d is outside of image width %d", x, r.Width())
+	}
+	return r.bandValues(x, 0, 1, r.Height())
+}
+
+// bandValues returns the values of each band of the pixels of an area, row by row
+func (r *ImageRef) bandValues(left, top, width, height int) ([][]float64, error) {
+	double, err := vipsCast(r.image, BandFormatDouble)
+	if err != nil {
+		return nil, err
+	}
+	defer clearImage(double)
+
+	buf, err := vipsRegionFetch(double, left, top, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	n, bands := width*height, r.Bands()
+	if len(buf) != 8*n*bands {
+		return nil, fmt.Errorf("expected %d bytes of pixels, got %d", 8*n*bands, len(buf))
+	}
+	pixels := bytesAsFloat64s(buf)
+
+	values := make([][]float64, bands)
+	for b := range values {
+		values[b] = make([]float64, n)
+		for i := range values[b] {
+			values[b][i] = pixels[i*bands+b]
+		}
+	}
+	return values, nil
+}
+
+func vipsRegionFetch(in *C.VipsImage, left, top, width, height int) ([]byte, error) {
+	incOpCounter("regionFetch")
+	var out unsafe.Pointer
+	var length C.size_t
+
+	if err := C.region_fetch(in, C.int(left), C.int(top), C.int(width), C.int(height), &out, &length); err != 0 {
+		return nil, handleVipsError()
+	}
+	defer gFreePointer(out)
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+func vipsRegionPoints(in *C.VipsImage, points []image.Point) ([][]float64, error) {
+	incOpCounter("regionPoints")
+	bands := int(in.Bands)
+
+	xs := make([]C.int, len(points))
+	ys := make([]C.int, len(points))
+	for i, p := range points {
+		xs[i], ys[i] = C.int(p.X), C.int(p.Y)
+	}
+	out := make([]C.double, len(points)*bands)
+
+	if err := C.region_points(in, &xs[0], &ys[0], C.int(len(points)), &out[0]); err != 0 {
+		return nil, handleVipsError()
+	}
+
+	pixels := make([][]float64, len(points))
+	for i := range pixels {
+		pixels[i] = make([]float64, bands)
+		for b := range pixels[i] {
+			pixels[i][b] = float64(out[i*bands+b])
+		}
+	}
+	return pixels, nil
+}
@@ -0,0 +1,9 @@
+// https://www.libvips.org/API/current/VipsRegion.html
+
+#include <stdlib.h>
+#include <vips/vips.h>
+
+int region_fetch(VipsImage *in, int left, int top, int width, int height,
+                 void **out, size_t *length);
+int region_points(VipsImage *in, const int *xs, const int *ys, int n,
+                  double *out);
@@ -0,0 +1,78 @@
+package vips
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRef_Region(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	pixels, err := img.Region(10, 20, 4, 3)
+	require.NoError(t, err)
+	require.Len(t, pixels, 4*3*3)
+
+	// the last pixel of the region
+	point, err := img.GetPoint(13, 22)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{float64(pixels[33]), float64(pixels[34]), float64(pixels[35])}, point)
+
+	_, err = img.Region(1910, 0, 20, 20)
+	assert.Error(t, err)
+	_, err = img.Region(0, 0, 0, 10)
+	assert.Error(t, err)
+}
+
+func TestImageRef_GetPoints(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	points := []image.Point{{X: 0, Y: 0}, {X: 100, Y: 200}, {X: 1919, Y: 1079}}
+	pixels, err := img.GetPoints(points)
+	require.NoError(t, err)
+	require.Len(t, pixels, len(points))
+
+	for i, p := range points {
+		point, err := img.GetPoint(p.X, p.Y)
+		require.NoError(t, err)
+		assert.Equal(t, point, pixels[i])
+	}
+
+	_, err = img.GetPoints([]image.Point{{X: 1920, Y: 0}})
+	assert.Error(t, err)
+}
+
+func TestImageRef_RowColumn(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "png-24bit.png")
+	require.NoError(t, err)
+
+	row, err := img.Row(200)
+	require.NoError(t, err)
+	require.Len(t, row, 3)
+	require.Len(t, row[0], 1920)
+
+	column, err := img.Column(100)
+	require.NoError(t, err)
+	require.Len(t, column, 3)
+	require.Len(t, column[2], 1080)
+
+	point, err := img.GetPoint(100, 200)
+	require.NoError(t, err)
+	assert.Equal(t, point, []float64{row[0][100], row[1][100], row[2][100]})
+	assert.Equal(t, point, []float64{column[0][200], column[1][200], column[2][200]})
+
+	_, err = img.Row(1080)
+	assert.Error(t, err)
+	_, err = img.Column(-1)
+	assert.Error(t, err)
+}
@@ -55,6 +55,95 @@ int mapim(VipsImage *in, VipsImage **out, VipsImage *index) {
   return vips_mapim(in, out, index, NULL);
 }
 
+// Projects the image onto a cylinder of radius focal_length pixels around the
+// center of the image: each output pixel at angle theta and height h on the
+// cylinder is looked up at (f * tan(theta), h / cos(theta)) from the center.
+int cylindrical_project(VipsImage *in, VipsImage **out, double focal_length) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 12);
+  double xc = in->Xsize / 2.0;
+  double yc = in->Ysize / 2.0;
+  // vips_tan and vips_cos take degrees
+  double degrees = 180.0 / G_PI / focal_length;
+
+  if (vips_xyz(&t[0], in->Xsize, in->Ysize, NULL) ||
+      vips_cast(t[0], &t[1], VIPS_FORMAT_FLOAT, NULL) ||
+      vips_extract_band(t[1], &t[2], 0, NULL) ||
+      vips_extract_band(t[1], &t[3], 1, NULL) ||
+      vips_linear1(t[2], &t[4], degrees, -xc * degrees, NULL) ||
+      vips_tan(t[4], &t[5], NULL) ||
+      vips_cos(t[4], &t[6], NULL) ||
+      vips_linear1(t[5], &t[7], focal_length, xc, NULL) ||
+      vips_linear1(t[3], &t[8], 1.0, -yc, NULL) ||
+      vips_divide(t[8], t[6], &t[9], NULL) ||
+      vips_linear1(t[9], &t[10], 1.0, yc, NULL) ||
+      vips_bandjoin2(t[7], t[10], &t[11], NULL) ||
+      vips_mapim(in, out, t[11], NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
+// scales a band about the center of the image, keeping its size and
+// repeating the edge pixels where a band shrinks
+static int scale_band(VipsObject *base, VipsImage *in, VipsImage **out,
+                      int band, double scale) {
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 1);
+  double cx = in->Xsize / 2.0;
+  double cy = in->Ysize / 2.0;
+  VipsArrayInt *oarea;
+  int code;
+
+  if (vips_extract_band(in, &t[0], band, NULL)) {
+    return 1;
+  }
+  if (scale == 1.0) {
+    return vips_copy(t[0], out, NULL);
+  }
+
+  oarea = vips_array_int_newv(4, 0, 0, in->Xsize, in->Ysize);
+  code = vips_affine(t[0], out, scale, 0, 0, scale, "oarea", oarea, "idx",
+                     -cx, "idy", -cy, "odx", cx, "ody", cy, "extend",
+                     VIPS_EXTEND_COPY, NULL);
+  vips_area_unref(VIPS_AREA(oarea));
+  return code;
+}
+
+int correct_chromatic_aberration(VipsImage *in, VipsImage **out,
+                                 double red_scale, double blue_scale) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 5);
+  int n = 3;
+
+  if (scale_band(VIPS_OBJECT(base), in, &t[0], 0, red_scale) ||
+      vips_extract_band(in, &t[1], 1, NULL) ||
+      scale_band(VIPS_OBJECT(base), in, &t[2], 2, blue_scale)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  // alpha and any other extra bands are kept as is
+  if (in->Bands > 3) {
+    if (vips_extract_band(in, &t[3], 3, "n", in->Bands - 3, NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    n = 4;
+  }
+
+  if (vips_bandjoin(t, &t[4], n, NULL) ||
+      vips_copy(t[4], out, "interpretation", in->Type, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+
+  g_object_unref(base);
+  return 0;
+}
+
 int maplut(VipsImage *in, VipsImage **out, VipsImage *lut) {
   return vips_maplut(in, out, lut, NULL);
 }
@@ -67,7 +67,7 @@ func vipsThumbnailFromFile(filename string, width, height int, crop Interesting,
 
 	filenameOption := filename
 	if params != nil {
-		filenameOption += "[" + params.OptionString() + "]"
+		filenameOption += "[" + params.optionString(vipsFindLoad(filename)) + "]"
 	}
 
 	cFileName := C.CString(filenameOption)
@@ -102,7 +102,7 @@ func vipsThumbnailFromBuffer(buf []byte, width, height int, crop Interesting, si
 	if params == nil {
 		err = C.thumbnail_buffer(unsafe.Pointer(&src[0]), C.size_t(len(src)), &out, C.int(width), C.int(height), C.int(crop), C.int(size))
 	} else {
-		cOptionString := C.CString(params.OptionString())
+		cOptionString := C.CString(params.optionString(DetermineImageType(src)))
 		defer freeCString(cOptionString)
 
 		err = C.thumbnail_buffer_with_option(unsafe.Pointer(&src[0]), C.size_t(len(src)), &out, C.int(width), C.int(height), C.int(crop), C.int(size), cOptionString)
@@ -133,6 +133,28 @@ func vipsMapim(in *C.VipsImage, index *C.VipsImage) (*C.VipsImage, error) {
 	return out, nil
 }
 
+func vipsCylindricalProject(in *C.VipsImage, focalLength float64) (*C.VipsImage, error) {
+	incOpCounter("cylindricalProject")
+	var out *C.VipsImage
+
+	if err := C.cylindrical_project(in, &out, C.double(focalLength)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsCorrectChromaticAberration(in *C.VipsImage, redScale, blueScale float64) (*C.VipsImage, error) {
+	incOpCounter("correctChromaticAberration")
+	var out *C.VipsImage
+
+	if err := C.correct_chromatic_aberration(in, &out, C.double(redScale), C.double(blueScale)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-histogram.html#vips-maplut
 func vipsMaplut(in *C.VipsImage, lut *C.VipsImage) (*C.VipsImage, error) {
 	incOpCounter("maplut")
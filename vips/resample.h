@@ -21,4 +21,7 @@ int thumbnail_buffer_with_option(void *buf, size_t len, VipsImage **out,
                     int width, int height, int crop, int size,
                     const char *option_string);
 int mapim(VipsImage *in, VipsImage **out, VipsImage *index);
+int cylindrical_project(VipsImage *in, VipsImage **out, double focal_length);
+int correct_chromatic_aberration(VipsImage *in, VipsImage **out,
+                                 double red_scale, double blue_scale);
 int maplut(VipsImage *in, VipsImage **out, VipsImage *lut);
@@ -0,0 +1,62 @@
+package vips
+
+// #include <vips/vips.h>
+import "C"
+import "errors"
+
+// StackMean averages repeated captures of the same scene pixel by pixel and band by band, e.g. to average noise out.
+// The images must be aligned and have the same size and bands; the result has the band format of the first one.
+func StackMean(images []*ImageRef) (*ImageRef, error) {
+	ins, err := stackImages(images)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := vipsStackMean(ins)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(out, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// StackMedian takes the median of repeated captures pixel by pixel and band by band, which unlike StackMean also
+// removes outliers such as passers-by or hot pixels. For an even number of images the upper of the two middle values
+// is used. The images must be aligned and have the same size and bands.
+func StackMedian(images []*ImageRef) (*ImageRef, error) {
+	return stackRank(images, len(images)/2)
+}
+
+// StackMax takes the maximum of repeated captures pixel by pixel and band by band, e.g. for star trails.
+// The images must be aligned and have the same size and bands.
+func StackMax(images []*ImageRef) (*ImageRef, error) {
+	return stackRank(images, len(images)-1)
+}
+
+func stackRank(images []*ImageRef, index int) (*ImageRef, error) {
+	ins, err := stackImages(images)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := vipsStackRank(ins, index)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(out, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// stackImages checks that the images have the same shape and returns their libvips images
+func stackImages(images []*ImageRef) ([]*C.VipsImage, error) {
+	if len(images) == 0 {
+		return nil, errors.New("no images to stack")
+	}
+
+	ins := make([]*C.VipsImage, len(images))
+	for i, img := range images {
+		if err := images[0].checkSameShape(img); err != nil {
+			return nil, err
+		}
+		ins[i] = img.image
+	}
+	return ins, nil
+}
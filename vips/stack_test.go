@@ -0,0 +1,47 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStack(t *testing.T) {
+	Startup(nil)
+
+	var images []*ImageRef
+	for _, value := range []float64{10, 20, 90, 40} {
+		img, err := Black(10, 10)
+		require.NoError(t, err)
+		require.NoError(t, img.Linear([]float64{1}, []float64{value}))
+		require.NoError(t, img.Cast(BandFormatUchar))
+		images = append(images, img)
+	}
+
+	for name, test := range map[string]struct {
+		stack    func([]*ImageRef) (*ImageRef, error)
+		expected float64
+	}{
+		"mean":   {StackMean, 40},
+		"median": {StackMedian, 40},
+		"max":    {StackMax, 90},
+	} {
+		t.Run(name, func(t *testing.T) {
+			img, err := test.stack(images)
+			require.NoError(t, err)
+			assert.Equal(t, BandFormatUchar, img.BandFormat())
+			avg, err := img.Average()
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, avg)
+		})
+	}
+
+	small, err := Black(5, 5)
+	require.NoError(t, err)
+	_, err = StackMean(append(images, small))
+	assert.Error(t, err)
+
+	_, err = StackMax(nil)
+	assert.Error(t, err)
+}
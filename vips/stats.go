@@ -2,15 +2,19 @@ package vips
 
 import "sync"
 
-// RuntimeStats is a data structure to house a map of govips operation counts
+// RuntimeStats is a data structure to house a map of govips operation counts and the memory statistics of libvips
 type RuntimeStats struct {
 	OperationCounts map[string]int64
+	Memory          MemoryStats
 }
 
 var (
 	operationCounter chan string
 	runtimeStats     *RuntimeStats
 	statLock         sync.RWMutex
+
+	// openImages counts the ImageRefs holding an image, so services can spot images that are never closed
+	openImages int64
 )
 
 func incOpCounter(op string) {
@@ -39,7 +43,7 @@ func collectStats() chan struct{} {
 	return done
 }
 
-// ReadRuntimeStats returns operation counts for govips
+// ReadRuntimeStats returns operation counts for govips along with the memory statistics of ReadVipsMemStats
 func ReadRuntimeStats(stats *RuntimeStats) {
 	statLock.RLock()
 	defer statLock.RUnlock()
@@ -47,6 +51,7 @@ func ReadRuntimeStats(stats *RuntimeStats) {
 	for k, v := range runtimeStats.OperationCounts {
 		stats.OperationCounts[k] = v
 	}
+	ReadVipsMemStats(&stats.Memory)
 }
 
 func init() {
@@ -0,0 +1,67 @@
+#include "stream.h"
+
+#include "_cgo_export.h"
+
+static gint64 go_source_read(VipsSourceCustom *source, void *buffer,
+                             gint64 length, void *handle) {
+  return goSourceRead(handle, buffer, length);
+}
+
+static gint64 go_source_seek(VipsSourceCustom *source, gint64 offset,
+                             int whence, void *handle) {
+  return goSourceSeek(handle, offset, whence);
+}
+
+static void go_source_finalize(void *handle, GObject *source) {
+  goSourceRelease(handle);
+}
+
+VipsSourceCustom *create_go_source(void *handle) {
+  VipsSourceCustom *source = vips_source_custom_new();
+
+  g_signal_connect(source, "read", G_CALLBACK(go_source_read), handle);
+  g_signal_connect(source, "seek", G_CALLBACK(go_source_seek), handle);
+
+  // The Go reader must outlive every image that may still pull pixels from
+  // the source, so it is only released once libvips drops the last reference.
+  g_object_weak_ref(G_OBJECT(source), go_source_finalize, handle);
+
+  return source;
+}
+
+static gint64 go_target_write(VipsTargetCustom *target, void *data,
+                              gint64 length, void *handle) {
+  return goTargetWrite(handle, data, length);
+}
+
+static void go_target_finalize(void *handle, GObject *target) {
+  goTargetRelease(handle);
+}
+
+VipsTargetCustom *create_go_target(void *handle) {
+  VipsTargetCustom *target = vips_target_custom_new();
+
+  g_signal_connect(target, "write", G_CALLBACK(go_target_write), handle);
+  g_object_weak_ref(G_OBJECT(target), go_target_finalize, handle);
+
+  return target;
+}
+
+int load_from_source(VipsSourceCustom *source, const char *options,
+                     VipsImage **out) {
+  *out = vips_image_new_from_source(VIPS_SOURCE(source), options, NULL);
+  if (!*out) return 1;
+
+  return 0;
+}
+
+// https://www.libvips.org/API/current/VipsForeignLoad.html#vips-foreign-find-load-source
+const char *find_source_load_nickname(VipsSourceCustom *source) {
+  const char *name = vips_foreign_find_load_source(VIPS_SOURCE(source));
+  if (!name) {
+    vips_error_clear();
+    return NULL;
+  }
+
+  return vips_nickname_find(g_type_from_name(name));
+}
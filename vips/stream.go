@@ -0,0 +1,171 @@
+package vips
+
+// #include "stream.h"
+import "C"
+import (
+	"io"
+	"sync"
+	"unsafe"
+)
+
+// readerSource feeds a Go io.Reader to a libvips custom source
+type readerSource struct {
+	reader io.Reader
+	seeker io.Seeker
+}
+
+// maxEmptySourceReads is how many consecutive (0, nil) reads fail a read, see io.ErrNoProgress
+const maxEmptySourceReads = 100
+
+var (
+	readerSourcesLock sync.Mutex
+	readerSources     = make(map[unsafe.Pointer]*readerSource)
+)
+
+// Go pointers can't be retained by C, so each source is registered under a
+// small C allocation that serves as its handle.
+func registerReaderSource(r io.Reader) unsafe.Pointer {
+	source := &readerSource{reader: r}
+	if seeker, ok := r.(io.Seeker); ok {
+		source.seeker = seeker
+	}
+
+	handle := C.malloc(1)
+
+	readerSourcesLock.Lock()
+	readerSources[handle] = source
+	readerSourcesLock.Unlock()
+
+	return handle
+}
+
+func lookupReaderSource(handle unsafe.Pointer) *readerSource {
+	readerSourcesLock.Lock()
+	defer readerSourcesLock.Unlock()
+
+	return readerSources[handle]
+}
+
+//export goSourceRead
+func goSourceRead(handle unsafe.Pointer, buffer unsafe.Pointer, length C.gint64) C.gint64 {
+	source := lookupReaderSource(handle)
+	if source == nil || length <= 0 {
+		return -1
+	}
+
+	if length > 1<<30 {
+		length = 1 << 30
+	}
+	buf := (*[1 << 30]byte)(buffer)[:length:length]
+
+	// like bufio, give up on readers that keep returning no data and no error
+	for i := 0; i < maxEmptySourceReads; i++ {
+		n, err := source.reader.Read(buf)
+		if n > 0 {
+			return C.gint64(n)
+		}
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			return -1
+		}
+	}
+	return -1
+}
+
+//export goSourceSeek
+func goSourceSeek(handle unsafe.Pointer, offset C.gint64, whence C.int) C.gint64 {
+	source := lookupReaderSource(handle)
+	if source == nil || source.seeker == nil {
+		return -1
+	}
+
+	// SEEK_SET, SEEK_CUR and SEEK_END share their values with io.SeekStart, io.SeekCurrent and io.SeekEnd
+	pos, err := source.seeker.Seek(int64(offset), int(whence))
+	if err != nil {
+		return -1
+	}
+
+	return C.gint64(pos)
+}
+
+//export goSourceRelease
+func goSourceRelease(handle unsafe.Pointer) {
+	readerSourcesLock.Lock()
+	delete(readerSources, handle)
+	readerSourcesLock.Unlock()
+
+	C.free(handle)
+}
+
+// writerTarget receives the output of a libvips custom target
+type writerTarget struct {
+	writer io.Writer
+	err    error
+}
+
+var (
+	writerTargetsLock sync.Mutex
+	writerTargets     = make(map[unsafe.Pointer]*writerTarget)
+)
+
+// newWriterTarget creates a libvips target writing to w, the caller owns the returned reference.
+// Write errors are recorded on the returned writerTarget.
+func newWriterTarget(w io.Writer) (*C.VipsTargetCustom, *writerTarget) {
+	target := &writerTarget{writer: w}
+	handle := C.malloc(1)
+
+	writerTargetsLock.Lock()
+	writerTargets[handle] = target
+	writerTargetsLock.Unlock()
+
+	return C.create_go_target(handle), target
+}
+
+//export goTargetWrite
+func goTargetWrite(handle unsafe.Pointer, data unsafe.Pointer, length C.gint64) C.gint64 {
+	writerTargetsLock.Lock()
+	target := writerTargets[handle]
+	writerTargetsLock.Unlock()
+
+	if target == nil || target.err != nil {
+		return -1
+	}
+
+	n, err := target.writer.Write(C.GoBytes(data, C.int(length)))
+	if err != nil {
+		target.err = err
+		return -1
+	}
+
+	return C.gint64(n)
+}
+
+//export goTargetRelease
+func goTargetRelease(handle unsafe.Pointer) {
+	writerTargetsLock.Lock()
+	delete(writerTargets, handle)
+	writerTargetsLock.Unlock()
+
+	C.free(handle)
+}
+
+// https://www.libvips.org/API/current/VipsImage.html#vips-image-new-from-source
+func vipsLoadFromReader(r io.Reader, params *ImportParams) (*C.VipsImage, ImageType, error) {
+	incOpCounter("loadFromSource")
+	var out *C.VipsImage
+
+	source := C.create_go_source(registerReaderSource(r))
+	defer C.g_object_unref(C.gpointer(source))
+
+	format := imageTypeFromLoader(C.GoString(C.find_source_load_nickname(source)))
+	cOptions := C.CString(params.optionString(format))
+	defer freeCString(cOptions)
+
+	if err := C.load_from_source(source, cOptions, &out); err != 0 {
+		return nil, ImageTypeUnknown, handleImageError(out)
+	}
+
+	return out, vipsDetermineImageTypeFromMetaLoader(out), nil
+}
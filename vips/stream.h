@@ -0,0 +1,12 @@
+// https://www.libvips.org/API/current/VipsSourceCustom.html
+// https://www.libvips.org/API/current/VipsTargetCustom.html
+
+#include <stdlib.h>
+#include <vips/vips.h>
+
+VipsSourceCustom *create_go_source(void *handle);
+int load_from_source(VipsSourceCustom *source, const char *options,
+                     VipsImage **out);
+const char *find_source_load_nickname(VipsSourceCustom *source);
+
+VipsTargetCustom *create_go_target(void *handle);
@@ -0,0 +1,129 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// TranscodeWithoutRecompress exports the image to target without decoding and re-encoding the pixels
+// when possible, i.e. when the image is unmodified since it was loaded and target is its format.
+// JPEG and PNG sources can also have their metadata stripped this way; other formats are copied as is.
+// In every other case it falls back to a regular export with default parameters.
+func (r *ImageRef) TranscodeWithoutRecompress(target ImageType, stripMetadata bool) ([]byte, *ImageMetadata, error) {
+	if r.unmodified && target == r.format && len(r.buf) > 0 {
+		buf, err := rewrapBuffer(r.buf, target, stripMetadata)
+		if err == nil {
+			return buf, r.newMetadata(target), nil
+		}
+		govipsLog("govips", LogLevelDebug, "falling back to export: "+err.Error())
+	}
+
+	params := NewDefaultExportParams()
+	params.Format = target
+	params.StripMetadata = stripMetadata
+	return r.Export(params)
+}
+
+var errCannotRewrap = errors.New("cannot rewrap image without recompression")
+
+func rewrapBuffer(buf []byte, format ImageType, stripMetadata bool) ([]byte, error) {
+	if !stripMetadata {
+		out := make([]byte, len(buf))
+		copy(out, buf)
+		return out, nil
+	}
+
+	switch format {
+	case ImageTypeJPEG:
+		return stripJpegMetadata(buf)
+	case ImageTypePNG:
+		return stripPngMetadata(buf)
+	default:
+		return nil, errCannotRewrap
+	}
+}
+
+// stripJpegMetadata drops the APP1-APP15 and COM segments preceding the scan data,
+// which hold EXIF, XMP, ICC, IPTC and comments, copying the DCT data untouched. APP14 is kept.
+func stripJpegMetadata(buf []byte) ([]byte, error) {
+	if !isJPEG(buf) {
+		return nil, errCannotRewrap
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(buf)))
+	out.Write(buf[:2])
+
+	pos := 2
+	for pos+4 <= len(buf) {
+		if buf[pos] != 0xFF {
+			return nil, errCannotRewrap
+		}
+		marker := buf[pos+1]
+		if marker == 0xFF {
+			// fill byte
+			pos++
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(buf[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(buf) {
+			return nil, errCannotRewrap
+		}
+
+		if marker == 0xDA {
+			// start of scan, the rest is entropy coded data
+			out.Write(buf[pos:])
+			return out.Bytes(), nil
+		}
+
+		// APP14 "Adobe" is kept, decoders need its color transform flag to read CMYK and YCCK images
+		isAppN := marker >= 0xE1 && marker <= 0xEF && marker != 0xEE
+		if !isAppN && marker != 0xFE {
+			out.Write(buf[pos:end])
+		}
+		pos = end
+	}
+
+	return nil, errCannotRewrap
+}
+
+const pngSignatureLength = 8
+
+// pngKeptChunks are the chunks needed to render a PNG, all other ancillary chunks are metadata
+var pngKeptChunks = map[string]bool{
+	"IHDR": true, "PLTE": true, "IDAT": true, "IEND": true,
+	"tRNS": true, "gAMA": true, "cHRM": true, "sRGB": true, "sBIT": true,
+	"acTL": true, "fcTL": true, "fdAT": true,
+}
+
+// stripPngMetadata drops text, EXIF, ICC and other metadata chunks, copying the image data untouched.
+func stripPngMetadata(buf []byte) ([]byte, error) {
+	if !isPNG(buf) {
+		return nil, errCannotRewrap
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(buf)))
+	out.Write(buf[:pngSignatureLength])
+
+	pos := pngSignatureLength
+	for pos+12 <= len(buf) {
+		length := int(binary.BigEndian.Uint32(buf[pos:]))
+		chunkType := string(buf[pos+4 : pos+8])
+		end := pos + 12 + length
+		if length < 0 || end > len(buf) {
+			return nil, errCannotRewrap
+		}
+
+		if pngKeptChunks[chunkType] {
+			out.Write(buf[pos:end])
+		}
+		if chunkType == "IEND" {
+			return out.Bytes(), nil
+		}
+		pos = end
+	}
+
+	return nil, errCannotRewrap
+}
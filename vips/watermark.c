@@ -0,0 +1,143 @@
+#include "watermark.h"
+
+// prepare_overlay turns overlay into a single page sRGB image with alpha,
+// scaled relative to the base width and faded by the opacity
+static int prepare_overlay(VipsObject *base, VipsImage *in, VipsImage *overlay,
+                           VipsImage **out, WatermarkOptions *o) {
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 6);
+  VipsImage *current = overlay;
+  int page_height = vips_image_get_page_height(overlay);
+
+  if (page_height < overlay->Ysize) {
+    if (vips_extract_area(current, &t[0], 0, 0, current->Xsize, page_height,
+                          NULL)) {
+      return -1;
+    }
+    current = t[0];
+  }
+
+  if (vips_colourspace(current, &t[1], VIPS_INTERPRETATION_sRGB, NULL) ||
+      vips_cast(t[1], &t[2], VIPS_FORMAT_UCHAR, NULL)) {
+    return -1;
+  }
+  current = t[2];
+
+  if (!vips_image_hasalpha(current)) {
+    if (vips_addalpha(current, &t[3], NULL)) {
+      return -1;
+    }
+    current = t[3];
+  }
+
+  if (o->Scale > 0) {
+    double factor = o->Scale * in->Xsize / current->Xsize;
+    if (vips_resize(current, &t[4], factor, NULL)) {
+      return -1;
+    }
+    current = t[4];
+  }
+
+  if (o->Opacity < 1) {
+    double a[4] = {1, 1, 1, o->Opacity};
+    double b[4] = {0, 0, 0, 0};
+    if (vips_linear(current, &t[5], a, b, 4, NULL) ||
+        vips_cast(t[5], out, VIPS_FORMAT_UCHAR, NULL)) {
+      return -1;
+    }
+    return 0;
+  }
+
+  return vips_copy(current, out, NULL);
+}
+
+// tile_overlay repeats overlay, spaced apart, over a width x height area
+static int tile_overlay(VipsObject *base, VipsImage *overlay, VipsImage **out,
+                        int width, int height, int spacing) {
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 2);
+  int cell_width = overlay->Xsize + spacing;
+  int cell_height = overlay->Ysize + spacing;
+
+  return vips_embed(overlay, &t[0], 0, 0, cell_width, cell_height, NULL) ||
+         vips_replicate(t[0], &t[1], VIPS_ROUND_UP(width, cell_width) / cell_width,
+                        VIPS_ROUND_UP(height, cell_height) / cell_height,
+                        NULL) ||
+         vips_extract_area(t[1], out, 0, 0, width, height, NULL);
+}
+
+int watermark_overlay(VipsImage *in, VipsImage *overlay, VipsImage **out,
+                      WatermarkOptions *o) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  int code = prepare_overlay(base, in, overlay, out, o);
+
+  g_object_unref(base);
+  return code;
+}
+
+// watermark blends an overlay prepared by watermark_overlay at x, y of every
+// page, or tiled over them
+int watermark(VipsImage *in, VipsImage **out, VipsImage *overlay, int x, int y,
+              WatermarkOptions *o) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 4);
+  VipsImage *current = in;
+  int page_height = vips_image_get_page_height(in);
+  int n_pages = in->Ysize / page_height;
+
+  // the watermark is blended in sRGB, keeping 16-bit RGB as is
+  if (in->Type != VIPS_INTERPRETATION_sRGB &&
+      in->Type != VIPS_INTERPRETATION_RGB16) {
+    if (vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL)) {
+      g_object_unref(base);
+      return -1;
+    }
+    current = t[0];
+  }
+
+  if (o->Tile) {
+    if (tile_overlay(base, overlay, &t[2], in->Xsize, page_height,
+                     o->Spacing)) {
+      g_object_unref(base);
+      return -1;
+    }
+  } else {
+    t[2] = overlay;
+    g_object_ref(t[2]);
+  }
+
+  // every page of a multi-page image gets its own copy of the watermark
+  VipsImage **ins = (VipsImage **)vips_object_local_array(base, n_pages + 1);
+  int *modes = VIPS_ARRAY(base, n_pages, int);
+  int *xs = VIPS_ARRAY(base, n_pages, int);
+  int *ys = VIPS_ARRAY(base, n_pages, int);
+
+  ins[0] = current;
+  g_object_ref(ins[0]);
+  for (int i = 0; i < n_pages; i++) {
+    ins[i + 1] = t[2];
+    g_object_ref(ins[i + 1]);
+    modes[i] = VIPS_BLEND_MODE_OVER;
+    xs[i] = x;
+    ys[i] = y + i * page_height;
+  }
+
+  VipsArrayInt *vipsXs = vips_array_int_new(xs, n_pages);
+  VipsArrayInt *vipsYs = vips_array_int_new(ys, n_pages);
+
+  int code = vips_composite(ins, &t[3], n_pages + 1, modes, "x", vipsXs, "y",
+                            vipsYs, NULL);
+
+  vips_area_unref(VIPS_AREA(vipsXs));
+  vips_area_unref(VIPS_AREA(vipsYs));
+
+  // composite adds an alpha channel, an opaque base stays opaque
+  if (code == 0) {
+    if (vips_image_hasalpha(current)) {
+      code = vips_copy(t[3], out, NULL);
+    } else {
+      code = vips_extract_band(t[3], out, 0, "n", current->Bands, NULL);
+    }
+  }
+
+  g_object_unref(base);
+  return code;
+}
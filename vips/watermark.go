@@ -0,0 +1,104 @@
+package vips
+
+// #include "watermark.h"
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+// WatermarkParams describe a watermark, either an image or a text, drawn with ImageRef.Watermark
+type WatermarkParams struct {
+	// Image is the watermark image, only its first page is used
+	Image *ImageRef
+	// Text is rendered as watermark with Text when no Image is given
+	Text *TextParams
+	// Gravity positions the watermark on every page, Margin pixels away from the edges it is placed against
+	Gravity Gravity
+	Margin  int
+	// Opacity of the watermark from 0 to 1, fully opaque when 0
+	Opacity float64
+	// Scale resizes the watermark to this fraction of the image width, 0 keeps its size
+	Scale float64
+	// Tile repeats the watermark over the whole page instead of placing it once, Spacing pixels apart
+	Tile    bool
+	Spacing int
+}
+
+// NewWatermarkParams creates params for a half transparent watermark in the bottom right corner
+func NewWatermarkParams() *WatermarkParams {
+	return &WatermarkParams{
+		Gravity: GravitySouthEast,
+		Margin:  10,
+		Opacity: 0.5,
+	}
+}
+
+// ErrNoWatermark is returned by ImageRef.Watermark when neither an image nor a text is given
+var ErrNoWatermark = errors.New("watermark needs an image or a text")
+
+type vipsWatermarkOptions struct {
+	Opacity C.double
+	Scale   C.double
+	Tile    C.int
+	Spacing C.int
+}
+
+// Watermark draws an image or text watermark on every page of the image. The watermark is converted to sRGB with
+// an alpha channel and blended over the image, which is converted to sRGB as well unless it is 16-bit RGB.
+func (r *ImageRef) Watermark(params *WatermarkParams) error {
+	if params == nil || params.Image == nil && params.Text == nil {
+		return ErrNoWatermark
+	}
+
+	overlay := params.Image
+	if overlay == nil {
+		text, err := Text(params.Text)
+		if err != nil {
+			return err
+		}
+		defer text.Close()
+		overlay = text
+	}
+
+	out, err := vipsWatermark(r.image, overlay.image, params)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+func vipsWatermark(in *C.VipsImage, overlay *C.VipsImage, params *WatermarkParams) (*C.VipsImage, error) {
+	incOpCounter("watermark")
+	var out *C.VipsImage
+
+	opacity := params.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+
+	opts := vipsWatermarkOptions{
+		Opacity: C.double(opacity),
+		Scale:   C.double(params.Scale),
+		Tile:    C.int(boolToInt(params.Tile)),
+		Spacing: C.int(params.Spacing),
+	}
+
+	cOpts := (*C.WatermarkOptions)(unsafe.Pointer(&opts))
+
+	var prepared *C.VipsImage
+	if err := C.watermark_overlay(in, overlay, &prepared, cOpts); err != 0 {
+		return nil, handleImageError(prepared)
+	}
+	defer clearImage(prepared)
+
+	x, y := gravityOffsets(int(in.Xsize), vipsGetPageHeight(in), int(prepared.Xsize), int(prepared.Ysize),
+		params.Gravity, params.Margin, params.Margin)
+
+	if err := C.watermark(in, &out, prepared, C.int(x), C.int(y), cOpts); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
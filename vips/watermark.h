@@ -0,0 +1,14 @@
+#include <stdlib.h>
+#include <vips/vips.h>
+
+typedef struct {
+  double Opacity;
+  double Scale;
+  int Tile;
+  int Spacing;
+} WatermarkOptions;
+
+int watermark_overlay(VipsImage *in, VipsImage *overlay, VipsImage **out,
+                      WatermarkOptions *o);
+int watermark(VipsImage *in, VipsImage **out, VipsImage *overlay, int x, int y,
+              WatermarkOptions *o);
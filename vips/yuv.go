@@ -0,0 +1,73 @@
+package vips
+
+import "fmt"
+
+// YUVLayout is the memory layout of an 8-bit YUV 4:2:0 video frame
+type YUVLayout int
+
+// YUVLayout enum
+const (
+	// YUVLayoutI420 is a Y plane followed by a U plane and a V plane, also known as YUV420P
+	YUVLayoutI420 YUVLayout = iota
+	// YUVLayoutYV12 is a Y plane followed by a V plane and a U plane
+	YUVLayoutYV12
+	// YUVLayoutNV12 is a Y plane followed by a plane of interleaved U and V samples
+	YUVLayoutNV12
+	// YUVLayoutNV21 is a Y plane followed by a plane of interleaved V and U samples
+	YUVLayoutNV21
+)
+
+// NewImageFromYUV creates an 8-bit sRGB image from a YUV 4:2:0 video frame of width x height pixels in BT.601 limited
+// range, the output of most video decoders. The planes must be tightly packed: a Y plane of width x height bytes
+// followed by chroma planes of half the width and height rounded up, see ToYUV420 for the reverse conversion.
+func NewImageFromYUV(buf []byte, layout YUVLayout, width, height int) (*ImageRef, error) {
+	startupIfNeeded()
+
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid frame size %dx%d", width, height)
+	}
+
+	chromaWidth, chromaHeight := (width+1)/2, (height+1)/2
+	lumaSize, chromaSize := width*height, chromaWidth*chromaHeight
+	if len(buf) != lumaSize+2*chromaSize {
+		return nil, fmt.Errorf("expected %d bytes for a %dx%d frame, got %d", lumaSize+2*chromaSize, width, height, len(buf))
+	}
+
+	// offset of the first U and V samples after the Y plane and the distance between consecutive ones
+	var uOffset, vOffset, step int
+	switch layout {
+	case YUVLayoutI420:
+		uOffset, vOffset, step = lumaSize, lumaSize+chromaSize, 1
+	case YUVLayoutYV12:
+		uOffset, vOffset, step = lumaSize+chromaSize, lumaSize, 1
+	case YUVLayoutNV12:
+		uOffset, vOffset, step = lumaSize, lumaSize+1, 2
+	case YUVLayoutNV21:
+		uOffset, vOffset, step = lumaSize+1, lumaSize, 2
+	default:
+		return nil, fmt.Errorf("unknown YUV layout %d", layout)
+	}
+
+	pix := make([]byte, 0, 3*lumaSize)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := (y/2*chromaWidth + x/2) * step
+			r, g, b := yuvToRGB(buf[y*width+x], buf[uOffset+c], buf[vOffset+c])
+			pix = append(pix, r, g, b)
+		}
+	}
+
+	out, err := vipsImageFromMemory(pix, width, height, 3, InterpretationSRGB)
+	if err != nil {
+		return nil, err
+	}
+	return newImageRef(out, ImageTypeUnknown, ImageTypeUnknown, nil), nil
+}
+
+// yuvToRGB converts a BT.601 limited range sample to RGB
+func yuvToRGB(y, u, v uint8) (uint8, uint8, uint8) {
+	c, d, e := 1.164*(float64(y)-16), float64(u)-128, float64(v)-128
+	return uint8(clampInt(roundFloat(c+1.596*e), 0, 255)),
+		uint8(clampInt(roundFloat(c-0.392*d-0.813*e), 0, 255)),
+		uint8(clampInt(roundFloat(c+2.017*d), 0, 255))
+}
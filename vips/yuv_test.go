@@ -0,0 +1,76 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewImageFromYUV(t *testing.T) {
+	Startup(nil)
+
+	// a 3x3 frame, 2x2 chroma, of an orange-ish color
+	y := []byte{100, 100, 100, 100, 100, 100, 100, 100, 100}
+	u := []byte{90, 90, 90, 90}
+	v := []byte{170, 170, 170, 170}
+	expected := []float64{
+		float64(uint8(clampInt(roundFloat(1.164*84+1.596*42), 0, 255))),
+		float64(uint8(clampInt(roundFloat(1.164*84+0.392*38-0.813*42), 0, 255))),
+		float64(uint8(clampInt(roundFloat(1.164*84-2.017*38), 0, 255))),
+	}
+
+	var nv12 []byte
+	nv12 = append(nv12, y...)
+	for i := range u {
+		nv12 = append(nv12, u[i], v[i])
+	}
+
+	tests := []struct {
+		name   string
+		layout YUVLayout
+		buf    []byte
+	}{
+		{"I420", YUVLayoutI420, append(append(append([]byte{}, y...), u...), v...)},
+		{"YV12", YUVLayoutYV12, append(append(append([]byte{}, y...), v...), u...)},
+		{"NV12", YUVLayoutNV12, nv12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := NewImageFromYUV(tt.buf, tt.layout, 3, 3)
+			require.NoError(t, err)
+			defer img.Close()
+
+			assert.Equal(t, 3, img.Width())
+			assert.Equal(t, 3, img.Height())
+			assert.Equal(t, 3, img.Bands())
+
+			point, err := img.GetPoint(2, 2)
+			require.NoError(t, err)
+			assert.Equal(t, expected, point)
+		})
+	}
+
+	_, err := NewImageFromYUV(y, YUVLayoutI420, 3, 3)
+	assert.Error(t, err)
+}
+
+func TestNewImageFromYUV_RoundTrip(t *testing.T) {
+	Startup(nil)
+
+	img, err := NewImageFromFile(resources + "jpg-24bit.jpg")
+	require.NoError(t, err)
+
+	y, u, v, _, _, err := img.ToYUV420()
+	require.NoError(t, err)
+
+	frame, err := NewImageFromYUV(append(append(y, u...), v...), YUVLayoutI420, img.Width(), img.Height())
+	require.NoError(t, err)
+
+	before, err := img.Average()
+	require.NoError(t, err)
+	after, err := frame.Average()
+	require.NoError(t, err)
+	assert.InDelta(t, before, after, 2)
+}